@@ -2,80 +2,354 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"distributed-cache/internal/api"
+	"distributed-cache/internal/config"
 	"distributed-cache/internal/logs"
+	"distributed-cache/internal/memcache"
 	"distributed-cache/internal/metrics"
 	"distributed-cache/internal/peers"
+	"distributed-cache/internal/pubsub"
 	"distributed-cache/internal/replication"
+	"distributed-cache/internal/resp"
+	"distributed-cache/internal/snapshot"
 	"distributed-cache/internal/store"
 	"distributed-cache/internal/ttl"
+	"distributed-cache/internal/wal"
 )
 
 func main() {
-	// Root context
-	ctx := context.Background()
+	// Configuration
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Root context, cancelled on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Background workers stop when ctx is cancelled.
+	var workers sync.WaitGroup
+	runWorker := func(start func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			start(ctx)
+		}()
+	}
 
 	// Logger
-	logger := logs.NewLogger(1000, logs.DEBUG)
+	logger := logs.NewLogger(cfg.Log.BufferSize, cfg.LogLevel())
 
 	// Metrics
 	metricsRegistry := metrics.NewRegistry()
 	metricsRegistry.Inc(metrics.ReplicationRetriesTotal)
 
-	// Store
-	cacheStore := store.NewStore(metricsRegistry)
+	// Write-ahead log: every accepted Set/Delete, local or replicated
+	var walLog *wal.Log
+	storeOpts := []store.Option{
+		store.WithShards(cfg.Store.Shards),
+		store.WithMaxEntries(cfg.Store.MaxEntries),
+		store.WithMaxBytes(int64(cfg.Store.MaxBytes)),
+		store.WithEvictionPolicy(cfg.EvictionPolicy()),
+		store.WithWatchHistory(cfg.Store.WatchHistory),
+	}
+	if cfg.WAL.Dir != "" {
+		walLog, err = wal.Open(cfg.WAL.Dir, cfg.WALSyncPolicy(), logger, metricsRegistry)
+		if err != nil {
+			log.Fatal(err)
+		}
+		storeOpts = append(storeOpts, store.WithWriteHook(walLog.Hook))
+	}
+
+	// Store, with the configured namespaces
+	cacheStore := store.NewStore(metricsRegistry, storeOpts...)
+	for _, ns := range cfg.Namespaces {
+		if _, err := cacheStore.CreateNamespace(ns.Name, ns.StoreSettings()); err != nil {
+			log.Fatal(err)
+		}
+	}
 	// logger.Error("panic: simulated failure")
 
+	// Snapshots: warm restart from the newest valid snapshot
+	var snapshots *snapshot.Manager
+	if cfg.Snapshot.Dir != "" {
+		snapshots = snapshot.NewManager(
+			cfg.Snapshot.Dir,
+			cfg.NodeID,
+			cfg.Snapshot.Retain,
+			time.Duration(cfg.Snapshot.Interval),
+			cacheStore,
+			logger,
+			metricsRegistry,
+		)
+		if _, err := snapshots.Restore(); err != nil {
+			log.Printf("snapshot restore: %v (starting cold)", err)
+		}
+		runWorker(snapshots.Start)
+	}
+
+	// Replay writes logged after the snapshot, then compact in the background
+	var compactor *wal.Compactor
+	if walLog != nil {
+		info, err := walLog.Replay(func(namespace, key string, entry store.Entry) {
+			// Namespaces created at runtime come back with default settings
+			// unless a snapshot restored them first.
+			if ns, err := cacheStore.EnsureNamespace(namespace, store.NamespaceSettings{}); err == nil {
+				ns.Restore(key, entry)
+			}
+		})
+		if err != nil {
+			log.Fatalf("wal replay: %v", err)
+		}
+		log.Printf("wal replayed %d records from %d segments", info.Records, info.Segments)
+
+		compactor = wal.NewCompactor(walLog, snapshots, int64(cfg.WAL.CompactBytes), logger, metricsRegistry)
+		runWorker(walLog.Start)
+		runWorker(compactor.Start)
+	}
+
 	// Peer management
-	peerConfig := peers.DefaultPeerConfig()
+	peerConfig := cfg.PeerConfig()
 	peerManager := peers.NewPeerManager(peerConfig, metricsRegistry)
 
-	peerManager.AddPeer("node-2")
-	peerManager.MarkFailure("node-2")
-	peerManager.MarkFailure("node-2")
-	peerManager.MarkSuccess("node-2")
+	for _, peer := range cfg.Peers {
+		peerManager.AddPeer(peer)
+	}
+
+	// Heartbeats
+	heartbeatWorker := peers.NewHeartbeatWorker(
+		peerManager,
+		peerConfig,
+		metricsRegistry,
+	)
+	runWorker(heartbeatWorker.Start)
 
 	// Replication
 	replicator := replication.NewReplicator(
-		"node-1",
+		cfg.NodeID,
 		peerManager,
 		peerConfig,
 		logger,
 		metricsRegistry,
 	)
-	_ = replicator // (replication will be triggered later via API or hooks)
+	replicator.SetBinaryPeers(cfg.Replication.BinaryPeers)
+	replicator.UpdateQueueConfig(cfg.ReplicationQueueConfig())
+	replicator.UpdateHintConfig(cfg.ReplicationHintConfig())
+	if cfg.Replication.HintsDir != "" {
+		if err := replicator.PersistHints(cfg.Replication.HintsDir); err != nil {
+			log.Fatalf("replication hints: %v", err)
+		}
+	}
+	runWorker(replicator.Start)
 
 	// TTL cleaner
 	ttlCleaner := ttl.NewCleaner(
 		cacheStore,
-		5*time.Second,
+		time.Duration(cfg.TTL.Interval),
+		time.Duration(cfg.TTL.TombstoneGrace),
 		logger,
 		metricsRegistry,
 	)
-	go ttlCleaner.Start(ctx)
+	runWorker(ttlCleaner.Start)
+
+	// Hot reload (SIGHUP or POST /admin/config/reload)
+	reloader := config.NewReloader(
+		cfg,
+		func() (config.Config, error) { return config.Load(os.Args[1:], os.Getenv) },
+		func(next config.Config) {
+			nextPeerConfig := next.PeerConfig()
+
+			logger.SetLevel(next.LogLevel())
+			peerManager.UpdateConfig(nextPeerConfig)
+			peerManager.SetPeers(next.Peers)
+			heartbeatWorker.UpdateConfig(nextPeerConfig)
+			replicator.UpdateConfig(nextPeerConfig)
+			replicator.SetBinaryPeers(next.Replication.BinaryPeers)
+			replicator.UpdateQueueConfig(next.ReplicationQueueConfig())
+			replicator.UpdateHintConfig(next.ReplicationHintConfig())
+			ttlCleaner.UpdateSettings(
+				time.Duration(next.TTL.Interval),
+				time.Duration(next.TTL.TombstoneGrace),
+			)
+			if snapshots != nil {
+				snapshots.UpdateInterval(time.Duration(next.Snapshot.Interval))
+			}
+		},
+		logger,
+	)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if _, err := reloader.Reload(); err != nil {
+				log.Printf("config reload failed: %v", err)
+			}
+		}
+	}()
 
 	// API
+	broker := pubsub.NewBroker(metricsRegistry)
+	handlerOpts := []api.Option{api.WithConfigReloader(reloader), api.WithBroker(broker)}
+	if snapshots != nil {
+		handlerOpts = append(handlerOpts, api.WithSnapshotter(snapshots))
+	}
 	handler := api.NewHandler(
+		cfg.NodeID,
 		cacheStore,
 		metricsRegistry,
 		logger,
 		peerManager,
+		replicator,
+		handlerOpts...,
 	)
 	mux := http.NewServeMux()
 	httpHandler := api.RegisterRoutes(mux, handler)
 
 	server := &http.Server{
-		Addr:    ":8080",
+		Addr:    cfg.ListenAddr,
 		Handler: httpHandler,
 	}
+	// Watch and subscribe streams never finish on their own; end them so shutdown can drain.
+	server.RegisterOnShutdown(cacheStore.StopWatches)
+	server.RegisterOnShutdown(broker.Close)
 
-	logger.Info("server started on :8080")
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
 
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatal(err)
+	logger.Info("server started on " + cfg.ListenAddr)
+
+	// Redis protocol front-end
+	var respServer *resp.Server
+	if cfg.RESP.ListenAddr != "" {
+		respServer = resp.NewServer(cfg.NodeID, cacheStore, replicator, metricsRegistry, logger)
+		go func() {
+			if err := respServer.ListenAndServe(cfg.RESP.ListenAddr); err != nil && !errors.Is(err, resp.ErrServerClosed) {
+				log.Fatal(err)
+			}
+		}()
+		logger.Info("resp server started on " + cfg.RESP.ListenAddr)
+	}
+
+	// memcached protocol front-end
+	var memcacheServer *memcache.Server
+	if cfg.Memcache.ListenAddr != "" {
+		memcacheServer = memcache.NewServer(cfg.NodeID, cacheStore, replicator, metricsRegistry, logger)
+		go func() {
+			if err := memcacheServer.ListenAndServe(cfg.Memcache.ListenAddr); err != nil && !errors.Is(err, memcache.ErrServerClosed) {
+				log.Fatal(err)
+			}
+		}()
+		logger.Info("memcache server started on " + cfg.Memcache.ListenAddr)
+	}
+
+	// Binary replication from peers
+	var replicationServer *replication.Server
+	if cfg.Replication.ListenAddr != "" {
+		replicationServer = replication.NewServer(handler, metricsRegistry, logger)
+		go func() {
+			if err := replicationServer.ListenAndServe(cfg.Replication.ListenAddr); err != nil && !errors.Is(err, replication.ErrServerClosed) {
+				log.Fatal(err)
+			}
+		}()
+		logger.Info("replication server started on " + cfg.Replication.ListenAddr)
+	}
+
+	<-ctx.Done()
+	stop()
+
+	shutdown(reloader.Current().Shutdown, server, respServer, memcacheServer, replicationServer, replicator, &workers, snapshots, walLog, compactor, logger)
+}
+
+// shutdown stops the node in dependency order:
+// 1. stop accepting requests (HTTP, RESP, memcached and binary replication) and finish the in-flight ones
+// 2. drain replication started by those requests, then close peer connections
+// 3. wait for background workers (already cancelled via the root context)
+// 4. take a final snapshot, if snapshots are enabled
+// 5. compact and close the write-ahead log, if enabled
+//
+// Steps 1 and 2 share the configured deadline.
+func shutdown(
+	cfg config.ShutdownSettings,
+	server *http.Server,
+	respServer *resp.Server,
+	memcacheServer *memcache.Server,
+	replicationServer *replication.Server,
+	replicator *replication.Replicator,
+	workers *sync.WaitGroup,
+	snapshots *snapshot.Manager,
+	walLog *wal.Log,
+	compactor *wal.Compactor,
+	logger *logs.Logger,
+) {
+	log.Printf("shutting down (timeout %s)", cfg.Timeout)
+	logger.Info("shutdown started")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Timeout))
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("http shutdown: %v", err)
+	}
+	if respServer != nil {
+		if err := respServer.Shutdown(ctx); err != nil {
+			log.Printf("resp shutdown: %v", err)
+		}
+	}
+	if memcacheServer != nil {
+		if err := memcacheServer.Shutdown(ctx); err != nil {
+			log.Printf("memcache shutdown: %v", err)
+		}
+	}
+	if replicationServer != nil {
+		if err := replicationServer.Shutdown(ctx); err != nil {
+			log.Printf("replication server shutdown: %v", err)
+		}
+	}
+
+	if err := replicator.Drain(ctx); err != nil {
+		log.Printf("replication drain: %v", err)
 	}
+	replicator.Close()
+
+	workers.Wait()
+
+	switch {
+	case compactor != nil:
+		// Compaction includes the final snapshot.
+		if err := compactor.Compact(); err != nil {
+			log.Printf("shutdown compaction: %v", err)
+		}
+	case snapshots != nil:
+		if info, err := snapshots.Save(); err != nil {
+			log.Printf("shutdown snapshot: %v", err)
+		} else {
+			log.Printf("snapshot written to %s", info.Path)
+		}
+	}
+
+	if walLog != nil {
+		if err := walLog.Close(); err != nil {
+			log.Printf("wal close: %v", err)
+		}
+	}
+
+	log.Print("shutdown complete")
 }
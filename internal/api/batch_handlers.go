@@ -0,0 +1,237 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"distributed-cache/internal/replication"
+	"distributed-cache/internal/store"
+)
+
+// maxBatchKeys bounds the number of keys in one batch request.
+const maxBatchKeys = 1000
+
+// Per-key statuses of batch responses.
+const (
+	batchFound              = "found"
+	batchMissing            = "missing"
+	batchExpired            = "expired"
+	batchOK                 = "ok"
+	batchPreconditionFailed = "precondition_failed"
+	batchStale              = "stale"
+)
+
+type batchKeysRequest struct {
+	Keys []string `json:"keys"`
+}
+
+type batchSetRequest struct {
+	Items []batchSetItem `json:"items"`
+}
+
+// batchSetItem is one write of POST /kv/_mset.
+// IfMatch and IfAbsent mirror the If-Match and If-None-Match: * headers.
+type batchSetItem struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	TTLms    int64  `json:"ttl_ms,omitempty"`
+	IfMatch  uint64 `json:"if_match,omitempty"`
+	IfAbsent bool   `json:"if_absent,omitempty"`
+}
+
+// batchResult is the outcome for one key of a batch request.
+//
+// Values written in raw mode are returned base64-encoded in Data with
+// their ContentType, as are values that are not valid UTF-8; other values
+// are returned as strings in Value.
+type batchResult struct {
+	Key         string  `json:"key"`
+	Status      string  `json:"status"`
+	Value       *string `json:"value,omitempty"`
+	Data        []byte  `json:"data,omitempty"`
+	ContentType string  `json:"content_type,omitempty"`
+	Version     uint64  `json:"version,omitempty"`
+	Error       string  `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Results []batchResult `json:"results"`
+}
+
+// decodeBatch reads a batch request body into req.
+func decodeBatch(w http.ResponseWriter, r *http.Request, req any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxValueBytes)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return errors.New("invalid json body")
+	}
+	return nil
+}
+
+// checkBatchSize rejects empty and oversized batches.
+func checkBatchSize(n int) error {
+	if n == 0 {
+		return errors.New("batch is empty")
+	}
+	if n > maxBatchKeys {
+		return fmt.Errorf("batch has %d keys, the limit is %d", n, maxBatchKeys)
+	}
+	return nil
+}
+
+/* ---------------- POST /kv/_mget ---------------- */
+
+// MultiGet reads many keys in one request.
+//
+// Request: {"keys": ["a", "b"]}
+// Each result has status found, missing or expired; found keys carry
+// their value and version.
+func (h *Handler) MultiGet(w http.ResponseWriter, r *http.Request) {
+	var req batchKeysRequest
+	if err := decodeBatch(w, r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkBatchSize(len(req.Keys)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	lookups := h.namespace(r).GetMany(req.Keys)
+
+	resp := batchResponse{Results: make([]batchResult, len(req.Keys))}
+	for i, l := range lookups {
+		res := batchResult{Key: req.Keys[i], Status: batchMissing}
+		switch {
+		case l.Found:
+			res.Status = batchFound
+			res.Version = l.Entry.Version
+			res.Value, res.Data = jsonValue(l.Entry)
+			res.ContentType = l.Entry.ContentType
+		case l.Expired:
+			res.Status = batchExpired
+		}
+		resp.Results[i] = res
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+/* ---------------- POST /kv/_mset ---------------- */
+
+// MultiSet writes many keys in one request.
+//
+// Request: {"items": [{"key": "a", "value": "1", "ttl_ms": 500, "if_match": 3}]}
+// Every item succeeds or fails on its own (status ok, precondition_failed
+// or stale); the accepted writes are replicated as one batch.
+func (h *Handler) MultiSet(w http.ResponseWriter, r *http.Request) {
+	acks, err := parseReplicationAcks(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req batchSetRequest
+	if err := decodeBatch(w, r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkBatchSize(len(req.Items)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writes := make([]store.Write, len(req.Items))
+	for i, item := range req.Items {
+		if item.Key == "" {
+			http.Error(w, fmt.Sprintf("item %d: missing key", i), http.StatusBadRequest)
+			return
+		}
+		writes[i] = store.Write{
+			Key:   item.Key,
+			Entry: newEntry([]byte(item.Value), "", item.TTLms),
+			Cond:  store.Precondition{IfMatch: item.IfMatch, IfAbsent: item.IfAbsent},
+		}
+	}
+
+	h.applyBatch(w, r, writes, acks)
+}
+
+/* ---------------- POST /kv/_mdelete ---------------- */
+
+// MultiDelete deletes many keys in one request.
+//
+// Request: {"keys": ["a", "b"]}
+// Deleting a missing key succeeds, as with DELETE /kv/{key}.
+func (h *Handler) MultiDelete(w http.ResponseWriter, r *http.Request) {
+	acks, err := parseReplicationAcks(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req batchKeysRequest
+	if err := decodeBatch(w, r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkBatchSize(len(req.Keys)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writes := make([]store.Write, len(req.Keys))
+	for i, key := range req.Keys {
+		if key == "" {
+			http.Error(w, fmt.Sprintf("key %d is empty", i), http.StatusBadRequest)
+			return
+		}
+		writes[i] = store.Write{Key: key, Entry: store.Tombstone(time.Now().UnixNano())}
+	}
+
+	h.applyBatch(w, r, writes, acks)
+}
+
+// applyBatch applies writes to the store, replicates the accepted ones in
+// a single batch and writes the per-key results. The replication headers
+// report zero peers and acks when no write was accepted.
+func (h *Handler) applyBatch(w http.ResponseWriter, r *http.Request, writes []store.Write, acks int) {
+	ns := h.namespace(r)
+	results := ns.SetMany(writes)
+
+	resp := batchResponse{Results: make([]batchResult, len(writes))}
+	accepted := make([]replication.Write, 0, len(writes))
+	for i, res := range results {
+		out := batchResult{Key: writes[i].Key, Status: batchOK}
+		switch {
+		case res.Err == nil:
+			out.Version = res.Entry.Version
+			accepted = append(accepted, replication.Write{Key: writes[i].Key, Entry: res.Entry})
+		case errors.Is(res.Err, store.ErrPreconditionFailed):
+			out.Status = batchPreconditionFailed
+			out.Error = res.Err.Error()
+		default:
+			out.Status = batchStale
+			out.Error = res.Err.Error()
+		}
+		resp.Results[i] = out
+	}
+
+	if len(accepted) > 0 {
+		res := h.replicator.ReplicateBatch(context.WithoutCancel(r.Context()), ns.Name(), accepted)
+		if !waitForAcks(w, r, res, acks) {
+			return
+		}
+	} else {
+		// Nothing to replicate, so nothing to wait for.
+		w.Header().Set(replicationPeersHeader, "0")
+		w.Header().Set(replicationAckedHeader, "0")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"distributed-cache/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postBatch(t *testing.T, url string, body any) (*http.Response, batchResponse) {
+	t.Helper()
+
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+	resp, err := http.Post(url, "application/json", bytes.NewReader(data))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var res batchResponse
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+	}
+	return resp, res
+}
+
+/* ---------------- POST /kv/_mget ---------------- */
+
+func TestMultiGet(t *testing.T) {
+	node := newTestNode(t, "node-A")
+	node.store.Set("a", store.Entry{Value: []byte("1"), Timestamp: 1})
+	node.store.Set("img", store.Entry{Value: []byte{0x89, 0x00}, ContentType: "image/png", Timestamp: 1})
+	node.store.Set("old", store.Entry{Value: []byte("x"), Timestamp: 1, ExpiresAt: time.Now().Add(-time.Second)})
+
+	resp, res := postBatch(t, node.server.URL+"/kv/_mget", batchKeysRequest{
+		Keys: []string{"a", "missing", "old", "img"},
+	})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, res.Results, 4)
+
+	assert.Equal(t, batchFound, res.Results[0].Status)
+	assert.Equal(t, "1", *res.Results[0].Value)
+	assert.Equal(t, uint64(1), res.Results[0].Version)
+
+	assert.Equal(t, batchResult{Key: "missing", Status: batchMissing}, res.Results[1])
+	assert.Equal(t, batchResult{Key: "old", Status: batchExpired}, res.Results[2])
+
+	assert.Equal(t, []byte{0x89, 0x00}, res.Results[3].Data)
+	assert.Equal(t, "image/png", res.Results[3].ContentType)
+	assert.Nil(t, res.Results[3].Value)
+}
+
+/* ---------------- POST /kv/_mset, /kv/_mdelete ---------------- */
+
+func TestMultiSetAndDelete(t *testing.T) {
+	nodeA := newTestNode(t, "node-A")
+	nodeB := newTestNode(t, "node-B")
+	nodeA.peers.AddPeer(nodeB.server.URL)
+
+	nodeA.store.Set("taken", store.Entry{Value: []byte("v"), Timestamp: 1})
+
+	t.Run("SetReplicatesAcceptedWrites", func(t *testing.T) {
+		resp, res := postBatch(t, nodeA.server.URL+"/kv/_mset?acks=all", batchSetRequest{Items: []batchSetItem{
+			{Key: "a", Value: "1"},
+			{Key: "b", Value: "2", TTLms: 60000},
+			{Key: "taken", Value: "w", IfAbsent: true},
+		}})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "1", resp.Header.Get("X-Replication-Acked"), "one request per peer")
+
+		require.Len(t, res.Results, 3)
+		assert.Equal(t, batchResult{Key: "a", Status: batchOK, Version: 1}, res.Results[0])
+		assert.Equal(t, batchOK, res.Results[1].Status)
+		assert.Equal(t, batchPreconditionFailed, res.Results[2].Status)
+
+		for _, key := range []string{"a", "b"} {
+			val, ok := nodeB.store.Get(key)
+			assert.True(t, ok, key)
+			assert.Equal(t, uint64(1), val.Version, key)
+		}
+		_, ok := nodeB.store.Get("taken")
+		assert.False(t, ok, "rejected writes are not replicated")
+	})
+
+	t.Run("NothingAcceptedReportsNoAcks", func(t *testing.T) {
+		resp, res := postBatch(t, nodeA.server.URL+"/kv/_mset?acks=all", batchSetRequest{Items: []batchSetItem{
+			{Key: "taken", Value: "w", IfAbsent: true},
+		}})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, batchPreconditionFailed, res.Results[0].Status)
+		assert.Equal(t, "0", resp.Header.Get("X-Replication-Peers"))
+		assert.Equal(t, "0", resp.Header.Get("X-Replication-Acked"))
+	})
+
+	t.Run("SetWithVersionCheck", func(t *testing.T) {
+		_, res := postBatch(t, nodeA.server.URL+"/kv/_mset", batchSetRequest{Items: []batchSetItem{
+			{Key: "a", Value: "10", IfMatch: 1},
+			{Key: "b", Value: "20", IfMatch: 7},
+		}})
+		assert.Equal(t, batchOK, res.Results[0].Status)
+		assert.Equal(t, uint64(2), res.Results[0].Version)
+		assert.Equal(t, batchPreconditionFailed, res.Results[1].Status)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		resp, res := postBatch(t, nodeA.server.URL+"/kv/_mdelete?acks=all", batchKeysRequest{
+			Keys: []string{"a", "b", "never-set"},
+		})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		for _, r := range res.Results {
+			assert.Equal(t, batchOK, r.Status, r.Key)
+		}
+
+		for _, node := range []*testNode{nodeA, nodeB} {
+			got := node.store.GetMany([]string{"a", "b"})
+			assert.False(t, got[0].Found)
+			assert.False(t, got[1].Found)
+		}
+	})
+}
+
+func TestBatch_InvalidRequests(t *testing.T) {
+	node := newTestNode(t, "node-A")
+
+	tooMany := make([]string, maxBatchKeys+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("k%d", i)
+	}
+
+	cases := map[string]struct {
+		path string
+		body string
+		want int
+	}{
+		"malformed":     {"/kv/_mget", `{"keys": "a"}`, http.StatusBadRequest},
+		"empty":         {"/kv/_mget", `{"keys": []}`, http.StatusBadRequest},
+		"too many":      {"/kv/_mdelete", `{"keys": ["` + strings.Join(tooMany, `","`) + `"]}`, http.StatusBadRequest},
+		"missing key":   {"/kv/_mset", `{"items": [{"value": "x"}]}`, http.StatusBadRequest},
+		"empty key":     {"/kv/_mdelete", `{"keys": [""]}`, http.StatusBadRequest},
+		"bad acks":      {"/kv/_mset?acks=x", `{"items": [{"key": "a"}]}`, http.StatusBadRequest},
+		"unknown batch": {"/kv/_mput", `{}`, http.StatusMethodNotAllowed},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			resp, err := http.Post(node.server.URL+tc.path, "application/json", strings.NewReader(tc.body))
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tc.want, resp.StatusCode)
+		})
+	}
+}
@@ -1,38 +1,246 @@
 package api
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"distributed-cache/internal/ai"
+	"distributed-cache/internal/config"
 	"distributed-cache/internal/logs"
 	"distributed-cache/internal/metrics"
 	"distributed-cache/internal/peers"
+	"distributed-cache/internal/pubsub"
+	"distributed-cache/internal/replication"
+	"distributed-cache/internal/snapshot"
 	"distributed-cache/internal/store"
 )
 
 // Handler holds dependencies for HTTP handlers.
 type Handler struct {
-	store    *store.Store
-	metrics  *metrics.Registry
-	analyzer *ai.HealthAnalyzer
-	peers    *peers.PeerManager
+	nodeID     string
+	store      *store.Store
+	metrics    *metrics.Registry
+	analyzer   *ai.HealthAnalyzer
+	peers      *peers.PeerManager
+	replicator *replication.Replicator
+	broker     *pubsub.Broker
+	reloader   ConfigReloader
+	snapshots  Snapshotter
+	startedAt  time.Time
+}
+
+// ConfigReloader reloads configuration on a running node.
+type ConfigReloader interface {
+	Reload() ([]config.Change, error)
+}
+
+// Option configures optional Handler features.
+type Option func(*Handler)
+
+// Snapshotter takes on-demand snapshots of the store.
+type Snapshotter interface {
+	Save() (snapshot.Info, error)
+}
+
+// WithSnapshotter enables POST /admin/snapshot.
+func WithSnapshotter(snapshots Snapshotter) Option {
+	return func(h *Handler) {
+		h.snapshots = snapshots
+	}
+}
+
+// WithConfigReloader enables POST /admin/config/reload.
+func WithConfigReloader(reloader ConfigReloader) Option {
+	return func(h *Handler) {
+		h.reloader = reloader
+	}
+}
+
+// WithBroker serves pub/sub from broker, e.g. to share it with other
+// protocols; by default the handler has a broker of its own.
+func WithBroker(broker *pubsub.Broker) Option {
+	return func(h *Handler) {
+		h.broker = broker
+	}
 }
 
 // NewHandler creates a new API handler.
 func NewHandler(
+	nodeID string,
 	store *store.Store,
 	metrics *metrics.Registry,
 	logger *logs.Logger,
 	peers *peers.PeerManager,
+	replicator *replication.Replicator,
+	opts ...Option,
 ) *Handler {
-	return &Handler{
-		store:    store,
-		metrics:  metrics,
-		analyzer: ai.NewHealthAnalyzer(metrics, logger),
-		peers:    peers,
+	h := &Handler{
+		nodeID:     nodeID,
+		store:      store,
+		metrics:    metrics,
+		analyzer:   ai.NewHealthAnalyzer(metrics, logger),
+		peers:      peers,
+		replicator: replicator,
+		startedAt:  time.Now(),
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.broker == nil {
+		h.broker = pubsub.NewBroker(metrics)
+	}
+	return h
+}
+
+/* ---------------- Replication acknowledgements ---------------- */
+
+// Clients choose how long a write waits for peers, per request, using
+// either the "acks" query parameter or the X-Replication-Acks header:
+// - absent or "0": fire-and-forget (respond once the local write is done)
+// - N > 0: wait until N peers acknowledged
+// - "all": wait until every targeted peer acknowledged
+//
+// Responses report the outcome in X-Replication-Peers (peers targeted)
+// and X-Replication-Acked (peers that acknowledged).
+const (
+	replicationAcksParam   = "acks"
+	replicationAcksHeader  = "X-Replication-Acks"
+	replicationPeersHeader = "X-Replication-Peers"
+	replicationAckedHeader = "X-Replication-Acked"
+	replicationAcksAll     = -1
+)
+
+// parseReplicationAcks reads the requested acknowledgement count.
+func parseReplicationAcks(r *http.Request) (int, error) {
+	raw := r.URL.Query().Get(replicationAcksParam)
+	if raw == "" {
+		raw = r.Header.Get(replicationAcksHeader)
+	}
+
+	switch raw {
+	case "":
+		return 0, nil
+	case "all":
+		return replicationAcksAll, nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid replication acks %q", raw)
+	}
+	return n, nil
+}
+
+// awaitReplication waits for the requested acknowledgements and writes
+// the final status. Replication keeps running after the response is sent,
+// so it is detached from the request's cancellation.
+func (h *Handler) awaitReplication(
+	w http.ResponseWriter,
+	r *http.Request,
+	res *replication.Result,
+	want int,
+) {
+	if waitForAcks(w, r, res, want) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// waitForAcks waits for the requested acknowledgements and sets the
+// replication headers. It writes a 504 and returns false when too few
+// peers acknowledged; otherwise the caller writes the response.
+func waitForAcks(
+	w http.ResponseWriter,
+	r *http.Request,
+	res *replication.Result,
+	want int,
+) bool {
+	if want == replicationAcksAll {
+		want = res.Peers
+	}
+
+	acked := 0
+	if want > 0 {
+		acked = res.Wait(r.Context(), want)
+	}
+
+	w.Header().Set(replicationPeersHeader, strconv.Itoa(res.Peers))
+	w.Header().Set(replicationAckedHeader, strconv.Itoa(acked))
+
+	if acked < want {
+		http.Error(w,
+			fmt.Sprintf("replication acknowledged by %d of %d required peers", acked, want),
+			http.StatusGatewayTimeout,
+		)
+		return false
+	}
+	return true
+}
+
+// Conditional requests: a key's version is its entity tag.
+const (
+	etagHeader        = "ETag"
+	ifMatchHeader     = "If-Match"
+	ifNoneMatchHeader = "If-None-Match"
+)
+
+// etag formats a version as a strong entity tag.
+func etag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
+// parsePrecondition reads the conditional headers of a write.
+//
+// Supported forms:
+// - If-Match: "<version>" requires the key to exist at that version
+// - If-Match: * requires the key to exist
+// - If-None-Match: * requires the key not to exist
+func parsePrecondition(r *http.Request) (store.Precondition, error) {
+	var cond store.Precondition
+
+	if v := strings.TrimSpace(r.Header.Get(ifMatchHeader)); v != "" {
+		if v == "*" {
+			cond.IfPresent = true
+		} else {
+			tag, ok := strings.CutPrefix(v, `"`)
+			tag, closed := strings.CutSuffix(tag, `"`)
+			version, err := strconv.ParseUint(tag, 10, 64)
+			if !ok || !closed || err != nil || version == 0 {
+				return store.Precondition{}, fmt.Errorf("invalid %s %q", ifMatchHeader, v)
+			}
+			cond.IfMatch = version
+		}
+	}
+
+	if v := strings.TrimSpace(r.Header.Get(ifNoneMatchHeader)); v != "" {
+		if v != "*" {
+			return store.Precondition{}, fmt.Errorf("unsupported %s %q (only * is supported)", ifNoneMatchHeader, v)
+		}
+		cond.IfAbsent = true
+	}
+
+	return cond, nil
+}
+
+// writeRejected reports a write the store refused.
+func writeRejected(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, store.ErrPreconditionFailed):
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+	case errors.Is(err, store.ErrStale), errors.Is(err, store.ErrNotCounter):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
@@ -43,6 +251,140 @@ type setRequest struct {
 	TTLms int64  `json:"ttl_ms,omitempty"`
 }
 
+// maxValueBytes bounds the request body of a PUT.
+const maxValueBytes = 64 << 20
+
+// defaultRawContentType is recorded for raw values sent without a Content-Type.
+const defaultRawContentType = "application/octet-stream"
+
+// jsonModeTypes are the media types still read as the JSON {"value": ...}
+// wrapper. Form encoding is included because it is what curl -d and other
+// older clients send by default.
+var jsonModeTypes = map[string]bool{
+	"application/json":                  true,
+	"application/x-www-form-urlencoded": true,
+}
+
+// isRawMode reports whether a request carries a raw value rather than the
+// JSON {"value": ...} wrapper. Raw mode is any Content-Type other than
+// none, application/json or form encoding, or an explicit ?raw=true.
+func isRawMode(r *http.Request) (bool, error) {
+	raw, _, err := rawParam(r)
+	if err != nil || raw {
+		return raw, err
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return false, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err != nil || !jsonModeTypes[mediaType], nil
+}
+
+// rawParam parses the ?raw= flag; set reports whether it was given.
+func rawParam(r *http.Request) (raw, set bool, err error) {
+	v := r.URL.Query().Get("raw")
+	if v == "" {
+		return false, false, nil
+	}
+	raw, err = strconv.ParseBool(v)
+	if err != nil {
+		return false, false, fmt.Errorf("invalid raw %q", v)
+	}
+	return raw, true, nil
+}
+
+// acceptsRaw reports whether a GET without ?raw= gets the value's bytes
+// rather than the JSON wrapper. An Accept header naming application/json
+// asks for JSON, and one that rules JSON out asks for the bytes. Otherwise,
+// e.g. for */* or no Accept at all, values stored in raw mode come back
+// raw and the others as JSON.
+func acceptsRaw(accept string, entry store.Entry) bool {
+	if accept != "" {
+		wantsJSON, wildcard := false, false
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(part)
+			if err != nil || !acceptable(params) {
+				continue
+			}
+			switch mediaType {
+			case "application/json":
+				wantsJSON = true
+			case "*/*", "application/*":
+				wildcard = true
+			}
+		}
+		if wantsJSON {
+			return false
+		}
+		if !wildcard {
+			return true
+		}
+	}
+	return entry.ContentType != ""
+}
+
+// acceptable reports whether an Accept entry's quality allows its media
+// type: q must be absent or a number in (0, 1]. An invalid q, e.g. "0.0x",
+// rules the type out rather than letting a malformed header pick the mode.
+func acceptable(params map[string]string) bool {
+	v, ok := params["q"]
+	if !ok {
+		return true
+	}
+	q, err := strconv.ParseFloat(v, 64)
+	return err == nil && q > 0 && q <= 1
+}
+
+// readEntry builds an entry from a PUT body in either mode.
+//
+// Modes:
+// - JSON: {"value": "...", "ttl_ms": N}; the value is stored as UTF-8 without a content type
+// - Raw: the body is stored byte for byte with its Content-Type; TTL comes from ?ttl_ms=N
+func readEntry(r *http.Request, raw bool) (store.Entry, error) {
+	if !raw {
+		var req setRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return store.Entry{}, err
+		}
+		return newEntry([]byte(req.Value), "", req.TTLms), nil
+	}
+
+	var ttlMs int64
+	if v := r.URL.Query().Get("ttl_ms"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return store.Entry{}, fmt.Errorf("invalid ttl_ms %q", v)
+		}
+		ttlMs = n
+	}
+
+	value, err := io.ReadAll(r.Body)
+	if err != nil {
+		return store.Entry{}, err
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = defaultRawContentType
+	}
+	return newEntry(value, contentType, ttlMs), nil
+}
+
+func newEntry(value []byte, contentType string, ttlMs int64) store.Entry {
+	entry := store.Entry{
+		Value:       value,
+		ContentType: contentType,
+		Timestamp:   time.Now().UnixNano(),
+	}
+
+	if ttlMs > 0 {
+		entry.ExpiresAt = time.Now().Add(time.Duration(ttlMs) * time.Millisecond)
+	}
+	return entry
+}
+
 func (h *Handler) SetKey(w http.ResponseWriter, r *http.Request) {
 	key := strings.TrimPrefix(r.URL.Path, "/kv/")
 	if key == "" {
@@ -50,27 +392,79 @@ func (h *Handler) SetKey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req setRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid json body", http.StatusBadRequest)
+	acks, err := parseReplicationAcks(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	entry := store.Entry{
-		Value:     req.Value,
-		Timestamp: time.Now().UnixNano(),
+	cond, err := parsePrecondition(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	if req.TTLms > 0 {
-		entry.ExpiresAt = time.Now().Add(time.Duration(req.TTLms) * time.Millisecond)
+	raw, err := isRawMode(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxValueBytes)
+	entry, err := readEntry(r, raw)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			http.Error(w, "value too large", http.StatusRequestEntityTooLarge)
+		case raw:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "invalid json body", http.StatusBadRequest)
+		}
+		return
 	}
 
-	h.store.Set(key, entry)
-	w.WriteHeader(http.StatusNoContent)
+	ns := h.namespace(r)
+	stored, err := ns.SetIf(key, entry, cond)
+	if err != nil {
+		writeRejected(w, err)
+		return
+	}
+	w.Header().Set(etagHeader, etag(stored.Version))
+
+	res := h.replicator.Replicate(context.WithoutCancel(r.Context()), ns.Name(), key, stored)
+	h.awaitReplication(w, r, res, acks)
 }
 
 /* ---------------- GET /kv/{key} ---------------- */
 
+// getResponse is the JSON-mode reply of GET /kv/{key}. A value that is
+// not valid UTF-8 is returned base64-encoded in Data instead of Value.
+type getResponse struct {
+	Value   *string `json:"value,omitempty"`
+	Data    []byte  `json:"data,omitempty"`
+	Version uint64  `json:"version"`
+}
+
+// jsonValue splits a value for a JSON response. Values written in raw mode,
+// and values that are not valid UTF-8 (e.g. set over RESP or memcached),
+// go in data, base64-encoded, since a JSON string would mangle them; other
+// values go in value as a string.
+func jsonValue(entry store.Entry) (value *string, data []byte) {
+	if entry.ContentType != "" || !utf8.Valid(entry.Value) {
+		return nil, entry.Value
+	}
+	s := string(entry.Value)
+	return &s, nil
+}
+
+// GetKey returns a value, as its bytes or in the JSON wrapper.
+//
+// Modes:
+// - ?raw=true or ?raw=false picks the mode explicitly
+// - Otherwise the Accept header decides, then the stored value (see acceptsRaw)
+// - Raw responses carry the stored Content-Type, or application/octet-stream
 func (h *Handler) GetKey(w http.ResponseWriter, r *http.Request) {
 	key := strings.TrimPrefix(r.URL.Path, "/kv/")
 	if key == "" {
@@ -78,14 +472,117 @@ func (h *Handler) GetKey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	value, ok := h.store.Get(key)
+	raw, explicit, err := rawParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entry, ok := h.namespace(r).Get(key)
 	if !ok {
 		http.Error(w, "key not found", http.StatusNotFound)
 		return
 	}
+	w.Header().Set(etagHeader, etag(entry.Version))
+
+	if !explicit {
+		raw = acceptsRaw(r.Header.Get("Accept"), entry)
+	}
+	if raw {
+		contentType := entry.ContentType
+		if contentType == "" {
+			contentType = defaultRawContentType
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(entry.Value)))
+		_, _ = w.Write(entry.Value)
+		return
+	}
+
+	resp := getResponse{Version: entry.Version}
+	resp.Value, resp.Data = jsonValue(entry)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+/* ---------------- POST /kv/{key}/incr, /kv/{key}/decr ---------------- */
+
+type incrRequest struct {
+	Delta *int64 `json:"delta,omitempty"`
+	TTLms int64  `json:"ttl_ms,omitempty"`
+}
+
+type counterResponse struct {
+	Value   int64  `json:"value"`
+	Version uint64 `json:"version"`
+}
+
+// IncrKey atomically adds to (incr) or subtracts from (decr) a counter.
+//
+// The body is optional: {"delta": N, "ttl_ms": N}; delta defaults to 1.
+// The updated counter state is replicated and merged on every peer,
+// so increments on different nodes add up (see store.Counter).
+//
+// Status codes:
+// - 200: the new value and version
+// - 400: malformed path or body, or the result would overflow an int64
+// - 409: the key holds a non-counter value
+func (h *Handler) IncrKey(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/kv/")
+
+	sign := int64(1)
+	key, ok := strings.CutSuffix(path, "/incr")
+	if !ok {
+		key, ok = strings.CutSuffix(path, "/decr")
+		sign = -1
+	}
+	if !ok || key == "" {
+		http.Error(w, "want /kv/{key}/incr or /kv/{key}/decr", http.StatusBadRequest)
+		return
+	}
+
+	acks, err := parseReplicationAcks(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req incrRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	if req.TTLms < 0 {
+		http.Error(w, "ttl_ms must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	delta := int64(1)
+	if req.Delta != nil {
+		delta = *req.Delta
+	}
+
+	ns := h.namespace(r)
+	stored, err := ns.Incr(key, h.nodeID, sign*delta, time.Duration(req.TTLms)*time.Millisecond)
+	if errors.Is(err, store.ErrCounterOverflow) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		writeRejected(w, err)
+		return
+	}
+	w.Header().Set(etagHeader, etag(stored.Version))
+
+	res := h.replicator.Replicate(context.WithoutCancel(r.Context()), ns.Name(), key, stored)
+	if !waitForAcks(w, r, res, acks) {
+		return
+	}
 
-	_ = json.NewEncoder(w).Encode(map[string]string{
-		"value": value,
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(counterResponse{
+		Value:   stored.Counter.Value(),
+		Version: stored.Version,
 	})
 }
 
@@ -98,23 +595,168 @@ func (h *Handler) DeleteKey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.store.Delete(key)
-	w.WriteHeader(http.StatusNoContent)
+	acks, err := parseReplicationAcks(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cond, err := parsePrecondition(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ns := h.namespace(r)
+	tombstone, err := ns.SetIf(key, store.Tombstone(time.Now().UnixNano()), cond)
+	if err != nil {
+		writeRejected(w, err)
+		return
+	}
+
+	res := h.replicator.Replicate(context.WithoutCancel(r.Context()), ns.Name(), key, tombstone)
+	h.awaitReplication(w, r, res, acks)
 }
 
 /* ---------------- GET /admin/keys ---------------- */
 
+// maxListLimit bounds the page size of GET /admin/keys.
+const maxListLimit = 1000
+
+type listItem struct {
+	Key         string  `json:"key"`
+	Value       *string `json:"value,omitempty"`
+	Data        []byte  `json:"data,omitempty"`
+	ContentType string  `json:"content_type,omitempty"`
+	Size        int64   `json:"size"`
+	Timestamp   int64   `json:"timestamp"`
+	Version     uint64  `json:"version"`
+	TTLms       int64   `json:"ttl_ms,omitempty"`
+}
+
+type listResponse struct {
+	Items      []listItem `json:"items"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// ListKeys returns one page of live keys in lexicographic order.
+// It is served as GET /admin/keys for the default namespace and as
+// GET /ns/{namespace}/keys for any other.
+//
+// Query parameters:
+// - cursor: next_cursor of the previous page
+// - prefix: only keys starting with it
+// - match: only keys matching a Redis-style glob (see util.Match), e.g. user:*:session
+// - limit: page size, 1 to 1000 (default 100)
+// - values=false: omit values and list metadata only
+//
+// Values written in raw mode or not valid UTF-8 are returned base64-encoded in data.
+// A page may hold fewer than limit keys, even none, while next_cursor is
+// set: each call examines a bounded number of keys. Only a missing
+// next_cursor ends the listing.
 func (h *Handler) ListKeys(w http.ResponseWriter, r *http.Request) {
-	entries := h.store.List()
+	query := r.URL.Query()
+
+	opts := store.ScanOptions{
+		Prefix:  query.Get("prefix"),
+		Pattern: query.Get("match"),
+	}
+
+	if v := query.Get("cursor"); v != "" {
+		after, err := base64.RawURLEncoding.DecodeString(v)
+		if err != nil {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+		opts.After = string(after)
+	}
+
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxListLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxListLimit), http.StatusBadRequest)
+			return
+		}
+		opts.Limit = n
+	}
 
-	resp := make(map[string]string)
-	for k, v := range entries {
-		resp[k] = v.Value
+	withValues := true
+	if v := query.Get("values"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid values %q", v), http.StatusBadRequest)
+			return
+		}
+		withValues = b
 	}
 
+	page := h.namespace(r).Scan(opts)
+
+	now := time.Now()
+	resp := listResponse{Items: make([]listItem, len(page.Items))}
+	for i, it := range page.Items {
+		item := listItem{
+			Key:       it.Key,
+			Size:      it.Size,
+			Timestamp: it.Entry.Timestamp,
+			Version:   it.Entry.Version,
+		}
+		if !it.Entry.ExpiresAt.IsZero() {
+			// A key about to expire still reports a TTL, never "no expiry".
+			item.TTLms = max(1, it.Entry.ExpiresAt.Sub(now).Milliseconds())
+		}
+		if withValues {
+			item.Value, item.Data = jsonValue(it.Entry)
+			item.ContentType = it.Entry.ContentType
+		}
+		resp.Items[i] = item
+	}
+	if page.Next != "" {
+		resp.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(page.Next))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(resp)
 }
 
+/* ---------------- POST /admin/config/reload ---------------- */
+
+func (h *Handler) ReloadConfig(w http.ResponseWriter, r *http.Request) {
+	if h.reloader == nil {
+		http.Error(w, "config reload is not enabled", http.StatusNotImplemented)
+		return
+	}
+
+	changes, err := h.reloader.Reload()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string][]config.Change{
+		"changes": changes,
+	})
+}
+
+/* ---------------- POST /admin/snapshot ---------------- */
+
+func (h *Handler) TakeSnapshot(w http.ResponseWriter, r *http.Request) {
+	if h.snapshots == nil {
+		http.Error(w, "snapshots are not enabled", http.StatusNotImplemented)
+		return
+	}
+
+	info, err := h.snapshots.Save()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(info)
+}
+
 /* ---------------- GET /metrics ---------------- */
 
 func (h *Handler) GetMetrics(w http.ResponseWriter, r *http.Request) {
@@ -3,12 +3,20 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"io"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"distributed-cache/internal/config"
 	"distributed-cache/internal/logs"
 	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/peers"
+	"distributed-cache/internal/replication"
+	"distributed-cache/internal/snapshot"
 	"distributed-cache/internal/store"
 
 	"github.com/stretchr/testify/assert"
@@ -18,8 +26,11 @@ func setUpTestServer() *httptest.Server {
 	reg := metrics.NewRegistry()
 	logger := logs.NewLogger(50, logs.DEBUG)
 	st := store.NewStore(reg)
+	cfg := peers.DefaultPeerConfig()
+	pm := peers.NewPeerManager(cfg, reg)
+	rep := replication.NewReplicator("node-test", pm, cfg, logger, reg)
 
-	h := NewHandler(st, reg, logger)
+	h := NewHandler("node-test", st, reg, logger, pm, rep)
 
 	mux := http.NewServeMux()
 	handler := RegisterRoutes(mux, h)
@@ -103,6 +114,360 @@ func TestGetKey(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
 	})
+
+	t.Run("JSONValueAsRaw", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/kv/active-key?raw=true")
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		data, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, "found-me", string(data))
+		assert.Equal(t, "application/octet-stream", resp.Header.Get("Content-Type"))
+	})
+}
+
+/* ---------------- PUT/GET /kv raw mode ---------------- */
+
+func TestRawValues(t *testing.T) {
+	server := setUpTestServer()
+	defer server.Close()
+
+	put := func(t *testing.T, path, contentType string, body []byte) *http.Response {
+		t.Helper()
+
+		req, _ := http.NewRequest(http.MethodPut, server.URL+path, bytes.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	t.Run("BinaryRoundTrip", func(t *testing.T) {
+		png := []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n', 0x00, 0xff, 0xfe}
+		resp := put(t, "/kv/image", "image/png", png)
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+		resp, err := http.Get(server.URL + "/kv/image")
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		data, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, png, data)
+		assert.Equal(t, "image/png", resp.Header.Get("Content-Type"))
+		assert.Equal(t, "11", resp.Header.Get("Content-Length"))
+	})
+
+	t.Run("RawFlagKeepsJSONBody", func(t *testing.T) {
+		doc := []byte(`{"value":"not unwrapped"}`)
+		resp := put(t, "/kv/doc?raw=true", "application/json", doc)
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+		resp, err := http.Get(server.URL + "/kv/doc")
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		data, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, doc, data)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	})
+
+	t.Run("JSONClientsKeepJSONMode", func(t *testing.T) {
+		for _, contentType := range []string{"", "application/json; charset=utf-8", "application/x-www-form-urlencoded"} {
+			resp := put(t, "/kv/legacy", contentType, []byte(`{"value":"wrapped"}`))
+			assert.Equal(t, http.StatusNoContent, resp.StatusCode, contentType)
+
+			resp, err := http.Get(server.URL + "/kv/legacy")
+			assert.NoError(t, err)
+
+			var res map[string]any
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&res), contentType)
+			resp.Body.Close()
+			assert.Equal(t, "wrapped", res["value"], contentType)
+		}
+	})
+
+	t.Run("AcceptPicksMode", func(t *testing.T) {
+		get := func(t *testing.T, path string, header map[string]string) (*http.Response, []byte) {
+			t.Helper()
+
+			req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+			for name, value := range header {
+				req.Header.Set(name, value)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			assert.NoError(t, err)
+			defer resp.Body.Close()
+
+			data, _ := io.ReadAll(resp.Body)
+			return resp, data
+		}
+
+		resp, data := get(t, "/kv/legacy", map[string]string{"Accept": "text/plain"})
+		assert.Equal(t, "wrapped", string(data))
+		assert.Equal(t, "application/octet-stream", resp.Header.Get("Content-Type"))
+
+		// A GET's Content-Type describes no body and is ignored.
+		resp, data = get(t, "/kv/legacy", map[string]string{"Content-Type": "image/png", "Accept": "*/*"})
+		assert.JSONEq(t, `{"value":"wrapped","version":3}`, string(data))
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+		resp, data = get(t, "/kv/image", map[string]string{"Accept": "application/json"})
+		var res getResponse
+		assert.NoError(t, json.Unmarshal(data, &res))
+		assert.Len(t, res.Data, 11, "raw values are base64-encoded in JSON")
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+		for _, accept := range []string{"application/json;q=0.0", "application/json;q=0.000", "application/json;q=bad", "application/json;q=-1"} {
+			_, data = get(t, "/kv/legacy", map[string]string{"Accept": accept})
+			assert.Equal(t, "wrapped", string(data), accept)
+		}
+		_, data = get(t, "/kv/image", map[string]string{"Accept": "image/png, application/json;q=0.5"})
+		assert.NoError(t, json.Unmarshal(data, &res))
+		assert.Len(t, res.Data, 11)
+
+		_, data = get(t, "/kv/image?raw=false", nil)
+		assert.NoError(t, json.Unmarshal(data, &res))
+		assert.Len(t, res.Data, 11)
+	})
+
+	t.Run("TTLFromQuery", func(t *testing.T) {
+		resp := put(t, "/kv/short?ttl_ms=50", "text/plain", []byte("soon gone"))
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+		assert.Eventually(t, func() bool {
+			resp, err := http.Get(server.URL + "/kv/short")
+			if err != nil {
+				return false
+			}
+			resp.Body.Close()
+			return resp.StatusCode == http.StatusNotFound
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("InvalidTTL", func(t *testing.T) {
+		resp := put(t, "/kv/bad?ttl_ms=soon", "text/plain", []byte("x"))
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("InvalidRawFlag", func(t *testing.T) {
+		resp := put(t, "/kv/bad?raw=maybe", "text/plain", []byte("x"))
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestNonUTF8ValuesInJSON(t *testing.T) {
+	node := newTestNode(t, "node-A")
+	binary := []byte{'o', 'k', 0xff, 0xfe, 0x00}
+	node.store.Set("bin", store.Entry{Value: binary, Timestamp: 1}) // e.g. a RESP SET
+
+	t.Run("Get", func(t *testing.T) {
+		resp := doRequest(t, http.MethodGet, node.server.URL+"/kv/bin", "")
+		var res getResponse
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+		assert.Nil(t, res.Value)
+		assert.Equal(t, binary, res.Data)
+	})
+
+	t.Run("MultiGet", func(t *testing.T) {
+		resp := doRequest(t, http.MethodPost, node.server.URL+"/kv/_mget", `{"keys":["bin"]}`)
+		var res batchResponse
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+		assert.Nil(t, res.Results[0].Value)
+		assert.Equal(t, binary, res.Results[0].Data)
+	})
+
+	t.Run("ListKeys", func(t *testing.T) {
+		resp := doRequest(t, http.MethodGet, node.server.URL+"/admin/keys", "")
+		var res listResponse
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+		assert.Nil(t, res.Items[0].Value)
+		assert.Equal(t, binary, res.Items[0].Data)
+	})
+}
+
+/* ---------------- Conditional PUT/DELETE /kv ---------------- */
+
+func TestConditionalWrites(t *testing.T) {
+	server := setUpTestServer()
+	defer server.Close()
+
+	do := func(t *testing.T, method, key string, headers map[string]string, body string) *http.Response {
+		t.Helper()
+
+		req, _ := http.NewRequest(method, server.URL+"/kv/"+key, bytes.NewBufferString(body))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	t.Run("CreateOnlyIfAbsent", func(t *testing.T) {
+		resp := do(t, http.MethodPut, "lock", map[string]string{"If-None-Match": "*"}, `{"value":"owner-1"}`)
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, `"1"`, resp.Header.Get("ETag"))
+
+		resp = do(t, http.MethodPut, "lock", map[string]string{"If-None-Match": "*"}, `{"value":"owner-2"}`)
+		assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	})
+
+	t.Run("GetReturnsVersion", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/kv/lock")
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		var res struct {
+			Value   string `json:"value"`
+			Version uint64 `json:"version"`
+		}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+		assert.Equal(t, "owner-1", res.Value)
+		assert.Equal(t, uint64(1), res.Version)
+		assert.Equal(t, `"1"`, resp.Header.Get("ETag"))
+	})
+
+	t.Run("CompareAndSwap", func(t *testing.T) {
+		resp := do(t, http.MethodPut, "lock", map[string]string{"If-Match": `"1"`}, `{"value":"owner-3"}`)
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+
+		// A second writer still holding version 1 loses.
+		resp = do(t, http.MethodPut, "lock", map[string]string{"If-Match": `"1"`}, `{"value":"owner-4"}`)
+		assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	})
+
+	t.Run("IfMatchAnyRequiresKey", func(t *testing.T) {
+		resp := do(t, http.MethodPut, "nothing-here", map[string]string{"If-Match": "*"}, `{"value":"x"}`)
+		assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	})
+
+	t.Run("ConditionalDelete", func(t *testing.T) {
+		resp := do(t, http.MethodDelete, "lock", map[string]string{"If-Match": `"1"`}, "")
+		assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+		resp = do(t, http.MethodDelete, "lock", map[string]string{"If-Match": `"2"`}, "")
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+		resp, err := http.Get(server.URL + "/kv/lock")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("InvalidHeaders", func(t *testing.T) {
+		for _, headers := range []map[string]string{
+			{"If-Match": "2"},
+			{"If-Match": `W/"2"`},
+			{"If-Match": `"0"`},
+			{"If-None-Match": `"2"`},
+		} {
+			resp := do(t, http.MethodPut, "lock", headers, `{"value":"x"}`)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, headers)
+		}
+	})
+}
+
+/* ---------------- POST /kv/{key}/incr ---------------- */
+
+func TestIncrKey(t *testing.T) {
+	server := setUpTestServer()
+	defer server.Close()
+
+	post := func(t *testing.T, path, body string) (*http.Response, counterResponse) {
+		t.Helper()
+
+		resp, err := http.Post(server.URL+path, "application/json", bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		var res counterResponse
+		if resp.StatusCode == http.StatusOK {
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+		}
+		return resp, res
+	}
+
+	t.Run("DefaultDelta", func(t *testing.T) {
+		resp, res := post(t, "/kv/hits/incr", "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int64(1), res.Value)
+		assert.Equal(t, `"1"`, resp.Header.Get("ETag"))
+	})
+
+	t.Run("IncrAndDecr", func(t *testing.T) {
+		_, res := post(t, "/kv/hits/incr", `{"delta":10}`)
+		assert.Equal(t, int64(11), res.Value)
+
+		_, res = post(t, "/kv/hits/decr", `{"delta":4}`)
+		assert.Equal(t, int64(7), res.Value)
+
+		_, res = post(t, "/kv/hits/decr", "")
+		assert.Equal(t, int64(6), res.Value)
+		assert.Equal(t, uint64(4), res.Version)
+	})
+
+	t.Run("ReadableWithGet", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/kv/hits")
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		var res getResponse
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+		assert.Equal(t, "6", *res.Value)
+	})
+
+	t.Run("WithTTL", func(t *testing.T) {
+		resp, _ := post(t, "/kv/window/incr", `{"ttl_ms":50}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		assert.Eventually(t, func() bool {
+			resp, err := http.Get(server.URL + "/kv/window")
+			if err != nil {
+				return false
+			}
+			resp.Body.Close()
+			return resp.StatusCode == http.StatusNotFound
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("PlainValue", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPut, server.URL+"/kv/plain", bytes.NewBufferString(`{"value":"x"}`))
+		_, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+
+		resp, _ := post(t, "/kv/plain/incr", "")
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	})
+
+	t.Run("Overflow", func(t *testing.T) {
+		resp, _ := post(t, "/kv/big/incr", `{"delta":9223372036854775807}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, _ = post(t, "/kv/big/incr", `{"delta":9223372036854775807}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		resp, _ = post(t, "/kv/big/decr", `{"delta":-9223372036854775808}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		_, res := post(t, "/kv/big/incr", `{"delta":0}`)
+		assert.Equal(t, int64(math.MaxInt64), res.Value, "the counter is unchanged")
+	})
+
+	t.Run("InvalidRequests", func(t *testing.T) {
+		for path, body := range map[string]string{
+			"/kv/hits/incr":        `{"delta":"one"}`,
+			"/kv/hits/decr":        `{"ttl_ms":-1}`,
+			"/kv//incr":            "",
+			"/kv/hits/incr?acks=x": "",
+		} {
+			resp, _ := post(t, path, body)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, path)
+		}
+	})
 }
 
 /* ---------------- DELETE /kv ---------------- */
@@ -131,34 +496,348 @@ func TestDeleteKey(t *testing.T) {
 	})
 }
 
+/* ---------------- PUT/DELETE replication ---------------- */
+
+func TestWritesAreReplicated(t *testing.T) {
+	nodeA := newTestNode(t, "node-A")
+	nodeB := newTestNode(t, "node-B")
+	nodeC := newTestNode(t, "node-C")
+
+	nodeA.peers.AddPeer(nodeB.server.URL)
+	nodeA.peers.AddPeer(nodeC.server.URL)
+
+	t.Run("PutWaitsForAllAcks", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPut, nodeA.server.URL+"/kv/k1?acks=all", bytes.NewBuffer([]byte(`{"value":"v1"}`)))
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, "2", resp.Header.Get("X-Replication-Peers"))
+		assert.Equal(t, "2", resp.Header.Get("X-Replication-Acked"))
+
+		// acks=all guarantees both peers applied the write before responding.
+		val, ok := nodeB.store.Get("k1")
+		assert.True(t, ok)
+		assert.Equal(t, "v1", string(val.Value))
+
+		val, ok = nodeC.store.Get("k1")
+		assert.True(t, ok)
+		assert.Equal(t, "v1", string(val.Value))
+	})
+
+	t.Run("PutBinaryValue", func(t *testing.T) {
+		value := []byte{0x00, 0xff, 0xc3, 0x28, 'v'}
+		req, _ := http.NewRequest(http.MethodPut, nodeA.server.URL+"/kv/bin?acks=all", bytes.NewReader(value))
+		req.Header.Set("Content-Type", "application/x-custom")
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+		for _, node := range []*testNode{nodeB, nodeC} {
+			val, ok := node.store.Get("bin")
+			assert.True(t, ok)
+			assert.Equal(t, value, val.Value)
+			assert.Equal(t, "application/x-custom", val.ContentType)
+		}
+	})
+
+	t.Run("PutKeepsVersionOnPeers", func(t *testing.T) {
+		nodeA.store.Set("versioned", store.Entry{Value: []byte("v0"), Timestamp: 1, Version: 41})
+
+		req, _ := http.NewRequest(http.MethodPut, nodeA.server.URL+"/kv/versioned?acks=all", bytes.NewBuffer([]byte(`{"value":"v1"}`)))
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		assert.Equal(t, `"42"`, resp.Header.Get("ETag"))
+
+		for _, node := range []*testNode{nodeB, nodeC} {
+			val, ok := node.store.Get("versioned")
+			assert.True(t, ok)
+			assert.Equal(t, uint64(42), val.Version, "peers share the writer's version")
+		}
+	})
+
+	t.Run("CountersMergeAcrossNodes", func(t *testing.T) {
+		defer nodeB.peers.SetPeers(nodeB.peers.GetPeers())
+		nodeB.peers.AddPeer(nodeA.server.URL)
+		nodeB.peers.AddPeer(nodeC.server.URL)
+
+		for _, node := range []*testNode{nodeA, nodeB} {
+			resp, err := http.Post(node.server.URL+"/kv/quota/incr?acks=all", "application/json", bytes.NewBufferString(`{"delta":5}`))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			resp.Body.Close()
+		}
+
+		for _, node := range []*testNode{nodeA, nodeB, nodeC} {
+			val, ok := node.store.Get("quota")
+			assert.True(t, ok)
+			assert.Equal(t, "10", string(val.Value), "increments from both nodes count")
+		}
+	})
+
+	t.Run("PutFireAndForget", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPut, nodeA.server.URL+"/kv/k2", bytes.NewBuffer([]byte(`{"value":"v2"}`)))
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, "2", resp.Header.Get("X-Replication-Peers"))
+		assert.Equal(t, "0", resp.Header.Get("X-Replication-Acked"))
+
+		assert.Eventually(t, func() bool {
+			_, ok := nodeB.store.Get("k2")
+			return ok
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("DeleteWaitsForHeaderAcks", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodDelete, nodeA.server.URL+"/kv/k1", nil)
+		req.Header.Set("X-Replication-Acks", "2")
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, "2", resp.Header.Get("X-Replication-Acked"))
+
+		_, ok := nodeB.store.Get("k1")
+		assert.False(t, ok)
+		_, ok = nodeC.store.Get("k1")
+		assert.False(t, ok)
+	})
+
+	t.Run("NotEnoughPeers", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPut, nodeA.server.URL+"/kv/k3?acks=3", bytes.NewBuffer([]byte(`{"value":"v3"}`)))
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
+		assert.Equal(t, "2", resp.Header.Get("X-Replication-Acked"))
+
+		// The local write is kept even when the ack target is missed.
+		_, ok := nodeA.store.Get("k3")
+		assert.True(t, ok)
+	})
+
+	t.Run("InvalidAcks", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPut, nodeA.server.URL+"/kv/k4?acks=some", bytes.NewBuffer([]byte(`{"value":"v4"}`)))
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
 /* ---------------- GET /admin/keys ---------------- */
 
 func TestListKeys(t *testing.T) {
 	server := setUpTestServer()
 	defer server.Close()
 
-	t.Run("EmptyStore", func(t *testing.T) {
-		resp, err := http.Get(server.URL + "/admin/keys")
+	list := func(t *testing.T, query string) listResponse {
+		t.Helper()
+
+		resp, err := http.Get(server.URL + "/admin/keys" + query)
 		assert.NoError(t, err)
+		defer resp.Body.Close()
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
 
-		var data map[string]string
-		json.NewDecoder(resp.Body).Decode(&data)
-		assert.Len(t, data, 0)
+		var data listResponse
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&data))
+		return data
+	}
+
+	t.Run("EmptyStore", func(t *testing.T) {
+		data := list(t, "")
+		assert.Len(t, data.Items, 0)
+		assert.Empty(t, data.NextCursor)
 	})
 
-	t.Run("WithData", func(t *testing.T) {
-		req, _ := http.NewRequest(http.MethodPut, server.URL+"/kv/a", bytes.NewBuffer([]byte(`{"value":"1"}`)))
+	for _, key := range []string{"a", "user:1", "user:2", "user:3"} {
+		req, _ := http.NewRequest(http.MethodPut, server.URL+"/kv/"+key, bytes.NewBuffer([]byte(`{"value":"1","ttl_ms":60000}`)))
 		http.DefaultClient.Do(req)
+	}
 
-		resp, err := http.Get(server.URL + "/admin/keys")
+	t.Run("WithData", func(t *testing.T) {
+		data := list(t, "")
+		assert.Len(t, data.Items, 4)
+
+		item := data.Items[0]
+		assert.Equal(t, "a", item.Key)
+		assert.Equal(t, "1", *item.Value)
+		assert.Equal(t, uint64(1), item.Version)
+		assert.Positive(t, item.Size)
+		assert.Positive(t, item.Timestamp)
+		assert.InDelta(t, 60000, item.TTLms, 1000)
+	})
+
+	t.Run("PaginatesWithCursor", func(t *testing.T) {
+		first := list(t, "?prefix=user:&limit=2")
+		assert.Len(t, first.Items, 2)
+		assert.Equal(t, "user:1", first.Items[0].Key)
+		assert.NotEmpty(t, first.NextCursor)
+
+		second := list(t, "?prefix=user:&limit=2&cursor="+first.NextCursor)
+		assert.Len(t, second.Items, 1)
+		assert.Equal(t, "user:3", second.Items[0].Key)
+		assert.Empty(t, second.NextCursor)
+	})
+
+	t.Run("GlobWithoutValues", func(t *testing.T) {
+		data := list(t, "?match=user:[12]&values=false")
+		assert.Len(t, data.Items, 2)
+		assert.Nil(t, data.Items[0].Value)
+	})
+
+	t.Run("InvalidParameters", func(t *testing.T) {
+		for _, query := range []string{"?limit=0", "?limit=1001", "?cursor=not*base64", "?values=maybe"} {
+			resp, err := http.Get(server.URL + "/admin/keys" + query)
+			assert.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+		}
+	})
+
+	t.Run("GlobMatchesAcrossSlash", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPut, server.URL+"/kv/user/9", bytes.NewBuffer([]byte(`{"value":"1"}`)))
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		data := list(t, "?match=user*&values=false")
+		keys := make([]string, len(data.Items))
+		for i, item := range data.Items {
+			keys[i] = item.Key
+		}
+		assert.Equal(t, []string{"user/9", "user:1", "user:2", "user:3"}, keys)
+	})
+}
+
+/* ---------------- POST /admin/config/reload ---------------- */
+
+type stubReloader struct {
+	changes []config.Change
+	err     error
+}
+
+func (s *stubReloader) Reload() ([]config.Change, error) {
+	return s.changes, s.err
+}
+
+func TestReloadConfig(t *testing.T) {
+	newServer := func(opts ...Option) *httptest.Server {
+		reg := metrics.NewRegistry()
+		logger := logs.NewLogger(50, logs.DEBUG)
+		cfg := peers.DefaultPeerConfig()
+		pm := peers.NewPeerManager(cfg, reg)
+		rep := replication.NewReplicator("node-test", pm, cfg, logger, reg)
+
+		h := NewHandler("node-test", store.NewStore(reg), reg, logger, pm, rep, opts...)
+		return httptest.NewServer(RegisterRoutes(http.NewServeMux(), h))
+	}
+
+	t.Run("AppliedChanges", func(t *testing.T) {
+		server := newServer(WithConfigReloader(&stubReloader{
+			changes: []config.Change{{Field: "log.level", Old: "DEBUG", New: "INFO"}},
+		}))
+		defer server.Close()
+
+		resp, err := http.Post(server.URL+"/admin/config/reload", "application/json", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body map[string][]config.Change
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "log.level", body["changes"][0].Field)
+		resp.Body.Close()
+	})
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		server := newServer(WithConfigReloader(&stubReloader{err: errors.New("invalid configuration")}))
+		defer server.Close()
+
+		resp, err := http.Post(server.URL+"/admin/config/reload", "application/json", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("NotEnabled", func(t *testing.T) {
+		server := newServer()
+		defer server.Close()
+
+		resp, err := http.Post(server.URL+"/admin/config/reload", "application/json", nil)
 		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+	})
+
+	t.Run("MethodNotAllowed", func(t *testing.T) {
+		server := newServer()
+		defer server.Close()
+
+		resp, err := http.Get(server.URL + "/admin/config/reload")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+}
+
+type stubSnapshotter struct {
+	info snapshot.Info
+	err  error
+}
+
+func (s *stubSnapshotter) Save() (snapshot.Info, error) {
+	return s.info, s.err
+}
+
+func TestTakeSnapshot(t *testing.T) {
+	newServer := func(opts ...Option) *httptest.Server {
+		reg := metrics.NewRegistry()
+		logger := logs.NewLogger(50, logs.DEBUG)
+		cfg := peers.DefaultPeerConfig()
+		pm := peers.NewPeerManager(cfg, reg)
+		rep := replication.NewReplicator("node-test", pm, cfg, logger, reg)
+
+		h := NewHandler("node-test", store.NewStore(reg), reg, logger, pm, rep, opts...)
+		return httptest.NewServer(RegisterRoutes(http.NewServeMux(), h))
+	}
+
+	t.Run("Saved", func(t *testing.T) {
+		server := newServer(WithSnapshotter(&stubSnapshotter{
+			info: snapshot.Info{Path: "/data/snapshot-1.snap", Keys: 3},
+		}))
+		defer server.Close()
+
+		resp, err := http.Post(server.URL+"/admin/snapshot", "application/json", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
 
-		var data map[string]string
-		json.NewDecoder(resp.Body).Decode(&data)
-		assert.Equal(t, "1", data["a"])
+		var info snapshot.Info
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&info))
+		assert.Equal(t, "/data/snapshot-1.snap", info.Path)
+		assert.Equal(t, 3, info.Keys)
 		resp.Body.Close()
 	})
+
+	t.Run("Failed", func(t *testing.T) {
+		server := newServer(WithSnapshotter(&stubSnapshotter{err: errors.New("disk full")}))
+		defer server.Close()
+
+		resp, err := http.Post(server.URL+"/admin/snapshot", "application/json", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	})
+
+	t.Run("NotEnabled", func(t *testing.T) {
+		server := newServer()
+		defer server.Close()
+
+		resp, err := http.Post(server.URL+"/admin/snapshot", "application/json", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+	})
+
+	t.Run("MethodNotAllowed", func(t *testing.T) {
+		server := newServer()
+		defer server.Close()
+
+		resp, err := http.Get(server.URL + "/admin/snapshot")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
 }
 
 /* ---------------- GET /metrics ---------------- */
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/peers"
+	"distributed-cache/internal/replication"
+	"distributed-cache/internal/store"
+	"distributed-cache/internal/version"
+)
+
+/* ---------------- POST /internal/replicate ---------------- */
+
+// ReceiveReplication applies a write replicated from a peer; see
+// ApplyReplication for the rules.
+//
+// Status codes:
+// - 204: payload applied
+// - 400: malformed payload
+// - 404: the namespace does not exist on this node
+// - 409: payload is stale or originated on this node
+func (h *Handler) ReceiveReplication(w http.ResponseWriter, r *http.Request) {
+	var payload replication.Payload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "invalid replication payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.ApplyReplication(payload); err != nil {
+		writeReplicationError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ApplyReplication applies a write replicated from a peer, over HTTP or
+// the binary transport.
+//
+// Rules:
+// - The entry is applied through the store, so LWW decides the outcome.
+// - Deletes arrive as tombstones and follow the same LWW rules.
+// - Replicated writes are never forwarded again; only the origin fans out.
+// - Payloads that originated on this node are rejected to break loops.
+// - Writes to a namespace this node does not have are refused; creating it
+//   here would bypass the settings an operator gives it.
+//
+// Errors wrap replication.ErrInvalidPayload, replication.ErrUnknownNamespace
+// or replication.ErrConflict.
+func (h *Handler) ApplyReplication(payload replication.Payload) error {
+	if payload.Key == "" || payload.OriginalNodeID == "" || payload.Entry.Timestamp <= 0 {
+		return fmt.Errorf("%w: missing key, origin or timestamp", replication.ErrInvalidPayload)
+	}
+
+	if payload.OriginalNodeID == h.nodeID {
+		return errOwnPayload
+	}
+
+	ns, ok := h.store.Namespace(payload.Namespace)
+	if !ok {
+		return unknownNamespace(payload.Namespace)
+	}
+
+	h.metrics.Inc(metrics.ReplicationReceivedTotal)
+
+	if !ns.Set(payload.Key, payload.Entry) {
+		h.metrics.Inc(metrics.ReplicationStaleTotal)
+		return fmt.Errorf("%w: stale replication payload", replication.ErrConflict)
+	}
+	return nil
+}
+
+/* ---------------- POST /internal/replicate/batch ---------------- */
+
+// ReceiveReplicationBatch applies a batch of writes replicated from a
+// peer; see ApplyReplicationBatch.
+//
+// Status codes:
+// - 204: batch applied
+// - 400: malformed payload
+// - 404: the namespace does not exist on this node
+// - 409: payload originated on this node
+func (h *Handler) ReceiveReplicationBatch(w http.ResponseWriter, r *http.Request) {
+	var payload replication.BatchPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "invalid replication payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.ApplyReplicationBatch(payload); err != nil {
+		writeReplicationError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ApplyReplicationBatch applies a batch of writes replicated from a peer.
+//
+// Every write follows the rules of ApplyReplication; stale writes are
+// counted and skipped rather than failing the batch, since the peer has
+// already converged on them.
+func (h *Handler) ApplyReplicationBatch(payload replication.BatchPayload) error {
+	if payload.OriginalNodeID == "" {
+		return fmt.Errorf("%w: missing origin", replication.ErrInvalidPayload)
+	}
+	writes := make([]store.Write, len(payload.Writes))
+	for i, wr := range payload.Writes {
+		if wr.Key == "" || wr.Entry.Timestamp <= 0 {
+			return fmt.Errorf("%w: missing key or timestamp", replication.ErrInvalidPayload)
+		}
+		writes[i] = store.Write{Key: wr.Key, Entry: wr.Entry}
+	}
+
+	if payload.OriginalNodeID == h.nodeID {
+		return errOwnPayload
+	}
+
+	ns, ok := h.store.Namespace(payload.Namespace)
+	if !ok {
+		return unknownNamespace(payload.Namespace)
+	}
+
+	h.metrics.Add(metrics.ReplicationReceivedTotal, int64(len(writes)))
+
+	for _, res := range ns.SetMany(writes) {
+		if res.Err != nil {
+			h.metrics.Inc(metrics.ReplicationStaleTotal)
+		}
+	}
+	return nil
+}
+
+// errOwnPayload rejects payloads that looped back to their origin.
+var errOwnPayload = fmt.Errorf("%w: payload originated on this node", replication.ErrConflict)
+
+// unknownNamespace refuses a write to a namespace missing on this node.
+func unknownNamespace(name string) error {
+	return fmt.Errorf("%w %q", replication.ErrUnknownNamespace, name)
+}
+
+// writeReplicationError maps an Apply error to its status code.
+func writeReplicationError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, replication.ErrInvalidPayload):
+		status = http.StatusBadRequest
+	case errors.Is(err, replication.ErrUnknownNamespace):
+		status = http.StatusNotFound
+	case errors.Is(err, replication.ErrConflict):
+		status = http.StatusConflict
+	}
+	http.Error(w, err.Error(), status)
+}
+
+/* ---------------- GET /internal/heartbeat ---------------- */
+
+// Heartbeat answers peer liveness probes with a short node status.
+func (h *Handler) Heartbeat(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(peers.HeartbeatStatus{
+		NodeID:        h.nodeID,
+		UptimeSeconds: int64(time.Since(h.startedAt) / time.Second),
+		Keys:          h.store.Len(),
+		Version:       version.Version,
+	})
+}
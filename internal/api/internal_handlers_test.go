@@ -0,0 +1,389 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"distributed-cache/internal/logs"
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/peers"
+	"distributed-cache/internal/replication"
+	"distributed-cache/internal/store"
+	"distributed-cache/internal/version"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testNode is a single cache node served over httptest.
+type testNode struct {
+	id         string
+	server     *httptest.Server
+	handler    *Handler
+	store      *store.Store
+	metrics    *metrics.Registry
+	peers      *peers.PeerManager
+	replicator *replication.Replicator
+}
+
+func newTestNode(t *testing.T, id string) *testNode {
+	t.Helper()
+
+	cfg := peers.DefaultPeerConfig()
+	cfg.Retry.BaseBackoff = time.Millisecond
+	cfg.Retry.JitterFn = func(d time.Duration) time.Duration { return 0 }
+
+	reg := metrics.NewRegistry()
+	logger := logs.NewLogger(50, logs.DEBUG)
+	st := store.NewStore(reg, store.WithWatchHistory(64))
+	pm := peers.NewPeerManager(cfg, reg)
+	rep := replication.NewReplicator(id, pm, cfg, logger, reg)
+
+	h := NewHandler(id, st, reg, logger, pm, rep)
+	server := httptest.NewServer(RegisterRoutes(http.NewServeMux(), h))
+	t.Cleanup(server.Close)
+
+	return &testNode{
+		id:         id,
+		server:     server,
+		handler:    h,
+		store:      st,
+		metrics:    reg,
+		peers:      pm,
+		replicator: rep,
+	}
+}
+
+func postPayload(t *testing.T, node *testNode, body []byte) *http.Response {
+	t.Helper()
+
+	resp, err := http.Post(node.server.URL+"/internal/replicate", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp
+}
+
+func encodePayload(t *testing.T, payload replication.Payload) []byte {
+	t.Helper()
+
+	body, err := json.Marshal(payload)
+	require.NoError(t, err)
+	return body
+}
+
+/* ---------------- POST /internal/replicate ---------------- */
+
+func TestReceiveReplication_EndToEnd(t *testing.T) {
+	nodeA := newTestNode(t, "node-A")
+	nodeB := newTestNode(t, "node-B")
+
+	nodeA.peers.AddPeer(nodeB.server.URL)
+
+	entry := store.Entry{Value: []byte("replicated"), Timestamp: time.Now().UnixNano()}
+	nodeA.store.Set("shared", entry)
+	nodeA.replicator.Replicate(context.Background(), store.DefaultNamespace, "shared", entry)
+
+	assert.Eventually(t, func() bool {
+		val, ok := nodeB.store.Get("shared")
+		return ok && string(val.Value) == "replicated"
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Eventually(t, func() bool {
+		return nodeA.metrics.Snapshot()[string(metrics.ReplicationSuccessTotal)] == 1
+	}, time.Second, 10*time.Millisecond)
+
+	assert.True(t, nodeA.peers.IsHealthy(nodeB.server.URL))
+	assert.Equal(t, int64(1), nodeB.metrics.Snapshot()[string(metrics.ReplicationReceivedTotal)])
+}
+
+func TestReceiveReplication_StaleWriteIsRejected(t *testing.T) {
+	nodeA := newTestNode(t, "node-A")
+	nodeB := newTestNode(t, "node-B")
+
+	nodeB.store.Set("key", store.Entry{Value: []byte("newer"), Timestamp: 10})
+
+	nodeA.peers.AddPeer(nodeB.server.URL)
+	nodeA.replicator.Replicate(context.Background(), store.DefaultNamespace, "key", store.Entry{Value: []byte("older"), Timestamp: 5})
+
+	assert.Eventually(t, func() bool {
+		return nodeB.metrics.Snapshot()[string(metrics.ReplicationStaleTotal)] == 1
+	}, time.Second, 10*time.Millisecond)
+
+	val, ok := nodeB.store.Get("key")
+	require.True(t, ok)
+	assert.Equal(t, "newer", string(val.Value))
+
+	// A stale answer means the peer converged, not that it failed.
+	assert.Eventually(t, func() bool {
+		return nodeA.metrics.Snapshot()[string(metrics.ReplicationSuccessTotal)] == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(0), nodeA.metrics.Snapshot()[string(metrics.ReplicationFailureTotal)])
+}
+
+func TestReceiveReplication_BinaryTransport(t *testing.T) {
+	nodeA := newTestNode(t, "node-A")
+	nodeB := newTestNode(t, "node-B")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	binaryServer := replication.NewServer(nodeB.handler, nodeB.metrics, logs.NewLogger(10, logs.DEBUG))
+	go binaryServer.Serve(l)
+	t.Cleanup(func() { _ = binaryServer.Shutdown(context.Background()) })
+
+	nodeA.peers.AddPeer(nodeB.server.URL)
+	nodeA.replicator.SetBinaryPeers(map[string]string{nodeB.server.URL: l.Addr().String()})
+	t.Cleanup(nodeA.replicator.Close)
+	ctx := context.Background()
+
+	entry := store.Entry{Value: []byte("replicated"), Timestamp: 10}
+	assert.Equal(t, 0, nodeA.replicator.Replicate(ctx, "orders", "shared", entry).Wait(ctx, 1),
+		"the peer refuses writes to a namespace it does not have")
+	assert.Equal(t, int64(1), nodeA.metrics.Snapshot()[string(metrics.ReplicationUnknownNamespaceTotal)])
+	assert.True(t, nodeA.peers.IsHealthy(nodeB.server.URL))
+
+	_, err = nodeB.store.CreateNamespace("orders", store.NamespaceSettings{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, nodeA.replicator.Replicate(ctx, "orders", "shared", entry).Wait(ctx, 1))
+
+	ns, ok := nodeB.store.Namespace("orders")
+	require.True(t, ok)
+	val, ok := ns.Get("shared")
+	require.True(t, ok)
+	assert.Equal(t, "replicated", string(val.Value))
+
+	// Stale writes are acknowledged, as with HTTP 409.
+	older := store.Entry{Value: []byte("older"), Timestamp: 5}
+	assert.Equal(t, 1, nodeA.replicator.Replicate(ctx, "orders", "shared", older).Wait(ctx, 1))
+
+	snap := nodeB.metrics.Snapshot()
+	assert.Equal(t, int64(2), snap[string(metrics.ReplicationReceivedTotal)])
+	assert.Equal(t, int64(1), snap[string(metrics.ReplicationStaleTotal)])
+	assert.Equal(t, int64(3), snap[string(metrics.ReplicationBinaryFramesTotal)])
+}
+
+func TestReceiveReplication_StatusCodes(t *testing.T) {
+	node := newTestNode(t, "node-B")
+
+	t.Run("Applied", func(t *testing.T) {
+		resp := postPayload(t, node, encodePayload(t, replication.Payload{
+			Key:            "k",
+			Entry:          store.Entry{Value: []byte("v"), Timestamp: 1},
+			OriginalNodeID: "node-A",
+		}))
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	})
+
+	t.Run("Stale", func(t *testing.T) {
+		resp := postPayload(t, node, encodePayload(t, replication.Payload{
+			Key:            "k",
+			Entry:          store.Entry{Value: []byte("v"), Timestamp: 1},
+			OriginalNodeID: "node-A",
+		}))
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	})
+
+	t.Run("OwnOrigin", func(t *testing.T) {
+		resp := postPayload(t, node, encodePayload(t, replication.Payload{
+			Key:            "loop",
+			Entry:          store.Entry{Value: []byte("v"), Timestamp: 1},
+			OriginalNodeID: "node-B",
+		}))
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+		_, ok := node.store.Get("loop")
+		assert.False(t, ok)
+	})
+
+	t.Run("InvalidJSON", func(t *testing.T) {
+		resp := postPayload(t, node, []byte(`{bad-json`))
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("MissingKey", func(t *testing.T) {
+		resp := postPayload(t, node, encodePayload(t, replication.Payload{
+			Entry:          store.Entry{Value: []byte("v"), Timestamp: 1},
+			OriginalNodeID: "node-A",
+		}))
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("MissingTimestamp", func(t *testing.T) {
+		resp := postPayload(t, node, encodePayload(t, replication.Payload{
+			Key:            "k2",
+			Entry:          store.Entry{Value: []byte("v")},
+			OriginalNodeID: "node-A",
+		}))
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("MethodNotAllowed", func(t *testing.T) {
+		resp, err := http.Get(node.server.URL + "/internal/replicate")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+}
+
+func TestReceiveReplication_Tombstones(t *testing.T) {
+	node := newTestNode(t, "node-B")
+	node.store.Set("k", store.Entry{Value: []byte("v"), Timestamp: 1})
+
+	t.Run("TombstoneDeletesKey", func(t *testing.T) {
+		resp := postPayload(t, node, encodePayload(t, replication.Payload{
+			Key:            "k",
+			Entry:          store.Tombstone(3),
+			OriginalNodeID: "node-A",
+		}))
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+		_, ok := node.store.Get("k")
+		assert.False(t, ok)
+	})
+
+	t.Run("DelayedOlderSetDoesNotResurrect", func(t *testing.T) {
+		resp := postPayload(t, node, encodePayload(t, replication.Payload{
+			Key:            "k",
+			Entry:          store.Entry{Value: []byte("late"), Timestamp: 2},
+			OriginalNodeID: "node-C",
+		}))
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+		_, ok := node.store.Get("k")
+		assert.False(t, ok)
+	})
+
+	t.Run("StaleTombstoneIsRejected", func(t *testing.T) {
+		node.store.Set("live", store.Entry{Value: []byte("v"), Timestamp: 10})
+
+		resp := postPayload(t, node, encodePayload(t, replication.Payload{
+			Key:            "live",
+			Entry:          store.Tombstone(5),
+			OriginalNodeID: "node-A",
+		}))
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+		_, ok := node.store.Get("live")
+		assert.True(t, ok)
+	})
+}
+
+/* ---------------- POST /internal/replicate/batch ---------------- */
+
+func TestReceiveReplicationBatch(t *testing.T) {
+	node := newTestNode(t, "node-B")
+	node.store.Set("newer", store.Entry{Value: []byte("keep"), Timestamp: 10})
+
+	post := func(t *testing.T, payload any) *http.Response {
+		t.Helper()
+
+		body, err := json.Marshal(payload)
+		require.NoError(t, err)
+		resp, err := http.Post(node.server.URL+"/internal/replicate/batch", "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	t.Run("AppliesWritesAndSkipsStaleOnes", func(t *testing.T) {
+		resp := post(t, replication.BatchPayload{
+			OriginalNodeID: "node-A",
+			Writes: []replication.Write{
+				{Key: "a", Entry: store.Entry{Value: []byte("1"), Timestamp: 5}},
+				{Key: "newer", Entry: store.Entry{Value: []byte("old"), Timestamp: 5}},
+				{Key: "b", Entry: store.Tombstone(5)},
+			},
+		})
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+		val, ok := node.store.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, "1", string(val.Value))
+		val, _ = node.store.Get("newer")
+		assert.Equal(t, "keep", string(val.Value))
+
+		snap := node.metrics.Snapshot()
+		assert.Equal(t, int64(3), snap[string(metrics.ReplicationReceivedTotal)])
+		assert.Equal(t, int64(1), snap[string(metrics.ReplicationStaleTotal)])
+	})
+
+	t.Run("StatusCodes", func(t *testing.T) {
+		cases := map[string]struct {
+			payload any
+			want    int
+		}{
+			"missing origin": {replication.BatchPayload{Writes: []replication.Write{{Key: "a", Entry: store.Entry{Timestamp: 1}}}}, http.StatusBadRequest},
+			"missing key":    {replication.BatchPayload{OriginalNodeID: "node-A", Writes: []replication.Write{{Entry: store.Entry{Timestamp: 1}}}}, http.StatusBadRequest},
+			"own payload":    {replication.BatchPayload{OriginalNodeID: "node-B", Writes: []replication.Write{{Key: "a", Entry: store.Entry{Timestamp: 1}}}}, http.StatusConflict},
+			"malformed":      {"not a batch", http.StatusBadRequest},
+		}
+		for name, tc := range cases {
+			t.Run(name, func(t *testing.T) {
+				assert.Equal(t, tc.want, post(t, tc.payload).StatusCode)
+			})
+		}
+	})
+}
+
+/* ---------------- GET /internal/heartbeat ---------------- */
+
+func TestHeartbeat(t *testing.T) {
+	node := newTestNode(t, "node-B")
+	node.store.Set("k1", store.Entry{Value: []byte("v"), Timestamp: 1})
+	node.store.Set("k2", store.Entry{Value: []byte("v"), Timestamp: 1})
+
+	t.Run("ReportsStatus", func(t *testing.T) {
+		resp, err := http.Get(node.server.URL + "/internal/heartbeat")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var status peers.HeartbeatStatus
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+		assert.Equal(t, "node-B", status.NodeID)
+		assert.Equal(t, 2, status.Keys)
+		assert.Equal(t, version.Version, status.Version)
+		assert.GreaterOrEqual(t, status.UptimeSeconds, int64(0))
+	})
+
+	t.Run("WorkerEnrichesPeerSnapshot", func(t *testing.T) {
+		nodeA := newTestNode(t, "node-A")
+		nodeA.peers.AddPeer(node.server.URL)
+
+		cfg := peers.DefaultPeerConfig()
+		cfg.Heartbeat.Interval = 10 * time.Millisecond
+
+		worker := peers.NewHeartbeatWorker(nodeA.peers, cfg, nodeA.metrics)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go worker.Start(ctx)
+
+		assert.Eventually(t, func() bool {
+			snaps := nodeA.peers.Snapshot()
+			return len(snaps) == 1 && snaps[0].NodeID == "node-B"
+		}, time.Second, 10*time.Millisecond)
+
+		resp, err := http.Get(nodeA.server.URL + "/admin/peers")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var snaps []peers.PeerSnapshot
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&snaps))
+		require.Len(t, snaps, 1)
+		assert.Equal(t, 2, snaps[0].Keys)
+	})
+
+	t.Run("MethodNotAllowed", func(t *testing.T) {
+		resp, err := http.Post(node.server.URL+"/internal/heartbeat", "application/json", nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+}
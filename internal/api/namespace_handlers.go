@@ -0,0 +1,220 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"distributed-cache/internal/replication"
+	"distributed-cache/internal/store"
+)
+
+// Namespaced requests mirror the default-namespace APIs under a prefix:
+// - /ns/{namespace}/kv/... serves every /kv/... endpoint
+// - /ns/{namespace}/keys serves GET /admin/keys
+// - /ns/{namespace}/watch serves GET /watch
+//
+// The namespace must exist; it is created through POST /admin/namespaces
+// or the namespaces section of the config.
+
+// namespaceContextKey carries the resolved *store.Namespace of a request.
+type namespaceContextKey struct{}
+
+// namespace returns the namespace a request addresses, the default one
+// unless it came through /ns/{namespace}/.
+func (h *Handler) namespace(r *http.Request) *store.Namespace {
+	if ns, ok := r.Context().Value(namespaceContextKey{}).(*store.Namespace); ok {
+		return ns
+	}
+	return h.store.Default()
+}
+
+// namespaceRequest resolves /ns/{namespace}/{rest} into a request for the
+// matching default-namespace path, carrying the namespace in its context.
+// It writes a 404 and returns false for an unknown namespace or path.
+func (h *Handler) namespaceRequest(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	name, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/ns/"), "/")
+
+	var path string
+	switch {
+	case strings.HasPrefix(rest, "kv/"):
+		path = "/" + rest
+	case rest == "keys":
+		path = "/admin/keys"
+	case rest == "watch":
+		path = "/watch"
+	default:
+		http.NotFound(w, r)
+		return nil, false
+	}
+
+	ns, ok := h.store.Namespace(name)
+	if !ok || name == "" {
+		http.Error(w, "namespace not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	r = r.WithContext(context.WithValue(r.Context(), namespaceContextKey{}, ns))
+	u := *r.URL
+	u.Path, u.RawPath = path, ""
+	r.URL = &u
+	return r, true
+}
+
+/* ---------------- GET, POST /admin/namespaces ---------------- */
+
+// namespaceSettings is the JSON form of store.NamespaceSettings.
+// Zero limits mean unbounded; an empty eviction_policy uses the store's.
+type namespaceSettings struct {
+	DefaultTTLms   int64  `json:"default_ttl_ms,omitempty"`
+	MaxEntries     int    `json:"max_entries,omitempty"`
+	MaxBytes       int64  `json:"max_bytes,omitempty"`
+	EvictionPolicy string `json:"eviction_policy,omitempty"`
+}
+
+type createNamespaceRequest struct {
+	Name string `json:"name"`
+	namespaceSettings
+}
+
+type namespaceInfo struct {
+	Name string `json:"name"`
+	namespaceSettings
+	Keys  int   `json:"keys"`
+	Bytes int64 `json:"bytes"`
+
+	// Metrics are the namespace's labeled metrics, reported by GET /admin/namespaces/{name}.
+	Metrics map[string]int64 `json:"metrics,omitempty"`
+}
+
+func newNamespaceInfo(ns *store.Namespace) namespaceInfo {
+	settings := ns.Settings()
+	return namespaceInfo{
+		Name: ns.Name(),
+		namespaceSettings: namespaceSettings{
+			DefaultTTLms:   settings.DefaultTTL.Milliseconds(),
+			MaxEntries:     settings.MaxEntries,
+			MaxBytes:       settings.MaxBytes,
+			EvictionPolicy: settings.EvictionPolicy,
+		},
+		Keys:  ns.Len(),
+		Bytes: ns.Bytes(),
+	}
+}
+
+// ListNamespaces returns every namespace, the default one included, by name.
+func (h *Handler) ListNamespaces(w http.ResponseWriter, r *http.Request) {
+	namespaces := h.store.Namespaces()
+
+	resp := make([]namespaceInfo, len(namespaces))
+	for i, ns := range namespaces {
+		resp[i] = newNamespaceInfo(ns)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string][]namespaceInfo{"namespaces": resp})
+}
+
+// CreateNamespace adds a namespace on this node.
+//
+// Request: {"name": "orders", "default_ttl_ms": N, "max_entries": N, "max_bytes": N, "eviction_policy": "lfu"}
+//
+// Create the namespace on every node: peers refuse replicated writes to
+// a namespace they do not have.
+//
+// Status codes:
+// - 201: the created namespace
+// - 400: malformed body, invalid name or settings
+// - 409: the namespace already exists
+func (h *Handler) CreateNamespace(w http.ResponseWriter, r *http.Request) {
+	var req createNamespaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+
+	ns, err := h.store.CreateNamespace(req.Name, store.NamespaceSettings{
+		DefaultTTL:     time.Duration(req.DefaultTTLms) * time.Millisecond,
+		MaxEntries:     req.MaxEntries,
+		MaxBytes:       req.MaxBytes,
+		EvictionPolicy: req.EvictionPolicy,
+	})
+	switch {
+	case errors.Is(err, store.ErrNamespaceExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(newNamespaceInfo(ns))
+}
+
+/* ---------------- GET /admin/namespaces/{name} ---------------- */
+
+// GetNamespace reports a namespace's settings, size and labeled metrics.
+func (h *Handler) GetNamespace(w http.ResponseWriter, r *http.Request) {
+	ns, ok := h.adminNamespace(w, r)
+	if !ok {
+		return
+	}
+
+	info := newNamespaceInfo(ns)
+	info.Metrics = ns.Metrics()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(info)
+}
+
+/* ---------------- POST /admin/namespaces/{name}/flush ---------------- */
+
+type flushResponse struct {
+	Namespace string `json:"namespace"`
+	Flushed   int    `json:"flushed"`
+}
+
+// FlushNamespace deletes every key of a namespace, keeping the namespace.
+//
+// The deletes are replicated in batches of up to maxBatchKeys keys, in the
+// background; the response does not wait for peers.
+func (h *Handler) FlushNamespace(w http.ResponseWriter, r *http.Request) {
+	ns, ok := h.adminNamespace(w, r)
+	if !ok {
+		return
+	}
+
+	flushed := ns.Flush()
+
+	ctx := context.WithoutCancel(r.Context())
+	for start := 0; start < len(flushed); start += maxBatchKeys {
+		chunk := flushed[start:min(start+maxBatchKeys, len(flushed))]
+
+		writes := make([]replication.Write, len(chunk))
+		for i, wr := range chunk {
+			writes[i] = replication.Write{Key: wr.Key, Entry: wr.Entry}
+		}
+		h.replicator.ReplicateBatch(ctx, ns.Name(), writes)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(flushResponse{Namespace: ns.Name(), Flushed: len(flushed)})
+}
+
+// adminNamespace resolves the {name} of /admin/namespaces/{name}[/...].
+// It writes a 404 and returns false for an unknown namespace.
+func (h *Handler) adminNamespace(w http.ResponseWriter, r *http.Request) (*store.Namespace, bool) {
+	name, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/admin/namespaces/"), "/")
+
+	ns, ok := h.store.Namespace(name)
+	if !ok || name == "" {
+		http.Error(w, "namespace not found", http.StatusNotFound)
+		return nil, false
+	}
+	return ns, true
+}
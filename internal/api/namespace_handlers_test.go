@@ -0,0 +1,279 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"distributed-cache/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func doRequest(t *testing.T, method, url, body string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func createNamespace(t *testing.T, node *testNode, body string) *http.Response {
+	t.Helper()
+	return doRequest(t, http.MethodPost, node.server.URL+"/admin/namespaces", body)
+}
+
+/* ---------------- /ns/{namespace}/... ---------------- */
+
+func TestNamespacedKV(t *testing.T) {
+	node := newTestNode(t, "node-A")
+	resp := createNamespace(t, node, `{"name":"orders"}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	base := node.server.URL + "/ns/orders"
+
+	resp = doRequest(t, http.MethodPut, base+"/kv/k", `{"value":"orders"}`)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp = doRequest(t, http.MethodPut, node.server.URL+"/kv/k", `{"value":"default"}`)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp = doRequest(t, http.MethodGet, base+"/kv/k", "")
+	var got getResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	require.NotNil(t, got.Value)
+	assert.Equal(t, "orders", *got.Value)
+
+	t.Run("Counters", func(t *testing.T) {
+		resp := doRequest(t, http.MethodPost, base+"/kv/hits/incr", `{"delta":5}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		_, ok := node.store.Get("hits")
+		assert.False(t, ok, "the counter lives in the namespace only")
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		resp, res := postBatch(t, base+"/kv/_mget", batchKeysRequest{Keys: []string{"k", "hits"}})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "orders", *res.Results[0].Value)
+		assert.Equal(t, "5", *res.Results[1].Value)
+	})
+
+	t.Run("Keys", func(t *testing.T) {
+		resp := doRequest(t, http.MethodGet, base+"/keys?values=false", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var list listResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+		require.Len(t, list.Items, 2)
+		assert.Equal(t, "hits", list.Items[0].Key)
+		assert.Equal(t, "k", list.Items[1].Key)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		resp := doRequest(t, http.MethodDelete, base+"/kv/k", "")
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+		resp = doRequest(t, http.MethodGet, base+"/kv/k", "")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		val, ok := node.store.Get("k")
+		require.True(t, ok)
+		assert.Equal(t, "default", string(val.Value))
+	})
+
+	t.Run("DefaultNamespaceByName", func(t *testing.T) {
+		resp := doRequest(t, http.MethodGet, node.server.URL+"/ns/default/kv/k", "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		for _, path := range []string{"/ns/missing/kv/k", "/ns/orders/other", "/ns//kv/k", "/ns/orders"} {
+			resp := doRequest(t, http.MethodGet, node.server.URL+path, "")
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+		}
+	})
+}
+
+func TestNamespacedKV_DefaultTTL(t *testing.T) {
+	node := newTestNode(t, "node-A")
+	resp := createNamespace(t, node, `{"name":"sessions","default_ttl_ms":50}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp = doRequest(t, http.MethodPut, node.server.URL+"/ns/sessions/kv/s", `{"value":"v"}`)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	assert.Eventually(t, func() bool {
+		resp := doRequest(t, http.MethodGet, node.server.URL+"/ns/sessions/kv/s", "")
+		return resp.StatusCode == http.StatusNotFound
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestNamespacedKV_Replication(t *testing.T) {
+	nodeA := newTestNode(t, "node-A")
+	nodeB := newTestNode(t, "node-B")
+	nodeA.peers.AddPeer(nodeB.server.URL)
+
+	for _, node := range []*testNode{nodeA, nodeB} {
+		resp := createNamespace(t, node, `{"name":"orders"}`)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+
+	resp := doRequest(t, http.MethodPut, nodeA.server.URL+"/ns/orders/kv/k?acks=all", `{"value":"v"}`)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	ns, ok := nodeB.store.Namespace("orders")
+	require.True(t, ok)
+	val, ok := ns.Get("k")
+	require.True(t, ok)
+	assert.Equal(t, "v", string(val.Value))
+
+	_, ok = nodeB.store.Get("k")
+	assert.False(t, ok)
+}
+
+/* ---------------- /admin/namespaces ---------------- */
+
+func TestCreateNamespace(t *testing.T) {
+	node := newTestNode(t, "node-A")
+
+	resp := createNamespace(t, node, `{"name":"images","max_bytes":1048576,"eviction_policy":"lfu"}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var info namespaceInfo
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&info))
+	assert.Equal(t, "images", info.Name)
+	assert.Equal(t, int64(1<<20), info.MaxBytes)
+	assert.Equal(t, "lfu", info.EvictionPolicy)
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"Exists", `{"name":"images"}`, http.StatusConflict},
+		{"Default", `{"name":"default"}`, http.StatusConflict},
+		{"InvalidName", `{"name":"Not Valid"}`, http.StatusBadRequest},
+		{"InvalidPolicy", `{"name":"x","eviction_policy":"fifo"}`, http.StatusBadRequest},
+		{"NegativeLimit", `{"name":"x","max_entries":-1}`, http.StatusBadRequest},
+		{"InvalidJSON", `{bad`, http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, createNamespace(t, node, tc.body).StatusCode)
+		})
+	}
+
+	resp = doRequest(t, http.MethodDelete, node.server.URL+"/admin/namespaces", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestListNamespaces(t *testing.T) {
+	node := newTestNode(t, "node-A")
+	createNamespace(t, node, `{"name":"orders","max_entries":100}`)
+	node.store.Set("k", store.Entry{Value: []byte("v"), Timestamp: 1})
+
+	resp := doRequest(t, http.MethodGet, node.server.URL+"/admin/namespaces", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var list map[string][]namespaceInfo
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	require.Len(t, list["namespaces"], 2)
+
+	def, orders := list["namespaces"][0], list["namespaces"][1]
+	assert.Equal(t, store.DefaultNamespace, def.Name)
+	assert.Equal(t, 1, def.Keys)
+	assert.Positive(t, def.Bytes)
+	assert.Equal(t, "orders", orders.Name)
+	assert.Equal(t, 100, orders.MaxEntries)
+	assert.Equal(t, 0, orders.Keys)
+	assert.Nil(t, orders.Metrics)
+}
+
+func TestGetNamespace(t *testing.T) {
+	node := newTestNode(t, "node-A")
+	createNamespace(t, node, `{"name":"orders","default_ttl_ms":60000}`)
+	doRequest(t, http.MethodPut, node.server.URL+"/ns/orders/kv/k", `{"value":"v"}`)
+	doRequest(t, http.MethodGet, node.server.URL+"/ns/orders/kv/missing", "")
+
+	resp := doRequest(t, http.MethodGet, node.server.URL+"/admin/namespaces/orders", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var info namespaceInfo
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&info))
+	assert.Equal(t, int64(60000), info.DefaultTTLms)
+	assert.Equal(t, 1, info.Keys)
+	assert.Equal(t, int64(1), info.Metrics["cache_sets_total"])
+	assert.Equal(t, int64(1), info.Metrics["cache_misses_total"])
+
+	resp = doRequest(t, http.MethodGet, node.server.URL+"/metrics", "")
+	body, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), `cache_sets_total{namespace=\"orders\"}`)
+
+	resp = doRequest(t, http.MethodGet, node.server.URL+"/admin/namespaces/missing", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestFlushNamespace(t *testing.T) {
+	nodeA := newTestNode(t, "node-A")
+	nodeB := newTestNode(t, "node-B")
+	nodeA.peers.AddPeer(nodeB.server.URL)
+
+	createNamespace(t, nodeA, `{"name":"orders"}`)
+	createNamespace(t, nodeB, `{"name":"orders"}`)
+	for _, key := range []string{"a", "b"} {
+		resp := doRequest(t, http.MethodPut, nodeA.server.URL+"/ns/orders/kv/"+key+"?acks=all", `{"value":"v"}`)
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	}
+	nodeA.store.Set("a", store.Entry{Value: []byte("default"), Timestamp: 1})
+
+	resp := doRequest(t, http.MethodPost, nodeA.server.URL+"/admin/namespaces/orders/flush", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var res flushResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+	assert.Equal(t, flushResponse{Namespace: "orders", Flushed: 2}, res)
+
+	ns, _ := nodeA.store.Namespace("orders")
+	assert.Equal(t, 0, ns.Len())
+	_, ok := nodeA.store.Get("a")
+	assert.True(t, ok, "other namespaces are untouched")
+
+	peerNS, ok := nodeB.store.Namespace("orders")
+	require.True(t, ok)
+	assert.Eventually(t, func() bool { return peerNS.Len() == 0 }, time.Second, 10*time.Millisecond)
+
+	resp = doRequest(t, http.MethodPost, nodeA.server.URL+"/admin/namespaces/missing/flush", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp = doRequest(t, http.MethodGet, nodeA.server.URL+"/admin/namespaces/orders/flush", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestReceiveReplication_UnknownNamespace(t *testing.T) {
+	node := newTestNode(t, "node-B")
+
+	for _, name := range []string{"orders", "Bad Name"} {
+		body, err := json.Marshal(map[string]any{
+			"namespace":        name,
+			"key":              "k",
+			"entry":            store.Entry{Value: []byte("v"), Timestamp: 1},
+			"original_node_id": "node-A",
+		})
+		require.NoError(t, err)
+
+		resp, err := http.Post(node.server.URL+"/internal/replicate", "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, name)
+	}
+
+	_, ok := node.store.Namespace("orders")
+	assert.False(t, ok, "replicated writes never create namespaces")
+}
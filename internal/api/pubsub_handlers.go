@@ -0,0 +1,197 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/pubsub"
+	"distributed-cache/internal/replication"
+)
+
+// pubsubChannel returns the {channel} of /pubsub/{channel}.
+func pubsubChannel(r *http.Request) string {
+	return strings.TrimPrefix(r.URL.Path, "/pubsub/")
+}
+
+/* ---------------- POST /pubsub/{channel} ---------------- */
+
+type publishRequest struct {
+	Message string `json:"message"`
+}
+
+type publishResponse struct {
+	// Receivers is the number of subscriptions on this node that received the message.
+	Receivers int `json:"receivers"`
+
+	// Peers is the number of healthy peers the message was forwarded to.
+	Peers int `json:"peers"`
+}
+
+// Publish sends a message to the subscribers of a channel on every node.
+//
+// Request: {"message": "..."}
+//
+// The message is delivered to local subscribers before responding and
+// forwarded to peers in the background. Like Redis pub/sub, messages are
+// not stored: only current subscribers receive them.
+//
+// Status codes:
+// - 200: published, with the receiver counts
+// - 400: empty channel or malformed body
+func (h *Handler) Publish(w http.ResponseWriter, r *http.Request) {
+	channel := pubsubChannel(r)
+	if channel == "" {
+		http.Error(w, "channel must not be empty", http.StatusBadRequest)
+		return
+	}
+
+	var req publishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+
+	message := []byte(req.Message)
+	receivers := h.broker.Publish(channel, message)
+	res := h.replicator.Publish(context.WithoutCancel(r.Context()), channel, message)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(publishResponse{Receivers: receivers, Peers: res.Peers})
+}
+
+/* ---------------- GET /pubsub/{channel} ---------------- */
+
+// pubsubMessage is the data of one SSE message event.
+type pubsubMessage struct {
+	Channel string `json:"channel"`
+	Pattern string `json:"pattern,omitempty"`
+	Message string `json:"message"`
+	Time    int64  `json:"time"`
+}
+
+// Subscribe streams the messages of a channel as Server-Sent Events.
+//
+// Query parameters:
+// - pattern=true: treat {channel} as a glob pattern, e.g. news.* (see util.Match)
+//
+// Each message is a "message" event with a JSON pubsubMessage as data.
+// Messages published on peers are received too. A client too slow to
+// keep up receives an error event and is disconnected.
+//
+// Status codes:
+// - 200: the event stream
+// - 400: empty channel or malformed pattern parameter
+// - 503: the node is shutting down
+func (h *Handler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	channel := pubsubChannel(r)
+	if channel == "" {
+		http.Error(w, "channel must not be empty", http.StatusBadRequest)
+		return
+	}
+
+	pattern := false
+	if v := r.URL.Query().Get("pattern"); v != "" {
+		var err error
+		if pattern, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "pattern must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	sub, err := h.broker.NewSubscription(pubsub.DefaultBuffer)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer sub.Close()
+
+	if pattern {
+		sub.PSubscribe(channel)
+	} else {
+		sub.Subscribe(channel)
+	}
+
+	stream, err := startSSE(w)
+	if err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-keepAlive.C:
+			if stream.KeepAlive() != nil {
+				return
+			}
+
+		case msg, ok := <-sub.Messages():
+			if !ok {
+				if err := sub.Err(); errors.Is(err, pubsub.ErrSlowConsumer) {
+					_ = stream.Send("", "error", sseError{Error: err.Error()})
+				}
+				return
+			}
+
+			err := stream.Send("", "message", pubsubMessage{
+				Channel: msg.Channel,
+				Pattern: msg.Pattern,
+				Message: string(msg.Payload),
+				Time:    msg.Time.UnixNano(),
+			})
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+/* ---------------- POST /internal/pubsub ---------------- */
+
+// ReceivePublish delivers a message published on a peer to this node's
+// subscribers; see ApplyPublish.
+//
+// Status codes:
+// - 204: delivered
+// - 400: malformed payload
+// - 409: payload originated on this node
+func (h *Handler) ReceivePublish(w http.ResponseWriter, r *http.Request) {
+	var payload replication.PublishPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "invalid publish payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.ApplyPublish(payload); err != nil {
+		writeReplicationError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ApplyPublish delivers a message published on a peer to this node's
+// subscribers. Messages are never forwarded again; only the origin fans out.
+func (h *Handler) ApplyPublish(payload replication.PublishPayload) error {
+	if payload.Channel == "" || payload.OriginalNodeID == "" {
+		return fmt.Errorf("%w: missing channel or origin", replication.ErrInvalidPayload)
+	}
+
+	if payload.OriginalNodeID == h.nodeID {
+		return errOwnPayload
+	}
+
+	h.metrics.Inc(metrics.PubSubPeerMessagesTotal)
+	h.broker.Publish(payload.Channel, payload.Message)
+	return nil
+}
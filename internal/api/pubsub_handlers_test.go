@@ -0,0 +1,127 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"distributed-cache/internal/replication"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func publish(t *testing.T, node *testNode, channel, message string) publishResponse {
+	t.Helper()
+
+	body, err := json.Marshal(publishRequest{Message: message})
+	require.NoError(t, err)
+	resp := doRequest(t, http.MethodPost, node.server.URL+"/pubsub/"+channel, string(body))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var res publishResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+	return res
+}
+
+func decodePubSubMessage(t *testing.T, ev sseEvent) pubsubMessage {
+	t.Helper()
+
+	require.Equal(t, "message", ev.event)
+	var msg pubsubMessage
+	require.NoError(t, json.Unmarshal([]byte(ev.data), &msg))
+	return msg
+}
+
+func TestPubSub(t *testing.T) {
+	node := newTestNode(t, "node-A")
+
+	resp, stream := openSSE(t, node.server.URL+"/pubsub/news", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	assert.Equal(t, publishResponse{Receivers: 0}, publish(t, node, "weather", "rain"))
+	assert.Equal(t, publishResponse{Receivers: 1}, publish(t, node, "news", "hello"))
+
+	msg := decodePubSubMessage(t, nextEvent(t, stream))
+	assert.Equal(t, "news", msg.Channel)
+	assert.Empty(t, msg.Pattern)
+	assert.Equal(t, "hello", msg.Message)
+	assert.Positive(t, msg.Time)
+}
+
+func TestPubSub_Pattern(t *testing.T) {
+	node := newTestNode(t, "node-A")
+
+	_, stream := openSSE(t, node.server.URL+"/pubsub/news.*?pattern=true", nil)
+
+	publish(t, node, "weather", "rain")
+	publish(t, node, "news.sport", "goal")
+
+	msg := decodePubSubMessage(t, nextEvent(t, stream))
+	assert.Equal(t, "news.sport", msg.Channel)
+	assert.Equal(t, "news.*", msg.Pattern)
+	assert.Equal(t, "goal", msg.Message)
+}
+
+func TestPubSub_ForwardedToPeers(t *testing.T) {
+	nodeA := newTestNode(t, "node-A")
+	nodeB := newTestNode(t, "node-B")
+	nodeA.peers.AddPeer(nodeB.server.URL)
+
+	_, stream := openSSE(t, nodeB.server.URL+"/pubsub/news", nil)
+
+	res := publish(t, nodeA, "news", "hello")
+	assert.Equal(t, publishResponse{Receivers: 0, Peers: 1}, res)
+
+	msg := decodePubSubMessage(t, nextEvent(t, stream))
+	assert.Equal(t, "hello", msg.Message)
+}
+
+func TestPubSub_BadRequests(t *testing.T) {
+	node := newTestNode(t, "node-A")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"PublishEmptyChannel", http.MethodPost, "/pubsub/", `{"message":"m"}`, http.StatusBadRequest},
+		{"PublishInvalidJSON", http.MethodPost, "/pubsub/news", `{bad`, http.StatusBadRequest},
+		{"SubscribeEmptyChannel", http.MethodGet, "/pubsub/", "", http.StatusBadRequest},
+		{"SubscribeInvalidPattern", http.MethodGet, "/pubsub/news?pattern=maybe", "", http.StatusBadRequest},
+		{"MethodNotAllowed", http.MethodPut, "/pubsub/news", "", http.StatusMethodNotAllowed},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := doRequest(t, tc.method, node.server.URL+tc.path, tc.body)
+			assert.Equal(t, tc.want, resp.StatusCode)
+		})
+	}
+}
+
+func TestReceivePublish(t *testing.T) {
+	node := newTestNode(t, "node-B")
+
+	post := func(payload replication.PublishPayload) int {
+		body, err := json.Marshal(payload)
+		require.NoError(t, err)
+
+		resp, err := http.Post(node.server.URL+"/internal/pubsub", "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	_, stream := openSSE(t, node.server.URL+"/pubsub/news", nil)
+
+	assert.Equal(t, http.StatusNoContent, post(replication.PublishPayload{Channel: "news", Message: []byte("m"), OriginalNodeID: "node-A"}))
+	assert.Equal(t, "m", decodePubSubMessage(t, nextEvent(t, stream)).Message)
+
+	assert.Equal(t, http.StatusConflict, post(replication.PublishPayload{Channel: "news", OriginalNodeID: "node-B"}))
+	assert.Equal(t, http.StatusBadRequest, post(replication.PublishPayload{Channel: "news"}))
+	assert.Equal(t, http.StatusBadRequest, post(replication.PublishPayload{OriginalNodeID: "node-A"}))
+}
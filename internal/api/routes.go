@@ -1,10 +1,13 @@
 package api
 
-import "net/http"
+import (
+	"net/http"
+	"strings"
+)
 
 func RegisterRoutes(mux *http.ServeMux, h *Handler) http.Handler {
 	// KV APIs
-	mux.HandleFunc("/kv/", func(w http.ResponseWriter, r *http.Request) {
+	kv := func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodPut:
 			h.SetKey(w, r)
@@ -12,13 +15,99 @@ func RegisterRoutes(mux *http.ServeMux, h *Handler) http.Handler {
 			h.GetKey(w, r)
 		case http.MethodDelete:
 			h.DeleteKey(w, r)
+		case http.MethodPost:
+			switch {
+			case r.URL.Path == "/kv/_mget":
+				h.MultiGet(w, r)
+			case r.URL.Path == "/kv/_mset":
+				h.MultiSet(w, r)
+			case r.URL.Path == "/kv/_mdelete":
+				h.MultiDelete(w, r)
+			case strings.HasSuffix(r.URL.Path, "/incr"), strings.HasSuffix(r.URL.Path, "/decr"):
+				h.IncrKey(w, r)
+			default:
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			}
 		default:
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		}
+	}
+	mux.HandleFunc("/kv/", kv)
+
+	// Change notifications (Server-Sent Events)
+	watch := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.Watch(w, r)
+	}
+	mux.HandleFunc("/watch", watch)
+
+	// Pub/sub channels
+	mux.HandleFunc("/pubsub/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			h.Publish(w, r)
+		case http.MethodGet:
+			h.Subscribe(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	// Namespaced APIs: /ns/{namespace}/kv/..., /ns/{namespace}/keys, /ns/{namespace}/watch
+	mux.HandleFunc("/ns/", func(w http.ResponseWriter, r *http.Request) {
+		r, ok := h.namespaceRequest(w, r)
+		if !ok {
+			return
+		}
+		switch r.URL.Path {
+		case "/admin/keys":
+			h.ListKeys(w, r)
+		case "/watch":
+			watch(w, r)
+		default:
+			kv(w, r)
+		}
 	})
 
 	// Admin APIs
 	mux.HandleFunc("/admin/keys", h.ListKeys)
+	mux.HandleFunc("/admin/namespaces", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			h.ListNamespaces(w, r)
+		case http.MethodPost:
+			h.CreateNamespace(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/admin/namespaces/", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && !strings.Contains(strings.TrimPrefix(r.URL.Path, "/admin/namespaces/"), "/"):
+			h.GetNamespace(w, r)
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/flush"):
+			h.FlushNamespace(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/admin/config/reload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.ReloadConfig(w, r)
+	})
+	mux.HandleFunc("/admin/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.TakeSnapshot(w, r)
+	})
 
 	// Observability APIs
 	mux.HandleFunc("/metrics", h.GetMetrics)
@@ -26,6 +115,37 @@ func RegisterRoutes(mux *http.ServeMux, h *Handler) http.Handler {
 	// Admin APIs
 	mux.HandleFunc("/admin/peers", h.GetPeers)
 
+	// Internal (node-to-node) APIs
+	mux.HandleFunc("/internal/replicate", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.ReceiveReplication(w, r)
+	})
+	mux.HandleFunc("/internal/replicate/batch", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.ReceiveReplicationBatch(w, r)
+	})
+	mux.HandleFunc("/internal/pubsub", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.ReceivePublish(w, r)
+	})
+
+	mux.HandleFunc("/internal/heartbeat", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.Heartbeat(w, r)
+	})
+
 	// Middlewares
 	return Chain(
 		mux,
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// sseKeepAlive is the interval of comments sent on idle event streams,
+// so proxies do not close them.
+const sseKeepAlive = 15 * time.Second
+
+// sseStream writes Server-Sent Events, flushing each one to the client.
+type sseStream struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// startSSE sends the headers of an event stream.
+func startSSE(w http.ResponseWriter) (*sseStream, error) {
+	s := &sseStream{w: w, rc: http.NewResponseController(w)}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	return s, s.rc.Flush()
+}
+
+// Send writes one event with data encoded as JSON; an empty id is omitted.
+func (s *sseStream) Send(id, event string, data any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if id != "" {
+		if _, err := fmt.Fprintf(s.w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, body); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// KeepAlive writes a comment, which clients ignore.
+func (s *sseStream) KeepAlive() error {
+	if _, err := fmt.Fprint(s.w, ": keep-alive\n\n"); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// sseError is the data of the error event sent before a stream is cut.
+type sseError struct {
+	Error string `json:"error"`
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"distributed-cache/internal/store"
+)
+
+// lastEventIDHeader is sent by SSE clients reconnecting to a stream.
+const lastEventIDHeader = "Last-Event-ID"
+
+// watchEvent is the data of one SSE event.
+type watchEvent struct {
+	Seq       uint64 `json:"seq"`
+	Type      string `json:"type"`
+	Namespace string `json:"namespace"`
+	Key       string `json:"key"`
+	Version   uint64 `json:"version"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+/* ---------------- GET /watch ---------------- */
+
+// Watch streams changes to keys as Server-Sent Events.
+// It is served as GET /watch for the default namespace and as
+// GET /ns/{namespace}/watch for any other.
+//
+// Query parameters:
+// - prefix: only keys starting with it
+// - after: resume after this sequence number (the Last-Event-ID header works too)
+//
+// Every event has the type set, delete or expire, its sequence number as
+// id and a JSON watchEvent as data. A client too slow to keep up receives
+// an error event and is disconnected; it can reconnect with the last id
+// it saw, as long as the node still retains the events that followed.
+//
+// Status codes:
+// - 200: the event stream
+// - 400: malformed sequence number, or resuming while store.watch_history is 0
+// - 410: the events after the requested sequence number are gone
+// - 503: the node is shutting down
+func (h *Handler) Watch(w http.ResponseWriter, r *http.Request) {
+	opts := store.WatchOptions{
+		Namespace: h.namespace(r).Name(),
+		Prefix:    r.URL.Query().Get("prefix"),
+	}
+
+	after := r.URL.Query().Get("after")
+	if after == "" {
+		after = r.Header.Get(lastEventIDHeader)
+	}
+	if after != "" {
+		seq, err := strconv.ParseUint(after, 10, 64)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid sequence number %q", after), http.StatusBadRequest)
+			return
+		}
+		opts.After = seq
+	}
+
+	watcher, err := h.store.Watch(opts)
+	switch {
+	case errors.Is(err, store.ErrNoWatchHistory):
+		http.Error(w, "cannot resume: store.watch_history is 0, so no events are retained", http.StatusBadRequest)
+		return
+	case errors.Is(err, store.ErrWatchGap):
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer watcher.Close()
+
+	stream, err := startSSE(w)
+	if err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-keepAlive.C:
+			if stream.KeepAlive() != nil {
+				return
+			}
+
+		case ev, ok := <-watcher.Events():
+			if !ok {
+				if err := watcher.Err(); errors.Is(err, store.ErrSlowConsumer) {
+					_ = stream.Send("", "error", sseError{Error: err.Error()})
+				}
+				return
+			}
+
+			err := stream.Send(strconv.FormatUint(ev.Seq, 10), string(ev.Type), watchEvent{
+				Seq:       ev.Seq,
+				Type:      string(ev.Type),
+				Namespace: ev.Namespace,
+				Key:       ev.Key,
+				Version:   ev.Version,
+				Timestamp: ev.Timestamp,
+			})
+			if err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"distributed-cache/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sseEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+// openSSE starts an SSE request; the stream is closed when the test ends.
+func openSSE(t *testing.T, url string, header http.Header) (*http.Response, *bufio.Reader) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp, bufio.NewReader(resp.Body)
+}
+
+// nextEvent reads the next SSE event, skipping comments.
+func nextEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+
+	var ev sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case line == "":
+			if ev != (sseEvent{}) {
+				return ev
+			}
+		case strings.HasPrefix(line, ":"):
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			ev.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			ev.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func decodeWatchEvent(t *testing.T, ev sseEvent) watchEvent {
+	t.Helper()
+
+	var data watchEvent
+	require.NoError(t, json.Unmarshal([]byte(ev.data), &data))
+	return data
+}
+
+func TestWatch(t *testing.T) {
+	node := newTestNode(t, "node-A")
+
+	resp, stream := openSSE(t, node.server.URL+"/watch", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	doRequest(t, http.MethodPut, node.server.URL+"/kv/k", `{"value":"v"}`)
+	doRequest(t, http.MethodDelete, node.server.URL+"/kv/k", "")
+
+	ev := nextEvent(t, stream)
+	assert.Equal(t, "1", ev.id)
+	assert.Equal(t, "set", ev.event)
+	data := decodeWatchEvent(t, ev)
+	assert.Equal(t, watchEvent{Seq: 1, Type: "set", Namespace: store.DefaultNamespace, Key: "k", Version: 1, Timestamp: data.Timestamp}, data)
+	assert.Positive(t, data.Timestamp)
+
+	ev = nextEvent(t, stream)
+	assert.Equal(t, "2", ev.id)
+	assert.Equal(t, "delete", ev.event)
+	assert.Equal(t, uint64(2), decodeWatchEvent(t, ev).Version)
+}
+
+func TestWatch_Prefix(t *testing.T) {
+	node := newTestNode(t, "node-A")
+
+	_, stream := openSSE(t, node.server.URL+"/watch?prefix=user:", nil)
+
+	doRequest(t, http.MethodPut, node.server.URL+"/kv/order:1", `{"value":"v"}`)
+	doRequest(t, http.MethodPut, node.server.URL+"/kv/user:1", `{"value":"v"}`)
+
+	ev := nextEvent(t, stream)
+	assert.Equal(t, "2", ev.id)
+	assert.Equal(t, "user:1", decodeWatchEvent(t, ev).Key)
+}
+
+func TestWatch_Resume(t *testing.T) {
+	node := newTestNode(t, "node-A")
+	for _, key := range []string{"a", "b", "c"} {
+		node.store.Set(key, store.Entry{Value: []byte("v"), Timestamp: 1})
+	}
+
+	t.Run("LastEventID", func(t *testing.T) {
+		_, stream := openSSE(t, node.server.URL+"/watch", http.Header{lastEventIDHeader: {"1"}})
+
+		assert.Equal(t, "b", decodeWatchEvent(t, nextEvent(t, stream)).Key)
+		assert.Equal(t, "c", decodeWatchEvent(t, nextEvent(t, stream)).Key)
+	})
+
+	t.Run("AfterParameter", func(t *testing.T) {
+		_, stream := openSSE(t, node.server.URL+"/watch?after=2", nil)
+
+		assert.Equal(t, "c", decodeWatchEvent(t, nextEvent(t, stream)).Key)
+	})
+
+	t.Run("Gap", func(t *testing.T) {
+		resp := doRequest(t, http.MethodGet, node.server.URL+"/watch?after=10", "")
+		assert.Equal(t, http.StatusGone, resp.StatusCode)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		resp := doRequest(t, http.MethodGet, node.server.URL+"/watch?after=abc", "")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestWatch_ResumeWithDefaultConfig(t *testing.T) {
+	// The test server's store keeps the default watch history: none.
+	server := setUpTestServer()
+	defer server.Close()
+
+	resp := doRequest(t, http.MethodGet, server.URL+"/watch?after=1", "")
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, string(body), "store.watch_history")
+}
+
+func TestWatch_Namespace(t *testing.T) {
+	node := newTestNode(t, "node-A")
+	createNamespace(t, node, `{"name":"orders"}`)
+
+	resp, stream := openSSE(t, node.server.URL+"/ns/orders/watch", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	doRequest(t, http.MethodPut, node.server.URL+"/kv/k", `{"value":"default"}`)
+	doRequest(t, http.MethodPut, node.server.URL+"/ns/orders/kv/k", `{"value":"orders"}`)
+
+	data := decodeWatchEvent(t, nextEvent(t, stream))
+	assert.Equal(t, "orders", data.Namespace)
+	assert.Equal(t, uint64(2), data.Seq)
+
+	resp = doRequest(t, http.MethodGet, node.server.URL+"/ns/missing/watch", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestWatch_Stopped(t *testing.T) {
+	node := newTestNode(t, "node-A")
+
+	_, stream := openSSE(t, node.server.URL+"/watch", nil)
+	node.store.StopWatches()
+
+	_, err := stream.ReadString('\n')
+	assert.Error(t, err, "the stream ends")
+
+	resp := doRequest(t, http.MethodGet, node.server.URL+"/watch", "")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	resp = doRequest(t, http.MethodPost, node.server.URL+"/watch", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
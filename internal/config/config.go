@@ -1,5 +1,509 @@
 package config
 
-// Config will hold application-wide configuration.
-// This file will be expanded in later phases.
-type Config struct{}
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"distributed-cache/internal/logs"
+	"distributed-cache/internal/peers"
+	"distributed-cache/internal/replication"
+	"distributed-cache/internal/store"
+	"distributed-cache/internal/ttl"
+	"distributed-cache/internal/wal"
+)
+
+// Config holds application-wide configuration.
+//
+// Values are resolved with the following precedence (highest first):
+// command-line flags, environment variables, config file, defaults.
+type Config struct {
+	ListenAddr string           `yaml:"listen_addr" json:"listen_addr"`
+	NodeID     string           `yaml:"node_id" json:"node_id"`
+	Peers      []string         `yaml:"peers" json:"peers"`
+	Peer       PeerSettings     `yaml:"peer" json:"peer"`
+	TTL        TTLSettings      `yaml:"ttl" json:"ttl"`
+	Store      StoreSettings    `yaml:"store" json:"store"`
+	Log        LogSettings      `yaml:"log" json:"log"`
+	Snapshot   SnapshotSettings `yaml:"snapshot" json:"snapshot"`
+	WAL        WALSettings      `yaml:"wal" json:"wal"`
+	Shutdown   ShutdownSettings `yaml:"shutdown" json:"shutdown"`
+	RESP       RESPSettings     `yaml:"resp" json:"resp"`
+	Memcache   MemcacheSettings `yaml:"memcache" json:"memcache"`
+
+	Replication ReplicationSettings `yaml:"replication" json:"replication"`
+
+	// Namespaces are created at startup, in addition to the default one.
+	Namespaces []NamespaceSettings `yaml:"namespaces" json:"namespaces"`
+}
+
+// PeerSettings mirrors peers.PeerConfig in a file-friendly form.
+type PeerSettings struct {
+	Retry     RetrySettings     `yaml:"retry" json:"retry"`
+	Timeout   TimeoutSettings   `yaml:"timeout" json:"timeout"`
+	Health    HealthSettings    `yaml:"health" json:"health"`
+	Heartbeat HeartbeatSettings `yaml:"heartbeat" json:"heartbeat"`
+}
+
+type RetrySettings struct {
+	MaxRetries  int      `yaml:"max_retries" json:"max_retries"`
+	BaseBackoff Duration `yaml:"base_backoff" json:"base_backoff"`
+	MaxBackoff  Duration `yaml:"max_backoff" json:"max_backoff"`
+}
+
+type TimeoutSettings struct {
+	Replication Duration `yaml:"replication" json:"replication"`
+	Heartbeat   Duration `yaml:"heartbeat" json:"heartbeat"`
+}
+
+type HealthSettings struct {
+	FailureThreshold int `yaml:"failure_threshold" json:"failure_threshold"`
+	SuccessThreshold int `yaml:"success_threshold" json:"success_threshold"`
+}
+
+type HeartbeatSettings struct {
+	Interval Duration `yaml:"interval" json:"interval"`
+}
+
+// TTLSettings controls the background TTL cleaner.
+type TTLSettings struct {
+	Interval       Duration `yaml:"interval" json:"interval"`
+	TombstoneGrace Duration `yaml:"tombstone_grace" json:"tombstone_grace"`
+}
+
+// StoreSettings partitions and bounds the in-memory store.
+// Zero limits mean unbounded.
+type StoreSettings struct {
+	Shards     int      `yaml:"shards" json:"shards"`
+	MaxEntries int      `yaml:"max_entries" json:"max_entries"`
+	MaxBytes   ByteSize `yaml:"max_bytes" json:"max_bytes"`
+
+	// EvictionPolicy is one of store.PolicyNames.
+	EvictionPolicy string `yaml:"eviction_policy" json:"eviction_policy"`
+
+	// WatchHistory is the number of recent events kept so that watches
+	// can resume after a disconnect; zero (the default) disables resuming.
+	WatchHistory int `yaml:"watch_history" json:"watch_history"`
+}
+
+// NamespaceSettings declares one namespace and its rules.
+// Zero limits mean unbounded; an empty eviction_policy uses store.eviction_policy.
+type NamespaceSettings struct {
+	Name           string   `yaml:"name" json:"name"`
+	DefaultTTL     Duration `yaml:"default_ttl" json:"default_ttl"`
+	MaxEntries     int      `yaml:"max_entries" json:"max_entries"`
+	MaxBytes       ByteSize `yaml:"max_bytes" json:"max_bytes"`
+	EvictionPolicy string   `yaml:"eviction_policy" json:"eviction_policy"`
+}
+
+// StoreSettings converts the namespace rules into the form used at runtime.
+func (n NamespaceSettings) StoreSettings() store.NamespaceSettings {
+	return store.NamespaceSettings{
+		DefaultTTL:     time.Duration(n.DefaultTTL),
+		MaxEntries:     n.MaxEntries,
+		MaxBytes:       int64(n.MaxBytes),
+		EvictionPolicy: n.EvictionPolicy,
+	}
+}
+
+// LogSettings controls the in-memory logger.
+type LogSettings struct {
+	Level      string `yaml:"level" json:"level"`
+	BufferSize int    `yaml:"buffer_size" json:"buffer_size"`
+}
+
+// ShutdownSettings controls graceful shutdown.
+type ShutdownSettings struct {
+	// Timeout bounds the whole shutdown: draining HTTP requests and
+	// in-flight replication.
+	Timeout Duration `yaml:"timeout" json:"timeout"`
+}
+
+// SnapshotSettings controls store persistence.
+// With snapshots enabled the node restores the newest valid snapshot at
+// startup and writes a final one on shutdown.
+type SnapshotSettings struct {
+	// Dir holds the snapshot files; empty disables snapshots.
+	Dir string `yaml:"dir" json:"dir"`
+
+	// Interval between periodic snapshots; zero disables them.
+	Interval Duration `yaml:"interval" json:"interval"`
+
+	// Retain is the number of snapshot files kept.
+	Retain int `yaml:"retain" json:"retain"`
+}
+
+// WALSettings controls the write-ahead log.
+// The log is compacted into snapshots, so it requires snapshot.dir.
+type WALSettings struct {
+	// Dir holds the log segments; empty disables the log.
+	Dir string `yaml:"dir" json:"dir"`
+
+	// Sync is the fsync policy: always, every-second or never.
+	Sync string `yaml:"sync" json:"sync"`
+
+	// CompactBytes is the log size that triggers compaction.
+	CompactBytes ByteSize `yaml:"compact_bytes" json:"compact_bytes"`
+}
+
+// RESPSettings controls the Redis protocol (RESP) listener.
+type RESPSettings struct {
+	// ListenAddr is the TCP address for Redis clients, e.g. ":6379"; empty disables it.
+	ListenAddr string `yaml:"listen_addr" json:"listen_addr"`
+}
+
+// MemcacheSettings controls the memcached protocol listener.
+type MemcacheSettings struct {
+	// ListenAddr is the TCP address for memcached clients, e.g. ":11211"; empty disables it.
+	ListenAddr string `yaml:"listen_addr" json:"listen_addr"`
+}
+
+// ReplicationSettings controls the binary replication transport, the
+// per-peer outbound queues and the hints kept for unhealthy peers. Peers
+// without a binary address are reached over HTTP/JSON.
+type ReplicationSettings struct {
+	// ListenAddr is the TCP address for binary replication from peers, e.g. ":7946"; empty disables it.
+	ListenAddr string `yaml:"listen_addr" json:"listen_addr"`
+
+	// BinaryPeers maps peer base URLs to the listen_addr of their binary replication server.
+	BinaryPeers map[string]string `yaml:"binary_peers" json:"binary_peers"`
+
+	// QueueSize is the number of distinct keys queued per peer before writers wait.
+	QueueSize int `yaml:"queue_size" json:"queue_size"`
+
+	// QueueMaxBytes bounds the keys and values queued per peer before writers wait.
+	QueueMaxBytes ByteSize `yaml:"queue_max_bytes" json:"queue_max_bytes"`
+
+	// BatchMaxWrites is the most writes sent to a peer in one request.
+	BatchMaxWrites int `yaml:"batch_max_writes" json:"batch_max_writes"`
+
+	// BatchMaxBytes bounds the keys and values of one batch.
+	BatchMaxBytes ByteSize `yaml:"batch_max_bytes" json:"batch_max_bytes"`
+
+	// BatchLinger is how long a batch waits to fill; zero sends right away.
+	BatchLinger Duration `yaml:"batch_linger" json:"batch_linger"`
+
+	// HintsDir keeps hints on disk across restarts; empty keeps them in memory only.
+	HintsDir string `yaml:"hints_dir" json:"hints_dir"`
+
+	// HintMax is the most hints kept per unhealthy peer; zero disables hinted handoff.
+	HintMax int `yaml:"hint_max" json:"hint_max"`
+
+	// HintMaxBytes bounds the keys and values hinted per peer.
+	HintMaxBytes ByteSize `yaml:"hint_max_bytes" json:"hint_max_bytes"`
+
+	// HintTTL is how long a hint is kept. While hinted handoff is enabled
+	// it must be set and at most ttl.tombstone_grace: a hint replayed after
+	// the peers purged the tombstone of a later delete brings the key back.
+	HintTTL Duration `yaml:"hint_ttl" json:"hint_ttl"`
+}
+
+// Duration is a time.Duration that reads and writes strings like "5s".
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// ByteSize is a byte count that reads strings like "64MiB", "1GB" or "4096".
+type ByteSize int64
+
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9},
+	{"B", 1},
+}
+
+// ParseByteSize parses a byte count with an optional unit suffix.
+func ParseByteSize(s string) (ByteSize, error) {
+	s = strings.TrimSpace(s)
+	number, multiplier := s, int64(1)
+	for _, unit := range byteUnits {
+		if strings.HasSuffix(s, unit.suffix) {
+			number, multiplier = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix)), unit.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	return ByteSize(n * multiplier), nil
+}
+
+func (b ByteSize) String() string {
+	return strconv.FormatInt(int64(b), 10)
+}
+
+func (b ByteSize) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	parsed, err := ParseByteSize(string(text))
+	if err != nil {
+		return err
+	}
+	*b = parsed
+	return nil
+}
+
+// UnmarshalJSON accepts both plain numbers and strings with a unit.
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	return b.UnmarshalText(bytes.Trim(data, `"`))
+}
+
+// Default returns the configuration used when nothing is overridden.
+func Default() Config {
+	peerDefaults := peers.DefaultPeerConfig()
+	queueDefaults := replication.DefaultQueueConfig()
+	hintDefaults := replication.DefaultHintConfig()
+
+	return Config{
+		ListenAddr: ":8080",
+		NodeID:     "node-1",
+		Peers:      []string{},
+		Peer: PeerSettings{
+			Retry: RetrySettings{
+				MaxRetries:  peerDefaults.Retry.MaxRetries,
+				BaseBackoff: Duration(peerDefaults.Retry.BaseBackoff),
+				MaxBackoff:  Duration(peerDefaults.Retry.MaxBackoff),
+			},
+			Timeout: TimeoutSettings{
+				Replication: Duration(peerDefaults.Timeout.ReplicationTimeout),
+				Heartbeat:   Duration(peerDefaults.Timeout.HeartbeatTimeout),
+			},
+			Health: HealthSettings{
+				FailureThreshold: peerDefaults.Health.FailureThreshold,
+				SuccessThreshold: peerDefaults.Health.SuccessThreshold,
+			},
+			Heartbeat: HeartbeatSettings{
+				Interval: Duration(peerDefaults.Heartbeat.Interval),
+			},
+		},
+		TTL: TTLSettings{
+			Interval:       Duration(5 * time.Second),
+			TombstoneGrace: Duration(ttl.DefaultTombstoneGrace),
+		},
+		Store: StoreSettings{
+			Shards:         store.DefaultShards,
+			EvictionPolicy: store.PolicyLRU,
+			WatchHistory:   store.DefaultWatchHistory,
+		},
+		Log: LogSettings{
+			Level:      string(logs.DEBUG),
+			BufferSize: 1000,
+		},
+		Snapshot: SnapshotSettings{
+			Interval: Duration(5 * time.Minute),
+			Retain:   3,
+		},
+		WAL: WALSettings{
+			Sync:         string(wal.SyncEverySecond),
+			CompactBytes: 64 << 20,
+		},
+		Shutdown: ShutdownSettings{
+			Timeout: Duration(15 * time.Second),
+		},
+		Replication: ReplicationSettings{
+			BinaryPeers:    map[string]string{},
+			QueueSize:      queueDefaults.Size,
+			QueueMaxBytes:  ByteSize(queueDefaults.MaxBytes),
+			BatchMaxWrites: queueDefaults.MaxBatch,
+			BatchMaxBytes:  ByteSize(queueDefaults.MaxBatchBytes),
+			BatchLinger:    Duration(queueDefaults.Linger),
+			HintMax:        hintDefaults.MaxHints,
+			HintMaxBytes:   ByteSize(hintDefaults.MaxBytes),
+			HintTTL:        Duration(hintDefaults.TTL),
+		},
+		Namespaces: []NamespaceSettings{},
+	}
+}
+
+// PeerConfig converts the peer settings into the policies used at runtime.
+// Retry jitter keeps the peers package default.
+func (c Config) PeerConfig() peers.PeerConfig {
+	cfg := peers.DefaultPeerConfig()
+
+	cfg.Retry.MaxRetries = c.Peer.Retry.MaxRetries
+	cfg.Retry.BaseBackoff = time.Duration(c.Peer.Retry.BaseBackoff)
+	cfg.Retry.MaxBackoff = time.Duration(c.Peer.Retry.MaxBackoff)
+	cfg.Timeout.ReplicationTimeout = time.Duration(c.Peer.Timeout.Replication)
+	cfg.Timeout.HeartbeatTimeout = time.Duration(c.Peer.Timeout.Heartbeat)
+	cfg.Health.FailureThreshold = c.Peer.Health.FailureThreshold
+	cfg.Health.SuccessThreshold = c.Peer.Health.SuccessThreshold
+	cfg.Heartbeat.Interval = time.Duration(c.Peer.Heartbeat.Interval)
+
+	return cfg
+}
+
+// ReplicationQueueConfig converts the replication settings into the
+// per-peer queue limits.
+func (c Config) ReplicationQueueConfig() replication.QueueConfig {
+	return replication.QueueConfig{
+		Size:          c.Replication.QueueSize,
+		MaxBytes:      int64(c.Replication.QueueMaxBytes),
+		MaxBatch:      c.Replication.BatchMaxWrites,
+		MaxBatchBytes: int64(c.Replication.BatchMaxBytes),
+		Linger:        time.Duration(c.Replication.BatchLinger),
+	}
+}
+
+// ReplicationHintConfig converts the replication settings into the hint
+// caps.
+func (c Config) ReplicationHintConfig() replication.HintConfig {
+	return replication.HintConfig{
+		MaxHints: c.Replication.HintMax,
+		MaxBytes: int64(c.Replication.HintMaxBytes),
+		TTL:      time.Duration(c.Replication.HintTTL),
+	}
+}
+
+// LogLevel returns the parsed log level.
+// Only call it on a validated config.
+func (c Config) LogLevel() logs.Level {
+	level, _ := logs.ParseLevel(c.Log.Level)
+	return level
+}
+
+// EvictionPolicy returns the constructor of the configured policy.
+// Only call it on a validated config.
+func (c Config) EvictionPolicy() func() store.EvictionPolicy {
+	newPolicy, _ := store.LookupPolicy(c.Store.EvictionPolicy)
+	return newPolicy
+}
+
+// WALSyncPolicy returns the parsed fsync policy.
+// Only call it on a validated config.
+func (c Config) WALSyncPolicy() wal.SyncPolicy {
+	policy, _ := wal.ParseSyncPolicy(c.WAL.Sync)
+	return policy
+}
+
+// Validate reports every invalid setting at once.
+func (c Config) Validate() error {
+	var errs []error
+
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.ListenAddr != "", "listen_addr must not be empty")
+	check(c.NodeID != "", "node_id must not be empty")
+
+	seen := make(map[string]bool, len(c.Peers))
+	for _, peer := range c.Peers {
+		u, err := url.Parse(peer)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"peer %q must be an http(s) base URL", peer)
+		check(!seen[peer], "peer %q is listed more than once", peer)
+		seen[peer] = true
+	}
+
+	retry := c.Peer.Retry
+	check(retry.MaxRetries >= 0, "peer.retry.max_retries must be >= 0")
+	check(retry.BaseBackoff > 0, "peer.retry.base_backoff must be > 0")
+	check(retry.MaxBackoff >= retry.BaseBackoff, "peer.retry.max_backoff must be >= peer.retry.base_backoff")
+
+	check(c.Peer.Timeout.Replication > 0, "peer.timeout.replication must be > 0")
+	check(c.Peer.Timeout.Heartbeat > 0, "peer.timeout.heartbeat must be > 0")
+	check(c.Peer.Health.FailureThreshold >= 1, "peer.health.failure_threshold must be >= 1")
+	check(c.Peer.Health.SuccessThreshold >= 1, "peer.health.success_threshold must be >= 1")
+	check(c.Peer.Heartbeat.Interval > 0, "peer.heartbeat.interval must be > 0")
+
+	check(c.TTL.Interval > 0, "ttl.interval must be > 0")
+	check(c.TTL.TombstoneGrace >= 0, "ttl.tombstone_grace must be >= 0")
+
+	_, err := logs.ParseLevel(c.Log.Level)
+	check(err == nil, "log.level %q must be one of DEBUG, INFO, WARN, ERROR", c.Log.Level)
+	check(c.Log.BufferSize > 0, "log.buffer_size must be > 0")
+
+	check(c.Store.Shards >= 1, "store.shards must be >= 1")
+	check(c.Store.MaxEntries >= 0, "store.max_entries must be >= 0")
+	check(c.Store.MaxBytes >= 0, "store.max_bytes must be >= 0")
+	_, err = store.LookupPolicy(c.Store.EvictionPolicy)
+	check(err == nil, "store.eviction_policy %q must be one of %s",
+		c.Store.EvictionPolicy, strings.Join(store.PolicyNames(), ", "))
+	check(c.Store.WatchHistory >= 0, "store.watch_history must be >= 0")
+
+	check(c.Snapshot.Interval >= 0, "snapshot.interval must be >= 0")
+	check(c.Snapshot.Retain >= 1, "snapshot.retain must be >= 1")
+
+	_, err = wal.ParseSyncPolicy(c.WAL.Sync)
+	check(err == nil, "wal.sync %q must be one of always, every-second, never", c.WAL.Sync)
+	check(c.WAL.CompactBytes > 0, "wal.compact_bytes must be > 0")
+	check(c.WAL.Dir == "" || c.Snapshot.Dir != "", "wal.dir requires snapshot.dir for compaction")
+
+	check(c.Shutdown.Timeout > 0, "shutdown.timeout must be > 0")
+
+	check(c.RESP.ListenAddr == "" || validHostPort(c.RESP.ListenAddr),
+		"resp.listen_addr %q must be a host:port address", c.RESP.ListenAddr)
+	check(c.Memcache.ListenAddr == "" || validHostPort(c.Memcache.ListenAddr),
+		"memcache.listen_addr %q must be a host:port address", c.Memcache.ListenAddr)
+
+	check(c.Replication.ListenAddr == "" || validHostPort(c.Replication.ListenAddr),
+		"replication.listen_addr %q must be a host:port address", c.Replication.ListenAddr)
+	for _, peer := range slices.Sorted(maps.Keys(c.Replication.BinaryPeers)) {
+		addr := c.Replication.BinaryPeers[peer]
+		check(seen[peer], "replication.binary_peers: %q is not a configured peer", peer)
+		check(validHostPort(addr), "replication.binary_peers: address %q of %q must be host:port", addr, peer)
+	}
+	check(c.Replication.QueueSize >= 1, "replication.queue_size must be >= 1")
+	check(c.Replication.QueueMaxBytes >= 1, "replication.queue_max_bytes must be >= 1")
+	check(c.Replication.BatchMaxWrites >= 1, "replication.batch_max_writes must be >= 1")
+	check(c.Replication.BatchMaxBytes >= 1, "replication.batch_max_bytes must be >= 1")
+	check(c.Replication.BatchLinger >= 0, "replication.batch_linger must be >= 0")
+	check(c.Replication.HintMax >= 0, "replication.hint_max must be >= 0")
+	check(c.Replication.HintMaxBytes >= 1, "replication.hint_max_bytes must be >= 1")
+	check(c.Replication.HintTTL >= 0, "replication.hint_ttl must be >= 0")
+	check(c.Replication.HintMax == 0 ||
+		(c.Replication.HintTTL > 0 && c.Replication.HintTTL <= c.TTL.TombstoneGrace),
+		"replication.hint_ttl must be > 0 and <= ttl.tombstone_grace (%s) while hints are enabled",
+		time.Duration(c.TTL.TombstoneGrace))
+
+	names := make(map[string]bool, len(c.Namespaces))
+	for _, ns := range c.Namespaces {
+		check(store.ValidNamespace(ns.Name) && ns.Name != store.DefaultNamespace,
+			"namespaces: name %q must be 1-64 characters of a-z, 0-9, '_' or '-' and not %q", ns.Name, store.DefaultNamespace)
+		check(!names[ns.Name], "namespaces: %q is listed more than once", ns.Name)
+		names[ns.Name] = true
+
+		err := ns.StoreSettings().Validate()
+		check(err == nil, "namespaces: %q: %v", ns.Name, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// validHostPort reports whether addr is a TCP address with a port.
+func validHostPort(addr string) bool {
+	_, port, err := net.SplitHostPort(addr)
+	return err == nil && port != ""
+}
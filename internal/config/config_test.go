@@ -0,0 +1,144 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"distributed-cache/internal/logs"
+	"distributed-cache/internal/peers"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefault_IsValid(t *testing.T) {
+	cfg := Default()
+
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, ":8080", cfg.ListenAddr)
+	assert.Equal(t, logs.DEBUG, cfg.LogLevel())
+
+	cfg.RESP.ListenAddr = ":6379"
+	cfg.Memcache.ListenAddr = "127.0.0.1:11211"
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestPeerConfig_MatchesPeerDefaults(t *testing.T) {
+	want := peers.DefaultPeerConfig()
+	got := Default().PeerConfig()
+
+	assert.Equal(t, want.Retry.MaxRetries, got.Retry.MaxRetries)
+	assert.Equal(t, want.Retry.BaseBackoff, got.Retry.BaseBackoff)
+	assert.Equal(t, want.Retry.MaxBackoff, got.Retry.MaxBackoff)
+	assert.Equal(t, want.Timeout, got.Timeout)
+	assert.Equal(t, want.Health, got.Health)
+	assert.Equal(t, want.Heartbeat, got.Heartbeat)
+	assert.NotNil(t, got.Retry.JitterFn)
+}
+
+func TestValidate_ReportsAllErrors(t *testing.T) {
+	cfg := Default()
+	cfg.NodeID = ""
+	cfg.Peers = []string{"node-2", "http://node-3:8080", "http://node-3:8080"}
+	cfg.Peer.Retry.BaseBackoff = Duration(time.Second)
+	cfg.Peer.Retry.MaxBackoff = Duration(time.Millisecond)
+	cfg.Peer.Health.FailureThreshold = 0
+	cfg.TTL.Interval = 0
+	cfg.Log.Level = "verbose"
+	cfg.Store.MaxBytes = -1
+	cfg.Store.Shards = 0
+	cfg.WAL.Sync = "sometimes"
+	cfg.Store.EvictionPolicy = "fifo"
+	cfg.Store.WatchHistory = -1
+	cfg.RESP.ListenAddr = "6379"
+	cfg.Memcache.ListenAddr = "localhost"
+	cfg.Replication.ListenAddr = "7946"
+	cfg.Replication.BinaryPeers = map[string]string{
+		"http://node-3:8080": "node-3",
+		"http://node-4:8080": "node-4:7946",
+	}
+	cfg.Replication.QueueSize = 0
+	cfg.Replication.QueueMaxBytes = 0
+	cfg.Replication.BatchLinger = -1
+	cfg.Replication.HintMaxBytes = 0
+
+	err := cfg.Validate()
+	assert.Error(t, err)
+
+	msg := err.Error()
+	assert.Contains(t, msg, "node_id must not be empty")
+	assert.Contains(t, msg, `peer "node-2" must be an http(s) base URL`)
+	assert.Contains(t, msg, `peer "http://node-3:8080" is listed more than once`)
+	assert.Contains(t, msg, "peer.retry.max_backoff")
+	assert.Contains(t, msg, "peer.health.failure_threshold")
+	assert.Contains(t, msg, "ttl.interval")
+	assert.Contains(t, msg, "log.level")
+	assert.Contains(t, msg, "store.max_bytes")
+	assert.Contains(t, msg, "store.shards")
+	assert.Contains(t, msg, `wal.sync "sometimes"`)
+	assert.Contains(t, msg, `store.eviction_policy "fifo"`)
+	assert.Contains(t, msg, "store.watch_history")
+	assert.Contains(t, msg, `resp.listen_addr "6379"`)
+	assert.Contains(t, msg, `memcache.listen_addr "localhost"`)
+	assert.Contains(t, msg, `replication.listen_addr "7946"`)
+	assert.Contains(t, msg, `address "node-3" of "http://node-3:8080" must be host:port`)
+	assert.Contains(t, msg, `replication.binary_peers: "http://node-4:8080" is not a configured peer`)
+	assert.Contains(t, msg, "replication.queue_size")
+	assert.Contains(t, msg, "replication.queue_max_bytes")
+	assert.Contains(t, msg, "replication.batch_linger")
+	assert.Contains(t, msg, "replication.hint_max_bytes")
+}
+
+func TestValidate_HintTTLWithinTombstoneGrace(t *testing.T) {
+	cfg := Default()
+	cfg.TTL.TombstoneGrace = Duration(10 * time.Minute)
+
+	cfg.Replication.HintTTL = Duration(10 * time.Minute)
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Replication.HintTTL = Duration(3 * time.Hour)
+	assert.ErrorContains(t, cfg.Validate(), "replication.hint_ttl must be > 0 and <= ttl.tombstone_grace (10m0s)",
+		"hints outliving tombstones resurrect deleted keys")
+
+	cfg.Replication.HintTTL = 0
+	assert.ErrorContains(t, cfg.Validate(), "replication.hint_ttl must be > 0", "hints must expire")
+
+	cfg.Replication.HintMax = 0
+	assert.NoError(t, cfg.Validate(), "no hints are kept")
+}
+
+func TestDuration_TextRoundTrip(t *testing.T) {
+	var d Duration
+	assert.NoError(t, d.UnmarshalText([]byte("1m30s")))
+	assert.Equal(t, Duration(90*time.Second), d)
+
+	text, err := d.MarshalText()
+	assert.NoError(t, err)
+	assert.Equal(t, "1m30s", string(text))
+
+	assert.Error(t, d.UnmarshalText([]byte("soon")))
+}
+
+func TestParseByteSize(t *testing.T) {
+	cases := map[string]ByteSize{
+		"0":     0,
+		"4096":  4096,
+		"512B":  512,
+		"64KiB": 64 << 10,
+		"64MiB": 64 << 20,
+		"2GiB":  2 << 30,
+		"1 GB":  1e9,
+		"10MB":  10e6,
+		" 1KB ": 1000,
+		"-1":    -1,
+	}
+	for in, want := range cases {
+		got, err := ParseByteSize(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	for _, in := range []string{"", "lots", "1.5GB", "MiB", "99999999999GiB"} {
+		_, err := ParseByteSize(in)
+		assert.Error(t, err, in)
+	}
+}
@@ -0,0 +1,250 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileEnv names the environment variable holding the config file path.
+// The -config flag takes precedence over it.
+const ConfigFileEnv = "CACHE_CONFIG"
+
+// setting binds one configuration value to its flag and environment variable.
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	apply func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{"listen", "CACHE_LISTEN_ADDR", "HTTP listen address",
+		func(c *Config, v string) error { c.ListenAddr = v; return nil }},
+	{"resp-listen", "CACHE_RESP_LISTEN_ADDR", "Redis protocol (RESP) listen address, e.g. :6379 (empty = disabled)",
+		func(c *Config, v string) error { c.RESP.ListenAddr = v; return nil }},
+	{"memcache-listen", "CACHE_MEMCACHE_LISTEN_ADDR", "memcached protocol listen address, e.g. :11211 (empty = disabled)",
+		func(c *Config, v string) error { c.Memcache.ListenAddr = v; return nil }},
+	{"replication-listen", "CACHE_REPLICATION_LISTEN_ADDR", "binary replication listen address, e.g. :7946 (empty = disabled)",
+		func(c *Config, v string) error { c.Replication.ListenAddr = v; return nil }},
+	{"replication-binary-peers", "CACHE_REPLICATION_BINARY_PEERS", "comma-separated peer=host:port pairs reached over binary replication",
+		func(c *Config, v string) (err error) { c.Replication.BinaryPeers, err = splitPairs(v); return err }},
+	{"replication-queue-size", "CACHE_REPLICATION_QUEUE_SIZE", "distinct keys queued per peer before writers wait",
+		intSetting(func(c *Config) *int { return &c.Replication.QueueSize })},
+	{"replication-queue-max-bytes", "CACHE_REPLICATION_QUEUE_MAX_BYTES", "size bound of the writes queued per peer, e.g. 64MiB",
+		func(c *Config, v string) error { return c.Replication.QueueMaxBytes.UnmarshalText([]byte(v)) }},
+	{"replication-batch-max-writes", "CACHE_REPLICATION_BATCH_MAX_WRITES", "most writes sent to a peer in one request",
+		intSetting(func(c *Config) *int { return &c.Replication.BatchMaxWrites })},
+	{"replication-batch-max-bytes", "CACHE_REPLICATION_BATCH_MAX_BYTES", "size bound of one replication batch, e.g. 1MiB",
+		func(c *Config, v string) error { return c.Replication.BatchMaxBytes.UnmarshalText([]byte(v)) }},
+	{"replication-batch-linger", "CACHE_REPLICATION_BATCH_LINGER", "how long a replication batch waits to fill (0 = send right away)",
+		durationSetting(func(c *Config) *Duration { return &c.Replication.BatchLinger })},
+	{"replication-hints-dir", "CACHE_REPLICATION_HINTS_DIR", "directory keeping hints for unhealthy peers across restarts (empty = memory only)",
+		func(c *Config, v string) error { c.Replication.HintsDir = v; return nil }},
+	{"replication-hint-max", "CACHE_REPLICATION_HINT_MAX", "hints kept per unhealthy peer (0 = no hinted handoff)",
+		intSetting(func(c *Config) *int { return &c.Replication.HintMax })},
+	{"replication-hint-max-bytes", "CACHE_REPLICATION_HINT_MAX_BYTES", "size bound of the hints kept per peer, e.g. 64MiB",
+		func(c *Config, v string) error { return c.Replication.HintMaxBytes.UnmarshalText([]byte(v)) }},
+	{"replication-hint-ttl", "CACHE_REPLICATION_HINT_TTL", "how long a hint is kept (0 = until replayed)",
+		durationSetting(func(c *Config) *Duration { return &c.Replication.HintTTL })},
+	{"node-id", "CACHE_NODE_ID", "unique ID of this node",
+		func(c *Config, v string) error { c.NodeID = v; return nil }},
+	{"peers", "CACHE_PEERS", "comma-separated peer base URLs",
+		func(c *Config, v string) error { c.Peers = splitList(v); return nil }},
+
+	{"retry-max", "CACHE_RETRY_MAX_RETRIES", "replication retry attempts",
+		intSetting(func(c *Config) *int { return &c.Peer.Retry.MaxRetries })},
+	{"retry-base-backoff", "CACHE_RETRY_BASE_BACKOFF", "initial replication retry backoff",
+		durationSetting(func(c *Config) *Duration { return &c.Peer.Retry.BaseBackoff })},
+	{"retry-max-backoff", "CACHE_RETRY_MAX_BACKOFF", "upper bound on replication retry backoff",
+		durationSetting(func(c *Config) *Duration { return &c.Peer.Retry.MaxBackoff })},
+	{"replication-timeout", "CACHE_REPLICATION_TIMEOUT", "timeout of a single replication request",
+		durationSetting(func(c *Config) *Duration { return &c.Peer.Timeout.Replication })},
+	{"heartbeat-timeout", "CACHE_HEARTBEAT_TIMEOUT", "timeout of a single heartbeat request",
+		durationSetting(func(c *Config) *Duration { return &c.Peer.Timeout.Heartbeat })},
+	{"failure-threshold", "CACHE_FAILURE_THRESHOLD", "consecutive failures before a peer is unhealthy",
+		intSetting(func(c *Config) *int { return &c.Peer.Health.FailureThreshold })},
+	{"success-threshold", "CACHE_SUCCESS_THRESHOLD", "consecutive successes before a peer recovers",
+		intSetting(func(c *Config) *int { return &c.Peer.Health.SuccessThreshold })},
+	{"heartbeat-interval", "CACHE_HEARTBEAT_INTERVAL", "interval between peer heartbeats",
+		durationSetting(func(c *Config) *Duration { return &c.Peer.Heartbeat.Interval })},
+
+	{"ttl-interval", "CACHE_TTL_INTERVAL", "interval between TTL cleanup runs",
+		durationSetting(func(c *Config) *Duration { return &c.TTL.Interval })},
+	{"tombstone-grace", "CACHE_TOMBSTONE_GRACE", "how long delete tombstones are kept",
+		durationSetting(func(c *Config) *Duration { return &c.TTL.TombstoneGrace })},
+
+	{"store-shards", "CACHE_STORE_SHARDS", "number of independently locked store partitions",
+		intSetting(func(c *Config) *int { return &c.Store.Shards })},
+	{"store-max-entries", "CACHE_STORE_MAX_ENTRIES", "maximum number of stored entries (0 = unbounded)",
+		intSetting(func(c *Config) *int { return &c.Store.MaxEntries })},
+	{"store-max-bytes", "CACHE_STORE_MAX_BYTES", "approximate memory bound of the store, e.g. 512MiB (0 = unbounded)",
+		func(c *Config, v string) error { return c.Store.MaxBytes.UnmarshalText([]byte(v)) }},
+	{"store-eviction-policy", "CACHE_STORE_EVICTION_POLICY", "eviction policy (lfu, lru, random, tinylfu, ttl)",
+		func(c *Config, v string) error { c.Store.EvictionPolicy = v; return nil }},
+	{"store-watch-history", "CACHE_STORE_WATCH_HISTORY", "number of recent events kept for resuming watches (0 = no resume)",
+		intSetting(func(c *Config) *int { return &c.Store.WatchHistory })},
+
+	{"log-level", "CACHE_LOG_LEVEL", "minimum log level (DEBUG, INFO, WARN, ERROR)",
+		func(c *Config, v string) error { c.Log.Level = v; return nil }},
+	{"log-buffer", "CACHE_LOG_BUFFER_SIZE", "number of log entries kept in memory",
+		intSetting(func(c *Config) *int { return &c.Log.BufferSize })},
+
+	{"snapshot-dir", "CACHE_SNAPSHOT_DIR", "directory for store snapshots (empty disables persistence)",
+		func(c *Config, v string) error { c.Snapshot.Dir = v; return nil }},
+	{"snapshot-interval", "CACHE_SNAPSHOT_INTERVAL", "interval between periodic snapshots (0 disables them)",
+		durationSetting(func(c *Config) *Duration { return &c.Snapshot.Interval })},
+	{"snapshot-retain", "CACHE_SNAPSHOT_RETAIN", "number of snapshot files kept",
+		intSetting(func(c *Config) *int { return &c.Snapshot.Retain })},
+
+	{"wal-dir", "CACHE_WAL_DIR", "directory for the write-ahead log (empty disables it)",
+		func(c *Config, v string) error { c.WAL.Dir = v; return nil }},
+	{"wal-sync", "CACHE_WAL_SYNC", "write-ahead log fsync policy (always, every-second, never)",
+		func(c *Config, v string) error { c.WAL.Sync = v; return nil }},
+	{"wal-compact-bytes", "CACHE_WAL_COMPACT_BYTES", "write-ahead log size that triggers compaction, e.g. 64MiB",
+		func(c *Config, v string) error { return c.WAL.CompactBytes.UnmarshalText([]byte(v)) }},
+
+	{"shutdown-timeout", "CACHE_SHUTDOWN_TIMEOUT", "deadline for draining requests and replication on shutdown",
+		durationSetting(func(c *Config) *Duration { return &c.Shutdown.Timeout })},
+}
+
+// Load resolves the configuration from defaults, an optional config file,
+// environment variables and command-line flags, then validates it.
+//
+// args are the command-line arguments without the program name.
+// getenv is usually os.Getenv; tests pass a map lookup instead.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	fs := flag.NewFlagSet("distributed-cache", flag.ContinueOnError)
+	path := fs.String("config", "", "path to a YAML or JSON config file (env "+ConfigFileEnv+")")
+
+	values := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		values[s.flag] = fs.String(s.flag, "", s.usage+" (env "+s.env+")")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	cfg := Default()
+
+	if *path == "" {
+		*path = getenv(ConfigFileEnv)
+	}
+	if *path != "" {
+		if err := loadFile(*path, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for _, s := range settings {
+		if v := getenv(s.env); v != "" {
+			if err := s.apply(&cfg, v); err != nil {
+				return Config{}, fmt.Errorf("env %s: %w", s.env, err)
+			}
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name && flagErr == nil {
+				if err := s.apply(&cfg, *values[s.flag]); err != nil {
+					flagErr = fmt.Errorf("flag -%s: %w", s.flag, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return Config{}, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	return cfg, nil
+}
+
+// loadFile overlays a YAML or JSON file onto cfg.
+// The format is chosen by file extension; unknown fields are rejected.
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && err != io.EOF {
+			return fmt.Errorf("parse config file %s: %w", path, err)
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(cfg); err != nil {
+			return fmt.Errorf("parse config file %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("config file %s: unsupported extension (want .yaml, .yml or .json)", path)
+	}
+
+	return nil
+}
+
+func splitList(v string) []string {
+	out := []string{}
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// splitPairs parses "key=value,key=value". Values split at the last '=',
+// so keys may be URLs with query strings.
+func splitPairs(v string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, item := range splitList(v) {
+		i := strings.LastIndexByte(item, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid pair %q (want key=value)", item)
+		}
+		out[strings.TrimSpace(item[:i])] = strings.TrimSpace(item[i+1:])
+	}
+	return out, nil
+}
+
+func intSetting(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", v)
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func durationSetting(field func(*Config) *Duration) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", v)
+		}
+		*field(c) = Duration(d)
+		return nil
+	}
+}
@@ -0,0 +1,340 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"distributed-cache/internal/replication"
+	"distributed-cache/internal/store"
+	"distributed-cache/internal/wal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func envMap(values map[string]string) func(string) string {
+	return func(key string) string { return values[key] }
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+const yamlConfig = `
+listen_addr: ":9090"
+node_id: node-from-file
+peers:
+  - http://node-2:8080
+  - http://node-3:8080
+peer:
+  retry:
+    max_retries: 5
+    base_backoff: 50ms
+  health:
+    failure_threshold: 4
+  heartbeat:
+    interval: 2s
+ttl:
+  interval: 1s
+log:
+  level: info
+  buffer_size: 200
+`
+
+func TestLoad_DefaultsOnly(t *testing.T) {
+	cfg, err := Load(nil, envMap(nil))
+	require.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+}
+
+func TestLoad_YAMLFile(t *testing.T) {
+	path := writeFile(t, "cache.yaml", yamlConfig)
+
+	cfg, err := Load([]string{"-config", path}, envMap(nil))
+	require.NoError(t, err)
+
+	assert.Equal(t, ":9090", cfg.ListenAddr)
+	assert.Equal(t, "node-from-file", cfg.NodeID)
+	assert.Equal(t, []string{"http://node-2:8080", "http://node-3:8080"}, cfg.Peers)
+	assert.Equal(t, 5, cfg.Peer.Retry.MaxRetries)
+	assert.Equal(t, Duration(50*time.Millisecond), cfg.Peer.Retry.BaseBackoff)
+	assert.Equal(t, 4, cfg.Peer.Health.FailureThreshold)
+	assert.Equal(t, Duration(2*time.Second), cfg.Peer.Heartbeat.Interval)
+	assert.Equal(t, Duration(time.Second), cfg.TTL.Interval)
+	assert.Equal(t, 200, cfg.Log.BufferSize)
+
+	// Unset fields keep their defaults.
+	assert.Equal(t, Default().Peer.Retry.MaxBackoff, cfg.Peer.Retry.MaxBackoff)
+	assert.Equal(t, Default().Peer.Health.SuccessThreshold, cfg.Peer.Health.SuccessThreshold)
+}
+
+func TestLoad_JSONFileFromEnv(t *testing.T) {
+	path := writeFile(t, "cache.json", `{"node_id":"json-node","ttl":{"interval":"3s"}}`)
+
+	cfg, err := Load(nil, envMap(map[string]string{ConfigFileEnv: path}))
+	require.NoError(t, err)
+
+	assert.Equal(t, "json-node", cfg.NodeID)
+	assert.Equal(t, Duration(3*time.Second), cfg.TTL.Interval)
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeFile(t, "cache.yaml", yamlConfig)
+	env := envMap(map[string]string{
+		"CACHE_NODE_ID":      "node-from-env",
+		"CACHE_PEERS":        "http://env-peer:8080, http://env-peer-2:8080",
+		"CACHE_TTL_INTERVAL": "10s",
+	})
+
+	cfg, err := Load([]string{"-config", path, "-node-id", "node-from-flag"}, env)
+	require.NoError(t, err)
+
+	assert.Equal(t, "node-from-flag", cfg.NodeID, "flags override env")
+	assert.Equal(t, []string{"http://env-peer:8080", "http://env-peer-2:8080"}, cfg.Peers, "env overrides file")
+	assert.Equal(t, Duration(10*time.Second), cfg.TTL.Interval, "env overrides file")
+	assert.Equal(t, ":9090", cfg.ListenAddr, "file overrides defaults")
+}
+
+func TestLoad_ShutdownSettings(t *testing.T) {
+	cfg, err := Load([]string{"-shutdown-timeout", "3s"}, envMap(nil))
+	require.NoError(t, err)
+
+	assert.Equal(t, Duration(3*time.Second), cfg.Shutdown.Timeout)
+}
+
+func TestLoad_RESPSettings(t *testing.T) {
+	cfg, err := Load(nil, envMap(nil))
+	require.NoError(t, err)
+	assert.Empty(t, cfg.RESP.ListenAddr, "the RESP listener is off by default")
+
+	cfg, err = Load(nil, envMap(map[string]string{"CACHE_RESP_LISTEN_ADDR": ":6379"}))
+	require.NoError(t, err)
+	assert.Equal(t, ":6379", cfg.RESP.ListenAddr)
+
+	cfg, err = Load([]string{"-resp-listen", "127.0.0.1:6380"}, envMap(nil))
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:6380", cfg.RESP.ListenAddr)
+}
+
+func TestLoad_MemcacheSettings(t *testing.T) {
+	cfg, err := Load(nil, envMap(nil))
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Memcache.ListenAddr, "the memcached listener is off by default")
+
+	cfg, err = Load(nil, envMap(map[string]string{"CACHE_MEMCACHE_LISTEN_ADDR": ":11211"}))
+	require.NoError(t, err)
+	assert.Equal(t, ":11211", cfg.Memcache.ListenAddr)
+
+	cfg, err = Load([]string{"-memcache-listen", "127.0.0.1:11212"}, envMap(nil))
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:11212", cfg.Memcache.ListenAddr)
+}
+
+func TestLoad_ReplicationSettings(t *testing.T) {
+	cfg, err := Load(nil, envMap(nil))
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Replication.ListenAddr, "the binary replication listener is off by default")
+	assert.Empty(t, cfg.Replication.BinaryPeers, "peers use HTTP/JSON by default")
+
+	env := envMap(map[string]string{
+		"CACHE_PEERS":                    "http://node-2:8080,http://node-3:8080",
+		"CACHE_REPLICATION_BINARY_PEERS": "http://node-2:8080=node-2:7946, http://node-3:8080 = node-3:7946",
+	})
+	cfg, err = Load([]string{"-replication-listen", ":7946"}, env)
+	require.NoError(t, err)
+	assert.Equal(t, ":7946", cfg.Replication.ListenAddr)
+	assert.Equal(t, map[string]string{
+		"http://node-2:8080": "node-2:7946",
+		"http://node-3:8080": "node-3:7946",
+	}, cfg.Replication.BinaryPeers)
+
+	_, err = Load([]string{"-replication-binary-peers", "node-2:7946"}, envMap(nil))
+	assert.ErrorContains(t, err, `invalid pair "node-2:7946"`)
+}
+
+func TestLoad_ReplicationQueueSettings(t *testing.T) {
+	cfg, err := Load(nil, envMap(nil))
+	require.NoError(t, err)
+	assert.Equal(t, replication.DefaultQueueConfig(), cfg.ReplicationQueueConfig())
+
+	env := envMap(map[string]string{
+		"CACHE_REPLICATION_QUEUE_MAX_BYTES": "8MiB",
+		"CACHE_REPLICATION_BATCH_MAX_BYTES": "64KiB",
+		"CACHE_REPLICATION_BATCH_LINGER":    "2ms",
+	})
+	cfg, err = Load([]string{"-replication-queue-size", "500", "-replication-batch-max-writes", "32"}, env)
+	require.NoError(t, err)
+	assert.Equal(t, replication.QueueConfig{
+		Size:          500,
+		MaxBytes:      8 << 20,
+		MaxBatch:      32,
+		MaxBatchBytes: 64 << 10,
+		Linger:        2 * time.Millisecond,
+	}, cfg.ReplicationQueueConfig())
+
+	_, err = Load([]string{"-replication-batch-max-writes", "0"}, envMap(nil))
+	assert.ErrorContains(t, err, "replication.batch_max_writes must be >= 1")
+}
+
+func TestLoad_ReplicationHintSettings(t *testing.T) {
+	cfg, err := Load(nil, envMap(nil))
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Replication.HintsDir, "hints are kept in memory by default")
+	assert.Equal(t, replication.DefaultHintConfig(), cfg.ReplicationHintConfig())
+
+	env := envMap(map[string]string{
+		"CACHE_REPLICATION_HINTS_DIR":      "/var/lib/cache/hints",
+		"CACHE_REPLICATION_HINT_MAX_BYTES": "16MiB",
+	})
+	cfg, err = Load([]string{"-replication-hint-max", "0", "-replication-hint-ttl", "30m"}, env)
+	require.NoError(t, err)
+	assert.Equal(t, "/var/lib/cache/hints", cfg.Replication.HintsDir)
+	assert.Equal(t, replication.HintConfig{
+		MaxHints: 0,
+		MaxBytes: 16 << 20,
+		TTL:      30 * time.Minute,
+	}, cfg.ReplicationHintConfig())
+
+	_, err = Load([]string{"-replication-hint-ttl", "-1s"}, envMap(nil))
+	assert.ErrorContains(t, err, "replication.hint_ttl must be >= 0")
+}
+
+func TestLoad_StoreSettings(t *testing.T) {
+	t.Run("flags and env", func(t *testing.T) {
+		env := envMap(map[string]string{
+			"CACHE_STORE_MAX_BYTES":       "256MiB",
+			"CACHE_STORE_EVICTION_POLICY": "tinylfu",
+			"CACHE_STORE_WATCH_HISTORY":   "0",
+		})
+		cfg, err := Load([]string{"-store-max-entries", "10000", "-store-shards", "64"}, env)
+		require.NoError(t, err)
+
+		assert.Equal(t, 64, cfg.Store.Shards)
+		assert.Equal(t, 10000, cfg.Store.MaxEntries)
+		assert.Equal(t, ByteSize(256<<20), cfg.Store.MaxBytes)
+		assert.Equal(t, "tinylfu", cfg.Store.EvictionPolicy)
+		assert.Equal(t, 0, cfg.Store.WatchHistory)
+		assert.NotNil(t, cfg.EvictionPolicy())
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		path := writeFile(t, "cache.yaml", "store:\n  max_bytes: 1GiB\n")
+		cfg, err := Load([]string{"-config", path}, envMap(nil))
+		require.NoError(t, err)
+		assert.Equal(t, ByteSize(1<<30), cfg.Store.MaxBytes)
+	})
+
+	t.Run("json number and string", func(t *testing.T) {
+		path := writeFile(t, "cache.json", `{"store":{"max_entries":5,"max_bytes":4096}}`)
+		cfg, err := Load([]string{"-config", path}, envMap(nil))
+		require.NoError(t, err)
+		assert.Equal(t, ByteSize(4096), cfg.Store.MaxBytes)
+
+		path = writeFile(t, "cache.json", `{"store":{"max_bytes":"2MB"}}`)
+		cfg, err = Load([]string{"-config", path}, envMap(nil))
+		require.NoError(t, err)
+		assert.Equal(t, ByteSize(2e6), cfg.Store.MaxBytes)
+	})
+
+	t.Run("invalid size", func(t *testing.T) {
+		_, err := Load([]string{"-store-max-bytes", "huge"}, envMap(nil))
+		assert.ErrorContains(t, err, "-store-max-bytes")
+	})
+}
+
+func TestLoad_SnapshotSettings(t *testing.T) {
+	env := envMap(map[string]string{"CACHE_SNAPSHOT_DIR": "/var/lib/cache"})
+	cfg, err := Load([]string{"-snapshot-interval", "30s", "-snapshot-retain", "5"}, env)
+	require.NoError(t, err)
+
+	assert.Equal(t, "/var/lib/cache", cfg.Snapshot.Dir)
+	assert.Equal(t, Duration(30*time.Second), cfg.Snapshot.Interval)
+	assert.Equal(t, 5, cfg.Snapshot.Retain)
+
+	_, err = Load([]string{"-snapshot-retain", "0"}, envMap(nil))
+	assert.ErrorContains(t, err, "snapshot.retain")
+}
+
+func TestLoad_WALSettings(t *testing.T) {
+	env := envMap(map[string]string{
+		"CACHE_SNAPSHOT_DIR": "/var/lib/cache/snapshots",
+		"CACHE_WAL_DIR":      "/var/lib/cache/wal",
+	})
+	cfg, err := Load([]string{"-wal-sync", "always", "-wal-compact-bytes", "16MiB"}, env)
+	require.NoError(t, err)
+
+	assert.Equal(t, "/var/lib/cache/wal", cfg.WAL.Dir)
+	assert.Equal(t, wal.SyncAlways, cfg.WALSyncPolicy())
+	assert.Equal(t, ByteSize(16<<20), cfg.WAL.CompactBytes)
+
+	_, err = Load([]string{"-wal-dir", "/var/lib/cache/wal"}, envMap(nil))
+	assert.ErrorContains(t, err, "wal.dir requires snapshot.dir")
+}
+
+func TestLoad_NamespaceSettings(t *testing.T) {
+	path := writeFile(t, "cache.yaml", `
+namespaces:
+  - name: sessions
+    default_ttl: 30m
+    max_entries: 10000
+  - name: images
+    max_bytes: 256MiB
+    eviction_policy: lfu
+`)
+	cfg, err := Load([]string{"-config", path}, envMap(nil))
+	require.NoError(t, err)
+
+	require.Len(t, cfg.Namespaces, 2)
+	assert.Equal(t, store.NamespaceSettings{DefaultTTL: 30 * time.Minute, MaxEntries: 10000}, cfg.Namespaces[0].StoreSettings())
+	assert.Equal(t, store.NamespaceSettings{MaxBytes: 256 << 20, EvictionPolicy: "lfu"}, cfg.Namespaces[1].StoreSettings())
+
+	path = writeFile(t, "cache.json", `{"namespaces":[{"name":"a","eviction_policy":"fifo"},{"name":"a"},{"name":"default"},{"name":"Bad"}]}`)
+	_, err = Load([]string{"-config", path}, envMap(nil))
+	require.Error(t, err)
+	assert.ErrorContains(t, err, `namespaces: "a": unknown eviction policy "fifo"`)
+	assert.ErrorContains(t, err, `namespaces: "a" is listed more than once`)
+	assert.ErrorContains(t, err, `namespaces: name "default"`)
+	assert.ErrorContains(t, err, `namespaces: name "Bad"`)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	t.Run("unknown field", func(t *testing.T) {
+		path := writeFile(t, "cache.yaml", "listen: :9090\n")
+		_, err := Load([]string{"-config", path}, envMap(nil))
+		assert.Error(t, err)
+	})
+
+	t.Run("unsupported extension", func(t *testing.T) {
+		path := writeFile(t, "cache.toml", "")
+		_, err := Load([]string{"-config", path}, envMap(nil))
+		assert.ErrorContains(t, err, "unsupported extension")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := Load([]string{"-config", "/does/not/exist.yaml"}, envMap(nil))
+		assert.Error(t, err)
+	})
+
+	t.Run("bad env duration", func(t *testing.T) {
+		_, err := Load(nil, envMap(map[string]string{"CACHE_TTL_INTERVAL": "often"}))
+		assert.ErrorContains(t, err, "CACHE_TTL_INTERVAL")
+	})
+
+	t.Run("bad flag integer", func(t *testing.T) {
+		_, err := Load([]string{"-retry-max", "many"}, envMap(nil))
+		assert.ErrorContains(t, err, "-retry-max")
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		_, err := Load([]string{"-log-level", "loud", "-peers", "node-2"}, envMap(nil))
+		assert.ErrorContains(t, err, "invalid configuration")
+		assert.ErrorContains(t, err, "log.level")
+		assert.ErrorContains(t, err, "node-2")
+	})
+}
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"distributed-cache/internal/logs"
+)
+
+// restartRequired lists settings that a running node cannot change,
+// by the paths Diff reports. Reload reports them but keeps the values the
+// node started with.
+var restartRequired = map[string]bool{
+	"listen_addr":             true,
+	"resp.listen_addr":        true,
+	"memcache.listen_addr":    true,
+	"replication.listen_addr": true,
+	"node_id":                 true,
+	"log.buffer_size":         true,
+	// The store's shards, limits and policies are fixed when it is built.
+	"store.shards":          true,
+	"store.max_entries":     true,
+	"store.max_bytes":       true,
+	"store.eviction_policy": true,
+	"store.watch_history":   true,
+	// Snapshots are restored from dir at startup only.
+	"snapshot.dir":    true,
+	"snapshot.retain": true,
+	// The log is opened and replayed at startup.
+	"wal.dir":           true,
+	"wal.sync":          true,
+	"wal.compact_bytes": true,
+	// Hints are loaded from their directory at startup.
+	"replication.hints_dir": true,
+	// Namespaces are created at startup; use POST /admin/namespaces at runtime.
+	"namespaces": true,
+}
+
+// Change describes one setting that differs between two configurations.
+type Change struct {
+	Field           string `json:"field"`
+	Old             string `json:"old"`
+	New             string `json:"new"`
+	RestartRequired bool   `json:"restart_required,omitempty"`
+}
+
+func (c Change) String() string {
+	s := fmt.Sprintf("%s: %s -> %s", c.Field, c.Old, c.New)
+	if c.RestartRequired {
+		s += " (restart required)"
+	}
+	return s
+}
+
+// Diff lists the settings that differ between old and new,
+// named by their config file path (e.g. "peer.retry.max_retries").
+func Diff(old, new Config) []Change {
+	var changes []Change
+	diffValues("", reflect.ValueOf(old), reflect.ValueOf(new), &changes)
+	return changes
+}
+
+func diffValues(prefix string, old, new reflect.Value, changes *[]Change) {
+	if old.Kind() == reflect.Struct {
+		for i := 0; i < old.NumField(); i++ {
+			diffValues(fieldPath(prefix, old.Type().Field(i)), old.Field(i), new.Field(i), changes)
+		}
+		return
+	}
+
+	if reflect.DeepEqual(old.Interface(), new.Interface()) {
+		return
+	}
+
+	*changes = append(*changes, Change{
+		Field:           prefix,
+		Old:             fmt.Sprint(old.Interface()),
+		New:             fmt.Sprint(new.Interface()),
+		RestartRequired: restartRequired[prefix],
+	})
+}
+
+// fieldPath names a struct field by its config file path.
+func fieldPath(prefix string, field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// pinRestartRequired copies every setting listed in restartRequired from
+// current into next, walking the same paths as Diff.
+func pinRestartRequired(prefix string, next, current reflect.Value) {
+	if restartRequired[prefix] {
+		next.Set(current)
+		return
+	}
+	if next.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < next.NumField(); i++ {
+		pinRestartRequired(fieldPath(prefix, next.Type().Field(i)), next.Field(i), current.Field(i))
+	}
+}
+
+// Reloader re-resolves the configuration on demand and applies
+// the reloadable changes to a running node.
+type Reloader struct {
+	mu      sync.Mutex
+	current Config
+	load    func() (Config, error)
+	apply   func(Config)
+	logger  *logs.Logger
+}
+
+// NewReloader creates a reloader.
+//
+// load: resolves the configuration again (usually the same Load call used at startup)
+// apply: pushes a validated configuration into the running components
+func NewReloader(
+	current Config,
+	load func() (Config, error),
+	apply func(Config),
+	logger *logs.Logger,
+) *Reloader {
+	return &Reloader{
+		current: current,
+		load:    load,
+		apply:   apply,
+		logger:  logger,
+	}
+}
+
+// Current returns the configuration currently in effect.
+func (r *Reloader) Current() Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.current
+}
+
+// Reload loads the configuration, applies it and returns the diff.
+//
+// An invalid configuration is rejected as a whole and nothing is applied.
+// Settings that need a restart are reported but keep their current value.
+// Concurrent reloads are serialized.
+func (r *Reloader) Reload() ([]Change, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := r.load()
+	if err != nil {
+		r.logger.Warn("config reload rejected: " + err.Error())
+		return nil, err
+	}
+
+	changes := Diff(r.current, next)
+	if len(changes) == 0 {
+		r.logger.Info("config reload: no changes")
+		return changes, nil
+	}
+
+	pinRestartRequired("", reflect.ValueOf(&next).Elem(), reflect.ValueOf(r.current))
+
+	r.apply(next)
+	r.current = next
+
+	for _, change := range changes {
+		if change.RestartRequired {
+			r.logger.Warn("config reload: " + change.String())
+		} else {
+			r.logger.Info("config reload: " + change.String())
+		}
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"distributed-cache/internal/logs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	old := Default()
+	new := Default()
+
+	assert.Empty(t, Diff(old, new))
+
+	new.NodeID = "node-9"
+	new.Peers = []string{"http://node-2:8080"}
+	new.Peer.Retry.BaseBackoff = Duration(time.Second)
+	new.Log.Level = "INFO"
+
+	changes := Diff(old, new)
+	require.Len(t, changes, 4)
+
+	assert.Equal(t, Change{Field: "node_id", Old: "node-1", New: "node-9", RestartRequired: true}, changes[0])
+	assert.Equal(t, Change{Field: "peers", Old: "[]", New: "[http://node-2:8080]"}, changes[1])
+	assert.Equal(t, Change{Field: "peer.retry.base_backoff", Old: "100ms", New: "1s"}, changes[2])
+	assert.Equal(t, Change{Field: "log.level", Old: "DEBUG", New: "INFO"}, changes[3])
+}
+
+func TestReloader_AppliesReloadableChanges(t *testing.T) {
+	start := Default()
+
+	next := Default()
+	next.NodeID = "renamed"
+	next.Peer.Health.FailureThreshold = 7
+
+	var applied []Config
+	logger := logs.NewLogger(10, logs.DEBUG)
+	reloader := NewReloader(
+		start,
+		func() (Config, error) { return next, nil },
+		func(c Config) { applied = append(applied, c) },
+		logger,
+	)
+
+	changes, err := reloader.Reload()
+	require.NoError(t, err)
+	assert.Len(t, changes, 2)
+
+	require.Len(t, applied, 1)
+	assert.Equal(t, 7, applied[0].Peer.Health.FailureThreshold)
+	assert.Equal(t, "node-1", applied[0].NodeID, "restart-only settings keep their value")
+	assert.Equal(t, applied[0], reloader.Current())
+
+	var messages []string
+	for _, entry := range logger.GetLast(10) {
+		messages = append(messages, entry.Message)
+	}
+	assert.Contains(t, messages, "config reload: peer.health.failure_threshold: 3 -> 7")
+	assert.Contains(t, messages, "config reload: node_id: node-1 -> renamed (restart required)")
+
+	// Reloading the same config again is a no-op, but the restart-only
+	// difference is still reported.
+	changes, err = reloader.Reload()
+	require.NoError(t, err)
+	assert.Len(t, changes, 1)
+	assert.True(t, changes[0].RestartRequired)
+}
+
+func TestReloader_KeepsRestartOnlySettings(t *testing.T) {
+	start := Default()
+
+	next := Default()
+	next.Store.MaxEntries = 5
+	next.Snapshot.Dir = "/x"
+	next.Log.Level = "INFO"
+
+	reloader := NewReloader(
+		start,
+		func() (Config, error) { return next, nil },
+		func(Config) {},
+		logs.NewLogger(10, logs.DEBUG),
+	)
+
+	for i := 0; i < 2; i++ {
+		changes, err := reloader.Reload()
+		require.NoError(t, err)
+
+		var restart []string
+		for _, change := range changes {
+			if change.RestartRequired {
+				restart = append(restart, change.Field)
+			}
+		}
+		assert.Equal(t, []string{"store.max_entries", "snapshot.dir"}, restart, "reload %d", i+1)
+	}
+
+	current := reloader.Current()
+	assert.Equal(t, start.Store.MaxEntries, current.Store.MaxEntries)
+	assert.Equal(t, start.Snapshot.Dir, current.Snapshot.Dir)
+	assert.Equal(t, "INFO", current.Log.Level)
+}
+
+func TestRestartRequired_NamesConfigPaths(t *testing.T) {
+	paths := map[string]bool{}
+	var walk func(prefix string, typ reflect.Type)
+	walk = func(prefix string, typ reflect.Type) {
+		if prefix != "" {
+			paths[prefix] = true
+		}
+		if typ.Kind() != reflect.Struct {
+			return
+		}
+		for i := 0; i < typ.NumField(); i++ {
+			walk(fieldPath(prefix, typ.Field(i)), typ.Field(i).Type)
+		}
+	}
+	walk("", reflect.TypeOf(Config{}))
+
+	for path := range restartRequired {
+		assert.True(t, paths[path], "restartRequired names unknown setting %q", path)
+	}
+}
+
+func TestReloader_RejectsInvalidConfig(t *testing.T) {
+	applied := false
+	reloader := NewReloader(
+		Default(),
+		func() (Config, error) { return Config{}, errors.New("invalid configuration") },
+		func(Config) { applied = true },
+		logs.NewLogger(10, logs.DEBUG),
+	)
+
+	_, err := reloader.Reload()
+	assert.Error(t, err)
+	assert.False(t, applied)
+	assert.Equal(t, Default(), reloader.Current())
+}
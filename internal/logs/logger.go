@@ -1,6 +1,8 @@
 package logs
 
 import (
+	"fmt"
+	"strings"
 	"sync"
 	"time"
 )
@@ -23,6 +25,15 @@ var levelPriority = map[Level]int{
 	ERROR: 4,
 }
 
+// ParseLevel converts a case-insensitive level name into a Level.
+func ParseLevel(s string) (Level, error) {
+	level := Level(strings.ToUpper(strings.TrimSpace(s)))
+	if _, ok := levelPriority[level]; !ok {
+		return "", fmt.Errorf("unknown log level %q", s)
+	}
+	return level, nil
+}
+
 type Entry struct {
 	TimeStamp time.Time `json:"timestamp"`
 	Level     Level     `json:"level"`
@@ -50,14 +61,14 @@ func NewLogger(maxSize int, level Level) *Logger {
 // log is the internal logging function
 // it applies level filtering and ring buffer behavior
 func (l *Logger) log(level Level, msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	//filter logds below the current level
 	if levelPriority[level] < levelPriority[l.level] {
 		return
 	}
 
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
 	if len(l.entries) >= l.maxSize {
 		//remove oldest entry(ring behavior	)
 		l.entries = l.entries[1:]
@@ -70,6 +81,14 @@ func (l *Logger) log(level Level, msg string) {
 	})
 }
 
+// SetLevel changes the minimum level recorded from now on.
+func (l *Logger) SetLevel(level Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.level = level
+}
+
 func (l *Logger) Debug(msg string) {
 	l.log(DEBUG, msg)
 }
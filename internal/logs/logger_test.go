@@ -90,3 +90,30 @@ func TestLogger(t *testing.T) {
 		assert.Equal(t, "original message", entriesAfterModification[0].Message, "Modifying retrieved entries should not affect internal log storage")
 	})
 }
+
+func TestLoggerSetLevel(t *testing.T) {
+	logger := NewLogger(10, INFO)
+
+	logger.Debug("dropped")
+	logger.SetLevel(DEBUG)
+	logger.Debug("kept")
+	logger.SetLevel(ERROR)
+	logger.Warn("dropped")
+
+	entries := logger.GetLast(10)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "kept", entries[0].Message)
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("warn")
+	assert.NoError(t, err)
+	assert.Equal(t, WARN, level)
+
+	level, err = ParseLevel(" DEBUG ")
+	assert.NoError(t, err)
+	assert.Equal(t, DEBUG, level)
+
+	_, err = ParseLevel("verbose")
+	assert.Error(t, err)
+}
@@ -0,0 +1,489 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/store"
+	"distributed-cache/internal/version"
+)
+
+// memcachedVersion is the memcached version reported by the version
+// command. Clients use the meta protocol from 1.6 on.
+const memcachedVersion = "1.6.21"
+
+// maxCASRetries bounds the retries of read-modify-write commands (incr,
+// decr, ma) that lose a race with a concurrent write.
+const maxCASRetries = 16
+
+// command runs one command. The returned error is a read error of the
+// connection, e.g. a bad data block; command failures are replied to.
+type command func(s *Server, c *conn, args [][]byte) error
+
+var commands = map[string]command{
+	"get":       (*Server).get,
+	"gets":      (*Server).get,
+	"set":       (*Server).storage,
+	"add":       (*Server).storage,
+	"replace":   (*Server).storage,
+	"cas":       (*Server).storage,
+	"delete":    (*Server).delete,
+	"incr":      (*Server).incr,
+	"decr":      (*Server).incr,
+	"touch":     (*Server).touch,
+	"version":   (*Server).version,
+	"verbosity": (*Server).verbosity,
+	"stats":     (*Server).stats,
+	"quit":      (*Server).quit,
+
+	// Meta protocol
+	"mg": (*Server).metaGet,
+	"ms": (*Server).metaSet,
+	"md": (*Server).metaDelete,
+	"ma": (*Server).metaArithmetic,
+	"mn": (*Server).metaNoop,
+}
+
+// commandMetrics holds the per-command counters, e.g.
+// memcache_commands_total{command="get"}.
+var commandMetrics = make(map[string]metrics.MetricKey)
+
+func init() {
+	for name := range commands {
+		commandMetrics[name] = metrics.Labeled(metrics.MemcacheCommandsTotal, "command", name)
+	}
+}
+
+// Errors of read-modify-write commands.
+var (
+	errNotFound   = errors.New("not found")
+	errNonNumeric = errors.New("cannot increment or decrement non-numeric value")
+)
+
+const errBadFormat = "bad command line format"
+
+// dispatch runs one command. Command names are case-sensitive, as in memcached.
+func (s *Server) dispatch(c *conn, args [][]byte) error {
+	name := string(args[0])
+	s.metrics.Inc(metrics.MemcacheCommandsTotal)
+
+	cmd, ok := commands[name]
+	if !ok {
+		c.unknownCommand()
+		return nil
+	}
+	s.metrics.Inc(commandMetrics[name])
+
+	c.noreply = false
+	defer func() { c.noreply = false }()
+	return cmd(s, c, args)
+}
+
+// noreply checks the argument count of a command taking want arguments
+// (its name included) and an optional "noreply"; set reports "noreply".
+func noreply(args [][]byte, want int) (set, ok bool) {
+	switch {
+	case len(args) == want:
+		return false, true
+	case len(args) == want+1 && string(args[want]) == "noreply":
+		return true, true
+	}
+	return false, false
+}
+
+// write stores a value and replicates it, like the HTTP API does.
+func (s *Server) write(key string, entry store.Entry, cond store.Precondition) (store.Entry, error) {
+	stored, err := s.store.SetIf(key, entry, cond)
+	if err != nil {
+		return store.Entry{}, err
+	}
+	s.replicator.Replicate(context.Background(), s.store.Default().Name(), key, stored)
+	return stored, nil
+}
+
+// remove deletes a present key and replicates the tombstone.
+func (s *Server) remove(key string, cond store.Precondition) error {
+	cond.IfPresent = true
+	_, err := s.write(key, store.Tombstone(time.Now().UnixNano()), cond)
+	return err
+}
+
+// compareFailed words a failed compare-and-swap: exists when the key is
+// present at another version, missing otherwise.
+func (s *Server) compareFailed(key, exists, missing string) string {
+	if _, ok := s.store.Get(key); ok {
+		return exists
+	}
+	return missing
+}
+
+// casCondition guards a write on a CAS token. Versions start at 1, so a
+// zero token never matches.
+func casCondition(cas uint64) store.Precondition {
+	if cas == 0 {
+		return store.Precondition{IfMatch: math.MaxUint64}
+	}
+	return store.Precondition{IfMatch: cas}
+}
+
+// arithmetic adds delta to the number at key, or subtracts it when decr
+// is set; decrements stop at zero, as in memcached.
+//
+// Values written by set are converted to counters (see store.Counter) on
+// their first increment, seeding this node's slot with the stored number.
+// Two nodes converting the same value concurrently both count it.
+//
+// A missing key fails with errNotFound unless seed is set, in which case
+// the key is created with seed's value and returned without applying delta.
+func (s *Server) arithmetic(key string, delta uint64, decr bool, seed *store.Entry) (store.Entry, error) {
+	for range maxCASRetries {
+		entry, ok := s.store.Get(key)
+		if !ok {
+			if seed == nil {
+				return store.Entry{}, errNotFound
+			}
+			created, err := s.write(key, *seed, store.Precondition{IfAbsent: true})
+			if errors.Is(err, store.ErrPreconditionFailed) {
+				continue
+			}
+			return created, err
+		}
+
+		if entry.Counter == nil {
+			converted, err := s.toCounter(key, entry)
+			if errors.Is(err, store.ErrPreconditionFailed) {
+				continue
+			}
+			if err != nil {
+				return store.Entry{}, err
+			}
+			entry = converted
+		}
+
+		d := int64(delta)
+		if decr {
+			d = -min(d, max(entry.Counter.Value(), 0))
+		}
+		if d == 0 {
+			return entry, nil
+		}
+
+		stored, err := s.store.Incr(key, s.nodeID, d, 0)
+		if errors.Is(err, store.ErrNotCounter) {
+			continue // overwritten by set in the meantime
+		}
+		if err != nil {
+			return store.Entry{}, err
+		}
+		s.replicator.Replicate(context.Background(), s.store.Default().Name(), key, stored)
+		return stored, nil
+	}
+	return store.Entry{}, errors.New("too much contention")
+}
+
+// toCounter rewrites a plain decimal value as a counter, if it was not
+// changed since it was read.
+func (s *Server) toCounter(key string, entry store.Entry) (store.Entry, error) {
+	// Memcached numbers are unsigned.
+	if _, ok := parseUint(entry.Value, 63); !ok {
+		return store.Entry{}, errNonNumeric
+	}
+
+	converted, _ := store.AsCounter(entry, s.nodeID)
+	return s.write(key, converted, store.Precondition{IfMatch: entry.Version})
+}
+
+// newCounter returns a counter of the given epoch holding n in nodeID's slot.
+func newCounter(nodeID string, n uint64, epoch int64) *store.Counter {
+	return &store.Counter{
+		Epoch: epoch,
+		P:     map[string]int64{nodeID: int64(n)},
+		N:     map[string]int64{},
+	}
+}
+
+/* ---------------- Retrieval ---------------- */
+
+// get: get|gets <key>*
+//
+// gets also returns each item's CAS token.
+func (s *Server) get(c *conn, args [][]byte) error {
+	if len(args) < 2 {
+		c.unknownCommand()
+		return nil
+	}
+
+	keys := make([]string, len(args)-1)
+	for i, key := range args[1:] {
+		if !validKey(key) {
+			c.clientError(errBadFormat)
+			return nil
+		}
+		keys[i] = string(key)
+	}
+	withCAS := string(args[0]) == "gets"
+
+	for i, res := range s.store.GetMany(keys) {
+		if !res.Found {
+			s.metrics.Inc(metrics.MemcacheGetMissesTotal)
+			continue
+		}
+		s.metrics.Inc(metrics.MemcacheGetHitsTotal)
+
+		fmt.Fprintf(c.writer, "VALUE %s %d %d", keys[i], res.Entry.Flags, len(res.Entry.Value))
+		if withCAS {
+			fmt.Fprintf(c.writer, " %d", res.Entry.Version)
+		}
+		c.writer.WriteString("\r\n")
+		c.writer.Write(res.Entry.Value)
+		c.writer.WriteString("\r\n")
+	}
+	c.writer.WriteString("END\r\n")
+	return nil
+}
+
+/* ---------------- Storage ---------------- */
+
+// storage: set|add|replace <key> <flags> <exptime> <bytes> [noreply]
+// and cas <key> <flags> <exptime> <bytes> <cas unique> [noreply],
+// each followed by a data block.
+//
+// Replies STORED, or NOT_STORED when add finds the key present, replace
+// finds it missing, or a newer write already exists. cas replies EXISTS
+// when the key changed since the token was read and NOT_FOUND when it is gone.
+func (s *Server) storage(c *conn, args [][]byte) error {
+	name := string(args[0])
+	args = args[1:]
+
+	want := 4
+	if name == "cas" {
+		want = 5
+	}
+	quiet, ok := noreply(args, want)
+	if !ok {
+		c.unknownCommand()
+		return nil
+	}
+	c.noreply = quiet
+
+	size, err := strconv.Atoi(string(args[3]))
+	if err != nil || size < 0 {
+		c.clientError(errBadFormat)
+		return nil
+	}
+
+	flags, flagsOK := parseUint(args[1], 32)
+	exptime, exptimeOK := parseExptime(args[2])
+	var cas uint64
+	casOK := true
+	if name == "cas" {
+		cas, casOK = parseUint(args[4], 64)
+	}
+	switch {
+	case !validKey(args[0]) || !flagsOK || !exptimeOK || !casOK:
+		c.clientError(errBadFormat)
+		return c.reader.Discard(size)
+	case size > maxValueLen:
+		c.serverError("object too large for cache")
+		return c.reader.Discard(size)
+	}
+
+	data, err := c.reader.ReadData(size)
+	if err != nil {
+		return err
+	}
+
+	key := string(args[0])
+	now := time.Now()
+	entry := store.Entry{
+		Value:     data,
+		Flags:     uint32(flags),
+		ExpiresAt: expiry(exptime, now),
+		Timestamp: now.UnixNano(),
+	}
+
+	var cond store.Precondition
+	switch name {
+	case "add":
+		cond.IfAbsent = true
+	case "replace":
+		cond.IfPresent = true
+	case "cas":
+		cond = casCondition(cas)
+	}
+
+	_, err = s.write(key, entry, cond)
+	switch {
+	case err == nil:
+		c.reply("STORED")
+	case name == "cas" && errors.Is(err, store.ErrPreconditionFailed):
+		c.reply(s.compareFailed(key, "EXISTS", "NOT_FOUND"))
+	default:
+		c.reply("NOT_STORED")
+	}
+	return nil
+}
+
+// delete: delete <key> [0] [noreply]
+//
+// The legacy hold time is accepted when it is 0.
+func (s *Server) delete(c *conn, args [][]byte) error {
+	if len(args) > 2 && string(args[2]) == "0" {
+		args = append(args[:2], args[3:]...)
+	}
+	quiet, ok := noreply(args, 2)
+	if !ok {
+		c.clientError(errBadFormat + ".  Usage: delete <key> [noreply]")
+		return nil
+	}
+	c.noreply = quiet
+
+	if !validKey(args[1]) {
+		c.clientError(errBadFormat)
+		return nil
+	}
+
+	if err := s.remove(string(args[1]), store.Precondition{}); err != nil {
+		c.reply("NOT_FOUND")
+		return nil
+	}
+	c.reply("DELETED")
+	return nil
+}
+
+// incr: incr|decr <key> <value> [noreply], replying with the new value.
+func (s *Server) incr(c *conn, args [][]byte) error {
+	quiet, ok := noreply(args, 3)
+	if !ok {
+		c.unknownCommand()
+		return nil
+	}
+	c.noreply = quiet
+
+	if !validKey(args[1]) {
+		c.clientError(errBadFormat)
+		return nil
+	}
+	delta, ok := parseUint(args[2], 63)
+	if !ok {
+		c.clientError("invalid numeric delta argument")
+		return nil
+	}
+
+	stored, err := s.arithmetic(string(args[1]), delta, string(args[0]) == "decr", nil)
+	switch {
+	case errors.Is(err, errNotFound):
+		c.reply("NOT_FOUND")
+	case errors.Is(err, errNonNumeric), errors.Is(err, store.ErrCounterOverflow):
+		c.clientError(err.Error())
+	case err != nil:
+		c.serverError(err.Error())
+	default:
+		c.reply(string(stored.Value))
+	}
+	return nil
+}
+
+// touch: touch <key> <exptime> [noreply]
+func (s *Server) touch(c *conn, args [][]byte) error {
+	quiet, ok := noreply(args, 3)
+	if !ok {
+		c.unknownCommand()
+		return nil
+	}
+	c.noreply = quiet
+
+	exptime, ok := parseExptime(args[2])
+	if !validKey(args[1]) || !ok {
+		c.clientError(errBadFormat)
+		return nil
+	}
+
+	if _, ok := s.touchKey(string(args[1]), exptime); !ok {
+		c.reply("NOT_FOUND")
+		return nil
+	}
+	c.reply("TOUCHED")
+	return nil
+}
+
+// touchKey sets the expiry of a present key and replicates it.
+func (s *Server) touchKey(key string, exptime int64) (store.Entry, bool) {
+	stored, ok := s.store.Expire(key, expiry(exptime, time.Now()))
+	if ok {
+		s.replicator.Replicate(context.Background(), s.store.Default().Name(), key, stored)
+	}
+	return stored, ok
+}
+
+/* ---------------- Server ---------------- */
+
+// version: version
+func (s *Server) version(c *conn, args [][]byte) error {
+	c.reply("VERSION " + memcachedVersion)
+	return nil
+}
+
+// verbosity: verbosity <level> [noreply]; the level is ignored.
+func (s *Server) verbosity(c *conn, args [][]byte) error {
+	quiet, ok := noreply(args, 2)
+	if !ok {
+		c.unknownCommand()
+		return nil
+	}
+	c.noreply = quiet
+	c.reply("OK")
+	return nil
+}
+
+// stats: stats [group]
+//
+// Only the general statistics exist; other groups are empty.
+func (s *Server) stats(c *conn, args [][]byte) error {
+	if len(args) > 1 {
+		c.reply("END")
+		return nil
+	}
+
+	snap := s.metrics.Snapshot()
+	commandCount := func(names ...string) int64 {
+		var total int64
+		for _, name := range names {
+			total += snap[string(commandMetrics[name])]
+		}
+		return total
+	}
+
+	stat := func(name string, value any) {
+		fmt.Fprintf(c.writer, "STAT %s %v\r\n", name, value)
+	}
+	stat("pid", os.Getpid())
+	stat("uptime", int64(time.Since(s.startedAt)/time.Second))
+	stat("time", time.Now().Unix())
+	stat("version", memcachedVersion)
+	stat("cache_version", version.Version)
+	stat("node_id", s.nodeID)
+	stat("curr_connections", s.tcp.NumConns())
+	stat("total_connections", snap[string(metrics.MemcacheConnectionsTotal)])
+	stat("cmd_get", commandCount("get", "gets", "mg"))
+	stat("cmd_set", commandCount("set", "add", "replace", "cas", "ms"))
+	stat("cmd_touch", commandCount("touch"))
+	stat("get_hits", snap[string(metrics.MemcacheGetHitsTotal)])
+	stat("get_misses", snap[string(metrics.MemcacheGetMissesTotal)])
+	stat("curr_items", s.store.Default().Len())
+	stat("bytes", s.store.Default().Bytes())
+	c.reply("END")
+	return nil
+}
+
+// quit: quit, closing the connection without a reply.
+func (s *Server) quit(c *conn, args [][]byte) error {
+	c.quit = true
+	return nil
+}
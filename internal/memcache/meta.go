@@ -0,0 +1,428 @@
+package memcache
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/store"
+)
+
+// Meta commands take a key followed by single-letter flags, some with a
+// token: "mg foo v f t". Flags asking for data are answered in the order
+// they were given. Supported flags:
+// - b: the key is base64-encoded (binary keys)
+// - c: return the CAS token
+// - f: return the client flags
+// - k: return the key
+// - O(token): return an opaque token, to match pipelined replies
+// - q: quiet mode, hiding the HD, EN and NF replies
+// - s: return the value size
+// - t: return the remaining TTL in seconds, -1 without expiry
+// - v: return the value
+// - C(token): compare the CAS token before writing
+// - F(token): client flags to store
+// - T(token): exptime to store, or to update on reads
+// - M(token): mode; see metaSet and metaArithmetic
+// - N(token), J(token), D(token): see metaArithmetic
+
+// metaFlag is one flag of a meta command and its token, if any.
+type metaFlag struct {
+	name  byte
+	token string
+}
+
+// metaRequest is a parsed meta command.
+type metaRequest struct {
+	key      string
+	rawKey   string // as sent, base64 with the b flag
+	flags    []metaFlag
+	quiet    bool
+	valueLen int // data length of ms
+}
+
+// has reports whether the flag was given.
+func (r *metaRequest) has(name byte) bool {
+	_, ok := r.token(name)
+	return ok
+}
+
+// token returns the token of a flag.
+func (r *metaRequest) token(name byte) (string, bool) {
+	for _, f := range r.flags {
+		if f.name == name {
+			return f.token, true
+		}
+	}
+	return "", false
+}
+
+// numToken parses the numeric token of a flag; def is used when the flag
+// is absent.
+func (r *metaRequest) numToken(name byte, bits int, def uint64) (uint64, bool) {
+	token, ok := r.token(name)
+	if !ok {
+		return def, true
+	}
+	return parseUint([]byte(token), bits)
+}
+
+// exptimeToken parses the exptime token of a flag.
+func (r *metaRequest) exptimeToken(name byte) (int64, bool, bool) {
+	token, ok := r.token(name)
+	if !ok {
+		return 0, false, true
+	}
+	exptime, valid := parseExptime([]byte(token))
+	return exptime, true, valid
+}
+
+// parseMeta parses "<key> <flags>*", accepting the flags in allowed.
+func parseMeta(key []byte, flags [][]byte, allowed string) (*metaRequest, string) {
+	req := &metaRequest{rawKey: string(key)}
+	for _, f := range flags {
+		if !strings.ContainsRune(allowed, rune(f[0])) {
+			return nil, "invalid flag"
+		}
+		req.flags = append(req.flags, metaFlag{name: f[0], token: string(f[1:])})
+	}
+	req.quiet = req.has('q')
+
+	if req.has('b') {
+		decoded, err := base64.StdEncoding.DecodeString(req.rawKey)
+		if err != nil || len(decoded) == 0 || len(decoded) > maxKeyLen {
+			return nil, "error decoding key"
+		}
+		req.key = string(decoded)
+	} else {
+		if !validKey(key) {
+			return nil, errBadFormat
+		}
+		req.key = req.rawKey
+	}
+	return req, ""
+}
+
+// metaReply writes a reply code followed by the return flags asked for.
+// In quiet mode, HD, EN and NF are not sent.
+func (c *conn) metaReply(req *metaRequest, code string, entry store.Entry) {
+	if req.quiet && (code == "HD" || code == "EN" || code == "NF") {
+		return
+	}
+
+	c.writer.WriteString(code)
+	if code == "VA" {
+		fmt.Fprintf(c.writer, " %d", len(entry.Value))
+	}
+	if code == "HD" || code == "VA" {
+		c.writeReturnFlags(req, entry)
+	} else {
+		c.writeEchoFlags(req)
+	}
+	c.writer.WriteString("\r\n")
+
+	if code == "VA" {
+		c.writer.Write(entry.Value)
+		c.writer.WriteString("\r\n")
+	}
+}
+
+func (c *conn) writeReturnFlags(req *metaRequest, entry store.Entry) {
+	for _, f := range req.flags {
+		switch f.name {
+		case 'c':
+			fmt.Fprintf(c.writer, " c%d", entry.Version)
+		case 'f':
+			fmt.Fprintf(c.writer, " f%d", entry.Flags)
+		case 's':
+			fmt.Fprintf(c.writer, " s%d", len(entry.Value))
+		case 't':
+			fmt.Fprintf(c.writer, " t%d", remainingTTL(entry.ExpiresAt))
+		case 'b', 'k', 'O':
+			c.writeEchoFlag(req, f)
+		}
+	}
+}
+
+// writeEchoFlags writes the flags returned on every reply: the key and
+// the opaque token.
+func (c *conn) writeEchoFlags(req *metaRequest) {
+	for _, f := range req.flags {
+		c.writeEchoFlag(req, f)
+	}
+}
+
+func (c *conn) writeEchoFlag(req *metaRequest, f metaFlag) {
+	switch f.name {
+	case 'b':
+		if req.has('k') {
+			c.writer.WriteString(" b")
+		}
+	case 'k':
+		c.writer.WriteString(" k" + req.rawKey)
+	case 'O':
+		c.writer.WriteString(" O" + f.token)
+	}
+}
+
+/* ---------------- mg ---------------- */
+
+// metaGet: mg <key> <flags>*
+//
+// Replies VA with the value when v is given, HD without it and EN on a miss.
+// T updates the expiry of a hit, like touch.
+func (s *Server) metaGet(c *conn, args [][]byte) error {
+	if len(args) < 2 {
+		c.unknownCommand()
+		return nil
+	}
+	req, msg := parseMeta(args[1], args[2:], "bcfkOqstvT")
+	if msg != "" {
+		c.clientError(msg)
+		return nil
+	}
+	exptime, touch, ok := req.exptimeToken('T')
+	if !ok {
+		c.clientError("bad token in command line format")
+		return nil
+	}
+
+	entry, found := s.store.Get(req.key)
+	if found && touch {
+		entry, found = s.touchKey(req.key, exptime)
+	}
+	if !found {
+		s.metrics.Inc(metrics.MemcacheGetMissesTotal)
+		c.metaReply(req, "EN", store.Entry{})
+		return nil
+	}
+	s.metrics.Inc(metrics.MemcacheGetHitsTotal)
+
+	if req.has('v') {
+		c.metaReply(req, "VA", entry)
+		return nil
+	}
+	c.metaReply(req, "HD", entry)
+	return nil
+}
+
+/* ---------------- ms ---------------- */
+
+// metaSet: ms <key> <datalen> <flags>*, followed by a data block.
+//
+// Modes (M): S set (the default), E add, R replace. Replies HD when
+// stored, NS when the mode's condition fails, EX when the C token does
+// not match and NF when C is given for a missing key.
+func (s *Server) metaSet(c *conn, args [][]byte) error {
+	if len(args) < 3 {
+		c.unknownCommand()
+		return nil
+	}
+	size, err := strconv.Atoi(string(args[2]))
+	if err != nil || size < 0 {
+		c.clientError("bad data chunk")
+		return nil
+	}
+
+	req, msg := parseMeta(args[1], args[3:], "bcCFkOqTM")
+	if msg == "" {
+		msg = req.validateSet()
+	}
+	switch {
+	case msg != "":
+		c.clientError(msg)
+		return c.reader.Discard(size)
+	case size > maxValueLen:
+		c.serverError("object too large for cache")
+		return c.reader.Discard(size)
+	}
+
+	data, err := c.reader.ReadData(size)
+	if err != nil {
+		return err
+	}
+
+	flags, _ := req.numToken('F', 32, 0)
+	exptime, _, _ := req.exptimeToken('T')
+	now := time.Now()
+	entry := store.Entry{
+		Value:     data,
+		Flags:     uint32(flags),
+		ExpiresAt: expiry(exptime, now),
+		Timestamp: now.UnixNano(),
+	}
+
+	var cond store.Precondition
+	cas, compare := req.token('C')
+	if compare {
+		n, _ := parseUint([]byte(cas), 64)
+		cond = casCondition(n)
+	}
+	mode, _ := req.token('M')
+	switch strings.ToUpper(mode) {
+	case "E":
+		cond.IfAbsent = true
+	case "R":
+		cond.IfPresent = true
+	}
+
+	stored, err := s.write(req.key, entry, cond)
+	switch {
+	case err == nil:
+		c.metaReply(req, "HD", stored)
+	case compare && errors.Is(err, store.ErrPreconditionFailed):
+		c.metaReply(req, s.compareFailed(req.key, "EX", "NF"), store.Entry{})
+	default:
+		c.metaReply(req, "NS", store.Entry{})
+	}
+	return nil
+}
+
+// validateSet checks the tokens of ms, returning an error message.
+func (r *metaRequest) validateSet() string {
+	if _, ok := r.numToken('F', 32, 0); !ok {
+		return "bad token in command line format"
+	}
+	if _, ok := r.numToken('C', 64, 0); !ok {
+		return "bad token in command line format"
+	}
+	if _, _, ok := r.exptimeToken('T'); !ok {
+		return "bad token in command line format"
+	}
+	switch mode, _ := r.token('M'); strings.ToUpper(mode) {
+	case "", "S", "E", "R":
+		return ""
+	}
+	return "invalid mode for ms STORE"
+}
+
+/* ---------------- md ---------------- */
+
+// metaDelete: md <key> <flags>*
+//
+// Replies HD when deleted, NF for a missing key and EX when the C token
+// does not match.
+func (s *Server) metaDelete(c *conn, args [][]byte) error {
+	if len(args) < 2 {
+		c.unknownCommand()
+		return nil
+	}
+	req, msg := parseMeta(args[1], args[2:], "bCkOq")
+	if msg != "" {
+		c.clientError(msg)
+		return nil
+	}
+
+	var cond store.Precondition
+	cas, compare := req.token('C')
+	if compare {
+		n, ok := parseUint([]byte(cas), 64)
+		if !ok {
+			c.clientError("bad token in command line format")
+			return nil
+		}
+		cond = casCondition(n)
+	}
+
+	switch err := s.remove(req.key, cond); {
+	case err == nil:
+		c.metaReply(req, "HD", store.Entry{})
+	case compare:
+		c.metaReply(req, s.compareFailed(req.key, "EX", "NF"), store.Entry{})
+	default:
+		c.metaReply(req, "NF", store.Entry{})
+	}
+	return nil
+}
+
+/* ---------------- ma ---------------- */
+
+// metaArithmetic: ma <key> <flags>*
+//
+// Modes (M): I or + increments (the default), D or - decrements; D sets
+// the delta, 1 by default. N creates a missing key with the exptime of its
+// token and the initial value J (0 by default), as incr cannot. T updates
+// the expiry. Replies HD, or VA with the new value when v is given, and NF
+// for a missing key.
+func (s *Server) metaArithmetic(c *conn, args [][]byte) error {
+	if len(args) < 2 {
+		c.unknownCommand()
+		return nil
+	}
+	req, msg := parseMeta(args[1], args[2:], "bcktvOqNJDTM")
+	if msg != "" {
+		c.clientError(msg)
+		return nil
+	}
+
+	delta, deltaOK := req.numToken('D', 63, 1)
+	initial, initialOK := req.numToken('J', 63, 0)
+	vivify, create, vivifyOK := req.exptimeToken('N')
+	exptime, touch, exptimeOK := req.exptimeToken('T')
+	if !deltaOK || !initialOK || !vivifyOK || !exptimeOK {
+		c.clientError("bad token in command line format")
+		return nil
+	}
+
+	var decr bool
+	switch mode, _ := req.token('M'); mode {
+	case "", "I", "i", "+":
+	case "D", "d", "-":
+		decr = true
+	default:
+		c.clientError("invalid mode for ma")
+		return nil
+	}
+
+	var seed *store.Entry
+	if create {
+		// A vivified counter starts its own epoch, so it replaces any
+		// counter a peer still holds from before the key was deleted.
+		now := time.Now()
+		seed = &store.Entry{
+			Value:     []byte(strconv.FormatUint(initial, 10)),
+			Counter:   newCounter(s.nodeID, initial, now.UnixNano()),
+			ExpiresAt: expiry(vivify, now),
+			Timestamp: now.UnixNano(),
+		}
+	}
+
+	entry, err := s.arithmetic(req.key, delta, decr, seed)
+	switch {
+	case errors.Is(err, errNotFound):
+		c.metaReply(req, "NF", store.Entry{})
+		return nil
+	case errors.Is(err, errNonNumeric), errors.Is(err, store.ErrCounterOverflow):
+		c.clientError(err.Error())
+		return nil
+	case err != nil:
+		c.serverError(err.Error())
+		return nil
+	}
+
+	if touch {
+		if touched, ok := s.touchKey(req.key, exptime); ok {
+			entry = touched
+		}
+	}
+
+	if req.has('v') {
+		c.metaReply(req, "VA", entry)
+		return nil
+	}
+	c.metaReply(req, "HD", entry)
+	return nil
+}
+
+/* ---------------- mn ---------------- */
+
+// metaNoop: mn, replying MN. Clients send it after quiet commands to
+// learn that every earlier reply has arrived.
+func (s *Server) metaNoop(c *conn, args [][]byte) error {
+	c.writer.WriteString("MN\r\n")
+	return nil
+}
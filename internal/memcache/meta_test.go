@@ -0,0 +1,131 @@
+package memcache
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeta_GetSet(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	assert.Equal(t, "EN", c.do("mg k v"))
+	assert.Equal(t, "HD", c.do("ms k 5 F9 T100", "hello"))
+
+	entry, ok := ts.store.Get("k")
+	require.True(t, ok)
+	assert.Equal(t, uint32(9), entry.Flags)
+
+	c.send("mg k s v f t c k Oabc")
+	assert.Equal(t, fmt.Sprintf("VA 5 s5 f9 t100 c%d kk Oabc", entry.Version), c.line())
+	assert.Equal(t, "hello", c.line())
+
+	assert.Equal(t, "HD f9", c.do("mg k f"), "without v there is no value")
+	assert.Equal(t, "EN kmissing Ox", c.do("mg missing v k Ox"), "misses echo the key and opaque")
+	assert.Equal(t, fmt.Sprintf("HD c%d", entry.Version+1), c.do("ms k 1 c", "x"), "c returns the new token")
+}
+
+func TestMeta_SetModes(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	assert.Equal(t, "NS", c.do("ms k 1 MR", "a"), "replace needs the key")
+	assert.Equal(t, "HD", c.do("ms k 1 ME", "a"))
+	assert.Equal(t, "NS", c.do("ms k 1 ME", "b"), "add needs the key missing")
+	assert.Equal(t, "HD", c.do("ms k 1 Mr", "c"))
+	assert.Equal(t, "CLIENT_ERROR invalid mode for ms STORE", c.do("ms k 1 MA", "d"))
+
+	entry, _ := ts.store.Get("k")
+	assert.Equal(t, "EX", c.do(fmt.Sprintf("ms k 1 C%d", entry.Version+1), "e"))
+	assert.Equal(t, "HD", c.do(fmt.Sprintf("ms k 1 C%d", entry.Version), "e"))
+	assert.Equal(t, "NF", c.do("ms missing 1 C1", "e"))
+}
+
+func TestMeta_Delete(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	c.set("k", "v")
+	entry, _ := ts.store.Get("k")
+
+	assert.Equal(t, "EX Oq", c.do(fmt.Sprintf("md k C%d Oq", entry.Version+1)))
+	assert.Equal(t, "HD kk", c.do(fmt.Sprintf("md k C%d k", entry.Version)))
+	assert.Equal(t, "NF", c.do("md k"))
+}
+
+func TestMeta_Arithmetic(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	assert.Equal(t, "NF", c.do("ma n"))
+
+	c.send("ma n N0 J10 v")
+	assert.Equal(t, []string{"VA 2", "10"}, []string{c.line(), c.line()}, "N creates the key with J")
+
+	assert.Equal(t, "HD", c.do("ma n"))
+	c.send("ma n D5 MD v t")
+	assert.Equal(t, []string{"VA 1 t-1", "6"}, []string{c.line(), c.line()})
+
+	c.send("ma n M- D100 v T60 t")
+	assert.Equal(t, []string{"VA 1 t60", "0"}, []string{c.line(), c.line()}, "T updates the expiry")
+
+	assert.Equal(t, "CLIENT_ERROR invalid mode for ma", c.do("ma n MX"))
+	assert.Equal(t, "CLIENT_ERROR bad token in command line format", c.do("ma n Dlots"))
+}
+
+func TestMeta_Touch(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	c.set("k", "v")
+	assert.Equal(t, "HD t30", c.do("mg k T30 t"))
+
+	entry, _ := ts.store.Get("k")
+	assert.WithinDuration(t, time.Now().Add(30*time.Second), entry.ExpiresAt, 2*time.Second)
+}
+
+func TestMeta_Base64Keys(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	key := base64.StdEncoding.EncodeToString([]byte("binary key\x00"))
+	assert.Equal(t, "HD", c.do(fmt.Sprintf("ms %s 1 b", key), "v"))
+
+	_, ok := ts.store.Get("binary key\x00")
+	assert.True(t, ok)
+	assert.Equal(t, fmt.Sprintf("HD b k%s", key), c.do(fmt.Sprintf("mg %s b k", key)))
+	assert.Equal(t, "CLIENT_ERROR error decoding key", c.do("mg !!! b"))
+}
+
+func TestMeta_QuietMode(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	c.send(
+		"ms a 1 q", "1",
+		"mg missing v q",
+		"md missing q",
+		"ms a 1 ME q Oadd", "2", // NS is still sent
+		"mg a v q",
+		"mn",
+	)
+	assert.Equal(t, []string{"NS Oadd", "VA 1", "1", "MN"}, c.until("MN"))
+}
+
+func TestMeta_Errors(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	assert.Equal(t, "CLIENT_ERROR invalid flag", c.do("mg k z"))
+	assert.Equal(t, "CLIENT_ERROR bad data chunk", c.do("ms k x"))
+	assert.Equal(t, "ERROR", c.do("mg"))
+
+	// A bad flag swallows the data block.
+	assert.Equal(t, "CLIENT_ERROR bad token in command line format", c.do("ms k 1 Fx", "a"))
+	assert.Equal(t, "EN", c.do("mg k"))
+}
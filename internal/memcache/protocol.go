@@ -0,0 +1,150 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Protocol limits. Keys are limited as in memcached; maxValueLen matches
+// the value limit of the HTTP API.
+const (
+	maxKeyLen   = 250
+	maxLineLen  = 8 << 10
+	maxValueLen = 64 << 20
+)
+
+// maxRelativeExptime is the largest exptime taken as relative seconds (30
+// days); larger values are absolute Unix times, as in memcached.
+const maxRelativeExptime = 60 * 60 * 24 * 30
+
+// protocolError is input the connection cannot recover from. It is replied
+// to as a CLIENT_ERROR, then the connection is closed.
+type protocolError string
+
+func (e protocolError) Error() string {
+	return string(e)
+}
+
+// Reader reads client command lines and the data blocks that follow
+// storage commands.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader wraps r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Buffered reports whether more pipelined input is already buffered,
+// so replies can be flushed once per batch.
+func (r *Reader) Buffered() bool {
+	return r.r.Buffered() > 0
+}
+
+// ReadCommand returns the words of the next command line, the command
+// name first. Empty lines are skipped.
+func (r *Reader) ReadCommand() ([][]byte, error) {
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if args := bytes.Fields(line); len(args) > 0 {
+			return args, nil
+		}
+	}
+}
+
+// ReadData reads a data block of n bytes and its CRLF terminator.
+func (r *Reader) ReadData(n int) ([]byte, error) {
+	buf := make([]byte, n+2)
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		return nil, err
+	}
+	if buf[n] != '\r' || buf[n+1] != '\n' {
+		return nil, protocolError("bad data chunk")
+	}
+	return buf[:n], nil
+}
+
+// Discard skips a data block of n bytes and its terminator, e.g. one that
+// is too large to store.
+func (r *Reader) Discard(n int) error {
+	_, err := r.r.Discard(n + 2)
+	return err
+}
+
+// readLine reads one line without its CRLF (or bare LF).
+func (r *Reader) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.r.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, chunk...)
+		if len(line) > maxLineLen {
+			return nil, protocolError("line too long")
+		}
+		if !isPrefix {
+			return line, nil
+		}
+	}
+}
+
+// validKey reports whether key is a valid memcached key: 1 to 250 bytes
+// without spaces or control characters.
+func validKey(key []byte) bool {
+	if len(key) == 0 || len(key) > maxKeyLen {
+		return false
+	}
+	for _, b := range key {
+		if b <= ' ' || b == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// parseUint parses a decimal argument of the given bit size.
+func parseUint(arg []byte, bits int) (uint64, bool) {
+	n, err := strconv.ParseUint(string(arg), 10, bits)
+	return n, err == nil
+}
+
+// parseExptime parses an exptime argument; see expiry.
+func parseExptime(arg []byte) (int64, bool) {
+	n, err := strconv.ParseInt(string(arg), 10, 64)
+	return n, err == nil
+}
+
+// expiry converts a memcached exptime to Entry.ExpiresAt:
+// - 0 never expires (the zero time)
+// - up to 30 days is relative, in seconds from now
+// - larger values are absolute Unix times
+// - negative values expire the item immediately
+func expiry(exptime int64, now time.Time) time.Time {
+	switch {
+	case exptime == 0:
+		return time.Time{}
+	case exptime < 0:
+		return now.Add(-time.Second)
+	case exptime <= maxRelativeExptime:
+		return now.Add(time.Duration(exptime) * time.Second)
+	default:
+		return time.Unix(exptime, 0)
+	}
+}
+
+// remainingTTL returns the seconds until expiresAt, or -1 without expiry,
+// as reported by the meta t flag.
+func remainingTTL(expiresAt time.Time) int64 {
+	if expiresAt.IsZero() {
+		return -1
+	}
+	return max(int64((time.Until(expiresAt)+time.Second/2)/time.Second), 0)
+}
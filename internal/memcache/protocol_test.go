@@ -0,0 +1,58 @@
+package memcache
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"distributed-cache/internal/tcpserver/tcptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReader(t *testing.T) {
+	r := NewReader(strings.NewReader("set k 0 0 4\r\na\r\nb\r\n\r\nget  k\n"))
+
+	args, err := r.ReadCommand()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"set", "k", "0", "0", "4"}, tcptest.Words(args))
+
+	data, err := r.ReadData(4)
+	require.NoError(t, err)
+	assert.Equal(t, "a\r\nb", string(data), "data blocks are binary-safe")
+
+	args, err = r.ReadCommand()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"get", "k"}, tcptest.Words(args), "empty lines are skipped")
+
+	_, err = r.ReadCommand()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestReader_Errors(t *testing.T) {
+	_, err := NewReader(strings.NewReader("abcdef")).ReadData(4)
+	assert.Equal(t, protocolError("bad data chunk"), err)
+
+	_, err = NewReader(strings.NewReader(strings.Repeat("a", maxLineLen+1) + "\r\n")).ReadCommand()
+	assert.Equal(t, protocolError("line too long"), err)
+}
+
+func TestValidKey(t *testing.T) {
+	assert.True(t, validKey([]byte("user:1")))
+	assert.True(t, validKey([]byte(strings.Repeat("k", maxKeyLen))))
+	assert.False(t, validKey(nil))
+	assert.False(t, validKey([]byte(strings.Repeat("k", maxKeyLen+1))))
+	assert.False(t, validKey([]byte("a\x01b")))
+}
+
+func TestExpiry(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+
+	assert.True(t, expiry(0, now).IsZero(), "0 never expires")
+	assert.Equal(t, now.Add(time.Minute), expiry(60, now), "relative seconds")
+	assert.Equal(t, now.Add(30*24*time.Hour), expiry(maxRelativeExptime, now))
+	assert.Equal(t, time.Unix(1_800_000_000, 0), expiry(1_800_000_000, now), "absolute Unix time")
+	assert.True(t, expiry(-1, now).Before(now), "negative values expire immediately")
+}
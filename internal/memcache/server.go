@@ -0,0 +1,171 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"distributed-cache/internal/logs"
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/replication"
+	"distributed-cache/internal/store"
+	"distributed-cache/internal/tcpserver"
+)
+
+// ErrServerClosed is returned by Serve after Shutdown.
+var ErrServerClosed = tcpserver.ErrServerClosed
+
+// Server serves the store to memcached clients over the text and meta
+// protocols.
+//
+// Commands act on the default namespace. Writes go through the same store
+// methods as the HTTP API and are replicated to peers the same way,
+// without waiting for acknowledgements. CAS tokens are the keys' versions.
+type Server struct {
+	nodeID     string
+	store      *store.Store
+	replicator *replication.Replicator
+	metrics    *metrics.Registry
+	logger     *logs.Logger
+	startedAt  time.Time
+
+	tcp *tcpserver.Server
+}
+
+// NewServer creates a memcached protocol server; call Serve or
+// ListenAndServe to start it.
+func NewServer(
+	nodeID string,
+	cacheStore *store.Store,
+	replicator *replication.Replicator,
+	metricsRegistry *metrics.Registry,
+	logger *logs.Logger,
+) *Server {
+	s := &Server{
+		nodeID:     nodeID,
+		store:      cacheStore,
+		replicator: replicator,
+		metrics:    metricsRegistry,
+		logger:     logger,
+		startedAt:  time.Now(),
+	}
+	s.tcp = tcpserver.New(s.serveConn, metricsRegistry, tcpserver.Metrics{
+		Connections:      metrics.MemcacheConnections,
+		ConnectionsTotal: metrics.MemcacheConnectionsTotal,
+	})
+	return s
+}
+
+// ListenAndServe listens on the TCP address addr and serves clients.
+func (s *Server) ListenAndServe(addr string) error {
+	return s.tcp.ListenAndServe(addr)
+}
+
+// Serve accepts clients on l until Shutdown; it always returns an error,
+// ErrServerClosed after Shutdown.
+func (s *Server) Serve(l net.Listener) error {
+	return s.tcp.Serve(l)
+}
+
+// Shutdown stops accepting clients, lets every connection finish the
+// command it is running and closes it. It returns ctx.Err() if ctx ends
+// first, after closing the remaining connections.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.tcp.Shutdown(ctx)
+}
+
+// conn is one client connection. Commands of a connection run one at a time.
+type conn struct {
+	*tcpserver.Conn
+	server *Server
+	reader *Reader
+	writer *bufio.Writer
+	quit   bool
+
+	// noreply is set while a text command asked for no reply.
+	noreply bool
+}
+
+func (s *Server) serveConn(tc *tcpserver.Conn) {
+	c := &conn{
+		Conn:   tc,
+		server: s,
+		reader: NewReader(tc),
+		writer: bufio.NewWriter(tc),
+	}
+
+	for {
+		args, err := c.reader.ReadCommand()
+		if err != nil {
+			var perr protocolError
+			if errors.As(err, &perr) {
+				c.clientError(string(perr))
+				_ = c.writer.Flush()
+			} else if !errors.Is(err, io.EOF) && !c.Closing() {
+				s.logger.Debug("memcache connection closed: " + err.Error())
+			}
+			return
+		}
+
+		if !c.Begin() {
+			_ = c.writer.Flush() // replies to earlier pipelined commands
+			return
+		}
+		err = s.dispatch(c, args)
+		c.End()
+
+		// A bad data block leaves the stream out of sync: reply and close.
+		var perr protocolError
+		if errors.As(err, &perr) {
+			c.clientError(string(perr))
+			c.quit = true
+		} else if err != nil {
+			if !errors.Is(err, io.EOF) && !c.Closing() {
+				s.logger.Debug("memcache connection closed: " + err.Error())
+			}
+			return
+		}
+
+		// Pipelined commands are answered with a single write.
+		done := c.quit || c.Closing()
+		if done || !c.reader.Buffered() {
+			if err := c.writer.Flush(); err != nil {
+				return
+			}
+		}
+		if done {
+			return
+		}
+	}
+}
+
+// reply writes a status line, unless the command asked for noreply.
+func (c *conn) reply(line string) {
+	if c.noreply {
+		return
+	}
+	c.writer.WriteString(line)
+	c.writer.WriteString("\r\n")
+}
+
+// unknownCommand writes the reply to an unknown command.
+func (c *conn) unknownCommand() {
+	c.server.metrics.Inc(metrics.MemcacheErrorsTotal)
+	c.writer.WriteString("ERROR\r\n")
+}
+
+// clientError writes an error caused by the request; errors are sent
+// even with noreply.
+func (c *conn) clientError(msg string) {
+	c.server.metrics.Inc(metrics.MemcacheErrorsTotal)
+	c.writer.WriteString("CLIENT_ERROR " + msg + "\r\n")
+}
+
+// serverError writes an error caused by the server.
+func (c *conn) serverError(msg string) {
+	c.server.metrics.Inc(metrics.MemcacheErrorsTotal)
+	c.writer.WriteString("SERVER_ERROR " + msg + "\r\n")
+}
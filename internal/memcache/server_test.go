@@ -0,0 +1,337 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/peers"
+	"distributed-cache/internal/replication"
+	"distributed-cache/internal/store"
+	"distributed-cache/internal/tcpserver/tcptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testServer struct {
+	*Server
+	addr    string
+	store   *store.Store
+	metrics *metrics.Registry
+	peers   *peers.PeerManager
+	served  <-chan error
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	node := tcptest.NewNode()
+	ts := &testServer{
+		Server:  NewServer(tcptest.NodeID, node.Store, node.Replicator, node.Metrics, node.Logger),
+		store:   node.Store,
+		metrics: node.Metrics,
+		peers:   node.Peers,
+	}
+	ts.addr, ts.served = tcptest.Serve(t, ts.Server)
+	return ts
+}
+
+// client is a minimal memcached client working on raw lines.
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dial(t *testing.T, ts *testServer) *client {
+	t.Helper()
+
+	conn := tcptest.Dial(t, ts.addr)
+	return &client{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+// send writes lines, each terminated by CRLF.
+func (c *client) send(lines ...string) {
+	c.t.Helper()
+
+	_, err := io.WriteString(c.conn, strings.Join(lines, "\r\n")+"\r\n")
+	require.NoError(c.t, err)
+}
+
+// line reads one reply line without its CRLF.
+func (c *client) line() string {
+	c.t.Helper()
+
+	_ = c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := c.r.ReadString('\n')
+	require.NoError(c.t, err)
+	return strings.TrimSuffix(line, "\r\n")
+}
+
+// do sends lines and returns the first reply line.
+func (c *client) do(lines ...string) string {
+	c.t.Helper()
+
+	c.send(lines...)
+	return c.line()
+}
+
+// until reads reply lines up to and including last.
+func (c *client) until(last string) []string {
+	c.t.Helper()
+
+	var lines []string
+	for {
+		line := c.line()
+		lines = append(lines, line)
+		if line == last {
+			return lines
+		}
+	}
+}
+
+func (c *client) set(key, value string) {
+	c.t.Helper()
+
+	require.Equal(c.t, "STORED", c.do(fmt.Sprintf("set %s 0 0 %d", key, len(value)), value))
+}
+
+func TestServer_GetSet(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	assert.Equal(t, "END", c.do("get k"))
+	assert.Equal(t, "STORED", c.do("set k 42 0 5", "hello"))
+
+	c.send("get k missing")
+	assert.Equal(t, []string{"VALUE k 42 5", "hello", "END"}, c.until("END"))
+
+	entry, ok := ts.store.Get("k")
+	require.True(t, ok)
+	assert.Equal(t, uint32(42), entry.Flags)
+
+	c.send("gets k")
+	assert.Equal(t, []string{fmt.Sprintf("VALUE k 42 5 %d", entry.Version), "hello", "END"}, c.until("END"))
+
+	snap := ts.metrics.Snapshot()
+	assert.Equal(t, int64(2), snap[string(metrics.MemcacheGetHitsTotal)])
+	assert.Equal(t, int64(2), snap[string(metrics.MemcacheGetMissesTotal)])
+	assert.Equal(t, int64(2), snap[`memcache_commands_total{command="get"}`])
+}
+
+func TestServer_AddReplace(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	assert.Equal(t, "NOT_STORED", c.do("replace k 0 0 1", "a"))
+	assert.Equal(t, "STORED", c.do("add k 0 0 1", "b"))
+	assert.Equal(t, "NOT_STORED", c.do("add k 0 0 1", "c"))
+	assert.Equal(t, "STORED", c.do("replace k 0 0 1", "d"))
+
+	c.send("get k")
+	assert.Equal(t, []string{"VALUE k 0 1", "d", "END"}, c.until("END"))
+}
+
+func TestServer_CAS(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	assert.Equal(t, "NOT_FOUND", c.do("cas k 0 0 1 1", "a"))
+
+	c.set("k", "a")
+	entry, _ := ts.store.Get("k")
+
+	assert.Equal(t, "EXISTS", c.do(fmt.Sprintf("cas k 0 0 1 %d", entry.Version+1), "b"))
+	assert.Equal(t, "EXISTS", c.do("cas k 0 0 1 0", "b"), "a zero token never matches")
+	assert.Equal(t, "STORED", c.do(fmt.Sprintf("cas k 0 0 1 %d", entry.Version), "b"))
+	assert.Equal(t, "EXISTS", c.do(fmt.Sprintf("cas k 0 0 1 %d", entry.Version), "c"), "the token is used up")
+}
+
+func TestServer_Expiry(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	assert.Equal(t, "STORED", c.do("set k 0 100 1", "a"))
+	entry, _ := ts.store.Get("k")
+	assert.WithinDuration(t, time.Now().Add(100*time.Second), entry.ExpiresAt, 2*time.Second)
+
+	assert.Equal(t, "STORED", c.do("set gone 0 -1 1", "a"))
+	assert.Equal(t, "END", c.do("get gone"), "a negative exptime expires at once")
+
+	assert.Equal(t, "NOT_FOUND", c.do("touch missing 10"))
+	assert.Equal(t, "TOUCHED", c.do("touch k 0"))
+	entry, _ = ts.store.Get("k")
+	assert.True(t, entry.ExpiresAt.IsZero())
+
+	assert.Equal(t, "TOUCHED", c.do(fmt.Sprintf("touch k %d", time.Now().Add(time.Hour).Unix())))
+	entry, _ = ts.store.Get("k")
+	assert.WithinDuration(t, time.Now().Add(time.Hour), entry.ExpiresAt, 2*time.Second)
+}
+
+func TestServer_Delete(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	c.set("k", "v")
+	assert.Equal(t, "DELETED", c.do("delete k"))
+	assert.Equal(t, "NOT_FOUND", c.do("delete k"))
+	assert.Equal(t, "NOT_FOUND", c.do("delete k 0"))
+	assert.Equal(t, "CLIENT_ERROR bad command line format.  Usage: delete <key> [noreply]", c.do("delete k 5"))
+}
+
+func TestServer_IncrDecr(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	assert.Equal(t, "NOT_FOUND", c.do("incr n 1"))
+
+	assert.Equal(t, "STORED", c.do("set n 7 0 2", "10"))
+	assert.Equal(t, "15", c.do("incr n 5"))
+	assert.Equal(t, "12", c.do("decr n 3"))
+	assert.Equal(t, "0", c.do("decr n 100"), "decrements stop at zero")
+	assert.Equal(t, "1", c.do("incr n 1"))
+
+	c.send("get n")
+	assert.Equal(t, []string{"VALUE n 7 1", "1", "END"}, c.until("END"), "flags are kept")
+
+	entry, _ := ts.store.Get("n")
+	require.NotNil(t, entry.Counter, "the value was converted to a counter")
+
+	c.set("word", "abc")
+	assert.Equal(t, "CLIENT_ERROR cannot increment or decrement non-numeric value", c.do("incr word 1"))
+	assert.Equal(t, "CLIENT_ERROR invalid numeric delta argument", c.do("incr n -1"))
+
+	c.set("max", "9223372036854775807")
+	assert.Equal(t, "CLIENT_ERROR increment or decrement would overflow", c.do("incr max 1"))
+	assert.Equal(t, "9223372036854775806", c.do("decr max 1"))
+}
+
+func TestServer_Noreply(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	c.send("set k 0 0 1 noreply", "1", "add k 0 0 1 noreply", "2", "incr k 1 noreply", "delete missing noreply")
+	assert.Equal(t, "CLIENT_ERROR bad command line format", c.do("touch k soon noreply"), "errors are sent anyway")
+	assert.Equal(t, "VERSION "+memcachedVersion, c.do("version"))
+
+	c.send("get k")
+	assert.Equal(t, []string{"VALUE k 0 1", "2", "END"}, c.until("END"))
+}
+
+func TestServer_Errors(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	assert.Equal(t, "ERROR", c.do("flush_all"))
+	assert.Equal(t, "ERROR", c.do("GET k"), "commands are case-sensitive")
+	assert.Equal(t, "ERROR", c.do("set k 0 0"))
+	assert.Equal(t, "CLIENT_ERROR bad command line format", c.do("get "+strings.Repeat("k", maxKeyLen+1)))
+
+	// A bad header swallows the data block.
+	assert.Equal(t, "CLIENT_ERROR bad command line format", c.do("set k x 0 1", "a"))
+	assert.Equal(t, "END", c.do("get k"))
+	assert.Equal(t, int64(5), ts.metrics.Snapshot()[string(metrics.MemcacheErrorsTotal)])
+
+	// A data block longer than announced closes the connection.
+	assert.Equal(t, "CLIENT_ERROR bad data chunk", c.do("set k 0 0 1", "abc"))
+	_, err := c.r.ReadByte()
+	assert.Error(t, err)
+}
+
+func TestServer_Pipelining(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	c.send("set k 0 0 1", "v", "get k", "version", "quit")
+	assert.Equal(t, []string{"STORED", "VALUE k 0 1", "v", "END", "VERSION " + memcachedVersion}, c.until("VERSION "+memcachedVersion))
+
+	_, err := c.r.ReadByte()
+	assert.Error(t, err, "quit closes the connection")
+}
+
+func TestServer_Stats(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+	c.set("k", "v")
+	c.do("get k missing")
+	c.until("END")
+
+	c.send("stats")
+	stats := make(map[string]string)
+	for _, line := range c.until("END") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[0] == "STAT" {
+			stats[fields[1]] = fields[2]
+		}
+	}
+	assert.Equal(t, memcachedVersion, stats["version"])
+	assert.Equal(t, "1", stats["curr_connections"])
+	assert.Equal(t, "1", stats["cmd_get"])
+	assert.Equal(t, "1", stats["cmd_set"])
+	assert.Equal(t, "1", stats["get_hits"])
+	assert.Equal(t, "1", stats["get_misses"])
+	assert.Equal(t, "1", stats["curr_items"])
+
+	assert.Equal(t, "END", c.do("stats slabs"))
+}
+
+func TestServer_ReplicatesWrites(t *testing.T) {
+	received := make(chan string, 16)
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload replication.Payload
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received <- fmt.Sprintf("%s deleted=%t flags=%d", payload.Key, payload.Entry.Deleted, payload.Entry.Flags)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer peer.Close()
+
+	ts := newTestServer(t)
+	ts.peers.AddPeer(peer.URL)
+	c := dial(t, ts)
+
+	c.do("set a 3 0 1", "1")
+	c.do("add a 0 0 1", "2") // refused, not replicated
+	c.do("incr a 1")         // converts to a counter, then increments
+	c.do("touch a 60")
+	c.do("delete a")
+
+	// Writes to a key still queued for the peer are coalesced, so the peer
+	// sees at most five writes and the delete last.
+	var got []string
+	for len(got) == 0 || got[len(got)-1] != "a deleted=true flags=0" {
+		select {
+		case msg := <-received:
+			got = append(got, msg)
+		case <-time.After(time.Second):
+			t.Fatalf("replicated %v", got)
+		}
+	}
+	assert.LessOrEqual(t, len(got), 5)
+	for _, msg := range got[:len(got)-1] {
+		assert.Equal(t, "a deleted=false flags=3", msg)
+	}
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+	assert.Equal(t, "VERSION "+memcachedVersion, c.do("version"))
+
+	require.NoError(t, ts.Shutdown(context.Background()))
+	assert.ErrorIs(t, <-ts.served, ErrServerClosed)
+
+	_, err := c.r.ReadByte()
+	assert.Error(t, err, "idle connections are closed")
+	assert.Zero(t, ts.metrics.Snapshot()[string(metrics.MemcacheConnections)])
+	assert.Equal(t, int64(1), ts.metrics.Snapshot()[string(metrics.MemcacheConnectionsTotal)])
+}
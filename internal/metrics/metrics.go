@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"fmt"
 	"sync"
 	"sync/atomic"
 )
@@ -13,21 +14,89 @@ const (
 	// Cache
 	CacheKeysTotal    MetricKey = "cache_keys_total"
 	CacheSetsTotal    MetricKey = "cache_sets_total"
+	CacheDeletesTotal MetricKey = "cache_deletes_total"
 	CacheGetsTotal    MetricKey = "cache_gets_total"
 	CacheMissesTotal  MetricKey = "cache_misses_total"
 	CacheExpiredTotal MetricKey = "cache_expired_total"
 
+	CachePreconditionFailedTotal MetricKey = "cache_precondition_failed_total"
+
+	// Memory bounds
+	CacheEvictionsTotal MetricKey = "cache_evictions_total"
+	CacheBytes          MetricKey = "cache_bytes"
+
 	// Replication
 	ReplicationAttemptsTotal MetricKey = "replication_attempts_total"
 	ReplicationSuccessTotal  MetricKey = "replication_success_total"
 	ReplicationFailureTotal  MetricKey = "replication_failure_total"
 	ReplicationRetriesTotal  MetricKey = "replication_retries_total"
+	ReplicationReceivedTotal MetricKey = "replication_received_total"
+	ReplicationStaleTotal    MetricKey = "replication_stale_total"
+
+	// Writes a healthy peer refused because it lacks their namespace
+	ReplicationUnknownNamespaceTotal MetricKey = "replication_unknown_namespace_total"
+
+	// Per-peer replication queues; depth is also labeled by peer
+	ReplicationQueueDepth         MetricKey = "replication_queue_depth"
+	ReplicationQueueDroppedTotal  MetricKey = "replication_queue_dropped_total"
+	ReplicationCoalescedTotal     MetricKey = "replication_coalesced_total"
+	ReplicationBatchesTotal       MetricKey = "replication_batches_total"
+	ReplicationBatchedWritesTotal MetricKey = "replication_batched_writes_total"
+
+	// Hinted handoff for unhealthy peers; pending hints are also labeled by peer
+	ReplicationHints              MetricKey = "replication_hints"
+	ReplicationHintsStoredTotal   MetricKey = "replication_hints_stored_total"
+	ReplicationHintsReplayedTotal MetricKey = "replication_hints_replayed_total"
+	ReplicationHintsDroppedTotal  MetricKey = "replication_hints_dropped_total"
+
+	// Binary replication transport (receiving side)
+	ReplicationBinaryConnections MetricKey = "replication_binary_connections"
+	ReplicationBinaryFramesTotal MetricKey = "replication_binary_frames_total"
 
 	// TTL
 	TTLCleanupRunsTotal MetricKey = "ttl_cleanup_runs_total"
 	TTLKeysRemovedTotal MetricKey = "ttl_keys_removed_total"
+	TTLTombstonesPurged MetricKey = "ttl_tombstones_purged_total"
+
+	// Snapshots
+	SnapshotSavesTotal    MetricKey = "snapshot_saves_total"
+	SnapshotFailuresTotal MetricKey = "snapshot_failures_total"
+	SnapshotCorruptTotal  MetricKey = "snapshot_corrupt_total"
+	SnapshotRestoredKeys  MetricKey = "snapshot_restored_keys"
 
-	// Peers
+	// Write-ahead log
+	WALAppendsTotal     MetricKey = "wal_appends_total"
+	WALFailuresTotal    MetricKey = "wal_failures_total"
+	WALBytes            MetricKey = "wal_bytes"
+	WALReplayedRecords  MetricKey = "wal_replayed_records"
+	WALTornRecordsTotal MetricKey = "wal_torn_records_total"
+	WALCompactionsTotal MetricKey = "wal_compactions_total"
+
+	// Watches
+	WatchSubscribers        MetricKey = "watch_subscribers"
+	WatchSlowConsumersTotal MetricKey = "watch_slow_consumers_total"
+
+	// Pub/sub
+	PubSubSubscribers        MetricKey = "pubsub_subscribers"
+	PubSubPublishedTotal     MetricKey = "pubsub_published_total"
+	PubSubDeliveredTotal     MetricKey = "pubsub_delivered_total"
+	PubSubPeerMessagesTotal  MetricKey = "pubsub_peer_messages_total"
+	PubSubSlowConsumersTotal MetricKey = "pubsub_slow_consumers_total"
+
+	// RESP (Redis protocol) front-end
+	RESPConnections   MetricKey = "resp_connections"
+	RESPCommandsTotal MetricKey = "resp_commands_total"
+	RESPErrorsTotal   MetricKey = "resp_errors_total"
+
+	// Memcached protocol front-end
+	MemcacheConnections      MetricKey = "memcache_connections"
+	MemcacheConnectionsTotal MetricKey = "memcache_connections_total"
+	MemcacheCommandsTotal    MetricKey = "memcache_commands_total"
+	MemcacheErrorsTotal      MetricKey = "memcache_errors_total"
+	MemcacheGetHitsTotal     MetricKey = "memcache_get_hits_total"
+	MemcacheGetMissesTotal   MetricKey = "memcache_get_misses_total"
+
+	// Peers; healthy and unhealthy count the tracked peers in each state
 	PeersHealthy      MetricKey = "peers_healthy"
 	PeersUnhealthy    MetricKey = "peers_unhealthy"
 	PeerFailuresTotal MetricKey = "peer_failures_total"
@@ -81,3 +150,11 @@ func (r *Registry) Add(key MetricKey, delta int64) {
 	r.counters[key] = &val
 	atomic.AddInt64(&val, delta)
 }
+
+// Labeled returns key qualified by a single label,
+// e.g. cache_sets_total{namespace="orders"}.
+//
+// Labeled keys are separate counters; recording one does not update key.
+func Labeled(key MetricKey, name, value string) MetricKey {
+	return MetricKey(fmt.Sprintf("%s{%s=%q}", key, name, value))
+}
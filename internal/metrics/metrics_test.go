@@ -78,3 +78,15 @@ func TestRegistry_UnknownMetricHandledGracefully(t *testing.T) {
 	snap := r.Snapshot()
 	assert.Equal(t, int64(1), snap["unknown_metric"])
 }
+
+func TestLabeled(t *testing.T) {
+	key := Labeled(CacheSetsTotal, "namespace", "orders")
+	assert.Equal(t, MetricKey(`cache_sets_total{namespace="orders"}`), key)
+
+	r := NewRegistry()
+	r.Inc(key)
+
+	snap := r.Snapshot()
+	assert.Equal(t, int64(1), snap[string(key)])
+	assert.NotContains(t, snap, string(CacheSetsTotal))
+}
@@ -2,18 +2,33 @@ package peers
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
+	"sync"
 	"time"
 
 	"distributed-cache/internal/metrics"
 )
 
+// HeartbeatStatus is the body served by a node's /internal/heartbeat endpoint.
+type HeartbeatStatus struct {
+	NodeID        string `json:"node_id"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+	Keys          int    `json:"keys"`
+	Version       string `json:"version"`
+}
+
 // HeartbeatWorker periodically checks peer liveness.
 type HeartbeatWorker struct {
 	manager *PeerManager
-	client  *http.Client
-	config  PeerConfig
 	metrics *metrics.Registry
+
+	mu     sync.RWMutex
+	client *http.Client
+	config PeerConfig
+
+	// reconfigured wakes Start so a new interval takes effect immediately.
+	reconfigured chan struct{}
 }
 
 // NewHeartbeatWorker creates a new heartbeat worker.
@@ -23,23 +38,54 @@ func NewHeartbeatWorker(
 	metricsRegistry *metrics.Registry,
 ) *HeartbeatWorker {
 	return &HeartbeatWorker{
-		manager: manager,
-		client:  &http.Client{Timeout: cfg.Timeout.HeartbeatTimeout},
-		config:  cfg,
-		metrics: metricsRegistry,
+		manager:      manager,
+		client:       &http.Client{Timeout: cfg.Timeout.HeartbeatTimeout},
+		config:       cfg,
+		metrics:      metricsRegistry,
+		reconfigured: make(chan struct{}, 1),
+	}
+}
+
+// UpdateConfig swaps the heartbeat interval and timeout.
+// A running loop picks up the new interval without waiting for the old one.
+func (hw *HeartbeatWorker) UpdateConfig(cfg PeerConfig) {
+	hw.mu.Lock()
+	hw.config = cfg
+	hw.client = &http.Client{Timeout: cfg.Timeout.HeartbeatTimeout}
+	hw.mu.Unlock()
+
+	select {
+	case hw.reconfigured <- struct{}{}:
+	default:
 	}
 }
 
+func (hw *HeartbeatWorker) interval() time.Duration {
+	hw.mu.RLock()
+	defer hw.mu.RUnlock()
+
+	return hw.config.Heartbeat.Interval
+}
+
+func (hw *HeartbeatWorker) httpClient() *http.Client {
+	hw.mu.RLock()
+	defer hw.mu.RUnlock()
+
+	return hw.client
+}
+
 // Start begins the heartbeat loop.
 // Stops immediately when the ctx is cancelled.
 func (hw *HeartbeatWorker) Start(ctx context.Context) {
-	ticker := time.NewTicker(hw.config.Heartbeat.Interval)
+	ticker := time.NewTicker(hw.interval())
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
 			hw.runOnce(ctx)
+		case <-hw.reconfigured:
+			ticker.Reset(hw.interval())
 		case <-ctx.Done():
 			return
 		}
@@ -49,6 +95,7 @@ func (hw *HeartbeatWorker) Start(ctx context.Context) {
 // runOnce performs a single heartbeat check for all peers.
 func (hw *HeartbeatWorker) runOnce(ctx context.Context) {
 	hw.metrics.Inc(metrics.HeartbeatRunsTotal)
+	client := hw.httpClient()
 
 	for _, peer := range hw.manager.GetPeers() {
 		req, err := http.NewRequestWithContext(
@@ -63,13 +110,20 @@ func (hw *HeartbeatWorker) runOnce(ctx context.Context) {
 			continue
 		}
 
-		resp, err := hw.client.Do(req)
+		resp, err := client.Do(req)
 		if err != nil || resp.StatusCode != http.StatusOK {
 			hw.metrics.Inc(metrics.HeartbeatFailuresTotal)
 			hw.manager.MarkFailure(peer)
 		} else {
 			hw.metrics.Inc(metrics.HeartbeatSuccessTotal)
 			hw.manager.MarkSuccess(peer)
+
+			// The status body is informational: a peer that answers 200
+			// is alive even if it does not report its status.
+			var status HeartbeatStatus
+			if err := json.NewDecoder(resp.Body).Decode(&status); err == nil {
+				hw.manager.RecordHeartbeat(peer, status)
+			}
 		}
 
 		if resp != nil {
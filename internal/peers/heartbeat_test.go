@@ -2,6 +2,7 @@ package peers
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -10,6 +11,7 @@ import (
 	"distributed-cache/internal/metrics"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestHeartbeatWorker_RunOnce_Success(t *testing.T) {
@@ -180,3 +182,58 @@ func TestHeartbeatWorker_RequestCreationError_IncrementsMetrics(t *testing.T) {
 		snap[string(metrics.HeartbeatFailuresTotal)],
 	)
 }
+
+func TestHeartbeatWorker_RunOnce_RecordsStatus(t *testing.T) {
+	cfg := DefaultPeerConfig()
+
+	reg := metrics.NewRegistry()
+	pm := NewPeerManager(cfg, reg)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(HeartbeatStatus{
+			NodeID:        "node-2",
+			UptimeSeconds: 42,
+			Keys:          7,
+			Version:       "v1.2.3",
+		})
+	}))
+	defer server.Close()
+
+	pm.AddPeer(server.URL)
+
+	worker := NewHeartbeatWorker(pm, cfg, reg)
+	worker.runOnce(context.Background())
+
+	snaps := pm.Snapshot()
+	require.Len(t, snaps, 1)
+
+	snap := snaps[0]
+	assert.Equal(t, "healthy", snap.State)
+	assert.Equal(t, "node-2", snap.NodeID)
+	assert.Equal(t, int64(42), snap.UptimeSeconds)
+	assert.Equal(t, 7, snap.Keys)
+	assert.Equal(t, "v1.2.3", snap.Version)
+	assert.NotNil(t, snap.LastHeartbeat)
+}
+
+func TestHeartbeatWorker_UpdateConfig_ResetsInterval(t *testing.T) {
+	cfg := DefaultPeerConfig()
+	cfg.Heartbeat.Interval = time.Hour
+
+	reg := metrics.NewRegistry()
+	pm := NewPeerManager(cfg, reg)
+
+	worker := NewHeartbeatWorker(pm, cfg, reg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go worker.Start(ctx)
+
+	cfg.Heartbeat.Interval = 5 * time.Millisecond
+	worker.UpdateConfig(cfg)
+
+	assert.Eventually(t, func() bool {
+		return reg.Snapshot()[string(metrics.HeartbeatRunsTotal)] >= 2
+	}, time.Second, 5*time.Millisecond)
+}
@@ -2,6 +2,7 @@ package peers
 
 import (
 	"sync"
+	"time"
 
 	"distributed-cache/internal/metrics"
 )
@@ -20,6 +21,10 @@ type Peer struct {
 	State        PeerState
 	FailureCount int
 	SuccessCount int
+
+	// Populated from the latest successful heartbeat.
+	Status        HeartbeatStatus
+	LastHeartbeat time.Time
 }
 
 // PeerManager manages the health state of multiple peers.
@@ -28,6 +33,12 @@ type PeerManager struct {
 	peers   map[string]*Peer
 	config  PeerConfig
 	metrics *metrics.Registry
+
+	// onRecover is called when a peer becomes healthy again.
+	onRecover []func(addr string)
+
+	// onRemove is called when SetPeers drops a peer.
+	onRemove []func(addr string)
 }
 
 // NewPeerManager creates a new PeerManager.
@@ -44,14 +55,84 @@ func (pm *PeerManager) AddPeer(addr string) {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 
-	if _, exists := pm.peers[addr]; !exists {
-		pm.peers[addr] = &Peer{
-			Address: addr,
-			State:   Healthy,
+	pm.addLocked(addr)
+}
+
+// SetPeers replaces the peer set.
+// Peers already tracked keep their health state.
+func (pm *PeerManager) SetPeers(addrs []string) {
+	pm.mu.Lock()
+
+	keep := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		keep[addr] = true
+		pm.addLocked(addr)
+	}
+
+	var removed []string
+	for addr, peer := range pm.peers {
+		if !keep[addr] {
+			pm.metrics.Add(stateGauge(peer.State), -1)
+			delete(pm.peers, addr)
+			removed = append(removed, addr)
+		}
+	}
+	callbacks := pm.onRemove
+	pm.mu.Unlock()
+
+	for _, addr := range removed {
+		for _, fn := range callbacks {
+			fn(addr)
 		}
 	}
 }
 
+// addLocked starts tracking addr as healthy unless it is tracked already.
+func (pm *PeerManager) addLocked(addr string) {
+	if _, exists := pm.peers[addr]; exists {
+		return
+	}
+	pm.peers[addr] = &Peer{
+		Address: addr,
+		State:   Healthy,
+	}
+	pm.metrics.Inc(metrics.PeersHealthy)
+}
+
+// stateGauge returns the gauge counting the peers in state.
+func stateGauge(state PeerState) metrics.MetricKey {
+	if state == Unhealthy {
+		return metrics.PeersUnhealthy
+	}
+	return metrics.PeersHealthy
+}
+
+// OnRecover registers fn to be called, outside the manager's lock, each
+// time an unhealthy peer becomes healthy again.
+func (pm *PeerManager) OnRecover(fn func(addr string)) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	pm.onRecover = append(pm.onRecover, fn)
+}
+
+// OnRemove registers fn to be called, outside the manager's lock, for
+// each peer SetPeers drops.
+func (pm *PeerManager) OnRemove(fn func(addr string)) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	pm.onRemove = append(pm.onRemove, fn)
+}
+
+// UpdateConfig swaps the health policy used for future transitions.
+func (pm *PeerManager) UpdateConfig(cfg PeerConfig) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	pm.config = cfg
+}
+
 // MarkFailure records a failure and may mark the peer unhealthy.
 func (pm *PeerManager) MarkFailure(addr string) {
 	pm.mu.Lock()
@@ -71,18 +152,27 @@ func (pm *PeerManager) MarkFailure(addr string) {
 		peer.FailureCount >= pm.config.Health.FailureThreshold {
 
 		peer.State = Unhealthy
+		pm.metrics.Add(metrics.PeersHealthy, -1)
 		pm.metrics.Inc(metrics.PeersUnhealthy)
 	}
 }
 
 // MarkSuccess records a success and may recover the peer.
 func (pm *PeerManager) MarkSuccess(addr string) {
+	for _, fn := range pm.markSuccess(addr) {
+		fn(addr)
+	}
+}
+
+// markSuccess updates the peer and returns the recovery callbacks to run
+// if it became healthy.
+func (pm *PeerManager) markSuccess(addr string) []func(string) {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 
 	peer, ok := pm.peers[addr]
 	if !ok {
-		return
+		return nil
 	}
 
 	peer.SuccessCount++
@@ -92,8 +182,25 @@ func (pm *PeerManager) MarkSuccess(addr string) {
 		peer.SuccessCount >= pm.config.Health.SuccessThreshold {
 
 		peer.State = Healthy
+		pm.metrics.Add(metrics.PeersUnhealthy, -1)
 		pm.metrics.Inc(metrics.PeersHealthy)
+		return pm.onRecover
+	}
+	return nil
+}
+
+// RecordHeartbeat stores the status reported by a peer's heartbeat.
+func (pm *PeerManager) RecordHeartbeat(addr string, status HeartbeatStatus) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	peer, ok := pm.peers[addr]
+	if !ok {
+		return
 	}
+
+	peer.Status = status
+	peer.LastHeartbeat = time.Now()
 }
 
 // IsHealthy returns whether a peer is healthy.
@@ -123,6 +230,13 @@ type PeerSnapshot struct {
 	State        string `json:"state"`
 	FailureCount int    `json:"failure_count"`
 	SuccessCount int    `json:"success_count"`
+
+	// Heartbeat-reported fields; empty until the first successful heartbeat.
+	NodeID        string     `json:"node_id,omitempty"`
+	Version       string     `json:"version,omitempty"`
+	UptimeSeconds int64      `json:"uptime_seconds,omitempty"`
+	Keys          int        `json:"keys,omitempty"`
+	LastHeartbeat *time.Time `json:"last_heartbeat,omitempty"`
 }
 
 // Snapshot returns a copy of all peer states
@@ -137,12 +251,22 @@ func (pm *PeerManager) Snapshot() []PeerSnapshot {
 			state = "unhealthy"
 		}
 
-		out = append(out, PeerSnapshot{
-			Address:      p.Address,
-			State:        state,
-			FailureCount: p.FailureCount,
-			SuccessCount: p.SuccessCount,
-		})
+		snap := PeerSnapshot{
+			Address:       p.Address,
+			State:         state,
+			FailureCount:  p.FailureCount,
+			SuccessCount:  p.SuccessCount,
+			NodeID:        p.Status.NodeID,
+			Version:       p.Status.Version,
+			UptimeSeconds: p.Status.UptimeSeconds,
+			Keys:          p.Status.Keys,
+		}
+		if !p.LastHeartbeat.IsZero() {
+			last := p.LastHeartbeat
+			snap.LastHeartbeat = &last
+		}
+
+		out = append(out, snap)
 	}
 	return out
 }
@@ -60,6 +60,45 @@ func TestPeerManagerMarkSuccessRecoversPeer(t *testing.T) {
 	assert.Equal(t, int64(1), snap[string(metrics.PeersHealthy)])
 }
 
+func TestPeerManagerOnRecover(t *testing.T) {
+	cfg := DefaultPeerConfig()
+	cfg.Health.FailureThreshold = 1
+	cfg.Health.SuccessThreshold = 1
+
+	pm := NewPeerManager(cfg, metrics.NewRegistry())
+	pm.AddPeer("node-1")
+
+	var recovered []string
+	pm.OnRecover(func(addr string) {
+		// Called outside the lock, so the callback may query the manager.
+		assert.True(t, pm.IsHealthy(addr))
+		recovered = append(recovered, addr)
+	})
+
+	pm.MarkSuccess("node-1")
+	assert.Empty(t, recovered, "a healthy peer does not recover")
+
+	pm.MarkFailure("node-1")
+	pm.MarkSuccess("node-1")
+	pm.MarkSuccess("node-1")
+	assert.Equal(t, []string{"node-1"}, recovered)
+}
+
+func TestPeerManagerOnRemove(t *testing.T) {
+	pm := NewPeerManager(DefaultPeerConfig(), metrics.NewRegistry())
+	pm.SetPeers([]string{"node-1", "node-2"})
+
+	var removed []string
+	pm.OnRemove(func(addr string) {
+		assert.Equal(t, []string{"node-2"}, pm.GetPeers(), "called once the peer set is replaced")
+		removed = append(removed, addr)
+	})
+
+	pm.SetPeers([]string{"node-2"})
+	pm.SetPeers([]string{"node-2"})
+	assert.Equal(t, []string{"node-1"}, removed)
+}
+
 func TestPeerManagerCountersResetCorrectly(t *testing.T) {
 	cfg := DefaultPeerConfig()
 	reg := metrics.NewRegistry()
@@ -99,3 +138,94 @@ func TestPeerManagerGetPeersSnapshot(t *testing.T) {
 	assert.Contains(t, peers, "node-1")
 	assert.Contains(t, peers, "node-2")
 }
+
+func TestPeerManagerRecordHeartbeat(t *testing.T) {
+	cfg := DefaultPeerConfig()
+	reg := metrics.NewRegistry()
+	pm := NewPeerManager(cfg, reg)
+
+	pm.AddPeer("node-1")
+
+	snap := pm.Snapshot()[0]
+	assert.Empty(t, snap.NodeID)
+	assert.Nil(t, snap.LastHeartbeat)
+
+	pm.RecordHeartbeat("node-1", HeartbeatStatus{NodeID: "n1", Keys: 3})
+
+	snap = pm.Snapshot()[0]
+	assert.Equal(t, "n1", snap.NodeID)
+	assert.Equal(t, 3, snap.Keys)
+	assert.NotNil(t, snap.LastHeartbeat)
+
+	assert.NotPanics(t, func() {
+		pm.RecordHeartbeat("unknown-peer", HeartbeatStatus{})
+	})
+}
+
+func TestPeerManagerSetPeers(t *testing.T) {
+	cfg := DefaultPeerConfig()
+	cfg.Health.FailureThreshold = 1
+
+	reg := metrics.NewRegistry()
+	pm := NewPeerManager(cfg, reg)
+
+	pm.AddPeer("node-1")
+	pm.AddPeer("node-2")
+	pm.MarkFailure("node-2")
+
+	pm.SetPeers([]string{"node-2", "node-3"})
+
+	peers := pm.GetPeers()
+	assert.ElementsMatch(t, []string{"node-2", "node-3"}, peers)
+
+	// Existing peers keep their health state.
+	assert.False(t, pm.IsHealthy("node-2"))
+	assert.True(t, pm.IsHealthy("node-3"))
+
+	pm.SetPeers([]string{"node-2"})
+	assert.ElementsMatch(t, []string{"node-2"}, pm.GetPeers())
+}
+
+func TestPeerManagerStateGauges(t *testing.T) {
+	cfg := DefaultPeerConfig()
+	cfg.Health.FailureThreshold = 1
+	cfg.Health.SuccessThreshold = 1
+
+	reg := metrics.NewRegistry()
+	pm := NewPeerManager(cfg, reg)
+	gauges := func() (int64, int64) {
+		snap := reg.Snapshot()
+		return snap[string(metrics.PeersHealthy)], snap[string(metrics.PeersUnhealthy)]
+	}
+
+	pm.SetPeers([]string{"node-1", "node-2", "node-3"})
+	pm.AddPeer("node-1") // already tracked
+	pm.MarkFailure("node-2")
+	pm.MarkFailure("node-3")
+	healthy, unhealthy := gauges()
+	assert.Equal(t, []int64{1, 2}, []int64{healthy, unhealthy})
+
+	pm.MarkSuccess("node-3")
+	healthy, unhealthy = gauges()
+	assert.Equal(t, []int64{2, 1}, []int64{healthy, unhealthy})
+
+	// Removed peers leave the gauge of their state.
+	pm.SetPeers([]string{"node-3"})
+	healthy, unhealthy = gauges()
+	assert.Equal(t, []int64{1, 0}, []int64{healthy, unhealthy})
+}
+
+func TestPeerManagerUpdateConfig(t *testing.T) {
+	cfg := DefaultPeerConfig()
+	cfg.Health.FailureThreshold = 3
+
+	reg := metrics.NewRegistry()
+	pm := NewPeerManager(cfg, reg)
+	pm.AddPeer("node-1")
+
+	cfg.Health.FailureThreshold = 1
+	pm.UpdateConfig(cfg)
+
+	pm.MarkFailure("node-1")
+	assert.False(t, pm.IsHealthy("node-1"))
+}
@@ -0,0 +1,313 @@
+package pubsub
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/util"
+)
+
+// DefaultBuffer is the per-subscription buffer used when none is given.
+const DefaultBuffer = 256
+
+var (
+	// ErrSlowConsumer ends a subscription whose buffer filled up.
+	ErrSlowConsumer = errors.New("subscriber fell behind and was disconnected")
+
+	// ErrClosed ends the subscriptions of a closed Broker.
+	ErrClosed = errors.New("pubsub broker closed")
+)
+
+// Message is one published message, as received by a subscription.
+type Message struct {
+	Channel string
+
+	// Pattern is the pattern the subscription matched the channel with;
+	// empty for channel subscriptions.
+	Pattern string
+
+	Payload []byte
+	Time    time.Time
+}
+
+// Broker delivers messages published on channels to their subscribers
+// on this node, Redis style: messages are not stored, so a subscriber
+// only receives what is published while it is subscribed.
+//
+// Delivery never blocks publishers: a subscription whose buffer is full
+// is disconnected with ErrSlowConsumer instead.
+type Broker struct {
+	metrics *metrics.Registry
+
+	mu       sync.Mutex
+	channels map[string]map[*Subscription]struct{}
+	patterns map[string]map[*Subscription]struct{}
+	subs     map[*Subscription]struct{}
+	closed   bool
+}
+
+// NewBroker creates an empty broker.
+func NewBroker(metricsRegistry *metrics.Registry) *Broker {
+	return &Broker{
+		metrics:  metricsRegistry,
+		channels: make(map[string]map[*Subscription]struct{}),
+		patterns: make(map[string]map[*Subscription]struct{}),
+		subs:     make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscription receives the messages of a set of channels and patterns,
+// which can change while it is open.
+//
+// A message whose channel matches both a channel and a pattern of the
+// subscription, or several of its patterns, is received once per match.
+type Subscription struct {
+	broker   *Broker
+	messages chan Message
+	channels map[string]struct{}
+	patterns map[string]struct{}
+	err      error // guarded by broker.mu; set before messages is closed
+}
+
+// NewSubscription opens a subscription to nothing yet; buffer bounds the
+// messages queued for it (DefaultBuffer when < 1).
+func (b *Broker) NewSubscription(buffer int) (*Subscription, error) {
+	if buffer < 1 {
+		buffer = DefaultBuffer
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+
+	sub := &Subscription{
+		broker:   b,
+		messages: make(chan Message, buffer),
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+	}
+	b.subs[sub] = struct{}{}
+	b.metrics.Inc(metrics.PubSubSubscribers)
+	return sub, nil
+}
+
+// Publish delivers payload to the subscribers of channel on this node
+// and returns the number of subscriptions that received it.
+func (b *Broker) Publish(channel string, payload []byte) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.metrics.Inc(metrics.PubSubPublishedTotal)
+
+	now := time.Now()
+	received := 0
+
+	for sub := range b.channels[channel] {
+		if b.deliverLocked(sub, Message{Channel: channel, Payload: payload, Time: now}) {
+			received++
+		}
+	}
+	for pattern, subs := range b.patterns {
+		if !util.Match(pattern, channel) {
+			continue
+		}
+		for sub := range subs {
+			if b.deliverLocked(sub, Message{Channel: channel, Pattern: pattern, Payload: payload, Time: now}) {
+				received++
+			}
+		}
+	}
+	return received
+}
+
+// NumSubscribers returns the number of subscriptions to channel itself,
+// not counting pattern subscriptions.
+func (b *Broker) NumSubscribers(channel string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.channels[channel])
+}
+
+// Channels returns the channels with at least one subscriber.
+func (b *Broker) Channels() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	out := make([]string, 0, len(b.channels))
+	for channel := range b.channels {
+		out = append(out, channel)
+	}
+	return out
+}
+
+// Close ends every subscription with ErrClosed and refuses new ones,
+// e.g. on shutdown.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subs {
+		b.removeLocked(sub, ErrClosed)
+	}
+}
+
+// deliverLocked queues msg for sub without blocking, disconnecting sub
+// when its buffer is full.
+func (b *Broker) deliverLocked(sub *Subscription, msg Message) bool {
+	if _, ok := b.subs[sub]; !ok {
+		return false // disconnected earlier in this Publish
+	}
+
+	select {
+	case sub.messages <- msg:
+		b.metrics.Inc(metrics.PubSubDeliveredTotal)
+		return true
+	default:
+		b.metrics.Inc(metrics.PubSubSlowConsumersTotal)
+		b.removeLocked(sub, ErrSlowConsumer)
+		return false
+	}
+}
+
+// removeLocked ends sub with err; ending it twice is a no-op.
+func (b *Broker) removeLocked(sub *Subscription, err error) {
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+
+	for channel := range sub.channels {
+		unlink(b.channels, channel, sub)
+	}
+	for pattern := range sub.patterns {
+		unlink(b.patterns, pattern, sub)
+	}
+	clear(sub.channels)
+	clear(sub.patterns)
+
+	delete(b.subs, sub)
+	sub.err = err
+	close(sub.messages)
+	b.metrics.Add(metrics.PubSubSubscribers, -1)
+}
+
+func link(index map[string]map[*Subscription]struct{}, name string, sub *Subscription) {
+	if index[name] == nil {
+		index[name] = make(map[*Subscription]struct{})
+	}
+	index[name][sub] = struct{}{}
+}
+
+func unlink(index map[string]map[*Subscription]struct{}, name string, sub *Subscription) {
+	delete(index[name], sub)
+	if len(index[name]) == 0 {
+		delete(index, name)
+	}
+}
+
+// Messages returns the subscription's messages. The channel is closed
+// when the subscription ends; Err then reports why.
+func (s *Subscription) Messages() <-chan Message {
+	return s.messages
+}
+
+// Err returns ErrSlowConsumer or ErrClosed once Messages is closed,
+// and nil while the subscription runs or after Close.
+func (s *Subscription) Err() error {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+
+	return s.err
+}
+
+// Subscribe adds channels to the subscription and returns how many
+// channels and patterns it now has. Subscribing twice is a no-op.
+func (s *Subscription) Subscribe(channels ...string) int {
+	return s.update(func(b *Broker) {
+		for _, channel := range channels {
+			s.channels[channel] = struct{}{}
+			link(b.channels, channel, s)
+		}
+	})
+}
+
+// PSubscribe adds glob patterns (see util.Match) to the subscription and
+// returns how many channels and patterns it now has.
+func (s *Subscription) PSubscribe(patterns ...string) int {
+	return s.update(func(b *Broker) {
+		for _, pattern := range patterns {
+			s.patterns[pattern] = struct{}{}
+			link(b.patterns, pattern, s)
+		}
+	})
+}
+
+// Unsubscribe removes channels, or every channel when none is given,
+// and returns how many channels and patterns are left.
+func (s *Subscription) Unsubscribe(channels ...string) int {
+	return s.update(func(b *Broker) {
+		if len(channels) == 0 {
+			for channel := range s.channels {
+				channels = append(channels, channel)
+			}
+		}
+		for _, channel := range channels {
+			delete(s.channels, channel)
+			unlink(b.channels, channel, s)
+		}
+	})
+}
+
+// PUnsubscribe removes patterns, or every pattern when none is given,
+// and returns how many channels and patterns are left.
+func (s *Subscription) PUnsubscribe(patterns ...string) int {
+	return s.update(func(b *Broker) {
+		if len(patterns) == 0 {
+			for pattern := range s.patterns {
+				patterns = append(patterns, pattern)
+			}
+		}
+		for _, pattern := range patterns {
+			delete(s.patterns, pattern)
+			unlink(b.patterns, pattern, s)
+		}
+	})
+}
+
+// Channels returns the channels the subscription listens to.
+func (s *Subscription) Channels() []string {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+
+	out := make([]string, 0, len(s.channels))
+	for channel := range s.channels {
+		out = append(out, channel)
+	}
+	return out
+}
+
+// Close ends the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+
+	s.broker.removeLocked(s, nil)
+}
+
+// update applies fn to a running subscription and returns its size.
+// Once the subscription ended, fn is skipped.
+func (s *Subscription) update(fn func(b *Broker)) int {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+
+	if _, ok := s.broker.subs[s]; ok {
+		fn(s.broker)
+	}
+	return len(s.channels) + len(s.patterns)
+}
@@ -0,0 +1,175 @@
+package pubsub
+
+import (
+	"sort"
+	"sync"
+	"testing"
+
+	"distributed-cache/internal/metrics"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// received returns the messages queued for sub without blocking.
+func received(sub *Subscription) []Message {
+	var out []Message
+	for {
+		select {
+		case msg, ok := <-sub.Messages():
+			if !ok {
+				return out
+			}
+			out = append(out, msg)
+		default:
+			return out
+		}
+	}
+}
+
+func newSubscription(t *testing.T, b *Broker, buffer int) *Subscription {
+	t.Helper()
+
+	sub, err := b.NewSubscription(buffer)
+	require.NoError(t, err)
+	t.Cleanup(sub.Close)
+	return sub
+}
+
+func TestBroker_Publish(t *testing.T) {
+	reg := metrics.NewRegistry()
+	b := NewBroker(reg)
+
+	news := newSubscription(t, b, 0)
+	assert.Equal(t, 1, news.Subscribe("news"))
+	assert.Equal(t, 2, news.Subscribe("sport", "news"), "subscribing twice is a no-op")
+
+	all := newSubscription(t, b, 0)
+	assert.Equal(t, 1, all.PSubscribe("*"))
+
+	assert.Equal(t, 2, b.Publish("news", []byte("hello")))
+	assert.Equal(t, 1, b.Publish("weather", []byte("rain")))
+
+	msgs := received(news)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "news", msgs[0].Channel)
+	assert.Empty(t, msgs[0].Pattern)
+	assert.Equal(t, "hello", string(msgs[0].Payload))
+
+	msgs = received(all)
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "*", msgs[0].Pattern)
+	assert.Equal(t, "weather", msgs[1].Channel)
+
+	assert.Equal(t, 1, b.NumSubscribers("news"))
+	channels := b.Channels()
+	sort.Strings(channels)
+	assert.Equal(t, []string{"news", "sport"}, channels)
+
+	snap := reg.Snapshot()
+	assert.Equal(t, int64(2), snap[string(metrics.PubSubPublishedTotal)])
+	assert.Equal(t, int64(3), snap[string(metrics.PubSubDeliveredTotal)])
+	assert.Equal(t, int64(2), snap[string(metrics.PubSubSubscribers)])
+}
+
+func TestBroker_ChannelAndPatternMatchesAreSeparate(t *testing.T) {
+	b := NewBroker(metrics.NewRegistry())
+
+	sub := newSubscription(t, b, 0)
+	sub.Subscribe("news.sport")
+	sub.PSubscribe("news.*", "*.sport")
+
+	assert.Equal(t, 3, b.Publish("news.sport", []byte("goal")))
+	assert.Len(t, received(sub), 3)
+}
+
+func TestSubscription_Unsubscribe(t *testing.T) {
+	b := NewBroker(metrics.NewRegistry())
+
+	sub := newSubscription(t, b, 0)
+	sub.Subscribe("a", "b", "c")
+	sub.PSubscribe("x.*", "y.*")
+
+	assert.Equal(t, 4, sub.Unsubscribe("a"))
+	assert.Equal(t, 3, sub.PUnsubscribe("x.*"))
+	assert.Zero(t, b.Publish("a", nil))
+	assert.Zero(t, b.Publish("x.1", nil))
+	assert.Equal(t, 1, b.Publish("y.1", nil))
+
+	assert.Equal(t, 1, sub.Unsubscribe())
+	assert.Empty(t, sub.Channels())
+	assert.Equal(t, 0, sub.PUnsubscribe())
+	assert.Empty(t, b.Channels(), "channels without subscribers are forgotten")
+}
+
+func TestSubscription_SlowConsumerIsDisconnected(t *testing.T) {
+	reg := metrics.NewRegistry()
+	b := NewBroker(reg)
+
+	slow := newSubscription(t, b, 2)
+	slow.Subscribe("c")
+	fast := newSubscription(t, b, 10)
+	fast.Subscribe("c")
+
+	for range 3 {
+		b.Publish("c", []byte("m"))
+	}
+
+	assert.Len(t, received(slow), 2)
+	assert.ErrorIs(t, slow.Err(), ErrSlowConsumer)
+	assert.Equal(t, 0, slow.Subscribe("d"), "an ended subscription ignores changes")
+
+	assert.Len(t, received(fast), 3)
+	assert.NoError(t, fast.Err())
+
+	snap := reg.Snapshot()
+	assert.Equal(t, int64(1), snap[string(metrics.PubSubSlowConsumersTotal)])
+	assert.Equal(t, int64(1), snap[string(metrics.PubSubSubscribers)])
+}
+
+func TestBroker_Close(t *testing.T) {
+	b := NewBroker(metrics.NewRegistry())
+
+	sub := newSubscription(t, b, 0)
+	sub.Subscribe("c")
+
+	b.Close()
+	_, ok := <-sub.Messages()
+	assert.False(t, ok)
+	assert.ErrorIs(t, sub.Err(), ErrClosed)
+
+	_, err := b.NewSubscription(0)
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.Zero(t, b.Publish("c", nil))
+
+	sub.Close()
+	assert.ErrorIs(t, sub.Err(), ErrClosed, "Close after the end keeps the reason")
+}
+
+func TestBroker_ConcurrentUse(t *testing.T) {
+	b := NewBroker(metrics.NewRegistry())
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sub, err := b.NewSubscription(0)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer sub.Close()
+
+			sub.Subscribe("c")
+			for range 100 {
+				b.Publish("c", []byte{byte(i)})
+				received(sub)
+			}
+			sub.Unsubscribe("c")
+		}()
+	}
+	wg.Wait()
+
+	assert.Empty(t, b.Channels())
+}
@@ -0,0 +1,291 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// handshakeTimeout bounds a handshake whose context has no deadline.
+const handshakeTimeout = 5 * time.Second
+
+// ErrUnsupportedVersion is returned when a peer speaks no protocol
+// version this node supports.
+var ErrUnsupportedVersion = errors.New("replication: unsupported protocol version")
+
+var errConnClosed = errors.New("replication: connection closed")
+
+// peerError is a payload the peer refused for a reason other than a conflict.
+type peerError struct {
+	status  byte
+	message string
+}
+
+func (e *peerError) Error() string {
+	return "replication: peer refused payload: " + e.message
+}
+
+// Unwrap lets errors.Is match ErrInvalidPayload and ErrUnknownNamespace.
+func (e *peerError) Unwrap() error {
+	switch e.status {
+	case statusInvalid:
+		return ErrInvalidPayload
+	case statusUnknownNamespace:
+		return ErrUnknownNamespace
+	}
+	return nil
+}
+
+/* ---------------- binaryPeer ---------------- */
+
+// binaryPeer sends payloads to one peer over a single multiplexed
+// connection, dialed on first use and again after it breaks.
+type binaryPeer struct {
+	addr string
+
+	mu     sync.Mutex
+	conn   *muxConn
+	closed bool
+}
+
+func newBinaryPeer(addr string) *binaryPeer {
+	return &binaryPeer{addr: addr}
+}
+
+// send delivers one payload and waits for the peer to apply it.
+// A timeout above zero bounds the attempt, like the HTTP client timeout.
+func (p *binaryPeer) send(ctx context.Context, timeout time.Duration, payload any) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	frame, typ, err := appendPayload(make([]byte, frameHeaderLen, 256), payload)
+	if err != nil {
+		return err
+	}
+
+	conn, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := conn.roundTrip(ctx, typ, frame)
+	if err != nil {
+		return err
+	}
+
+	// As with HTTP 409, a conflict means the peer has converged.
+	if resp.status == statusOK || resp.status == statusConflict {
+		return nil
+	}
+	return &peerError{status: resp.status, message: resp.message}
+}
+
+// connect returns the open connection, dialing a new one if needed.
+func (p *binaryPeer) connect(ctx context.Context) (*muxConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, errConnClosed
+	}
+	if p.conn != nil && !p.conn.broken() {
+		return p.conn, nil
+	}
+
+	conn, err := dialMux(ctx, p.addr)
+	if err != nil {
+		return nil, err
+	}
+	p.conn = conn
+	return conn, nil
+}
+
+// close closes the connection; later sends fail.
+func (p *binaryPeer) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	if p.conn != nil {
+		p.conn.close()
+	}
+}
+
+/* ---------------- muxConn ---------------- */
+
+// muxConn is a client connection carrying many concurrent requests.
+// Writers take turns sending whole frames; a reader goroutine hands each
+// response to the request waiting for its ID.
+type muxConn struct {
+	nc net.Conn
+
+	wmu sync.Mutex // serializes frame writes
+
+	mu      sync.Mutex
+	pending map[uint32]chan response
+	nextID  uint32
+	err     error
+	done    chan struct{} // closed once the connection fails
+}
+
+// dialMux connects to addr and performs the handshake.
+func dialMux(ctx context.Context, addr string) (*muxConn, error) {
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := handshake(ctx, nc); err != nil {
+		nc.Close()
+		return nil, err
+	}
+
+	c := &muxConn{
+		nc:      nc,
+		pending: make(map[uint32]chan response),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+// handshake offers protocolVersion and checks the version the server accepts.
+func handshake(ctx context.Context, nc net.Conn) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(handshakeTimeout)
+	}
+	_ = nc.SetDeadline(deadline)
+	defer nc.SetDeadline(time.Time{})
+
+	if err := writeHello(nc, protocolVersion); err != nil {
+		return err
+	}
+	version, err := readHello(nc)
+	if err != nil {
+		return err
+	}
+	if version == 0 || version > protocolVersion {
+		return fmt.Errorf("%w %d", ErrUnsupportedVersion, version)
+	}
+	return nil
+}
+
+// roundTrip sends a frame built on frameHeaderLen reserved bytes and
+// waits for its response.
+func (c *muxConn) roundTrip(ctx context.Context, typ byte, frame []byte) (response, error) {
+	ch := make(chan response, 1)
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return response{}, c.err
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer c.forget(id)
+
+	putHeader(frame, typ, id)
+	if err := c.write(ctx, frame); err != nil {
+		// A partly written frame leaves the stream unusable.
+		c.fail(err)
+		return response{}, err
+	}
+
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-c.done:
+		return response{}, c.failure()
+	case <-ctx.Done():
+		return response{}, ctx.Err()
+	}
+}
+
+func (c *muxConn) write(ctx context.Context, frame []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	deadline, _ := ctx.Deadline() // zero means none
+	_ = c.nc.SetWriteDeadline(deadline)
+	_, err := c.nc.Write(frame)
+	return err
+}
+
+func (c *muxConn) forget(id uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.pending, id)
+}
+
+// readLoop delivers responses until the connection fails.
+func (c *muxConn) readLoop() {
+	r := newFrameReader(c.nc)
+	for {
+		f, err := r.next()
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		if f.typ != frameResponse {
+			c.fail(fmt.Errorf("replication: unexpected frame type %d", f.typ))
+			return
+		}
+		resp, err := decodeResponse(f.body)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[f.id]
+		delete(c.pending, f.id)
+		c.mu.Unlock()
+
+		// Requests that gave up waiting are no longer pending.
+		if ok {
+			ch <- resp
+		}
+	}
+}
+
+// fail closes the connection; requests in flight and later ones get err.
+func (c *muxConn) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	close(c.done)
+	c.nc.Close()
+}
+
+func (c *muxConn) failure() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.err
+}
+
+func (c *muxConn) broken() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *muxConn) close() {
+	c.fail(errConnClosed)
+}
@@ -0,0 +1,273 @@
+package replication
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"distributed-cache/internal/store"
+)
+
+// Binary encoding of payloads, used by the binary transport.
+//
+// Integers are varints, strings and byte slices are a uvarint length
+// followed by the raw bytes, and times are Unix nanoseconds. An entry
+// starts with a bit set telling which optional fields follow.
+
+// errTruncated reports a payload that ends in the middle of a field.
+var errTruncated = errors.New("truncated payload")
+
+// Entry field bits.
+const (
+	entryDeleted = 1 << iota
+	entryExpires
+	entryDeletedAt
+	entryCounter
+)
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendBytes(buf, b []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+func appendEntry(buf []byte, e store.Entry) []byte {
+	var bits byte
+	if e.Deleted {
+		bits |= entryDeleted
+	}
+	if !e.ExpiresAt.IsZero() {
+		bits |= entryExpires
+	}
+	if !e.DeletedAt.IsZero() {
+		bits |= entryDeletedAt
+	}
+	if e.Counter != nil {
+		bits |= entryCounter
+	}
+
+	buf = append(buf, bits)
+	buf = appendBytes(buf, e.Value)
+	buf = appendString(buf, e.ContentType)
+	buf = binary.AppendUvarint(buf, uint64(e.Flags))
+	buf = binary.AppendVarint(buf, e.Timestamp)
+	buf = binary.AppendUvarint(buf, e.Version)
+	if bits&entryExpires != 0 {
+		buf = binary.AppendVarint(buf, e.ExpiresAt.UnixNano())
+	}
+	if bits&entryDeletedAt != 0 {
+		buf = binary.AppendVarint(buf, e.DeletedAt.UnixNano())
+	}
+	if bits&entryCounter != 0 {
+		buf = binary.AppendVarint(buf, e.Counter.Epoch)
+		buf = appendSlots(buf, e.Counter.P)
+		buf = appendSlots(buf, e.Counter.N)
+	}
+	return buf
+}
+
+func appendSlots(buf []byte, slots map[string]int64) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(slots)))
+	for node, n := range slots {
+		buf = appendString(buf, node)
+		buf = binary.AppendVarint(buf, n)
+	}
+	return buf
+}
+
+// appendPayload encodes a Payload, BatchPayload or PublishPayload and
+// returns its frame type.
+func appendPayload(buf []byte, payload any) ([]byte, byte, error) {
+	switch p := payload.(type) {
+	case Payload:
+		buf = appendString(buf, p.Namespace)
+		buf = appendString(buf, p.Key)
+		buf = appendEntry(buf, p.Entry)
+		buf = appendString(buf, p.OriginalNodeID)
+		return buf, frameWrite, nil
+
+	case BatchPayload:
+		buf = appendString(buf, p.Namespace)
+		buf = appendString(buf, p.OriginalNodeID)
+		buf = binary.AppendUvarint(buf, uint64(len(p.Writes)))
+		for _, wr := range p.Writes {
+			buf = appendString(buf, wr.Key)
+			buf = appendEntry(buf, wr.Entry)
+		}
+		return buf, frameBatch, nil
+
+	case PublishPayload:
+		buf = appendString(buf, p.Channel)
+		buf = appendBytes(buf, p.Message)
+		buf = appendString(buf, p.OriginalNodeID)
+		return buf, framePublish, nil
+	}
+	return nil, 0, fmt.Errorf("replication: cannot encode %T", payload)
+}
+
+// decoder reads fields from a payload; the first error sticks.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = errTruncated
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.err = errTruncated
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.buf) == 0 {
+		d.err = errTruncated
+		return 0
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	return b
+}
+
+// bytes returns a copy, so the frame buffer can be reused; empty values
+// decode as nil.
+func (d *decoder) bytes() []byte {
+	n := d.uvarint()
+	if d.err != nil || n == 0 {
+		return nil
+	}
+	if n > uint64(len(d.buf)) {
+		d.err = errTruncated
+		return nil
+	}
+	b := make([]byte, n)
+	copy(b, d.buf)
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) string() string {
+	n := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if n > uint64(len(d.buf)) {
+		d.err = errTruncated
+		return ""
+	}
+	s := string(d.buf[:n])
+	d.buf = d.buf[n:]
+	return s
+}
+
+// count reads a length prefix, bounded by the bytes left so a corrupt
+// prefix cannot allocate without limit: every element takes a byte at least.
+func (d *decoder) count() int {
+	n := d.uvarint()
+	if n > uint64(len(d.buf)) {
+		d.err = errTruncated
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) entry() store.Entry {
+	bits := d.byte()
+	e := store.Entry{
+		Value:       d.bytes(),
+		ContentType: d.string(),
+		Flags:       uint32(d.uvarint()),
+		Timestamp:   d.varint(),
+		Version:     d.uvarint(),
+		Deleted:     bits&entryDeleted != 0,
+	}
+	if bits&entryExpires != 0 {
+		e.ExpiresAt = time.Unix(0, d.varint())
+	}
+	if bits&entryDeletedAt != 0 {
+		e.DeletedAt = time.Unix(0, d.varint())
+	}
+	if bits&entryCounter != 0 {
+		e.Counter = &store.Counter{Epoch: d.varint(), P: d.slots(), N: d.slots()}
+	}
+	return e
+}
+
+func (d *decoder) slots() map[string]int64 {
+	n := d.count()
+	slots := make(map[string]int64, n)
+	for range n {
+		node := d.string()
+		slots[node] = d.varint()
+	}
+	return slots
+}
+
+// decodePayload decodes the body of a request frame.
+func decodePayload(typ byte, body []byte) (any, error) {
+	d := &decoder{buf: body}
+
+	var payload any
+	switch typ {
+	case frameWrite:
+		payload = Payload{
+			Namespace:      d.string(),
+			Key:            d.string(),
+			Entry:          d.entry(),
+			OriginalNodeID: d.string(),
+		}
+
+	case frameBatch:
+		p := BatchPayload{Namespace: d.string(), OriginalNodeID: d.string()}
+		n := d.count()
+		p.Writes = make([]Write, 0, n)
+		for range n {
+			p.Writes = append(p.Writes, Write{Key: d.string(), Entry: d.entry()})
+		}
+		payload = p
+
+	case framePublish:
+		payload = PublishPayload{
+			Channel:        d.string(),
+			Message:        d.bytes(),
+			OriginalNodeID: d.string(),
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown frame type %d", typ)
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(d.buf) > 0 {
+		return nil, fmt.Errorf("%d trailing bytes", len(d.buf))
+	}
+	return payload, nil
+}
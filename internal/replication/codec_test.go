@@ -0,0 +1,107 @@
+package replication
+
+import (
+	"testing"
+	"time"
+
+	"distributed-cache/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func roundTrip(t *testing.T, payload any) any {
+	t.Helper()
+
+	body, typ, err := appendPayload(nil, payload)
+	require.NoError(t, err)
+	decoded, err := decodePayload(typ, body)
+	require.NoError(t, err)
+	return decoded
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	expires := time.Unix(1_700_000_000, 123)
+
+	t.Run("write", func(t *testing.T) {
+		p := Payload{
+			Namespace: "orders",
+			Key:       "k",
+			Entry: store.Entry{
+				Value:       []byte("v"),
+				ContentType: "text/plain",
+				Flags:       42,
+				ExpiresAt:   expires,
+				Timestamp:   7,
+				Version:     3,
+			},
+			OriginalNodeID: "node-A",
+		}
+		assert.Equal(t, p, roundTrip(t, p))
+	})
+
+	t.Run("tombstone", func(t *testing.T) {
+		p := Payload{Key: "k", Entry: store.Tombstone(9), OriginalNodeID: "node-A"}
+		got := roundTrip(t, p).(Payload)
+
+		assert.True(t, got.Entry.Deleted)
+		assert.True(t, p.Entry.DeletedAt.Equal(got.Entry.DeletedAt))
+		assert.Equal(t, int64(9), got.Entry.Timestamp)
+	})
+
+	t.Run("counter", func(t *testing.T) {
+		p := Payload{
+			Key: "n",
+			Entry: store.Entry{
+				Value:     []byte("4"),
+				Counter:   &store.Counter{Epoch: 3, P: map[string]int64{"a": 5, "b": 1}, N: map[string]int64{"a": 2}},
+				Timestamp: 1,
+			},
+			OriginalNodeID: "a",
+		}
+		assert.Equal(t, p, roundTrip(t, p))
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		p := BatchPayload{
+			Namespace: "orders",
+			Writes: []Write{
+				{Key: "a", Entry: store.Entry{Value: []byte("1"), Timestamp: 1}},
+				{Key: "b", Entry: store.Tombstone(2)},
+			},
+			OriginalNodeID: "node-A",
+		}
+		got := roundTrip(t, p).(BatchPayload)
+		require.Len(t, got.Writes, 2)
+		assert.Equal(t, p.Writes[0], got.Writes[0])
+		assert.True(t, got.Writes[1].Entry.Deleted)
+	})
+
+	t.Run("publish", func(t *testing.T) {
+		p := PublishPayload{Channel: "news", Message: []byte("hi"), OriginalNodeID: "node-A"}
+		assert.Equal(t, p, roundTrip(t, p))
+	})
+}
+
+func TestCodec_Errors(t *testing.T) {
+	_, _, err := appendPayload(nil, "not a payload")
+	assert.Error(t, err)
+
+	body, typ, err := appendPayload(nil, Payload{Key: "k", Entry: store.Entry{Value: []byte("value")}})
+	require.NoError(t, err)
+
+	for n := range len(body) {
+		_, err := decodePayload(typ, body[:n])
+		assert.Error(t, err, "truncated at %d", n)
+	}
+
+	_, err = decodePayload(typ, append(body, 0))
+	assert.ErrorContains(t, err, "trailing bytes")
+
+	_, err = decodePayload(99, body)
+	assert.ErrorContains(t, err, "unknown frame type")
+
+	// A huge count must not be trusted for allocation.
+	_, err = decodePayload(frameBatch, []byte{0, 0, 0xff, 0xff, 0xff, 0xff, 0x0f})
+	assert.ErrorIs(t, err, errTruncated)
+}
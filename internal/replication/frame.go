@@ -0,0 +1,150 @@
+package replication
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Binary protocol framing.
+//
+// A connection opens with a handshake: the client sends the magic "DCRB"
+// and the highest protocol version it speaks (uint16, big-endian); the
+// server answers with the magic and the version both will use, or 0 when
+// it supports none of the client's.
+//
+// Every message after the handshake is a frame:
+//
+//	length  uint32  bytes that follow
+//	type    uint8   frameWrite, frameBatch, framePublish or frameResponse
+//	id      uint32  request ID, echoed by the response
+//	body    []byte  encoded payload, or the response status and message
+//
+// Requests carry IDs chosen by the client, so many can be in flight on one
+// connection and responses are matched to them by ID.
+
+const (
+	protocolMagic   = "DCRB"
+	protocolVersion = 1
+
+	frameHeaderLen = 9
+	maxFrameLen    = 64 << 20
+)
+
+// Frame types.
+const (
+	frameWrite    byte = 1
+	frameBatch    byte = 2
+	framePublish  byte = 3
+	frameResponse byte = 0x80
+)
+
+// Response statuses, mirroring the HTTP status codes of the receive handlers.
+const (
+	statusOK       byte = 0 // 204
+	statusConflict byte = 1 // 409
+	statusInvalid  byte = 2 // 400
+	statusError    byte = 3 // 500
+
+	statusUnknownNamespace byte = 4 // 404
+)
+
+var errBadMagic = errors.New("replication: not a binary replication connection")
+
+// writeHello writes the handshake message.
+func writeHello(w io.Writer, version uint16) error {
+	hello := binary.BigEndian.AppendUint16([]byte(protocolMagic), version)
+	_, err := w.Write(hello)
+	return err
+}
+
+// readHello reads the handshake message and returns its version.
+func readHello(r io.Reader) (uint16, error) {
+	var hello [len(protocolMagic) + 2]byte
+	if _, err := io.ReadFull(r, hello[:]); err != nil {
+		return 0, err
+	}
+	if string(hello[:len(protocolMagic)]) != protocolMagic {
+		return 0, errBadMagic
+	}
+	return binary.BigEndian.Uint16(hello[len(protocolMagic):]), nil
+}
+
+// putHeader fills in the header of a frame built on frameHeaderLen
+// reserved bytes.
+func putHeader(frame []byte, typ byte, id uint32) {
+	binary.BigEndian.PutUint32(frame, uint32(len(frame)-4))
+	frame[4] = typ
+	binary.BigEndian.PutUint32(frame[5:], id)
+}
+
+// frame is a decoded frame; body is only valid until the next read.
+type frame struct {
+	typ  byte
+	id   uint32
+	body []byte
+}
+
+// frameReader reads frames, reusing one buffer for their bodies.
+type frameReader struct {
+	*bufio.Reader
+	buf []byte
+}
+
+func newFrameReader(r io.Reader) *frameReader {
+	return &frameReader{Reader: bufio.NewReaderSize(r, 64<<10)}
+}
+
+// next reads the next frame.
+func (r *frameReader) next() (frame, error) {
+	var header [frameHeaderLen]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return frame{}, err
+	}
+
+	n := binary.BigEndian.Uint32(header[:])
+	if n < frameHeaderLen-4 || n > maxFrameLen {
+		return frame{}, fmt.Errorf("replication: invalid frame length %d", n)
+	}
+	size := int(n) - (frameHeaderLen - 4)
+	if cap(r.buf) < size {
+		r.buf = make([]byte, size)
+	}
+	body := r.buf[:size]
+	if _, err := io.ReadFull(r, body); err != nil {
+		return frame{}, err
+	}
+
+	return frame{
+		typ:  header[4],
+		id:   binary.BigEndian.Uint32(header[5:]),
+		body: body,
+	}, nil
+}
+
+// appendResponse appends a response frame.
+func appendResponse(buf []byte, id uint32, status byte, message string) []byte {
+	start := len(buf)
+	buf = append(buf, make([]byte, frameHeaderLen)...)
+	buf = append(buf, status)
+	buf = appendString(buf, message)
+	putHeader(buf[start:], frameResponse, id)
+	return buf
+}
+
+// response is the decoded body of a response frame.
+type response struct {
+	status  byte
+	message string
+}
+
+func decodeResponse(body []byte) (response, error) {
+	d := &decoder{buf: body}
+	resp := response{status: d.byte(), message: d.string()}
+	if d.err != nil {
+		return response{}, d.err
+	}
+	return resp, nil
+}
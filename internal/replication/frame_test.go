@@ -0,0 +1,56 @@
+package replication
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHello(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeHello(&buf, 7))
+	assert.Equal(t, "DCRB\x00\x07", buf.String())
+
+	version, err := readHello(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(7), version)
+
+	_, err = readHello(bytes.NewBufferString("HTTP/1.1"))
+	assert.ErrorIs(t, err, errBadMagic)
+}
+
+func TestFrameReader(t *testing.T) {
+	var stream []byte
+	stream = appendResponse(stream, 1, statusOK, "")
+	stream = appendResponse(stream, 2, statusInvalid, "missing key")
+
+	r := newFrameReader(bytes.NewReader(stream))
+
+	f, err := r.next()
+	require.NoError(t, err)
+	assert.Equal(t, frameResponse, f.typ)
+	assert.Equal(t, uint32(1), f.id)
+
+	f, err = r.next()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), f.id)
+	resp, err := decodeResponse(f.body)
+	require.NoError(t, err)
+	assert.Equal(t, response{status: statusInvalid, message: "missing key"}, resp)
+
+	_, err = r.next()
+	assert.Error(t, err)
+}
+
+func TestFrameReader_RejectsBadLength(t *testing.T) {
+	for _, n := range []uint32{0, maxFrameLen + 1} {
+		header := binary.BigEndian.AppendUint32(nil, n)
+		header = append(header, make([]byte, frameHeaderLen-4)...)
+
+		_, err := newFrameReader(bytes.NewReader(header)).next()
+		assert.ErrorContains(t, err, "invalid frame length")
+	}
+}
@@ -0,0 +1,496 @@
+package replication
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"distributed-cache/internal/logs"
+	"distributed-cache/internal/metrics"
+)
+
+// HintConfig bounds the hints kept for unhealthy peers.
+type HintConfig struct {
+	// MaxHints is the most hints kept per peer; the oldest are dropped
+	// first. Zero disables hinted handoff.
+	MaxHints int
+
+	// MaxBytes bounds the keys and values hinted per peer.
+	MaxBytes int64
+
+	// TTL is how long a hint is kept. Zero keeps hints until they are
+	// replayed or pushed out by the caps.
+	//
+	// It must not exceed the tombstone grace period of the peers: once they
+	// purged the tombstone of a later delete, replaying the hint brings the
+	// deleted key back.
+	TTL time.Duration
+}
+
+// DefaultHintConfig returns the hint settings used by NewReplicator. Hints
+// expire well within ttl.DefaultTombstoneGrace.
+func DefaultHintConfig() HintConfig {
+	return HintConfig{
+		MaxHints: 100000,
+		MaxBytes: 64 << 20,
+		TTL:      5 * time.Minute,
+	}
+}
+
+// Hint files.
+//
+// Persisted hints live in one file per peer, named after the escaped peer
+// URL. As in WAL segments, every record is framed as length + CRC-32C +
+// payload; the payload is the creation time of the hint followed by the
+// binary encoding of its namespace, key and entry.
+//
+// Files are appended to without fsync and rewritten once most of their
+// records were replayed or dropped. A hint replayed twice after a crash is
+// harmless: writes are last-writer-wins.
+const (
+	hintFileSuffix = ".hints"
+	hintHeaderLen  = 8
+)
+
+var hintCRC = crc32.MakeTable(crc32.Castagnoli)
+
+// hint is a write kept for a peer that could not receive it.
+type hint struct {
+	seq       uint64
+	namespace string
+	write     Write
+	created   time.Time
+	size      int64
+}
+
+// hintLog holds the hints of one peer, oldest first.
+type hintLog struct {
+	peer  string
+	hints []hint
+	bytes int64
+
+	file *os.File // nil unless hints are persisted
+	dead int      // records at the start of file no longer in hints
+
+	replaying bool
+}
+
+// hintStore keeps the writes destined to unhealthy peers until they
+// recover.
+type hintStore struct {
+	mu     sync.Mutex
+	cfg    HintConfig
+	dir    string // empty keeps hints in memory only
+	logs   map[string]*hintLog
+	seq    uint64
+	closed bool
+
+	now     func() time.Time
+	logger  *logs.Logger
+	metrics *metrics.Registry
+}
+
+func newHintStore(cfg HintConfig, logger *logs.Logger, metricsRegistry *metrics.Registry) *hintStore {
+	return &hintStore{
+		cfg:     cfg,
+		logs:    make(map[string]*hintLog),
+		now:     time.Now,
+		logger:  logger,
+		metrics: metricsRegistry,
+	}
+}
+
+// update swaps the caps and applies them to the hints already kept.
+func (s *hintStore) update(cfg HintConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cfg = cfg
+	now := s.now()
+	for _, l := range s.logs {
+		s.enforceLocked(l, now)
+	}
+}
+
+// persist keeps hints in files under dir, loading the files left there by
+// a previous run.
+func (s *hintStore) persist(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dir = dir
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, hintFileSuffix) {
+			continue
+		}
+		peer, err := url.QueryUnescape(strings.TrimSuffix(name, hintFileSuffix))
+		if err != nil {
+			continue
+		}
+
+		loaded, err := s.load(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		l := s.logLocked(peer)
+		for _, h := range loaded {
+			s.seq++
+			h.seq = s.seq
+			l.hints = append(l.hints, h)
+			l.bytes += h.size
+		}
+		s.addDepth(peer, len(loaded))
+	}
+
+	// Rewrite every file: this drops torn records and covers the hints
+	// kept in memory so far.
+	now := s.now()
+	for _, l := range s.logs {
+		s.rewriteLocked(l)
+		s.enforceLocked(l, now)
+	}
+	return nil
+}
+
+// load reads the hints of one file. A torn or corrupt record ends it.
+func (s *hintStore) load(path string) ([]hint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var loaded []hint
+	for {
+		h, err := readHint(r)
+		if err == io.EOF {
+			return loaded, nil
+		}
+		if err != nil {
+			s.logger.Warn(fmt.Sprintf("replication: hints in %s end after %d records: %v", path, len(loaded), err))
+			return loaded, nil
+		}
+		loaded = append(loaded, h)
+	}
+}
+
+// add keeps writes for peer, then applies the caps.
+func (s *hintStore) add(peer, namespace string, writes []Write) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed || s.cfg.MaxHints <= 0 || len(writes) == 0 {
+		return
+	}
+
+	l := s.logLocked(peer)
+	now := s.now()
+	var buf []byte
+	for _, wr := range writes {
+		s.seq++
+		h := hint{
+			seq:       s.seq,
+			namespace: namespace,
+			write:     wr,
+			created:   now,
+			size:      writeSize(queueKey{namespace: namespace, key: wr.Key}, wr.Entry),
+		}
+		l.hints = append(l.hints, h)
+		l.bytes += h.size
+		buf = appendHint(buf, h)
+	}
+	s.metrics.Add(metrics.ReplicationHintsStoredTotal, int64(len(writes)))
+	s.addDepth(peer, len(writes))
+
+	if l.file != nil {
+		if _, err := l.file.Write(buf); err != nil {
+			s.detachLocked(l, err)
+		}
+	}
+	s.enforceLocked(l, now)
+}
+
+// startReplay claims the replay of peer's hints. It reports false when
+// there is nothing to replay or a replay is already running.
+func (s *hintStore) startReplay(peer string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.logs[peer]
+	if !ok || l.replaying || len(l.hints) == 0 || s.closed {
+		return false
+	}
+	l.replaying = true
+	return true
+}
+
+// stopReplay releases the replay of peer's hints.
+func (s *hintStore) stopReplay(peer string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if l, ok := s.logs[peer]; ok {
+		l.replaying = false
+	}
+}
+
+// next returns up to n of the oldest hints of peer. When none are left it
+// releases the replay and returns nil.
+func (s *hintStore) next(peer string, n int) []hint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.logs[peer]
+	if !ok {
+		return nil
+	}
+	if !s.closed {
+		s.enforceLocked(l, s.now())
+	}
+	if s.closed || len(l.hints) == 0 {
+		l.replaying = false
+		return nil
+	}
+	return slices.Clone(l.hints[:min(n, len(l.hints))])
+}
+
+// replayed removes the hints of peer up to seq once the peer acknowledged
+// them. Hints dropped in the meantime are skipped.
+func (s *hintStore) replayed(peer string, seq uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.logs[peer]
+	if !ok {
+		return
+	}
+	n := 0
+	for n < len(l.hints) && l.hints[n].seq <= seq {
+		n++
+	}
+	if n > 0 {
+		s.metrics.Add(metrics.ReplicationHintsReplayedTotal, int64(n))
+		s.removeLocked(l, n)
+	}
+}
+
+// remove drops the hints of a peer that was removed.
+func (s *hintStore) remove(peer string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.logs[peer]
+	if !ok {
+		return
+	}
+	s.metrics.Add(metrics.ReplicationHintsDroppedTotal, int64(len(l.hints)))
+	s.removeLocked(l, len(l.hints))
+	if l.file != nil {
+		l.file.Close()
+		if err := os.Remove(s.path(peer)); err != nil {
+			s.logger.Warn("replication: removing hints: " + err.Error())
+		}
+	}
+	delete(s.logs, peer)
+}
+
+// close stops keeping hints and closes their files.
+func (s *hintStore) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for _, l := range s.logs {
+		if l.file != nil {
+			if err := l.file.Close(); err != nil {
+				s.logger.Warn("replication: closing hints: " + err.Error())
+			}
+			l.file = nil
+		}
+	}
+}
+
+// logLocked returns the hints of peer, opening their file when hints are
+// persisted.
+func (s *hintStore) logLocked(peer string) *hintLog {
+	l, ok := s.logs[peer]
+	if ok {
+		return l
+	}
+
+	l = &hintLog{peer: peer}
+	if s.dir != "" {
+		f, err := os.OpenFile(s.path(peer), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			s.logger.Warn(fmt.Sprintf("replication: hints for %s kept in memory only: %v", peer, err))
+		}
+		l.file = f
+	}
+	s.logs[peer] = l
+	return l
+}
+
+// enforceLocked drops the oldest hints of l while they are expired or
+// over the caps.
+func (s *hintStore) enforceLocked(l *hintLog, now time.Time) {
+	n, bytes := 0, l.bytes
+	for n < len(l.hints) {
+		h := l.hints[n]
+		over := len(l.hints)-n > s.cfg.MaxHints || bytes > s.cfg.MaxBytes
+		expired := s.cfg.TTL > 0 && now.Sub(h.created) > s.cfg.TTL
+		if !over && !expired {
+			break
+		}
+		bytes -= h.size
+		n++
+	}
+	if n > 0 {
+		s.metrics.Add(metrics.ReplicationHintsDroppedTotal, int64(n))
+		s.removeLocked(l, n)
+	}
+}
+
+// removeLocked removes the n oldest hints of l.
+func (s *hintStore) removeLocked(l *hintLog, n int) {
+	for _, h := range l.hints[:n] {
+		l.bytes -= h.size
+	}
+	clear(l.hints[:n])
+	l.hints = l.hints[n:]
+	s.addDepth(l.peer, -n)
+
+	if l.file == nil {
+		return
+	}
+	l.dead += n
+	switch {
+	case len(l.hints) == 0:
+		if err := l.file.Truncate(0); err != nil {
+			s.detachLocked(l, err)
+			return
+		}
+		l.dead = 0
+	case l.dead > len(l.hints):
+		s.rewriteLocked(l)
+	}
+}
+
+// rewriteLocked replaces the file of l with the hints it still holds.
+func (s *hintStore) rewriteLocked(l *hintLog) {
+	var buf []byte
+	for _, h := range l.hints {
+		buf = appendHint(buf, h)
+	}
+
+	path := s.path(l.peer)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0o644); err != nil {
+		s.detachLocked(l, err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		s.detachLocked(l, err)
+		return
+	}
+
+	if l.file != nil {
+		l.file.Close()
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		l.file = nil
+		s.detachLocked(l, err)
+		return
+	}
+	l.file, l.dead = f, 0
+}
+
+// detachLocked keeps the hints of l in memory only after a file error.
+func (s *hintStore) detachLocked(l *hintLog, err error) {
+	s.logger.Warn(fmt.Sprintf("replication: hints for %s kept in memory only: %v", l.peer, err))
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+	l.dead = 0
+}
+
+func (s *hintStore) path(peer string) string {
+	return filepath.Join(s.dir, url.QueryEscape(peer)+hintFileSuffix)
+}
+
+func (s *hintStore) addDepth(peer string, n int) {
+	s.metrics.Add(metrics.ReplicationHints, int64(n))
+	s.metrics.Add(metrics.Labeled(metrics.ReplicationHints, "peer", peer), int64(n))
+}
+
+// appendHint appends the framed record of h to buf.
+func appendHint(buf []byte, h hint) []byte {
+	start := len(buf)
+	buf = append(buf, make([]byte, hintHeaderLen)...)
+	buf = binary.AppendVarint(buf, h.created.UnixNano())
+	buf = appendString(buf, h.namespace)
+	buf = appendString(buf, h.write.Key)
+	buf = appendEntry(buf, h.write.Entry)
+
+	payload := buf[start+hintHeaderLen:]
+	binary.BigEndian.PutUint32(buf[start:], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[start+4:], crc32.Checksum(payload, hintCRC))
+	return buf
+}
+
+// readHint reads one framed record. It returns io.EOF only at a clean
+// record boundary.
+func readHint(r io.Reader) (hint, error) {
+	var header [hintHeaderLen]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF {
+			return hint{}, io.EOF
+		}
+		return hint{}, errors.New("torn header")
+	}
+
+	length := binary.BigEndian.Uint32(header[:4])
+	if length == 0 || length > maxFrameLen {
+		return hint{}, fmt.Errorf("invalid record length %d", length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return hint{}, errors.New("torn payload")
+	}
+	if crc32.Checksum(payload, hintCRC) != binary.BigEndian.Uint32(header[4:]) {
+		return hint{}, errors.New("checksum mismatch")
+	}
+
+	d := &decoder{buf: payload}
+	h := hint{created: time.Unix(0, d.varint()), namespace: d.string()}
+	h.write = Write{Key: d.string(), Entry: d.entry()}
+	if d.err != nil {
+		return hint{}, d.err
+	}
+	if len(d.buf) != 0 {
+		return hint{}, errors.New("trailing bytes")
+	}
+	h.size = writeSize(queueKey{namespace: h.namespace, key: h.write.Key}, h.write.Entry)
+	return h, nil
+}
@@ -0,0 +1,313 @@
+package replication
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"distributed-cache/internal/logs"
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/peers"
+	"distributed-cache/internal/ttl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hintReplicator returns a replicator with one peer that turns unhealthy
+// after one failure and recovers after one success.
+func hintReplicator(t *testing.T, peer string) (*Replicator, *peers.PeerManager, *metrics.Registry) {
+	cfg := peers.DefaultPeerConfig()
+	cfg.Retry.MaxRetries = 0
+	cfg.Health.FailureThreshold = 1
+	cfg.Health.SuccessThreshold = 1
+
+	reg := metrics.NewRegistry()
+	pm := peers.NewPeerManager(cfg, reg)
+	pm.AddPeer(peer)
+
+	replicator := NewReplicator("node-A", pm, cfg, logs.NewLogger(10, logs.DEBUG), reg)
+	t.Cleanup(replicator.Close)
+	return replicator, pm, reg
+}
+
+func newTestHintStore(cfg HintConfig) (*hintStore, *metrics.Registry) {
+	reg := metrics.NewRegistry()
+	return newHintStore(cfg, logs.NewLogger(10, logs.DEBUG), reg), reg
+}
+
+// keys returns the hinted writes of peer as "key=value".
+func (s *hintStore) keys(peer string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []string
+	if l, ok := s.logs[peer]; ok {
+		for _, h := range l.hints {
+			out = append(out, h.write.Key+"="+string(h.write.Entry.Value))
+		}
+	}
+	return out
+}
+
+func TestHints_ReplayedInOrderOnRecovery(t *testing.T) {
+	peer := newBatchPeer(t, false)
+	replicator, pm, reg := hintReplicator(t, peer.URL)
+	ctx := context.Background()
+
+	pm.MarkFailure(peer.URL)
+	assert.Equal(t, 0, replicator.Replicate(ctx, "", "k", write("v1", 1)).Peers)
+	replicator.ReplicateBatch(ctx, "orders", []Write{{Key: "x", Entry: write("1", 1)}, {Key: "y", Entry: write("2", 1)}})
+	replicator.Replicate(ctx, "", "k", write("v2", 2))
+	replicator.Publish(ctx, "news", []byte("hi"))
+
+	assert.Empty(t, peer.received())
+	assert.Equal(t, []string{"k=v1", "x=1", "y=2", "k=v2"}, replicator.hints.keys(peer.URL), "messages are not hinted")
+	snap := reg.Snapshot()
+	assert.Equal(t, int64(4), snap[string(metrics.ReplicationHintsStoredTotal)])
+	assert.Equal(t, int64(4), snap[string(metrics.Labeled(metrics.ReplicationHints, "peer", peer.URL))])
+
+	pm.MarkSuccess(peer.URL)
+
+	require.Eventually(t, func() bool { return len(peer.received()) == 3 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, [][]string{{"k=v1"}, {"x=1", "y=2"}, {"k=v2"}}, peer.received())
+	require.Eventually(t, func() bool {
+		return reg.Snapshot()[string(metrics.ReplicationHintsReplayedTotal)] == 4
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int64(0), reg.Snapshot()[string(metrics.ReplicationHints)])
+}
+
+func TestHints_FailedReplayIsResumed(t *testing.T) {
+	peer := newBatchPeer(t, false)
+	replicator, pm, reg := hintReplicator(t, peer.URL)
+	ctx := context.Background()
+
+	// A write failing in flight marks the peer unhealthy and becomes a hint.
+	peer.failing.Store(true)
+	assert.Equal(t, 0, replicator.Replicate(ctx, "", "a", write("1", 1)).Wait(ctx, 1))
+	assert.False(t, pm.IsHealthy(peer.URL))
+	assert.Equal(t, []string{"a=1"}, replicator.hints.keys(peer.URL))
+
+	// The replay fails on the first recovery and the hint is kept.
+	pm.MarkSuccess(peer.URL)
+	require.Eventually(t, func() bool { return !pm.IsHealthy(peer.URL) }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"a=1"}, replicator.hints.keys(peer.URL))
+
+	peer.failing.Store(false)
+	pm.MarkSuccess(peer.URL)
+	require.Eventually(t, func() bool { return len(replicator.hints.keys(peer.URL)) == 0 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, [][]string{{"a=1"}}, peer.received())
+	assert.Equal(t, int64(1), reg.Snapshot()[string(metrics.ReplicationHintsReplayedTotal)])
+}
+
+func TestHints_StalledReplayIsRetried(t *testing.T) {
+	peer := newBatchPeer(t, false)
+	replicator, pm, reg := hintReplicator(t, peer.URL)
+	ctx := context.Background()
+
+	cfg := peers.DefaultPeerConfig()
+	cfg.Retry.MaxRetries = 0
+	cfg.Health.FailureThreshold = 2
+	cfg.Health.SuccessThreshold = 1
+	pm.UpdateConfig(cfg)
+	replicator.UpdateConfig(cfg)
+
+	pm.MarkFailure(peer.URL)
+	pm.MarkFailure(peer.URL)
+	replicator.Replicate(ctx, "", "a", write("1", 1))
+
+	// The replay fails once, which the peer survives: it stays healthy
+	// and nothing recovers to resume the replay.
+	peer.failing.Store(true)
+	pm.MarkSuccess(peer.URL)
+	require.Eventually(t, func() bool {
+		return reg.Snapshot()[string(metrics.ReplicationFailureTotal)] == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.True(t, pm.IsHealthy(peer.URL))
+
+	peer.failing.Store(false)
+	replicator.Replicate(ctx, "", "b", write("2", 1)).Wait(ctx, 1)
+	assert.Equal(t, []string{"a=1"}, replicator.hints.keys(peer.URL), "writes do not replay hints")
+
+	replicator.hintRetry = 10 * time.Millisecond
+	workerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go replicator.Start(workerCtx)
+
+	require.Eventually(t, func() bool { return len(replicator.hints.keys(peer.URL)) == 0 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, [][]string{{"b=2"}, {"a=1"}}, peer.received())
+}
+
+func TestHints_RemovedPeerIsDropped(t *testing.T) {
+	peer := newBatchPeer(t, false)
+	replicator, pm, reg := hintReplicator(t, peer.URL)
+	ctx := context.Background()
+
+	pm.MarkFailure(peer.URL)
+	replicator.Replicate(ctx, "", "a", write("1", 1))
+
+	pm.SetPeers(nil)
+
+	assert.Empty(t, replicator.hints.keys(peer.URL))
+	snap := reg.Snapshot()
+	assert.Equal(t, int64(1), snap[string(metrics.ReplicationHintsDroppedTotal)])
+	assert.Equal(t, int64(0), snap[string(metrics.ReplicationHints)])
+}
+
+func TestHintStore_Caps(t *testing.T) {
+	t.Run("count", func(t *testing.T) {
+		s, reg := newTestHintStore(HintConfig{MaxHints: 2, MaxBytes: 1 << 20})
+		s.add("p", "", []Write{{Key: "a", Entry: write("1", 1)}, {Key: "b", Entry: write("2", 1)}})
+		s.add("p", "", []Write{{Key: "c", Entry: write("3", 1)}})
+
+		assert.Equal(t, []string{"b=2", "c=3"}, s.keys("p"), "the oldest hint is dropped")
+		assert.Equal(t, int64(1), reg.Snapshot()[string(metrics.ReplicationHintsDroppedTotal)])
+	})
+
+	t.Run("bytes", func(t *testing.T) {
+		s, _ := newTestHintStore(HintConfig{MaxHints: 100, MaxBytes: 2*writeOverhead + 4})
+		s.add("p", "", []Write{{Key: "a", Entry: write("1", 1)}, {Key: "b", Entry: write("2", 1)}})
+		s.add("p", "", []Write{{Key: "c", Entry: write("3", 1)}})
+
+		assert.Equal(t, []string{"b=2", "c=3"}, s.keys("p"))
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		s, reg := newTestHintStore(HintConfig{MaxHints: 100, MaxBytes: 1 << 20, TTL: time.Minute})
+		now := time.Unix(1_700_000_000, 0)
+		s.now = func() time.Time { return now }
+
+		s.add("p", "", []Write{{Key: "a", Entry: write("1", 1)}})
+		now = now.Add(45 * time.Second)
+		s.add("p", "", []Write{{Key: "b", Entry: write("2", 1)}})
+		now = now.Add(30 * time.Second)
+
+		require.True(t, s.startReplay("p"))
+		batch := s.next("p", 10)
+		require.Len(t, batch, 1, "expired hints are not replayed")
+		assert.Equal(t, "b", batch[0].write.Key)
+		assert.Equal(t, int64(1), reg.Snapshot()[string(metrics.ReplicationHintsDroppedTotal)])
+	})
+
+	t.Run("update", func(t *testing.T) {
+		s, _ := newTestHintStore(DefaultHintConfig())
+		s.add("p", "", []Write{{Key: "a", Entry: write("1", 1)}, {Key: "b", Entry: write("2", 1)}})
+
+		s.update(HintConfig{MaxHints: 1, MaxBytes: 1 << 20})
+		assert.Equal(t, []string{"b=2"}, s.keys("p"))
+
+		s.update(HintConfig{})
+		assert.Empty(t, s.keys("p"), "zero disables hinted handoff")
+		s.add("p", "", []Write{{Key: "c", Entry: write("3", 1)}})
+		assert.Empty(t, s.keys("p"))
+	})
+}
+
+// A hint outliving the tombstones of the peers would undo a later delete:
+// once the peer purged the tombstone, the replayed write is accepted again.
+func TestHintStore_DefaultsExpireBeforeTombstones(t *testing.T) {
+	require.LessOrEqual(t, DefaultHintConfig().TTL, ttl.DefaultTombstoneGrace)
+
+	s, _ := newTestHintStore(DefaultHintConfig())
+	now := time.Unix(1_700_000_000, 0)
+	s.now = func() time.Time { return now }
+
+	// The write is hinted for the down peer, then deleted on the others.
+	s.add("p", "", []Write{{Key: "k", Entry: write("stale", 1)}})
+	deletedAt := now.Add(time.Second)
+
+	// When the peer is back after the tombstone was purged, the hint is gone.
+	now = deletedAt.Add(ttl.DefaultTombstoneGrace)
+	require.True(t, s.startReplay("p"))
+	assert.Nil(t, s.next("p", 10), "the hint would resurrect k")
+}
+
+func TestHintStore_ReplayedSkipsDroppedHints(t *testing.T) {
+	s, reg := newTestHintStore(HintConfig{MaxHints: 2, MaxBytes: 1 << 20})
+	s.add("p", "", []Write{{Key: "a", Entry: write("1", 1)}, {Key: "b", Entry: write("2", 1)}})
+
+	require.True(t, s.startReplay("p"))
+	assert.False(t, s.startReplay("p"), "one replay per peer")
+	batch := s.next("p", 10)
+	require.Len(t, batch, 2)
+
+	// While the batch is in flight, a new hint pushes out "a".
+	s.add("p", "", []Write{{Key: "c", Entry: write("3", 1)}})
+	s.replayed("p", batch[1].seq)
+
+	assert.Equal(t, []string{"c=3"}, s.keys("p"))
+	assert.Equal(t, int64(1), reg.Snapshot()[string(metrics.ReplicationHintsReplayedTotal)])
+
+	assert.Len(t, s.next("p", 10), 1)
+	s.replayed("p", s.seq)
+	assert.Nil(t, s.next("p", 10))
+	assert.False(t, s.startReplay("p"), "nothing left to replay")
+}
+
+func TestHintStore_Persist(t *testing.T) {
+	dir := t.TempDir()
+	const peer = "http://node-2:8080"
+	entry := write("v", 7)
+	entry.ExpiresAt = time.Unix(1_700_000_000, 0)
+
+	s, _ := newTestHintStore(DefaultHintConfig())
+	s.add(peer, "", []Write{{Key: "early", Entry: write("0", 1)}}) // kept in memory before persist
+	require.NoError(t, s.persist(dir))
+	s.add(peer, "orders", []Write{{Key: "a", Entry: entry}, {Key: "b", Entry: write("2", 1)}})
+	s.close()
+
+	path := filepath.Join(dir, "http%3A%2F%2Fnode-2%3A8080.hints")
+	require.FileExists(t, path)
+
+	// A torn record at the end is dropped on load.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0, 0, 0, 9, 1})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	loaded, reg := newTestHintStore(DefaultHintConfig())
+	require.NoError(t, loaded.persist(dir))
+
+	assert.Equal(t, []string{"early=0", "a=v", "b=2"}, loaded.keys(peer))
+	assert.Equal(t, int64(3), reg.Snapshot()[string(metrics.ReplicationHints)])
+
+	require.True(t, loaded.startReplay(peer))
+	batch := loaded.next(peer, 10)
+	require.Len(t, batch, 3)
+	assert.Equal(t, "orders", batch[1].namespace)
+	assert.Equal(t, int64(7), batch[1].write.Entry.Timestamp)
+	assert.True(t, entry.ExpiresAt.Equal(batch[1].write.Entry.ExpiresAt))
+
+	// Replayed hints leave the file once it is compacted.
+	loaded.replayed(peer, batch[1].seq)
+	loaded.close()
+
+	reloaded, _ := newTestHintStore(DefaultHintConfig())
+	require.NoError(t, reloaded.persist(dir))
+	defer reloaded.close()
+	assert.Equal(t, []string{"b=2"}, reloaded.keys(peer))
+
+	reloaded.replayed(peer, reloaded.seq)
+	stat, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Zero(t, stat.Size())
+}
+
+func TestReplicator_PersistHintsReplaysOnStartup(t *testing.T) {
+	peer := newBatchPeer(t, false)
+	dir := t.TempDir()
+
+	s, _ := newTestHintStore(DefaultHintConfig())
+	require.NoError(t, s.persist(dir))
+	s.add(peer.URL, "", []Write{{Key: "a", Entry: write("1", 1)}})
+	s.close()
+
+	replicator, _, _ := hintReplicator(t, peer.URL)
+	require.NoError(t, replicator.PersistHints(dir))
+
+	require.Eventually(t, func() bool { return len(peer.received()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, [][]string{{"a=1"}}, peer.received())
+}
@@ -5,12 +5,34 @@ import "distributed-cache/internal/store"
 //Payload represents the data structure used for replication between nodes
 
 // Each payload contains:
+// Namespace: the namespace of the key; empty means the default namespace
 // Key: the cache key being replicated
-// Entry: the full value+metadata(timestamp,TTL)
+// Entry: the full value+metadata(timestamp,TTL); deletes travel as tombstones
 // OriginalNodeID: the ID of the node where the change originated
 type Payload struct {
+	Namespace      string      `json:"namespace,omitempty"`
 	Key            string      `json:"key"`
 	Entry          store.Entry `json:"entry"`
 	OriginalNodeID string      `json:"original_node_id"`
 }
 
+// BatchPayload carries many writes to one namespace from one origin in a
+// single request, e.g. the writes of one batch API call.
+type BatchPayload struct {
+	Namespace      string  `json:"namespace,omitempty"`
+	Writes         []Write `json:"writes"`
+	OriginalNodeID string  `json:"original_node_id"`
+}
+
+// PublishPayload forwards a pub/sub message to the subscribers of peers.
+type PublishPayload struct {
+	Channel        string `json:"channel"`
+	Message        []byte `json:"message"`
+	OriginalNodeID string `json:"original_node_id"`
+}
+
+// Write is one key of a BatchPayload.
+type Write struct {
+	Key   string      `json:"key"`
+	Entry store.Entry `json:"entry"`
+}
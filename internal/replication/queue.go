@@ -0,0 +1,358 @@
+package replication
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/store"
+)
+
+// QueueConfig bounds the per-peer outbound queues and the batches sent
+// from them.
+type QueueConfig struct {
+	// Size is the number of distinct keys a peer's queue holds before
+	// writers wait for room.
+	Size int
+
+	// MaxBytes bounds the keys and values queued for a peer; writers wait
+	// for room beyond it. A single larger write is still queued on its own.
+	MaxBytes int64
+
+	// MaxBatch is the most writes sent to a peer in one request.
+	MaxBatch int
+
+	// MaxBatchBytes bounds the keys and values of one batch; a single
+	// larger write is still sent on its own.
+	MaxBatchBytes int64
+
+	// Linger is how long the sender waits for a batch to fill. Zero sends
+	// as soon as the sender is free; writes queued in the meantime form
+	// the next batch.
+	Linger time.Duration
+}
+
+// DefaultQueueConfig returns the queue settings used by NewReplicator.
+func DefaultQueueConfig() QueueConfig {
+	return QueueConfig{
+		Size:          10000,
+		MaxBytes:      64 << 20,
+		MaxBatch:      256,
+		MaxBatchBytes: 1 << 20,
+	}
+}
+
+// writeOverhead approximates the encoded size of a write besides its key
+// and value.
+const writeOverhead = 64
+
+// waiter collects the outcome of the writes of one Replicate or
+// ReplicateBatch call to one peer, and reports it once every write is done.
+type waiter struct {
+	mu      sync.Mutex
+	pending int
+	ok      bool
+	report  func(ok bool)
+}
+
+func newWaiter(writes int, report func(ok bool)) *waiter {
+	return &waiter{pending: writes, ok: true, report: report}
+}
+
+// done records the outcome of one write.
+func (w *waiter) done(ok bool) {
+	w.mu.Lock()
+	w.ok = w.ok && ok
+	w.pending--
+	finished, allOK := w.pending == 0, w.ok
+	w.mu.Unlock()
+
+	if finished {
+		w.report(allOK)
+	}
+}
+
+// queueKey identifies a key across namespaces.
+type queueKey struct {
+	namespace string
+	key       string
+}
+
+// queuedWrite is the newest write to one key not yet sent, and everyone
+// waiting for it. Older writes to the key were coalesced into it.
+type queuedWrite struct {
+	queueKey
+	entry   store.Entry
+	size    int64
+	waiters []*waiter
+}
+
+// peerQueue batches the writes to one peer. A single sender goroutine
+// drains it, so a peer has at most one replication request in flight.
+type peerQueue struct {
+	peer string
+	r    *Replicator
+
+	mu      sync.Mutex
+	pending map[queueKey]*queuedWrite
+	order   []*queuedWrite // FIFO of pending keys
+	bytes   int64
+	space   chan struct{} // closed when writes leave the queue
+	closed  bool
+
+	wake chan struct{} // signals the sender
+}
+
+func newPeerQueue(r *Replicator, peer string) *peerQueue {
+	q := &peerQueue{
+		peer:    peer,
+		r:       r,
+		pending: make(map[queueKey]*queuedWrite),
+		space:   make(chan struct{}),
+		wake:    make(chan struct{}, 1),
+	}
+	go q.run()
+	return q
+}
+
+// enqueue adds a write, replacing a pending write to the same key unless
+// that one is newer. When the queue is full, by keys or by bytes, it waits
+// for room until ctx is done, wait expires or the replicator shuts down,
+// and reports whether the write was queued.
+//
+// Replacing a pending tombstone with a counter recreated after it is safe:
+// the counter starts a newer epoch (see store.Counter), which replaces the
+// deleted incarnation on the peer just as the tombstone would have.
+func (q *peerQueue) enqueue(ctx context.Context, wait time.Duration, namespace string, wr Write, w *waiter) bool {
+	k := queueKey{namespace: namespace, key: wr.Key}
+	var timeout <-chan time.Time
+
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return false
+		}
+
+		cfg := q.r.queueConfig()
+		size := writeSize(k, wr.Entry)
+
+		if queued, ok := q.pending[k]; ok {
+			newer := wr.Entry.Timestamp > queued.entry.Timestamp
+			// Replacing a write takes no key slot, only the bytes it grows by.
+			if grow := size - queued.size; !newer || grow <= 0 || q.bytes+grow <= cfg.MaxBytes {
+				if newer {
+					q.bytes += grow
+					queued.entry, queued.size = wr.Entry, size
+				}
+				queued.waiters = append(queued.waiters, w)
+				q.mu.Unlock()
+				q.r.metrics.Inc(metrics.ReplicationCoalescedTotal)
+				return true
+			}
+		} else if len(q.order) < cfg.Size && (len(q.order) == 0 || q.bytes+size <= cfg.MaxBytes) {
+			queued := &queuedWrite{
+				queueKey: k,
+				entry:    wr.Entry,
+				size:     size,
+				waiters:  []*waiter{w},
+			}
+			q.pending[k] = queued
+			q.order = append(q.order, queued)
+			q.bytes += queued.size
+			q.mu.Unlock()
+
+			q.addDepth(1)
+			q.signal()
+			return true
+		}
+		space := q.space
+		q.mu.Unlock()
+
+		// Backpressure: the peer is slower than the writers.
+		if timeout == nil {
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case <-space:
+		case <-timeout:
+			return false
+		case <-ctx.Done():
+			return false
+		case <-q.r.done.Done():
+			return false
+		}
+	}
+}
+
+func writeSize(k queueKey, entry store.Entry) int64 {
+	return int64(len(k.namespace)+len(k.key)+len(entry.Value)) + writeOverhead
+}
+
+func (q *peerQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *peerQueue) addDepth(n int) {
+	q.r.metrics.Add(metrics.ReplicationQueueDepth, int64(n))
+	q.r.metrics.Add(metrics.Labeled(metrics.ReplicationQueueDepth, "peer", q.peer), int64(n))
+}
+
+// reconfigure wakes the sender and the writers waiting for room, so they
+// apply a new QueueConfig.
+func (q *peerQueue) reconfigure() {
+	q.mu.Lock()
+	close(q.space)
+	q.space = make(chan struct{})
+	q.mu.Unlock()
+
+	q.signal()
+}
+
+// close fails the writes still queued and stops the sender once the batch
+// in flight, if any, is done.
+func (q *peerQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	left := q.takeLocked(len(q.order), -1)
+	q.mu.Unlock()
+
+	complete(left, false)
+	q.signal()
+}
+
+// run sends batches until the queue is closed.
+func (q *peerQueue) run() {
+	for {
+		batch, ok := q.next()
+		if !ok {
+			return
+		}
+		q.send(batch)
+	}
+}
+
+// next waits for writes, lingers for the batch to fill and takes it.
+// It returns false once the queue is closed.
+func (q *peerQueue) next() ([]*queuedWrite, bool) {
+	var linger *time.Timer
+	lingered := false
+	defer func() {
+		if linger != nil {
+			linger.Stop()
+		}
+	}()
+
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return nil, false
+		}
+
+		cfg := q.r.queueConfig()
+		if n := len(q.order); n > 0 {
+			full := n >= cfg.MaxBatch || q.bytes >= cfg.MaxBatchBytes
+			if full || lingered || cfg.Linger <= 0 {
+				batch := q.takeLocked(cfg.MaxBatch, cfg.MaxBatchBytes)
+				q.mu.Unlock()
+				return batch, true
+			}
+			if linger == nil {
+				linger = time.NewTimer(cfg.Linger)
+			}
+		}
+		q.mu.Unlock()
+
+		var fired <-chan time.Time
+		if linger != nil {
+			fired = linger.C
+		}
+		select {
+		case <-q.wake:
+		case <-fired:
+			lingered = true
+		}
+	}
+}
+
+// takeLocked removes up to n writes and maxBytes (negative means any)
+// from the front of the queue; the first write is always taken.
+func (q *peerQueue) takeLocked(n int, maxBytes int64) []*queuedWrite {
+	var size int64
+	count := 0
+	for count < n && count < len(q.order) {
+		next := q.order[count].size
+		if count > 0 && maxBytes >= 0 && size+next > maxBytes {
+			break
+		}
+		size += next
+		count++
+	}
+	if count == 0 {
+		return nil
+	}
+
+	batch := make([]*queuedWrite, count)
+	copy(batch, q.order)
+	clear(q.order[:count])
+	q.order = q.order[count:]
+	for _, queued := range batch {
+		delete(q.pending, queued.queueKey)
+	}
+	q.bytes -= size
+
+	// Wake the writers waiting for room.
+	close(q.space)
+	q.space = make(chan struct{})
+
+	q.addDepth(-count)
+	return batch
+}
+
+// send replicates a batch, one request per namespace, and reports the
+// outcome to the waiters. Writes that failed because the peer became
+// unhealthy are kept as hints.
+func (q *peerQueue) send(batch []*queuedWrite) {
+	q.r.metrics.Inc(metrics.ReplicationBatchesTotal)
+	q.r.metrics.Add(metrics.ReplicationBatchedWritesTotal, int64(len(batch)))
+
+	groups := make(map[string][]*queuedWrite)
+	var namespaces []string
+	for _, queued := range batch {
+		if _, ok := groups[queued.namespace]; !ok {
+			namespaces = append(namespaces, queued.namespace)
+		}
+		groups[queued.namespace] = append(groups[queued.namespace], queued)
+	}
+
+	for _, namespace := range namespaces {
+		group := groups[namespace]
+		writes := make([]Write, len(group))
+		for i, queued := range group {
+			writes[i] = Write{Key: queued.key, Entry: queued.entry}
+		}
+
+		ok := q.r.sendWrites(q.peer, namespace, writes) == nil
+		if !ok && !q.r.peers.IsHealthy(q.peer) {
+			// The peer went down with the writes in flight.
+			q.r.hints.add(q.peer, namespace, writes)
+		}
+		complete(group, ok)
+	}
+}
+
+// complete reports the outcome of writes to their waiters.
+func complete(writes []*queuedWrite, ok bool) {
+	for _, queued := range writes {
+		for _, w := range queued.waiters {
+			w.done(ok)
+		}
+	}
+}
@@ -0,0 +1,371 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"distributed-cache/internal/logs"
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/peers"
+	"distributed-cache/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchPeer records the writes of every replication request as
+// "key=value". A blocking peer holds requests until gate is closed; a
+// failing one answers 500.
+type batchPeer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests [][]string
+	failing  atomic.Bool
+
+	started chan struct{}
+	gate    chan struct{}
+}
+
+func newBatchPeer(t *testing.T, blocking bool) *batchPeer {
+	p := &batchPeer{started: make(chan struct{}, 100)}
+	if blocking {
+		p.gate = make(chan struct{})
+	}
+
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p.failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		var writes []string
+		switch r.URL.Path {
+		case replicatePath:
+			var payload Payload
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			writes = append(writes, payload.Key+"="+string(payload.Entry.Value))
+		case replicateBatchPath:
+			var payload BatchPayload
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			for _, wr := range payload.Writes {
+				writes = append(writes, wr.Key+"="+string(wr.Entry.Value))
+			}
+		}
+
+		p.started <- struct{}{}
+		if p.gate != nil {
+			<-p.gate
+		}
+
+		p.mu.Lock()
+		p.requests = append(p.requests, writes)
+		p.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *batchPeer) received() [][]string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([][]string(nil), p.requests...)
+}
+
+// queueReplicator returns a replicator with one peer and the given queue settings.
+func queueReplicator(t *testing.T, peer string, qcfg QueueConfig) (*Replicator, *metrics.Registry) {
+	cfg := peers.DefaultPeerConfig()
+	cfg.Timeout.ReplicationTimeout = 2 * time.Second
+	cfg.Retry.MaxRetries = 0
+
+	reg := metrics.NewRegistry()
+	pm := peers.NewPeerManager(cfg, reg)
+	pm.AddPeer(peer)
+
+	replicator := NewReplicator("node-A", pm, cfg, logs.NewLogger(10, logs.DEBUG), reg)
+	replicator.UpdateQueueConfig(qcfg)
+	t.Cleanup(replicator.Close)
+	return replicator, reg
+}
+
+func write(value string, ts int64) store.Entry {
+	return store.Entry{Value: []byte(value), Timestamp: ts}
+}
+
+func TestQueue_CoalescesWritesToTheSameKey(t *testing.T) {
+	peer := newBatchPeer(t, true)
+	replicator, reg := queueReplicator(t, peer.URL, DefaultQueueConfig())
+	ctx := context.Background()
+
+	first := replicator.Replicate(ctx, "", "k", write("v1", 1))
+	<-peer.started // v1 is in flight; the rest queue up behind it
+
+	results := []*Result{
+		replicator.Replicate(ctx, "", "k", write("v3", 3)),
+		replicator.Replicate(ctx, "", "k", write("v2", 2)), // older, loses to v3
+		replicator.Replicate(ctx, "", "other", write("x", 1)),
+		replicator.Replicate(ctx, "orders", "k", write("ns", 1)), // another namespace
+	}
+	close(peer.gate)
+
+	assert.Equal(t, 1, first.Wait(ctx, 1))
+	for _, res := range results {
+		assert.Equal(t, 1, res.Wait(ctx, 1), "coalesced writes are acknowledged with the newest")
+	}
+
+	assert.Equal(t, [][]string{{"k=v1"}, {"k=v3", "other=x"}, {"k=ns"}}, peer.received())
+
+	snap := reg.Snapshot()
+	assert.Equal(t, int64(1), snap[string(metrics.ReplicationCoalescedTotal)])
+	assert.Equal(t, int64(2), snap[string(metrics.ReplicationBatchesTotal)])
+	assert.Equal(t, int64(4), snap[string(metrics.ReplicationBatchedWritesTotal)])
+	assert.Equal(t, int64(0), snap[string(metrics.ReplicationQueueDepth)])
+}
+
+func TestQueue_BatchLimits(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("count", func(t *testing.T) {
+		peer := newBatchPeer(t, false)
+		replicator, _ := queueReplicator(t, peer.URL, QueueConfig{
+			Size: 100, MaxBytes: 1 << 20, MaxBatch: 2, MaxBatchBytes: 1 << 20, Linger: time.Hour,
+		})
+
+		// A full batch does not wait for the linger time.
+		res := replicator.ReplicateBatch(ctx, "", []Write{
+			{Key: "a", Entry: write("1", 1)},
+			{Key: "b", Entry: write("2", 1)},
+			{Key: "c", Entry: write("3", 1)},
+			{Key: "d", Entry: write("4", 1)},
+		})
+		assert.Equal(t, 1, res.Wait(ctx, 1))
+		assert.Equal(t, [][]string{{"a=1", "b=2"}, {"c=3", "d=4"}}, peer.received())
+	})
+
+	t.Run("bytes", func(t *testing.T) {
+		peer := newBatchPeer(t, false)
+		replicator, _ := queueReplicator(t, peer.URL, QueueConfig{
+			Size: 100, MaxBytes: 1 << 20, MaxBatch: 100, MaxBatchBytes: 2*writeOverhead + 10,
+		})
+		big := string(make([]byte, 100))
+
+		res := replicator.ReplicateBatch(ctx, "", []Write{
+			{Key: "a", Entry: write("1", 1)},
+			{Key: "b", Entry: write("2", 1)},
+			{Key: "c", Entry: write(big, 1)},
+		})
+		assert.Equal(t, 1, res.Wait(ctx, 1))
+
+		received := peer.received()
+		require.Len(t, received, 2, "a write larger than the limit is sent alone")
+		assert.Equal(t, []string{"a=1", "b=2"}, received[0])
+		assert.Len(t, received[1], 1)
+	})
+
+	t.Run("linger", func(t *testing.T) {
+		peer := newBatchPeer(t, false)
+		replicator, _ := queueReplicator(t, peer.URL, QueueConfig{
+			Size: 100, MaxBytes: 1 << 20, MaxBatch: 100, MaxBatchBytes: 1 << 20, Linger: 50 * time.Millisecond,
+		})
+
+		start := time.Now()
+		a := replicator.Replicate(ctx, "", "a", write("1", 1))
+		b := replicator.Replicate(ctx, "", "b", write("2", 1))
+		assert.Equal(t, 1, a.Wait(ctx, 1))
+		assert.Equal(t, 1, b.Wait(ctx, 1))
+
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+		assert.Equal(t, [][]string{{"a=1", "b=2"}}, peer.received())
+	})
+}
+
+func TestQueue_Backpressure(t *testing.T) {
+	peer := newBatchPeer(t, true)
+	replicator, reg := queueReplicator(t, peer.URL, QueueConfig{
+		Size: 1, MaxBytes: 1 << 20, MaxBatch: 10, MaxBatchBytes: 1 << 20,
+	})
+	ctx := context.Background()
+
+	inflight := replicator.Replicate(ctx, "", "a", write("1", 1))
+	<-peer.started
+
+	queued := replicator.Replicate(ctx, "", "b", write("2", 1))
+	snap := reg.Snapshot()
+	assert.Equal(t, int64(1), snap[string(metrics.ReplicationQueueDepth)])
+	assert.Equal(t, int64(1), snap[string(metrics.Labeled(metrics.ReplicationQueueDepth, "peer", peer.URL))])
+
+	// The queue is full: the writer waits for room until its deadline.
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	dropped := replicator.Replicate(short, "", "c", write("3", 1))
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.Equal(t, 0, dropped.Wait(ctx, 1))
+	assert.Equal(t, int64(1), reg.Snapshot()[string(metrics.ReplicationQueueDroppedTotal)])
+
+	// A write to a queued key still fits.
+	coalesced := replicator.Replicate(ctx, "", "b", write("4", 2))
+
+	// Room frees up once the sender takes the next batch.
+	waiting := make(chan *Result)
+	go func() { waiting <- replicator.Replicate(ctx, "", "d", write("5", 1)) }()
+	close(peer.gate)
+
+	for _, res := range []*Result{inflight, queued, coalesced, <-waiting} {
+		assert.Equal(t, 1, res.Wait(ctx, 1))
+	}
+	assert.Equal(t, [][]string{{"a=1"}, {"b=4"}, {"d=5"}}, peer.received())
+}
+
+func TestQueue_BackpressureOnBytes(t *testing.T) {
+	peer := newBatchPeer(t, true)
+	replicator, reg := queueReplicator(t, peer.URL, QueueConfig{
+		Size: 100, MaxBytes: 2*writeOverhead + 10, MaxBatch: 10, MaxBatchBytes: 1 << 20,
+	})
+	ctx := context.Background()
+
+	inflight := replicator.Replicate(ctx, "", "a", write("1", 1))
+	<-peer.started
+
+	// Two small writes fit; a third would pass the byte bound.
+	queued := []*Result{
+		replicator.Replicate(ctx, "", "b", write("2", 1)),
+		replicator.Replicate(ctx, "", "c", write("3", 1)),
+	}
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	dropped := replicator.Replicate(short, "", "d", write("4", 1))
+	assert.Equal(t, 0, dropped.Wait(ctx, 1))
+
+	// Growing a queued write past the bound waits as well.
+	big := string(make([]byte, 100))
+	short, cancel = context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	grown := replicator.Replicate(short, "", "b", write(big, 2))
+	assert.Equal(t, 0, grown.Wait(ctx, 1))
+	assert.Equal(t, int64(2), reg.Snapshot()[string(metrics.ReplicationQueueDroppedTotal)])
+
+	// Once the queue drains, a write larger than the bound is queued on its own.
+	close(peer.gate)
+	for _, res := range append(queued, inflight) {
+		assert.Equal(t, 1, res.Wait(ctx, 1))
+	}
+	alone := replicator.Replicate(ctx, "", "e", write(big, 1))
+	assert.Equal(t, 1, alone.Wait(ctx, 1))
+
+	received := peer.received()
+	require.Len(t, received, 3)
+	assert.Equal(t, [][]string{{"a=1"}, {"b=2", "c=3"}}, received[:2])
+	assert.Equal(t, []string{"e=" + big}, received[2])
+}
+
+func TestQueue_CancelledContextIsNotQueued(t *testing.T) {
+	peer := newBatchPeer(t, false)
+	replicator, reg := queueReplicator(t, peer.URL, DefaultQueueConfig())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	res := replicator.Replicate(ctx, "", "k", write("v", 1))
+	assert.Equal(t, 0, res.Wait(context.Background(), 1))
+	assert.Empty(t, peer.received())
+	assert.Equal(t, int64(1), reg.Snapshot()[string(metrics.ReplicationQueueDroppedTotal)])
+}
+
+func TestQueue_CloseFailsQueuedWrites(t *testing.T) {
+	peer := newBatchPeer(t, true)
+	replicator, _ := queueReplicator(t, peer.URL, DefaultQueueConfig())
+	ctx := context.Background()
+
+	inflight := replicator.Replicate(ctx, "", "a", write("1", 1))
+	<-peer.started
+	queued := replicator.Replicate(ctx, "", "b", write("2", 1))
+
+	replicator.Close()
+	assert.Equal(t, 0, queued.Wait(ctx, 1))
+
+	close(peer.gate)
+	assert.Equal(t, 1, inflight.Wait(ctx, 1), "the batch in flight completes")
+
+	res := replicator.Replicate(ctx, "", "c", write("3", 1))
+	assert.Equal(t, 0, res.Wait(ctx, 1), "writes after Close are dropped")
+}
+
+func TestQueue_RemovedPeerQueueIsClosed(t *testing.T) {
+	peer := newBatchPeer(t, false)
+	replicator, _ := queueReplicator(t, peer.URL, DefaultQueueConfig())
+	ctx := context.Background()
+
+	assert.Equal(t, 1, replicator.Replicate(ctx, "", "a", write("1", 1)).Wait(ctx, 1))
+	assert.Len(t, replicator.queues, 1)
+
+	replicator.peers.SetPeers(nil)
+
+	replicator.qmu.Lock()
+	assert.Empty(t, replicator.queues)
+	replicator.qmu.Unlock()
+
+	assert.Equal(t, 0, replicator.Replicate(ctx, "", "a", write("2", 2)).Peers)
+}
+
+func TestQueue_CounterRecreatedOverQueuedDelete(t *testing.T) {
+	// The peer applies what it receives to its own store.
+	peerStore := store.NewStore(metrics.NewRegistry())
+	gate := make(chan struct{})
+	started := make(chan struct{}, 10)
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var writes []Write
+		switch r.URL.Path {
+		case replicatePath:
+			var payload Payload
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			writes = append(writes, Write{Key: payload.Key, Entry: payload.Entry})
+		case replicateBatchPath:
+			var payload BatchPayload
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			writes = payload.Writes
+		}
+
+		started <- struct{}{}
+		<-gate
+		for _, wr := range writes {
+			peerStore.Set(wr.Key, wr.Entry)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(peer.Close)
+
+	replicator, reg := queueReplicator(t, peer.URL, DefaultQueueConfig())
+	ctx := context.Background()
+	local := store.NewStore(metrics.NewRegistry())
+
+	// incr 5 is in flight while the delete and the recreating incr 1 queue up.
+	counted, err := local.Incr("quota", "node-A", 5, 0)
+	require.NoError(t, err)
+	first := replicator.Replicate(ctx, "", "quota", counted)
+	<-started
+
+	require.True(t, local.Delete("quota"))
+	deleted := replicator.Replicate(ctx, "", "quota", local.Entries()["quota"])
+	recreated, err := local.Incr("quota", "node-A", 1, 0)
+	require.NoError(t, err)
+	last := replicator.Replicate(ctx, "", "quota", recreated)
+	close(gate)
+
+	for _, res := range []*Result{first, deleted, last} {
+		assert.Equal(t, 1, res.Wait(ctx, 1))
+	}
+	assert.Equal(t, int64(1), reg.Snapshot()[string(metrics.ReplicationCoalescedTotal)], "the tombstone was replaced")
+
+	got, ok := peerStore.Get("quota")
+	require.True(t, ok)
+	assert.Equal(t, int64(1), got.Counter.Value())
+}
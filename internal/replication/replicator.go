@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"sync"
+	"time"
 
 	"distributed-cache/internal/logs"
 	"distributed-cache/internal/metrics"
@@ -13,15 +16,43 @@ import (
 )
 
 // Replicator handles reliable, health-aware replication of writes.
+//
+// Writes are queued per peer and sent in batches by one sender per peer;
+// see QueueConfig. Writes for unhealthy peers are kept as hints and
+// replayed in order once the peer recovers; see HintConfig. Peers are
+// reached over HTTP/JSON unless SetBinaryPeers maps them to the address of
+// their binary replication Server.
 type Replicator struct {
 	nodeID string
 
-	peers  *peers.PeerManager
-	config peers.PeerConfig
+	peers *peers.PeerManager
+
+	mu       sync.RWMutex
+	config   peers.PeerConfig
+	client   *http.Client
+	queueCfg QueueConfig
+
+	// binary holds the peers reached over the binary protocol, by peer URL.
+	binary map[string]*binaryPeer
+
+	// queues holds the outbound queue of each peer written to.
+	qmu    sync.Mutex
+	queues map[string]*peerQueue
+	closed bool
+
+	hints *hintStore
+
+	// hintRetry is how often Start resumes stalled hint replays.
+	hintRetry time.Duration
 
 	logger  *logs.Logger
-	client  *http.Client
 	metrics *metrics.Registry
+
+	// inflight tracks send goroutines so shutdown can drain them.
+	// done aborts whatever is still running once the drain deadline passes.
+	inflight sync.WaitGroup
+	done     context.Context
+	abort    context.CancelFunc
 }
 
 // NewReplicator creates a replication engine integrated with
@@ -33,7 +64,9 @@ func NewReplicator(
 	logger *logs.Logger,
 	metricsRegistry *metrics.Registry,
 ) *Replicator {
-	return &Replicator{
+	done, abort := context.WithCancel(context.Background())
+
+	r := &Replicator{
 		nodeID:  nodeID,
 		peers:   peerManager,
 		config:  cfg,
@@ -42,67 +75,486 @@ func NewReplicator(
 		client: &http.Client{
 			Timeout: cfg.Timeout.ReplicationTimeout,
 		},
+		queueCfg: DefaultQueueConfig(),
+		binary:   make(map[string]*binaryPeer),
+		queues:   make(map[string]*peerQueue),
+		hints:     newHintStore(DefaultHintConfig(), logger, metricsRegistry),
+		hintRetry: hintRetryInterval,
+		done:      done,
+		abort:     abort,
+	}
+	peerManager.OnRecover(r.replayHints)
+	peerManager.OnRemove(r.removePeer)
+	return r
+}
+
+// hintRetryInterval is how often hints left by a replay that stopped on a
+// failure the peer survived are retried.
+const hintRetryInterval = 5 * time.Second
+
+// Start resumes the hint replays of healthy peers periodically until the
+// context is cancelled. Replays also start whenever a peer recovers.
+func (r *Replicator) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.hintRetry)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, peer := range r.peers.GetPeers() {
+				if r.peers.IsHealthy(peer) {
+					r.replayHints(peer)
+				}
+			}
+		case <-ctx.Done():
+			r.logger.Debug("hint replay worker stopped")
+			return
+		}
+	}
+}
+
+// Drain waits for in-flight replication to finish.
+//
+// If ctx expires first, the remaining sends are cancelled, Drain waits
+// for them to unwind and returns ctx.Err().
+// Callers must stop producing writes (e.g. shut the HTTP server down)
+// before draining.
+func (r *Replicator) Drain(ctx context.Context) error {
+	finished := make(chan struct{})
+	go func() {
+		r.inflight.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		r.logger.Warn("replication drain deadline exceeded, cancelling in-flight sends")
+		r.abort()
+		<-finished
+		return ctx.Err()
+	}
+}
+
+// UpdateConfig swaps the retry and timeout policies.
+// Sends already in flight finish with the policies they started with.
+func (r *Replicator) UpdateConfig(cfg peers.PeerConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.config = cfg
+	r.client = &http.Client{
+		Timeout: cfg.Timeout.ReplicationTimeout,
+	}
+}
+
+// SetBinaryPeers selects the binary transport for the peers in addrs,
+// which maps peer base URLs to the TCP address of their replication
+// server. Other peers are reached over HTTP/JSON.
+// Connections of peers that are dropped or moved are closed.
+func (r *Replicator) SetBinaryPeers(addrs map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for peer, bp := range r.binary {
+		if addrs[peer] != bp.addr {
+			bp.close()
+			delete(r.binary, peer)
+		}
+	}
+	for peer, addr := range addrs {
+		if _, ok := r.binary[peer]; !ok {
+			r.binary[peer] = newBinaryPeer(addr)
+		}
+	}
+}
+
+// UpdateQueueConfig swaps the queue and batching settings.
+// Batches already taken finish with the settings they started with.
+func (r *Replicator) UpdateQueueConfig(cfg QueueConfig) {
+	r.mu.Lock()
+	r.queueCfg = cfg
+	r.mu.Unlock()
+
+	r.qmu.Lock()
+	defer r.qmu.Unlock()
+
+	for _, q := range r.queues {
+		q.reconfigure()
+	}
+}
+
+// UpdateHintConfig swaps the hint caps; hints already kept are trimmed to
+// the new ones.
+func (r *Replicator) UpdateHintConfig(cfg HintConfig) {
+	r.hints.update(cfg)
+}
+
+// PersistHints keeps hints in files under dir, so they survive a restart,
+// and loads the hints left there by a previous run. Call it at startup,
+// before writes are replicated.
+func (r *Replicator) PersistHints(dir string) error {
+	if err := r.hints.persist(dir); err != nil {
+		return err
+	}
+	for _, peer := range r.peers.GetPeers() {
+		if r.peers.IsHealthy(peer) {
+			r.replayHints(peer)
+		}
+	}
+	return nil
+}
+
+// Close stops the peer queues and closes the binary transport connections
+// and hint files. Call it after Drain; later writes are not replicated.
+func (r *Replicator) Close() {
+	r.qmu.Lock()
+	r.closed = true
+	for peer, q := range r.queues {
+		q.close()
+		delete(r.queues, peer)
+	}
+	r.qmu.Unlock()
+
+	r.hints.close()
+	r.SetBinaryPeers(nil)
+}
+
+// binaryPeer returns the binary transport of peer, or nil for HTTP/JSON.
+func (r *Replicator) binaryPeer(peer string) *binaryPeer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.binary[peer]
+}
+
+// queueConfig returns the current queue settings.
+func (r *Replicator) queueConfig() QueueConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.queueCfg
+}
+
+// settings returns a consistent view of the current policies.
+func (r *Replicator) settings() (peers.PeerConfig, *http.Client) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.config, r.client
+}
+
+// Result reports the outcome of a single replicated write.
+//
+// Each targeted peer reports exactly once, so a Result is meant to be
+// waited on by a single caller.
+type Result struct {
+	// Peers is the number of healthy peers the write was sent to.
+	Peers int
+
+	acks chan bool
+}
+
+// Wait blocks until at least n peers acknowledged the write, every
+// targeted peer has finished, or ctx is done.
+// It returns the number of acknowledgements observed.
+func (res *Result) Wait(ctx context.Context, n int) int {
+	acked, finished := 0, 0
+
+	for acked < n && finished < res.Peers {
+		select {
+		case ok := <-res.acks:
+			finished++
+			if ok {
+				acked++
+			}
+		case <-ctx.Done():
+			return acked
+		}
 	}
+	return acked
 }
 
+// Peer endpoints receiving replicated writes.
+const (
+	replicatePath      = "/internal/replicate"
+	replicateBatchPath = "/internal/replicate/batch"
+	publishPath        = "/internal/pubsub"
+)
+
 // Replicate sends a cache write to all healthy peers asynchronously.
+// Deletes are replicated by passing a tombstone entry.
 // Replication is retry-aware, cancellable, and updates peer health.
+// Unhealthy peers get the write as a hint instead.
+//
+// The write joins the outbound queue of each peer; a write to the same key
+// still queued is coalesced with it, keeping the newer timestamp. When a
+// queue is full, Replicate waits for room until ctx is done or the
+// replication timeout passes, then gives up on that peer.
 func (r *Replicator) Replicate(
 	ctx context.Context,
+	namespace string,
 	key string,
 	entry store.Entry,
-) {
-	payload := Payload{
-		Key:            key,
-		Entry:          entry,
+) *Result {
+	return r.enqueue(ctx, namespace, []Write{{Key: key, Entry: entry}})
+}
+
+// ReplicateBatch sends many writes to all healthy peers through their
+// queues, like Replicate. A peer acknowledges the batch once it has applied
+// every write; large batches may reach it in several requests.
+func (r *Replicator) ReplicateBatch(ctx context.Context, namespace string, writes []Write) *Result {
+	return r.enqueue(ctx, namespace, writes)
+}
+
+// Publish forwards a pub/sub message to all healthy peers, which deliver
+// it to their own subscribers. Forwarding follows the retry policy, so a
+// peer that timed out after delivering may deliver the message twice.
+// Messages bypass the write queues: they are neither batched nor coalesced,
+// nor hinted for unhealthy peers.
+func (r *Replicator) Publish(ctx context.Context, channel string, message []byte) *Result {
+	return r.fanOut(ctx, publishPath, PublishPayload{
+		Channel:        channel,
+		Message:        message,
 		OriginalNodeID: r.nodeID,
-	}
+	})
+}
 
-	for _, peer := range r.peers.GetPeers() {
+// targets returns the healthy and the unhealthy peers.
+func (r *Replicator) targets() (healthy, down []string) {
+	all := r.peers.GetPeers()
+	healthy = make([]string, 0, len(all))
+	for _, peer := range all {
 
 		// Skip unhealthy peers
 		if !r.peers.IsHealthy(peer) {
 			r.logger.Debug("skipping unhealthy peer " + peer)
+			down = append(down, peer)
 			continue
 		}
+		healthy = append(healthy, peer)
+	}
+	return healthy, down
+}
 
+// enqueue queues writes for every healthy peer; each peer reports on the
+// Result once all its writes are sent, or given up. Unhealthy peers get
+// hints, and do not report.
+func (r *Replicator) enqueue(ctx context.Context, namespace string, writes []Write) *Result {
+	targets, down := r.targets()
+	for _, peer := range down {
+		r.hints.add(peer, namespace, writes)
+	}
+
+	res := &Result{
+		Peers: len(targets),
+		acks:  make(chan bool, len(targets)),
+	}
+	cfg, _ := r.settings()
+
+	for _, peer := range targets {
+		r.metrics.Inc(metrics.ReplicationAttemptsTotal)
+
+		if len(writes) == 0 {
+			res.acks <- true
+			continue
+		}
+
+		r.inflight.Add(1)
+		w := newWaiter(len(writes), func(ok bool) {
+			res.acks <- ok
+			r.inflight.Done()
+		})
+
+		q := r.queue(peer)
+		for _, wr := range writes {
+			if q == nil || ctx.Err() != nil || !q.enqueue(ctx, cfg.Timeout.ReplicationTimeout, namespace, wr, w) {
+				r.metrics.Inc(metrics.ReplicationQueueDroppedTotal)
+				w.done(false)
+			}
+		}
+	}
+
+	return res
+}
+
+// queue returns the outbound queue of peer, or nil after Close.
+func (r *Replicator) queue(peer string) *peerQueue {
+	r.qmu.Lock()
+	defer r.qmu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	q, ok := r.queues[peer]
+	if !ok {
+		q = newPeerQueue(r, peer)
+		r.queues[peer] = q
+	}
+	return q
+}
+
+// removePeer closes the queue and drops the hints of a peer removed from
+// the peer set.
+func (r *Replicator) removePeer(peer string) {
+	r.qmu.Lock()
+	if q, ok := r.queues[peer]; ok {
+		q.close()
+		delete(r.queues, peer)
+	}
+	r.qmu.Unlock()
+
+	r.hints.remove(peer)
+}
+
+// replayHints sends the hints kept for peer in the background, oldest
+// first. The replay stops when a send fails; the hints left wait for the
+// next recovery of the peer, or Start's next retry.
+func (r *Replicator) replayHints(peer string) {
+	if !r.hints.startReplay(peer) {
+		return
+	}
+	go func() {
+		if !r.replay(peer) {
+			r.hints.stopReplay(peer)
+		}
+	}()
+}
+
+// replay sends hints while the peer is healthy, consecutive hints of one
+// namespace per request. It reports whether every hint was replayed.
+func (r *Replicator) replay(peer string) bool {
+	for r.peers.IsHealthy(peer) {
+		batch := r.hints.next(peer, r.queueConfig().MaxBatch)
+		if batch == nil {
+			return true
+		}
+
+		for len(batch) > 0 {
+			n := 1
+			for n < len(batch) && batch[n].namespace == batch[0].namespace {
+				n++
+			}
+			writes := make([]Write, n)
+			for i, h := range batch[:n] {
+				writes[i] = h.write
+			}
+			// Hints the peer refuses for lacking their namespace would be
+			// refused again, so they count as replayed.
+			if err := r.sendWrites(peer, batch[0].namespace, writes); err != nil && !errors.Is(err, ErrUnknownNamespace) {
+				return false
+			}
+			r.hints.replayed(peer, batch[n-1].seq)
+			batch = batch[n:]
+		}
+	}
+	return false
+}
+
+// sendWrites replicates writes of one namespace to peer; a lone write
+// travels as a plain replication payload. It returns sendWithRetry's error.
+func (r *Replicator) sendWrites(peer, namespace string, writes []Write) error {
+	if len(writes) == 1 {
+		return r.sendWithRetry(context.Background(), peer, replicatePath, Payload{
+			Namespace:      namespace,
+			Key:            writes[0].Key,
+			Entry:          writes[0].Entry,
+			OriginalNodeID: r.nodeID,
+		})
+	}
+	return r.sendWithRetry(context.Background(), peer, replicateBatchPath, BatchPayload{
+		Namespace:      namespace,
+		Writes:         writes,
+		OriginalNodeID: r.nodeID,
+	})
+}
+
+// fanOut posts payload to path on every healthy peer in the background.
+func (r *Replicator) fanOut(ctx context.Context, path string, payload any) *Result {
+	targets, _ := r.targets()
+	res := &Result{
+		Peers: len(targets),
+		acks:  make(chan bool, len(targets)),
+	}
+
+	for _, peer := range targets {
 		r.metrics.Inc(metrics.ReplicationAttemptsTotal)
 
 		peer := peer // capture loop variable
-		go r.sendWithRetry(ctx, peer, payload)
+		r.inflight.Add(1)
+		go func() {
+			defer r.inflight.Done()
+			res.acks <- r.sendWithRetry(ctx, peer, path, payload) == nil
+		}()
 	}
+
+	return res
 }
 
 // sendWithRetry performs replication using the Retry engine
 // and updates peer health based on the final outcome.
+// It returns nil once the peer acknowledged the write.
+//
+// A peer that refuses the write for lacking its namespace is up, so it
+// stays healthy; the refusal is counted and returned without a retry.
 func (r *Replicator) sendWithRetry(
 	ctx context.Context,
 	peer string,
-	payload Payload,
-) {
-	err := peers.Retry(ctx, r.config.Retry, func() error {
+	path string,
+	payload any,
+) error {
+	cfg, client := r.settings()
+
+	// Stop early if the replicator is aborted during shutdown.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(r.done, cancel)
+	defer stop()
+
+	var refused error
+	err := peers.Retry(ctx, cfg.Retry, func() error {
 		r.metrics.Inc(metrics.ReplicationRetriesTotal)
-		return r.sendOnce(ctx, peer, payload)
+
+		// Looked up per attempt, so a transport change applies on retry.
+		var err error
+		if bp := r.binaryPeer(peer); bp != nil {
+			err = bp.send(ctx, cfg.Timeout.ReplicationTimeout, payload)
+		} else {
+			err = r.send(ctx, client, peer+path, payload)
+		}
+		if errors.Is(err, ErrUnknownNamespace) {
+			refused = err // final: retrying cannot create the namespace
+			return nil
+		}
+		return err
 	})
 
-	if err != nil {
+	switch {
+	case refused != nil:
+		r.metrics.Inc(metrics.ReplicationUnknownNamespaceTotal)
+		r.peers.MarkSuccess(peer)
+		r.logger.Warn("replication refused by peer " + peer + ": " + refused.Error())
+		return refused
+	case err != nil:
 		r.metrics.Inc(metrics.ReplicationFailureTotal)
 		r.peers.MarkFailure(peer)
 		r.logger.Warn("replication failed to peer " + peer)
-		return
+		return err
 	}
 
 	r.metrics.Inc(metrics.ReplicationSuccessTotal)
 	r.peers.MarkSuccess(peer)
 	r.logger.Debug("replication succeeded to peer " + peer)
+	return nil
 }
 
-// sendOnce performs a single HTTP replication attempt.
-// It returns an error so Retry() can decide what to do.
-func (r *Replicator) sendOnce(
+// send performs a single HTTP replication attempt with the given client.
+func (r *Replicator) send(
 	ctx context.Context,
-	peer string,
-	payload Payload,
+	client *http.Client,
+	url string,
+	payload any,
 ) error {
 	body, err := json.Marshal(payload)
 	if err != nil {
@@ -112,7 +564,7 @@ func (r *Replicator) sendOnce(
 	req, err := http.NewRequestWithContext(
 		ctx,
 		http.MethodPost,
-		peer+"/internal/replicate",
+		url,
 		bytes.NewBuffer(body),
 	)
 	if err != nil {
@@ -121,12 +573,23 @@ func (r *Replicator) sendOnce(
 
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := r.client.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
+	// 409 means the peer already holds a newer write (or the payload
+	// looped back to its origin). The peer is converged, so retrying
+	// would only repeat the same answer.
+	if resp.StatusCode == http.StatusConflict {
+		return nil
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrUnknownNamespace
+	}
+
 	if resp.StatusCode != http.StatusNoContent {
 		return http.ErrHandlerTimeout // treated as retryable
 	}
@@ -0,0 +1,145 @@
+package replication
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"distributed-cache/internal/logs"
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/peers"
+	"distributed-cache/internal/store"
+)
+
+// Benchmarks comparing the HTTP/JSON and binary transports. Both peers
+// decode the payload and apply it to a receiver that does nothing, so the
+// numbers measure the transport and encoding only:
+//
+//	go test -run=NONE -bench=. -benchmem ./internal/replication
+
+// discardReceiver accepts every payload.
+type discardReceiver struct{}
+
+func (discardReceiver) ApplyReplication(Payload) error           { return nil }
+func (discardReceiver) ApplyReplicationBatch(BatchPayload) error { return nil }
+func (discardReceiver) ApplyPublish(PublishPayload) error        { return nil }
+
+func benchPayload() Payload {
+	return Payload{
+		Namespace: "orders",
+		Key:       "order:123456",
+		Entry: store.Entry{
+			Value:       bytes.Repeat([]byte("x"), 256),
+			ContentType: "application/json",
+			ExpiresAt:   time.Now().Add(time.Hour),
+			Timestamp:   time.Now().UnixNano(),
+			Version:     42,
+		},
+		OriginalNodeID: "node-A",
+	}
+}
+
+// benchReplicator returns a replicator with one peer; binaryAddr, if set,
+// selects the binary transport.
+func benchReplicator(b *testing.B, peer, binaryAddr string) *Replicator {
+	cfg := peers.DefaultPeerConfig()
+	reg := metrics.NewRegistry()
+	pm := peers.NewPeerManager(cfg, reg)
+	pm.AddPeer(peer)
+
+	replicator := NewReplicator("node-A", pm, cfg, logs.NewLogger(10, logs.ERROR), reg)
+	if binaryAddr != "" {
+		replicator.SetBinaryPeers(map[string]string{peer: binaryAddr})
+	}
+	b.Cleanup(replicator.Close)
+	return replicator
+}
+
+// benchReplicate replicates one write per iteration and waits for the ack.
+func benchReplicate(b *testing.B, replicator *Replicator) {
+	p := benchPayload()
+	ctx := context.Background()
+
+	b.Run("serial", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if replicator.Replicate(ctx, p.Namespace, p.Key, p.Entry).Wait(ctx, 1) != 1 {
+				b.Fatal("write not acknowledged")
+			}
+		}
+	})
+
+	b.Run("parallel", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if replicator.Replicate(ctx, p.Namespace, p.Key, p.Entry).Wait(ctx, 1) != 1 {
+					b.Error("write not acknowledged")
+					return
+				}
+			}
+		})
+	})
+}
+
+func BenchmarkReplicate_HTTP(b *testing.B) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload Payload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_ = discardReceiver{}.ApplyReplication(payload)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	b.Cleanup(server.Close)
+
+	benchReplicate(b, benchReplicator(b, server.URL, ""))
+}
+
+func BenchmarkReplicate_Binary(b *testing.B) {
+	server := NewServer(discardReceiver{}, metrics.NewRegistry(), logs.NewLogger(10, logs.ERROR))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	go server.Serve(l)
+	b.Cleanup(func() { _ = server.Shutdown(context.Background()) })
+
+	benchReplicate(b, benchReplicator(b, "http://127.0.0.1:1", l.Addr().String()))
+}
+
+func BenchmarkEncode_JSON(b *testing.B) {
+	p := benchPayload()
+	b.ReportAllocs()
+	for b.Loop() {
+		body, err := json.Marshal(p)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var decoded Payload
+		if err := json.Unmarshal(body, &decoded); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncode_Binary(b *testing.B) {
+	p := benchPayload()
+	buf := make([]byte, 0, 512)
+	b.ReportAllocs()
+	for b.Loop() {
+		body, typ, err := appendPayload(buf[:0], p)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := decodePayload(typ, body); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
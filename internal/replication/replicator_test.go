@@ -2,6 +2,7 @@ package replication
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"sync/atomic"
@@ -34,8 +35,8 @@ func TestReplicator_HealthyPeer_ReplicationSucceeds(t *testing.T) {
 	logger := logs.NewLogger(10, logs.DEBUG)
 	replicator := NewReplicator("node-A", pm, cfg, logger, reg)
 
-	replicator.Replicate(context.Background(), "key", store.Entry{
-		Value:     "val",
+	replicator.Replicate(context.Background(), store.DefaultNamespace, "key", store.Entry{
+		Value:     []byte("val"),
 		Timestamp: 1,
 	})
 
@@ -50,6 +51,67 @@ func TestReplicator_HealthyPeer_ReplicationSucceeds(t *testing.T) {
 	assert.Equal(t, int64(1), snap[string(metrics.ReplicationSuccessTotal)])
 }
 
+func TestReplicator_ReplicateBatch_SendsOneRequest(t *testing.T) {
+	received := make(chan BatchPayload, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/internal/replicate/batch", r.URL.Path)
+
+		var payload BatchPayload
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received <- payload
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	cfg := peers.DefaultPeerConfig()
+	reg := metrics.NewRegistry()
+	pm := peers.NewPeerManager(cfg, reg)
+	pm.AddPeer(server.URL)
+
+	replicator := NewReplicator("node-A", pm, cfg, logs.NewLogger(10, logs.DEBUG), reg)
+
+	res := replicator.ReplicateBatch(context.Background(), "orders", []Write{
+		{Key: "a", Entry: store.Entry{Value: []byte("1"), Timestamp: 1}},
+		{Key: "b", Entry: store.Tombstone(2)},
+	})
+	assert.Equal(t, 1, res.Wait(context.Background(), 1))
+
+	payload := <-received
+	assert.Equal(t, "node-A", payload.OriginalNodeID)
+	assert.Equal(t, "orders", payload.Namespace)
+	assert.Len(t, payload.Writes, 2)
+	assert.True(t, payload.Writes[1].Entry.Deleted)
+	assert.Equal(t, int64(1), reg.Snapshot()[string(metrics.ReplicationAttemptsTotal)])
+}
+
+func TestReplicator_Publish_ForwardsMessage(t *testing.T) {
+	received := make(chan PublishPayload, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/internal/pubsub", r.URL.Path)
+
+		var payload PublishPayload
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received <- payload
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	cfg := peers.DefaultPeerConfig()
+	reg := metrics.NewRegistry()
+	pm := peers.NewPeerManager(cfg, reg)
+	pm.AddPeer(server.URL)
+
+	replicator := NewReplicator("node-A", pm, cfg, logs.NewLogger(10, logs.DEBUG), reg)
+
+	res := replicator.Publish(context.Background(), "news", []byte("hello"))
+	assert.Equal(t, 1, res.Wait(context.Background(), 1))
+
+	payload := <-received
+	assert.Equal(t, PublishPayload{Channel: "news", Message: []byte("hello"), OriginalNodeID: "node-A"}, payload)
+}
+
 func TestReplicator_UnhealthyPeer_IsSkipped(t *testing.T) {
 	var calls int32
 
@@ -70,8 +132,8 @@ func TestReplicator_UnhealthyPeer_IsSkipped(t *testing.T) {
 	logger := logs.NewLogger(10, logs.DEBUG)
 	replicator := NewReplicator("node-A", pm, cfg, logger, reg)
 
-	replicator.Replicate(context.Background(), "key", store.Entry{
-		Value:     "val",
+	replicator.Replicate(context.Background(), store.DefaultNamespace, "key", store.Entry{
+		Value:     []byte("val"),
 		Timestamp: 1,
 	})
 
@@ -107,8 +169,8 @@ func TestReplicator_RetryThenSuccess(t *testing.T) {
 	logger := logs.NewLogger(10, logs.DEBUG)
 	replicator := NewReplicator("node-A", pm, cfg, logger, reg)
 
-	replicator.Replicate(context.Background(), "key", store.Entry{
-		Value:     "val",
+	replicator.Replicate(context.Background(), store.DefaultNamespace, "key", store.Entry{
+		Value:     []byte("val"),
 		Timestamp: 1,
 	})
 
@@ -145,8 +207,8 @@ func TestReplicator_RetryExhaustion_MarksUnhealthy(t *testing.T) {
 	logger := logs.NewLogger(10, logs.DEBUG)
 	replicator := NewReplicator("node-A", pm, cfg, logger, reg)
 
-	replicator.Replicate(context.Background(), "key", store.Entry{
-		Value:     "val",
+	replicator.Replicate(context.Background(), store.DefaultNamespace, "key", store.Entry{
+		Value:     []byte("val"),
 		Timestamp: 1,
 	})
 
@@ -181,8 +243,8 @@ func TestReplicator_ContextCancelled_NoRetry(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 
-	replicator.Replicate(ctx, "key", store.Entry{
-		Value:     "val",
+	replicator.Replicate(ctx, store.DefaultNamespace, "key", store.Entry{
+		Value:     []byte("val"),
 		Timestamp: 1,
 	})
 
@@ -190,11 +252,14 @@ func TestReplicator_ContextCancelled_NoRetry(t *testing.T) {
 	assert.LessOrEqual(t, atomic.LoadInt32(&calls), int32(1))
 }
 
-func TestSendOnce_RequestCreationError(t *testing.T) {
+func TestSendWithRetry_RequestCreationError(t *testing.T) {
 	cfg := peers.DefaultPeerConfig()
+	cfg.Retry.MaxRetries = 0
 
+	const peer = "http://\n"
 	reg := metrics.NewRegistry()
 	pm := peers.NewPeerManager(cfg, reg)
+	pm.AddPeer(peer)
 
 	logger := logs.NewLogger(10, logs.DEBUG)
 	r := NewReplicator("node-A", pm, cfg, logger, reg)
@@ -202,11 +267,222 @@ func TestSendOnce_RequestCreationError(t *testing.T) {
 	payload := Payload{
 		Key: "key",
 		Entry: store.Entry{
-			Value: "value",
+			Value: []byte("value"),
 		},
 		OriginalNodeID: "node-A",
 	}
 
-	err := r.sendOnce(context.Background(), "http://\n", payload)
-	assert.Error(t, err)
+	assert.Error(t, r.sendWithRetry(context.Background(), peer, replicatePath, payload))
+	assert.Equal(t, int64(1), reg.Snapshot()[string(metrics.ReplicationFailureTotal)])
+}
+
+func TestSendWithRetry_ConflictIsNotRetried(t *testing.T) {
+	var calls int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusConflict)
+	}))
+	defer server.Close()
+
+	cfg := peers.DefaultPeerConfig()
+	cfg.Retry.BaseBackoff = 1 * time.Millisecond
+
+	reg := metrics.NewRegistry()
+	pm := peers.NewPeerManager(cfg, reg)
+	pm.AddPeer(server.URL)
+
+	logger := logs.NewLogger(10, logs.DEBUG)
+	replicator := NewReplicator("node-A", pm, cfg, logger, reg)
+
+	replicator.Replicate(context.Background(), store.DefaultNamespace, "key", store.Entry{
+		Value:     []byte("val"),
+		Timestamp: 1,
+	})
+
+	assert.Eventually(t, func() bool {
+		return reg.Snapshot()[string(metrics.ReplicationSuccessTotal)] == 1
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.True(t, pm.IsHealthy(server.URL))
+}
+
+func TestReplicator_Result_WaitForAcks(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ok.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	cfg := peers.DefaultPeerConfig()
+	cfg.Retry.MaxRetries = 1
+	cfg.Retry.BaseBackoff = 1 * time.Millisecond
+	cfg.Retry.JitterFn = func(d time.Duration) time.Duration { return 0 }
+
+	reg := metrics.NewRegistry()
+	pm := peers.NewPeerManager(cfg, reg)
+	pm.AddPeer(ok.URL)
+	pm.AddPeer(failing.URL)
+
+	logger := logs.NewLogger(10, logs.DEBUG)
+	replicator := NewReplicator("node-A", pm, cfg, logger, reg)
+
+	t.Run("wait for one", func(t *testing.T) {
+		res := replicator.Replicate(context.Background(), store.DefaultNamespace, "key", store.Entry{Value: []byte("v"), Timestamp: 1})
+		assert.Equal(t, 2, res.Peers)
+		assert.Equal(t, 1, res.Wait(context.Background(), 1))
+	})
+
+	t.Run("wait for all returns once every peer finished", func(t *testing.T) {
+		res := replicator.Replicate(context.Background(), store.DefaultNamespace, "key", store.Tombstone(2))
+		assert.Equal(t, 1, res.Wait(context.Background(), res.Peers))
+	})
+
+	t.Run("wait honours context", func(t *testing.T) {
+		res := replicator.Replicate(context.Background(), store.DefaultNamespace, "key", store.Entry{Value: []byte("v"), Timestamp: 3})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.LessOrEqual(t, res.Wait(ctx, 2), 2)
+	})
+}
+
+func TestReplicator_NoPeers_WaitReturnsImmediately(t *testing.T) {
+	cfg := peers.DefaultPeerConfig()
+	reg := metrics.NewRegistry()
+	pm := peers.NewPeerManager(cfg, reg)
+
+	logger := logs.NewLogger(10, logs.DEBUG)
+	replicator := NewReplicator("node-A", pm, cfg, logger, reg)
+
+	res := replicator.Replicate(context.Background(), store.DefaultNamespace, "key", store.Entry{Value: []byte("v"), Timestamp: 1})
+	assert.Equal(t, 0, res.Peers)
+	assert.Equal(t, 0, res.Wait(context.Background(), 1))
+}
+
+func TestReplicator_UpdateConfig_AppliesRetryPolicy(t *testing.T) {
+	var calls int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	cfg := peers.DefaultPeerConfig()
+	cfg.Retry.MaxRetries = 0
+
+	reg := metrics.NewRegistry()
+	pm := peers.NewPeerManager(cfg, reg)
+	pm.AddPeer(server.URL)
+
+	logger := logs.NewLogger(10, logs.DEBUG)
+	replicator := NewReplicator("node-A", pm, cfg, logger, reg)
+
+	cfg.Retry.MaxRetries = 2
+	cfg.Retry.BaseBackoff = 1 * time.Millisecond
+	cfg.Retry.JitterFn = func(d time.Duration) time.Duration { return 0 }
+	replicator.UpdateConfig(cfg)
+
+	res := replicator.Replicate(context.Background(), store.DefaultNamespace, "key", store.Entry{Value: []byte("v"), Timestamp: 1})
+	assert.Equal(t, 0, res.Wait(context.Background(), 1))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestReplicator_Drain_WaitsForInflight(t *testing.T) {
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	cfg := peers.DefaultPeerConfig()
+
+	reg := metrics.NewRegistry()
+	pm := peers.NewPeerManager(cfg, reg)
+	pm.AddPeer(server.URL)
+
+	logger := logs.NewLogger(10, logs.DEBUG)
+	replicator := NewReplicator("node-A", pm, cfg, logger, reg)
+
+	replicator.Replicate(context.Background(), store.DefaultNamespace, "key", store.Entry{Value: []byte("v"), Timestamp: 1})
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+
+	err := replicator.Drain(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), reg.Snapshot()[string(metrics.ReplicationSuccessTotal)])
+}
+
+func TestReplicator_Drain_DeadlineCancelsInflight(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	cfg := peers.DefaultPeerConfig()
+	cfg.Retry.MaxRetries = 100
+	cfg.Retry.BaseBackoff = time.Second
+	cfg.Retry.MaxBackoff = time.Second
+
+	reg := metrics.NewRegistry()
+	pm := peers.NewPeerManager(cfg, reg)
+	pm.AddPeer(server.URL)
+
+	logger := logs.NewLogger(10, logs.DEBUG)
+	replicator := NewReplicator("node-A", pm, cfg, logger, reg)
+
+	replicator.Replicate(context.Background(), store.DefaultNamespace, "key", store.Entry{Value: []byte("v"), Timestamp: 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := replicator.Drain(ctx)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, int64(1), reg.Snapshot()[string(metrics.ReplicationFailureTotal)])
+}
+
+func TestReplicator_SetBinaryPeers_SelectsTransport(t *testing.T) {
+	var httpCalls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&httpCalls, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	receiver := &recordingReceiver{}
+	binaryServer := startServer(t, receiver)
+
+	cfg := peers.DefaultPeerConfig()
+	reg := metrics.NewRegistry()
+	pm := peers.NewPeerManager(cfg, reg)
+	pm.AddPeer(server.URL)
+
+	replicator := NewReplicator("node-A", pm, cfg, logs.NewLogger(10, logs.DEBUG), reg)
+	defer replicator.Close()
+	ctx := context.Background()
+	entry := store.Entry{Value: []byte("val"), Timestamp: 1}
+
+	replicator.SetBinaryPeers(map[string]string{server.URL: binaryServer.addr})
+	assert.Equal(t, 1, replicator.Replicate(ctx, "", "key", entry).Wait(ctx, 1))
+	assert.Equal(t, 1, receiver.numWrites())
+	assert.Equal(t, int32(0), atomic.LoadInt32(&httpCalls))
+
+	replicator.SetBinaryPeers(nil)
+	assert.Equal(t, 1, replicator.Replicate(ctx, "", "key", entry).Wait(ctx, 1))
+	assert.Equal(t, 1, receiver.numWrites())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&httpCalls), "unmapped peers fall back to HTTP/JSON")
 }
@@ -0,0 +1,352 @@
+package replication
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"distributed-cache/internal/logs"
+	"distributed-cache/internal/metrics"
+)
+
+// Errors a Receiver wraps to classify why it refused a payload.
+var (
+	// ErrInvalidPayload reports a malformed payload (HTTP 400).
+	ErrInvalidPayload = errors.New("invalid payload")
+
+	// ErrConflict reports a stale payload or one that originated on the
+	// receiving node (HTTP 409); the sender treats it as delivered.
+	ErrConflict = errors.New("conflict")
+
+	// ErrUnknownNamespace reports a write to a namespace the receiving node
+	// does not have (HTTP 404). Namespaces are created on every node by an
+	// operator, so the sender counts the refusal instead of retrying.
+	ErrUnknownNamespace = errors.New("unknown namespace")
+)
+
+// ErrServerClosed is returned by Serve after Shutdown.
+var ErrServerClosed = errors.New("replication: server closed")
+
+// Receiver applies payloads replicated from peers. The HTTP endpoints and
+// the binary Server share it, so both transports apply payloads the same way.
+type Receiver interface {
+	ApplyReplication(Payload) error
+	ApplyReplicationBatch(BatchPayload) error
+	ApplyPublish(PublishPayload) error
+}
+
+// Server receives payloads over the binary protocol and hands them to a
+// Receiver.
+//
+// Frames of one connection are applied in the order they arrive, and their
+// responses are flushed together once no more frames are buffered.
+type Server struct {
+	receiver Receiver
+	metrics  *metrics.Registry
+	logger   *logs.Logger
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[*serverConn]struct{}
+	closed    bool
+	active    sync.WaitGroup
+}
+
+// NewServer creates a binary replication server; call Serve or
+// ListenAndServe to start it.
+func NewServer(
+	receiver Receiver,
+	metricsRegistry *metrics.Registry,
+	logger *logs.Logger,
+) *Server {
+	return &Server{
+		receiver:  receiver,
+		metrics:   metricsRegistry,
+		logger:    logger,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[*serverConn]struct{}),
+	}
+}
+
+// ListenAndServe listens on the TCP address addr and serves peers.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts peers on l until Shutdown; it always returns an error,
+// ErrServerClosed after Shutdown.
+func (s *Server) Serve(l net.Listener) error {
+	if !s.track(l) {
+		l.Close()
+		return ErrServerClosed
+	}
+	defer s.untrack(l)
+
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+
+		c := s.newConn(nc)
+		if c == nil {
+			nc.Close()
+			return ErrServerClosed
+		}
+		go s.serveConn(c)
+	}
+}
+
+// Shutdown stops accepting peers, lets every connection finish the frame
+// it is applying and closes it. It returns ctx.Err() if ctx ends first,
+// after closing the remaining connections.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for c := range s.conns {
+		c.closeIdle()
+	}
+	s.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		s.active.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for c := range s.conns {
+			c.nc.Close()
+		}
+		s.mu.Unlock()
+		<-finished
+		return ctx.Err()
+	}
+}
+
+func (s *Server) track(l net.Listener) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	s.listeners[l] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(l net.Listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.listeners, l)
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closed
+}
+
+// newConn registers a peer connection, or returns nil after Shutdown.
+func (s *Server) newConn(nc net.Conn) *serverConn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+
+	c := &serverConn{
+		nc:     nc,
+		server: s,
+		reader: newFrameReader(nc),
+		writer: bufio.NewWriterSize(nc, 16<<10),
+	}
+	s.conns[c] = struct{}{}
+	s.active.Add(1)
+	s.metrics.Inc(metrics.ReplicationBinaryConnections)
+	return c
+}
+
+func (s *Server) removeConn(c *serverConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.conns, c)
+	s.active.Done()
+	s.metrics.Add(metrics.ReplicationBinaryConnections, -1)
+}
+
+// serverConn is one peer connection.
+type serverConn struct {
+	nc     net.Conn
+	server *Server
+	reader *frameReader
+	writer *bufio.Writer
+	buf    []byte // response frame being written
+
+	// busy is set while a frame is applied; guarded by server.mu.
+	busy    bool
+	closing bool
+}
+
+func (s *Server) serveConn(c *serverConn) {
+	defer s.removeConn(c)
+	defer c.nc.Close()
+
+	if err := c.handshake(); err != nil {
+		s.logger.Debug("replication handshake failed: " + err.Error())
+		return
+	}
+
+	for {
+		f, err := c.reader.next()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !c.isClosing() {
+				s.logger.Debug("replication connection closed: " + err.Error())
+			}
+			return
+		}
+
+		if !c.begin() {
+			_ = c.writer.Flush() // responses to earlier frames
+			return
+		}
+		s.metrics.Inc(metrics.ReplicationBinaryFramesTotal)
+		status, message := s.apply(f)
+		c.buf = appendResponse(c.buf[:0], f.id, status, message)
+		_, err = c.writer.Write(c.buf)
+		c.end()
+		if err != nil {
+			return
+		}
+
+		// Frames sent back to back are answered with a single write.
+		done := c.isClosing()
+		if done || c.reader.Buffered() == 0 {
+			if err := c.writer.Flush(); err != nil {
+				return
+			}
+		}
+		if done {
+			return
+		}
+	}
+}
+
+// handshake answers the client's hello with the version both will use.
+func (c *serverConn) handshake() error {
+	_ = c.nc.SetDeadline(time.Now().Add(handshakeTimeout))
+
+	version, err := readHello(c.reader)
+	if err != nil {
+		return err
+	}
+	accepted := min(version, protocolVersion)
+	if err := writeHello(c.nc, accepted); err != nil {
+		return err
+	}
+	if accepted == 0 {
+		return ErrUnsupportedVersion
+	}
+
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+
+	// Shutdown may already have set the read deadline to close the connection.
+	if !c.closing {
+		_ = c.nc.SetDeadline(time.Time{})
+	}
+	return nil
+}
+
+// apply decodes a request frame and hands it to the receiver.
+func (s *Server) apply(f frame) (byte, string) {
+	payload, err := decodePayload(f.typ, f.body)
+	if err != nil {
+		return statusInvalid, err.Error()
+	}
+
+	switch p := payload.(type) {
+	case Payload:
+		err = s.receiver.ApplyReplication(p)
+	case BatchPayload:
+		err = s.receiver.ApplyReplicationBatch(p)
+	case PublishPayload:
+		err = s.receiver.ApplyPublish(p)
+	}
+
+	switch {
+	case err == nil:
+		return statusOK, ""
+	case errors.Is(err, ErrConflict):
+		return statusConflict, err.Error()
+	case errors.Is(err, ErrInvalidPayload):
+		return statusInvalid, err.Error()
+	case errors.Is(err, ErrUnknownNamespace):
+		return statusUnknownNamespace, err.Error()
+	}
+	return statusError, err.Error()
+}
+
+// begin marks the connection busy, unless Shutdown is closing it.
+func (c *serverConn) begin() bool {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+
+	if c.closing {
+		return false
+	}
+	c.busy = true
+	return true
+}
+
+func (c *serverConn) end() {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+
+	c.busy = false
+}
+
+func (c *serverConn) isClosing() bool {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+
+	return c.closing
+}
+
+// closeIdle closes the connection unless a frame is being applied, in
+// which case the connection closes itself after responding. Called with
+// server.mu held.
+func (c *serverConn) closeIdle() {
+	c.closing = true
+	if !c.busy {
+		// Unblock the pending read; serveConn then exits.
+		_ = c.nc.SetReadDeadline(time.Now())
+	}
+}
@@ -0,0 +1,303 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"distributed-cache/internal/logs"
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/peers"
+	"distributed-cache/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingReceiver records applied payloads and answers with err.
+type recordingReceiver struct {
+	mu        sync.Mutex
+	writes    []Payload
+	batches   []BatchPayload
+	published []PublishPayload
+	err       error
+}
+
+func (r *recordingReceiver) ApplyReplication(p Payload) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.writes = append(r.writes, p)
+	return r.err
+}
+
+func (r *recordingReceiver) ApplyReplicationBatch(p BatchPayload) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.batches = append(r.batches, p)
+	return r.err
+}
+
+func (r *recordingReceiver) ApplyPublish(p PublishPayload) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.published = append(r.published, p)
+	return r.err
+}
+
+func (r *recordingReceiver) numWrites() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.writes)
+}
+
+type testServer struct {
+	*Server
+	addr    string
+	metrics *metrics.Registry
+	served  chan error
+}
+
+func startServer(t *testing.T, receiver Receiver) *testServer {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	reg := metrics.NewRegistry()
+	ts := &testServer{
+		Server:  NewServer(receiver, reg, logs.NewLogger(10, logs.DEBUG)),
+		addr:    l.Addr().String(),
+		metrics: reg,
+		served:  make(chan error, 1),
+	}
+	go func() { ts.served <- ts.Serve(l) }()
+	t.Cleanup(func() { _ = ts.Shutdown(context.Background()) })
+	return ts
+}
+
+// binaryReplicator returns a replicator reaching one peer over the binary
+// transport only: its URL serves nothing.
+func binaryReplicator(t *testing.T, addr string, cfg peers.PeerConfig) (*Replicator, string) {
+	t.Helper()
+
+	const peer = "http://127.0.0.1:1"
+	reg := metrics.NewRegistry()
+	pm := peers.NewPeerManager(cfg, reg)
+	pm.AddPeer(peer)
+
+	replicator := NewReplicator("node-A", pm, cfg, logs.NewLogger(10, logs.DEBUG), reg)
+	replicator.SetBinaryPeers(map[string]string{peer: addr})
+	t.Cleanup(replicator.Close)
+	return replicator, peer
+}
+
+func TestBinary_ReplicatesPayloads(t *testing.T) {
+	receiver := &recordingReceiver{}
+	ts := startServer(t, receiver)
+	replicator, _ := binaryReplicator(t, ts.addr, peers.DefaultPeerConfig())
+	ctx := context.Background()
+
+	entry := store.Entry{Value: []byte("v"), Timestamp: 1}
+	batch := []Write{{Key: "a", Entry: entry}, {Key: "b", Entry: entry}}
+	assert.Equal(t, 1, replicator.Replicate(ctx, "orders", "k", entry).Wait(ctx, 1))
+	assert.Equal(t, 1, replicator.ReplicateBatch(ctx, "orders", batch).Wait(ctx, 1))
+	assert.Equal(t, 1, replicator.Publish(ctx, "news", []byte("hi")).Wait(ctx, 1))
+
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	assert.Equal(t, []Payload{{Namespace: "orders", Key: "k", Entry: entry, OriginalNodeID: "node-A"}}, receiver.writes)
+	assert.Equal(t, []BatchPayload{{Namespace: "orders", Writes: batch, OriginalNodeID: "node-A"}}, receiver.batches)
+	assert.Equal(t, []PublishPayload{{Channel: "news", Message: []byte("hi"), OriginalNodeID: "node-A"}}, receiver.published)
+}
+
+func TestBinary_MultiplexesOneConnection(t *testing.T) {
+	receiver := &recordingReceiver{}
+	ts := startServer(t, receiver)
+	bp := newBinaryPeer(ts.addr)
+	defer bp.close()
+	ctx := context.Background()
+
+	// Dial first, so the concurrent sends share the connection.
+	require.NoError(t, bp.send(ctx, time.Second, Payload{Key: "first", Entry: store.Entry{Timestamp: 1}}))
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, bp.send(ctx, time.Second, Payload{Key: "k", Entry: store.Entry{Timestamp: 1}}))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 51, receiver.numWrites())
+	snap := ts.metrics.Snapshot()
+	assert.Equal(t, int64(1), snap[string(metrics.ReplicationBinaryConnections)])
+	assert.Equal(t, int64(51), snap[string(metrics.ReplicationBinaryFramesTotal)])
+}
+
+func TestBinary_ReceiverErrors(t *testing.T) {
+	cfg := peers.DefaultPeerConfig()
+	cfg.Retry.MaxRetries = 1
+	cfg.Retry.BaseBackoff = time.Millisecond
+	cfg.Retry.JitterFn = func(d time.Duration) time.Duration { return 0 }
+	ctx := context.Background()
+	entry := store.Entry{Value: []byte("v"), Timestamp: 1}
+
+	t.Run("conflict is acknowledged", func(t *testing.T) {
+		receiver := &recordingReceiver{err: ErrConflict}
+		replicator, _ := binaryReplicator(t, startServer(t, receiver).addr, cfg)
+
+		assert.Equal(t, 1, replicator.Replicate(ctx, "", "k", entry).Wait(ctx, 1))
+		assert.Equal(t, 1, receiver.numWrites(), "conflicts are not retried")
+	})
+
+	t.Run("invalid payload fails", func(t *testing.T) {
+		receiver := &recordingReceiver{err: ErrInvalidPayload}
+		replicator, _ := binaryReplicator(t, startServer(t, receiver).addr, cfg)
+
+		assert.Equal(t, 0, replicator.Replicate(ctx, "", "k", entry).Wait(ctx, 1))
+		assert.Equal(t, 2, receiver.numWrites(), "like HTTP 400, the payload is retried")
+	})
+
+	t.Run("error reaches the sender", func(t *testing.T) {
+		receiver := &recordingReceiver{err: errors.New("disk full")}
+		ts := startServer(t, receiver)
+
+		err := newBinaryPeer(ts.addr).send(ctx, time.Second, Payload{Key: "k", Entry: entry})
+		var peerErr *peerError
+		require.ErrorAs(t, err, &peerErr)
+		assert.Equal(t, statusError, peerErr.status)
+		assert.Equal(t, "disk full", peerErr.message)
+	})
+}
+
+func TestBinary_RedialsBrokenConnection(t *testing.T) {
+	receiver := &recordingReceiver{}
+	ts := startServer(t, receiver)
+	replicator, peer := binaryReplicator(t, ts.addr, peers.DefaultPeerConfig())
+	ctx := context.Background()
+	entry := store.Entry{Value: []byte("v"), Timestamp: 1}
+
+	assert.Equal(t, 1, replicator.Replicate(ctx, "", "k", entry).Wait(ctx, 1))
+
+	bp := replicator.binaryPeer(peer)
+	bp.mu.Lock()
+	bp.conn.close()
+	bp.mu.Unlock()
+
+	assert.Equal(t, 1, replicator.Replicate(ctx, "", "k", entry).Wait(ctx, 1))
+	assert.Equal(t, 2, receiver.numWrites())
+}
+
+func TestBinary_UnreachablePeerFails(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	cfg := peers.DefaultPeerConfig()
+	cfg.Retry.MaxRetries = 0
+	replicator, _ := binaryReplicator(t, addr, cfg)
+
+	ctx := context.Background()
+	assert.Equal(t, 0, replicator.Replicate(ctx, "", "k", store.Entry{Value: []byte("v"), Timestamp: 1}).Wait(ctx, 1))
+}
+
+func TestHandshake_Versions(t *testing.T) {
+	ts := startServer(t, &recordingReceiver{})
+
+	t.Run("server downgrades to its version", func(t *testing.T) {
+		nc, err := net.Dial("tcp", ts.addr)
+		require.NoError(t, err)
+		defer nc.Close()
+
+		require.NoError(t, writeHello(nc, protocolVersion+5))
+		version, err := readHello(nc)
+		require.NoError(t, err)
+		assert.Equal(t, uint16(protocolVersion), version)
+	})
+
+	t.Run("server refuses version 0", func(t *testing.T) {
+		nc, err := net.Dial("tcp", ts.addr)
+		require.NoError(t, err)
+		defer nc.Close()
+
+		require.NoError(t, writeHello(nc, 0))
+		version, err := readHello(nc)
+		require.NoError(t, err)
+		assert.Equal(t, uint16(0), version)
+
+		_, err = nc.Read(make([]byte, 1))
+		assert.Error(t, err, "the server closes the connection")
+	})
+
+	t.Run("client refuses unknown versions", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer l.Close()
+
+		go func() {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer nc.Close()
+			_, _ = readHello(nc)
+			_ = writeHello(nc, protocolVersion+1)
+		}()
+
+		_, err = dialMux(context.Background(), l.Addr().String())
+		assert.ErrorIs(t, err, ErrUnsupportedVersion)
+	})
+}
+
+func TestServer_InvalidFrameKeepsConnection(t *testing.T) {
+	receiver := &recordingReceiver{}
+	ts := startServer(t, receiver)
+
+	conn, err := dialMux(context.Background(), ts.addr)
+	require.NoError(t, err)
+	defer conn.close()
+
+	bad := append(make([]byte, frameHeaderLen), "garbage"...)
+	resp, err := conn.roundTrip(context.Background(), 42, bad)
+	require.NoError(t, err)
+	assert.Equal(t, statusInvalid, resp.status)
+	assert.Contains(t, resp.message, "unknown frame type")
+
+	good, typ, err := appendPayload(make([]byte, frameHeaderLen), Payload{Key: "k", Entry: store.Entry{Timestamp: 1}})
+	require.NoError(t, err)
+	resp, err = conn.roundTrip(context.Background(), typ, good)
+	require.NoError(t, err)
+	assert.Equal(t, statusOK, resp.status)
+	assert.Equal(t, 1, receiver.numWrites())
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	ts := startServer(t, &recordingReceiver{})
+
+	conn, err := dialMux(context.Background(), ts.addr)
+	require.NoError(t, err)
+	defer conn.close()
+
+	require.NoError(t, ts.Shutdown(context.Background()))
+	assert.ErrorIs(t, <-ts.served, ErrServerClosed)
+
+	select {
+	case <-conn.done:
+	case <-time.After(time.Second):
+		t.Fatal("connection still open after shutdown")
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	assert.ErrorIs(t, ts.Serve(l), ErrServerClosed, "a closed server does not serve again")
+}
@@ -0,0 +1,670 @@
+package resp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/replication"
+	"distributed-cache/internal/store"
+	"distributed-cache/internal/util"
+	"distributed-cache/internal/version"
+)
+
+// redisVersion is the Redis version reported by HELLO and INFO. Clients
+// gate features on it; the commands served here behave as in that version.
+const redisVersion = "7.2.0"
+
+// maxReplicationBatch bounds the writes of one replicated batch (MSET, DEL).
+const maxReplicationBatch = 1000
+
+// maxScanCount bounds the page size a SCAN COUNT may ask for.
+const maxScanCount = 1000
+
+// maxCASRetries bounds the read-modify-write attempts of INCR when
+// concurrent writers keep changing the key.
+const maxCASRetries = 100
+
+// command is one supported command. Arity counts the command name, as in
+// Redis: positive means exactly that many arguments, negative at least -arity.
+type command struct {
+	arity int
+	run   func(s *Server, c *conn, args [][]byte)
+}
+
+var commands = map[string]command{
+	"get":    {2, (*Server).get},
+	"set":    {-3, (*Server).set},
+	"del":    {-2, (*Server).del},
+	"exists": {-2, (*Server).exists},
+	"expire": {3, (*Server).expire},
+	"ttl":    {2, (*Server).ttl},
+	"incr":   {2, (*Server).incr},
+	"mget":   {-2, (*Server).mget},
+	"mset":   {-3, (*Server).mset},
+	"scan":   {-2, (*Server).scan},
+	"ping":   {-1, (*Server).ping},
+	"echo":   {2, (*Server).echo},
+	"info":   {-1, (*Server).info},
+
+	// Connection handling, as sent by redis-cli and client libraries.
+	"hello":  {-1, (*Server).hello},
+	"client": {-2, (*Server).client},
+	"select": {2, (*Server).selectDB},
+	"quit":   {-1, (*Server).quit},
+}
+
+// Error replies shared by several commands, worded as in Redis.
+const (
+	errSyntax     = "ERR syntax error"
+	errNotInteger = "ERR value is not an integer or out of range"
+)
+
+// dispatch runs one command and reports whether the connection should close.
+func (s *Server) dispatch(c *conn, args [][]byte) bool {
+	name := strings.ToLower(string(args[0]))
+	s.metrics.Inc(metrics.RESPCommandsTotal)
+
+	cmd, ok := commands[name]
+	if !ok {
+		c.fail(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		return false
+	}
+	if cmd.arity > 0 && len(args) != cmd.arity || cmd.arity < 0 && len(args) < -cmd.arity {
+		c.fail(wrongArgs(name))
+		return false
+	}
+
+	cmd.run(s, c, args[1:])
+	return c.quit
+}
+
+// fail writes an error reply.
+func (c *conn) fail(msg string) {
+	c.server.metrics.Inc(metrics.RESPErrorsTotal)
+	c.writer.Error(msg)
+}
+
+func wrongArgs(name string) string {
+	return fmt.Sprintf("ERR wrong number of arguments for '%s' command", name)
+}
+
+// storeError words a rejected write as a Redis error.
+func storeError(err error) string {
+	if errors.Is(err, store.ErrNotCounter) {
+		return errNotInteger
+	}
+	if errors.Is(err, store.ErrCounterOverflow) {
+		return "ERR increment or decrement would overflow"
+	}
+	return "ERR " + err.Error()
+}
+
+// replicate sends accepted writes to peers, like the HTTP API does.
+func (s *Server) replicate(writes []store.Write) {
+	ctx := context.Background()
+	namespace := s.store.Default().Name()
+
+	if len(writes) == 1 {
+		s.replicator.Replicate(ctx, namespace, writes[0].Key, writes[0].Entry)
+		return
+	}
+	for chunk := range slices.Chunk(writes, maxReplicationBatch) {
+		batch := make([]replication.Write, len(chunk))
+		for i, wr := range chunk {
+			batch[i] = replication.Write{Key: wr.Key, Entry: wr.Entry}
+		}
+		s.replicator.ReplicateBatch(ctx, namespace, batch)
+	}
+}
+
+// parseInt parses a command's integer argument.
+func parseInt(arg []byte) (int64, bool) {
+	n, err := strconv.ParseInt(string(arg), 10, 64)
+	return n, err == nil
+}
+
+// expiresAt converts a relative expiry to an absolute time; false when
+// it is not positive or overflows.
+func expiresAt(n int64, unit time.Duration) (time.Time, bool) {
+	if n <= 0 || n > math.MaxInt64/int64(unit) {
+		return time.Time{}, false
+	}
+	return time.Now().Add(time.Duration(n) * unit), true
+}
+
+/* ---------------- Keys ---------------- */
+
+// get: GET key
+func (s *Server) get(c *conn, args [][]byte) {
+	entry, ok := s.store.Get(string(args[0]))
+	if !ok {
+		c.writer.Null()
+		return
+	}
+	c.writer.Bulk(entry.Value)
+}
+
+// set: SET key value [NX | XX] [EX seconds | PX milliseconds]
+//
+// Values are stored without a content type, like JSON writes of the HTTP
+// API. A write refused by NX or XX replies with a null.
+func (s *Server) set(c *conn, args [][]byte) {
+	key := string(args[0])
+	entry := store.Entry{Value: args[1], Timestamp: time.Now().UnixNano()}
+
+	var cond store.Precondition
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); {
+		case opt == "NX" && !cond.IfPresent:
+			cond.IfAbsent = true
+		case opt == "XX" && !cond.IfAbsent:
+			cond.IfPresent = true
+		case (opt == "EX" || opt == "PX") && entry.ExpiresAt.IsZero() && i+1 < len(args):
+			i++
+			n, ok := parseInt(args[i])
+			if !ok {
+				c.fail(errNotInteger)
+				return
+			}
+			unit := time.Second
+			if opt == "PX" {
+				unit = time.Millisecond
+			}
+			if entry.ExpiresAt, ok = expiresAt(n, unit); !ok {
+				c.fail("ERR invalid expire time in 'set' command")
+				return
+			}
+		default:
+			c.fail(errSyntax)
+			return
+		}
+	}
+
+	stored, err := s.store.SetIf(key, entry, cond)
+	switch {
+	case errors.Is(err, store.ErrPreconditionFailed):
+		c.writer.Null()
+		return
+	case err != nil:
+		c.fail(storeError(err))
+		return
+	}
+
+	s.replicate([]store.Write{{Key: key, Entry: stored}})
+	c.writer.SimpleString("OK")
+}
+
+// del: DEL key [key ...], replying with the number of keys removed.
+func (s *Server) del(c *conn, args [][]byte) {
+	c.writer.Integer(int64(s.deleteKeys(args)))
+}
+
+// deleteKeys writes a tombstone for every present key and replicates them.
+func (s *Server) deleteKeys(keys [][]byte) int {
+	now := time.Now().UnixNano()
+
+	var writes []store.Write
+	for _, key := range keys {
+		stored, err := s.store.SetIf(string(key), store.Tombstone(now), store.Precondition{IfPresent: true})
+		if err == nil {
+			writes = append(writes, store.Write{Key: string(key), Entry: stored})
+		}
+	}
+
+	if len(writes) > 0 {
+		s.replicate(writes)
+	}
+	return len(writes)
+}
+
+// exists: EXISTS key [key ...], counting a key as often as it is named.
+func (s *Server) exists(c *conn, args [][]byte) {
+	count := 0
+	for _, key := range args {
+		if _, ok := s.store.Get(string(key)); ok {
+			count++
+		}
+	}
+	c.writer.Integer(int64(count))
+}
+
+// expire: EXPIRE key seconds, replying 1 when the key exists.
+// A non-positive timeout deletes the key, as in Redis.
+func (s *Server) expire(c *conn, args [][]byte) {
+	key := string(args[0])
+
+	seconds, ok := parseInt(args[1])
+	if !ok {
+		c.fail(errNotInteger)
+		return
+	}
+	if seconds <= 0 {
+		c.writer.Integer(int64(s.deleteKeys(args[:1])))
+		return
+	}
+
+	at, ok := expiresAt(seconds, time.Second)
+	if !ok {
+		c.fail("ERR invalid expire time in 'expire' command")
+		return
+	}
+
+	stored, ok := s.store.Expire(key, at)
+	if !ok {
+		c.writer.Integer(0)
+		return
+	}
+	s.replicate([]store.Write{{Key: key, Entry: stored}})
+	c.writer.Integer(1)
+}
+
+// ttl: TTL key, in seconds; -1 without expiry and -2 for a missing key.
+func (s *Server) ttl(c *conn, args [][]byte) {
+	entry, ok := s.store.Get(string(args[0]))
+	switch {
+	case !ok:
+		c.writer.Integer(-2)
+	case entry.ExpiresAt.IsZero():
+		c.writer.Integer(-1)
+	default:
+		remaining := time.Until(entry.ExpiresAt)
+		c.writer.Integer(int64((remaining + time.Second/2) / time.Second))
+	}
+}
+
+// incr: INCR key
+//
+// Keys are counters (see store.Counter): increments on different nodes add
+// up. A number written by SET becomes a counter on its first increment,
+// seeding this node's slot with it (see store.AsCounter), so the usual
+// SET key 0 EX n NX followed by INCR key works as in Redis.
+func (s *Server) incr(c *conn, args [][]byte) {
+	key := string(args[0])
+
+	for range maxCASRetries {
+		if entry, ok := s.store.Get(key); ok && entry.Counter == nil {
+			converted, ok := store.AsCounter(entry, s.nodeID)
+			if !ok {
+				c.fail(errNotInteger)
+				return
+			}
+			// Peers learn of the conversion with the increment below.
+			_, err := s.store.SetIf(key, converted, store.Precondition{IfMatch: entry.Version})
+			if errors.Is(err, store.ErrPreconditionFailed) {
+				continue
+			}
+			if err != nil {
+				c.fail(storeError(err))
+				return
+			}
+		}
+
+		stored, err := s.store.Incr(key, s.nodeID, 1, 0)
+		if errors.Is(err, store.ErrNotCounter) {
+			continue // overwritten by SET in the meantime
+		}
+		if err != nil {
+			c.fail(storeError(err))
+			return
+		}
+
+		s.replicate([]store.Write{{Key: key, Entry: stored}})
+		c.writer.Integer(stored.Counter.Value())
+		return
+	}
+	c.fail("ERR too much contention")
+}
+
+// mget: MGET key [key ...]
+func (s *Server) mget(c *conn, args [][]byte) {
+	keys := make([]string, len(args))
+	for i, key := range args {
+		keys[i] = string(key)
+	}
+
+	results := s.store.GetMany(keys)
+	c.writer.Array(len(results))
+	for _, res := range results {
+		if res.Found {
+			c.writer.Bulk(res.Entry.Value)
+		} else {
+			c.writer.Null()
+		}
+	}
+}
+
+// mset: MSET key value [key value ...]
+func (s *Server) mset(c *conn, args [][]byte) {
+	if len(args)%2 != 0 {
+		c.fail(wrongArgs("mset"))
+		return
+	}
+
+	now := time.Now().UnixNano()
+	writes := make([]store.Write, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		writes = append(writes, store.Write{
+			Key:   string(args[i]),
+			Entry: store.Entry{Value: args[i+1], Timestamp: now},
+		})
+	}
+
+	accepted := writes[:0]
+	for i, res := range s.store.SetMany(writes) {
+		if res.Err == nil {
+			accepted = append(accepted, store.Write{Key: writes[i].Key, Entry: res.Entry})
+		}
+	}
+	if len(accepted) > 0 {
+		s.replicate(accepted)
+	}
+	c.writer.SimpleString("OK")
+}
+
+/* ---------------- SCAN ---------------- */
+
+// maxCursors bounds the SCAN cursors remembered by a server.
+const maxCursors = 4096
+
+// scan: SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+//
+// Keys are visited in lexicographic order, COUNT at a time; MATCH filters
+// each page afterwards, so a page may come back empty before the end, as in
+// Redis. Every key is a string, so TYPE string keeps all keys and any
+// other type none.
+//
+// Cursors are numbers standing for the last key of a page; a server
+// remembers the latest maxCursors of them and rejects older ones.
+func (s *Server) scan(c *conn, args [][]byte) {
+	cursor, err := strconv.ParseUint(string(args[0]), 10, 64)
+	if err != nil {
+		c.fail("ERR invalid cursor")
+		return
+	}
+
+	opts := store.ScanOptions{Limit: 10}
+	pattern, typ := "", "string"
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			c.fail(errSyntax)
+			return
+		}
+		switch strings.ToUpper(string(args[i])) {
+		case "MATCH":
+			pattern = string(args[i+1])
+		case "COUNT":
+			n, ok := parseInt(args[i+1])
+			if !ok {
+				c.fail(errNotInteger)
+				return
+			}
+			if n < 1 {
+				c.fail(errSyntax)
+				return
+			}
+			opts.Limit = int(min(n, maxScanCount))
+		case "TYPE":
+			typ = strings.ToLower(string(args[i+1]))
+		default:
+			c.fail(errSyntax)
+			return
+		}
+	}
+
+	if cursor != 0 {
+		after, ok := s.cursors.get(cursor)
+		if !ok {
+			c.fail("ERR invalid cursor")
+			return
+		}
+		opts.After = after
+	}
+
+	page := s.store.Scan(opts)
+
+	var keys []string
+	for _, item := range page.Items {
+		if typ == "string" && (pattern == "" || util.Match(pattern, item.Key)) {
+			keys = append(keys, item.Key)
+		}
+	}
+
+	next := uint64(0)
+	if page.Next != "" {
+		next = s.cursors.put(page.Next)
+	}
+
+	c.writer.Array(2)
+	c.writer.BulkString(strconv.FormatUint(next, 10))
+	c.writer.Array(len(keys))
+	for _, key := range keys {
+		c.writer.BulkString(key)
+	}
+}
+
+// cursorTable maps SCAN cursors to the key a scan resumes after.
+// The oldest cursors are forgotten first.
+type cursorTable struct {
+	mu    sync.Mutex
+	keys  map[uint64]string
+	order []uint64 // ring of issued cursors
+	next  int      // ring slot written next
+	last  uint64
+}
+
+func newCursorTable(size int) *cursorTable {
+	return &cursorTable{
+		keys:  make(map[uint64]string, size),
+		order: make([]uint64, size),
+	}
+}
+
+// put issues a cursor for after; cursors are never 0, which starts a scan.
+func (t *cursorTable) put(after string) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.last++
+	delete(t.keys, t.order[t.next])
+	t.order[t.next] = t.last
+	t.next = (t.next + 1) % len(t.order)
+	t.keys[t.last] = after
+	return t.last
+}
+
+func (t *cursorTable) get(cursor uint64) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	after, ok := t.keys[cursor]
+	return after, ok
+}
+
+/* ---------------- Server ---------------- */
+
+// ping: PING [message]
+func (s *Server) ping(c *conn, args [][]byte) {
+	switch len(args) {
+	case 0:
+		c.writer.SimpleString("PONG")
+	case 1:
+		c.writer.Bulk(args[0])
+	default:
+		c.fail(wrongArgs("ping"))
+	}
+}
+
+// echo: ECHO message
+func (s *Server) echo(c *conn, args [][]byte) {
+	c.writer.Bulk(args[0])
+}
+
+// info: INFO [section ...]
+//
+// Sections: server, clients, stats (the node's metrics) and keyspace.
+// No section, "default", "all" or "everything" selects them all.
+func (s *Server) info(c *conn, args [][]byte) {
+	sections := []struct {
+		name  string
+		write func(b *strings.Builder)
+	}{
+		{"server", s.infoServer},
+		{"clients", s.infoClients},
+		{"stats", s.infoStats},
+		{"keyspace", s.infoKeyspace},
+	}
+
+	wanted := make(map[string]bool)
+	for _, arg := range args {
+		wanted[strings.ToLower(string(arg))] = true
+	}
+	all := len(wanted) == 0 || wanted["default"] || wanted["all"] || wanted["everything"]
+
+	var b strings.Builder
+	for _, section := range sections {
+		if !all && !wanted[section.name] {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\r\n")
+		}
+		fmt.Fprintf(&b, "# %s%s\r\n", strings.ToUpper(section.name[:1]), section.name[1:])
+		section.write(&b)
+	}
+	c.writer.BulkString(b.String())
+}
+
+func (s *Server) infoServer(b *strings.Builder) {
+	uptime := time.Since(s.startedAt)
+	fmt.Fprintf(b, "redis_version:%s\r\n", redisVersion)
+	fmt.Fprintf(b, "redis_mode:standalone\r\n")
+	fmt.Fprintf(b, "cache_version:%s\r\n", version.Version)
+	fmt.Fprintf(b, "node_id:%s\r\n", s.nodeID)
+	fmt.Fprintf(b, "process_id:%d\r\n", os.Getpid())
+	fmt.Fprintf(b, "uptime_in_seconds:%d\r\n", int64(uptime/time.Second))
+	fmt.Fprintf(b, "uptime_in_days:%d\r\n", int64(uptime/(24*time.Hour)))
+}
+
+func (s *Server) infoClients(b *strings.Builder) {
+	fmt.Fprintf(b, "connected_clients:%d\r\n", s.tcp.NumConns())
+}
+
+// infoStats lists the node's unlabeled metrics.
+func (s *Server) infoStats(b *strings.Builder) {
+	snap := s.metrics.Snapshot()
+
+	names := make([]string, 0, len(snap))
+	for name := range snap {
+		if !strings.Contains(name, "{") {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		fmt.Fprintf(b, "%s:%d\r\n", name, snap[name])
+	}
+}
+
+func (s *Server) infoKeyspace(b *strings.Builder) {
+	if keys := s.store.Default().Len(); keys > 0 {
+		fmt.Fprintf(b, "db0:keys=%d\r\n", keys)
+	}
+}
+
+/* ---------------- Connection ---------------- */
+
+// hello: HELLO [protover [AUTH username password] [SETNAME clientname]]
+//
+// Switches the connection to RESP2 or RESP3. The node has no users, so
+// credentials are accepted and ignored.
+func (s *Server) hello(c *conn, args [][]byte) {
+	proto := c.writer.Protocol()
+	if len(args) > 0 {
+		n, ok := parseInt(args[0])
+		if !ok || n < 2 || n > 3 {
+			c.fail("NOPROTO unsupported protocol version")
+			return
+		}
+		proto = int(n)
+	}
+
+	name := c.name
+	for i := 1; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); {
+		case opt == "AUTH" && i+2 < len(args):
+			i += 2
+		case opt == "SETNAME" && i+1 < len(args):
+			i++
+			name = string(args[i])
+		default:
+			c.fail(errSyntax)
+			return
+		}
+	}
+
+	c.name = name
+	c.writer.SetProtocol(proto)
+
+	c.writer.Map(7)
+	c.writer.BulkString("server")
+	c.writer.BulkString("redis")
+	c.writer.BulkString("version")
+	c.writer.BulkString(redisVersion)
+	c.writer.BulkString("proto")
+	c.writer.Integer(int64(proto))
+	c.writer.BulkString("id")
+	c.writer.Integer(c.ID)
+	c.writer.BulkString("mode")
+	c.writer.BulkString("standalone")
+	c.writer.BulkString("role")
+	c.writer.BulkString("master")
+	c.writer.BulkString("modules")
+	c.writer.Array(0)
+}
+
+// client: CLIENT ID | GETNAME | SETNAME name | SETINFO attr value
+func (s *Server) client(c *conn, args [][]byte) {
+	sub := strings.ToUpper(string(args[0]))
+	switch {
+	case sub == "ID" && len(args) == 1:
+		c.writer.Integer(c.ID)
+	case sub == "GETNAME" && len(args) == 1:
+		if c.name == "" {
+			c.writer.Null()
+			return
+		}
+		c.writer.BulkString(c.name)
+	case sub == "SETNAME" && len(args) == 2:
+		c.name = string(args[1])
+		c.writer.SimpleString("OK")
+	case sub == "SETINFO" && len(args) == 3:
+		c.writer.SimpleString("OK")
+	default:
+		c.fail(fmt.Sprintf("ERR unknown subcommand or wrong number of arguments for '%s'", args[0]))
+	}
+}
+
+// selectDB: SELECT index; only database 0 exists.
+func (s *Server) selectDB(c *conn, args [][]byte) {
+	if string(args[0]) != "0" {
+		c.fail("ERR DB index is out of range")
+		return
+	}
+	c.writer.SimpleString("OK")
+}
+
+// quit: QUIT, closing the connection after the reply.
+func (s *Server) quit(c *conn, args [][]byte) {
+	c.quit = true
+	c.writer.SimpleString("OK")
+}
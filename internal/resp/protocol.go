@@ -0,0 +1,220 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Protocol limits, so a malformed or hostile request cannot allocate
+// without bound. maxBulkLen matches the value limit of the HTTP API.
+const (
+	maxArrayLen  = 1 << 20
+	maxBulkLen   = 64 << 20
+	maxInlineLen = 64 << 10
+)
+
+// ErrProtocol reports a request that is not valid RESP; the connection is
+// closed after replying, as Redis does.
+var ErrProtocol = errors.New("protocol error")
+
+func protocolError(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrProtocol, fmt.Sprintf(format, args...))
+}
+
+// Reader reads client commands.
+//
+// Commands are arrays of bulk strings, as sent by client libraries, or
+// inline commands (space-separated words on one line), as typed in telnet.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader wraps r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Buffered reports whether more pipelined input is already buffered,
+// so replies can be flushed once per batch.
+func (r *Reader) Buffered() bool {
+	return r.r.Buffered() > 0
+}
+
+// ReadCommand returns the next command's arguments, the command name
+// first. Empty inline lines are skipped.
+func (r *Reader) ReadCommand() ([][]byte, error) {
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(line) > 0 && line[0] == '*' {
+			return r.readArray(line)
+		}
+
+		if args := bytes.Fields(line); len(args) > 0 {
+			return args, nil
+		}
+	}
+}
+
+func (r *Reader) readArray(header []byte) ([][]byte, error) {
+	n, err := strconv.Atoi(string(header[1:]))
+	if err != nil || n > maxArrayLen {
+		return nil, protocolError("invalid multibulk length")
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+
+	args := make([][]byte, n)
+	for i := range args {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, protocolError("expected '$', got '%s'", printable(line))
+		}
+
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > maxBulkLen {
+			return nil, protocolError("invalid bulk length")
+		}
+
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r.r, buf); err != nil {
+			return nil, err
+		}
+		if buf[size] != '\r' || buf[size+1] != '\n' {
+			return nil, protocolError("bulk string not terminated by CRLF")
+		}
+		args[i] = buf[:size]
+	}
+	return args, nil
+}
+
+// readLine reads one line without its CRLF (or bare LF, for inline commands).
+func (r *Reader) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.r.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, chunk...)
+		if len(line) > maxInlineLen {
+			return nil, protocolError("too big inline request")
+		}
+		if !isPrefix {
+			return line, nil
+		}
+	}
+}
+
+// printable returns the first byte of line for error messages.
+func printable(line []byte) string {
+	if len(line) == 0 {
+		return ""
+	}
+	return string(line[:1])
+}
+
+// Writer encodes replies in the protocol version the client negotiated
+// with HELLO: RESP2 by default, or RESP3.
+//
+// RESP3-only types degrade the way Redis does on RESP2 connections:
+// nulls become null bulk strings and maps become flat arrays.
+type Writer struct {
+	w     *bufio.Writer
+	proto int
+}
+
+// NewWriter wraps w, speaking RESP2 until SetProtocol is called.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w), proto: 2}
+}
+
+// SetProtocol switches to protocol version 2 or 3.
+func (w *Writer) SetProtocol(proto int) {
+	w.proto = proto
+}
+
+// Protocol returns the protocol version in use.
+func (w *Writer) Protocol() int {
+	return w.proto
+}
+
+// SimpleString writes a status reply such as OK or PONG.
+func (w *Writer) SimpleString(s string) {
+	w.w.WriteByte('+')
+	w.w.WriteString(s)
+	w.w.WriteString("\r\n")
+}
+
+// Error writes an error reply. msg starts with an error code, e.g. "ERR ...".
+func (w *Writer) Error(msg string) {
+	w.w.WriteByte('-')
+	w.w.WriteString(msg)
+	w.w.WriteString("\r\n")
+}
+
+// Integer writes an integer reply.
+func (w *Writer) Integer(n int64) {
+	w.w.WriteByte(':')
+	w.w.WriteString(strconv.FormatInt(n, 10))
+	w.w.WriteString("\r\n")
+}
+
+// Bulk writes a binary-safe string.
+func (w *Writer) Bulk(b []byte) {
+	w.header('$', len(b))
+	w.w.Write(b)
+	w.w.WriteString("\r\n")
+}
+
+// BulkString writes s as a bulk string.
+func (w *Writer) BulkString(s string) {
+	w.header('$', len(s))
+	w.w.WriteString(s)
+	w.w.WriteString("\r\n")
+}
+
+// Null writes a missing value.
+func (w *Writer) Null() {
+	if w.proto >= 3 {
+		w.w.WriteString("_\r\n")
+		return
+	}
+	w.w.WriteString("$-1\r\n")
+}
+
+// Array starts an array of n elements; the caller writes them next.
+func (w *Writer) Array(n int) {
+	w.header('*', n)
+}
+
+// Map starts a map of n key-value pairs; the caller writes 2n elements next.
+func (w *Writer) Map(n int) {
+	if w.proto >= 3 {
+		w.header('%', n)
+		return
+	}
+	w.header('*', 2*n)
+}
+
+// Flush sends the buffered replies.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+func (w *Writer) header(kind byte, n int) {
+	w.w.WriteByte(kind)
+	w.w.WriteString(strconv.Itoa(n))
+	w.w.WriteString("\r\n")
+}
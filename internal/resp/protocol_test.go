@@ -0,0 +1,96 @@
+package resp
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"distributed-cache/internal/tcpserver/tcptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReader_ReadCommand(t *testing.T) {
+	r := NewReader(strings.NewReader(
+		"*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$5\r\na\r\nb \r\n" + // binary-safe value
+			"\r\n" + // empty inline lines are skipped
+			"PING  hello\r\n" +
+			"GET k\n",
+	))
+
+	args, err := r.ReadCommand()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SET", "k", "a\r\nb "}, tcptest.Words(args))
+	assert.True(t, r.Buffered())
+
+	args, err = r.ReadCommand()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"PING", "hello"}, tcptest.Words(args))
+
+	args, err = r.ReadCommand()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GET", "k"}, tcptest.Words(args))
+
+	_, err = r.ReadCommand()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestReader_ProtocolErrors(t *testing.T) {
+	tests := map[string]string{
+		"InvalidArrayLength": "*x\r\n",
+		"ArrayTooLong":       "*2000000\r\n",
+		"MissingBulkHeader":  "*1\r\n+OK\r\n",
+		"InvalidBulkLength":  "*1\r\n$-3\r\n",
+		"BulkTooLong":        "*1\r\n$100000000\r\n",
+		"UnterminatedBulk":   "*1\r\n$2\r\nabcd\r\n",
+		"InlineTooLong":      strings.Repeat("a", maxInlineLen+1) + "\r\n",
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewReader(strings.NewReader(input)).ReadCommand()
+			assert.ErrorIs(t, err, ErrProtocol)
+		})
+	}
+
+	_, err := NewReader(strings.NewReader("*2\r\n$3\r\nGET\r\n")).ReadCommand()
+	assert.ErrorIs(t, err, io.EOF, "a truncated command is not a protocol error")
+}
+
+func TestWriter(t *testing.T) {
+	encode := func(proto int, write func(w *Writer)) string {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		w.SetProtocol(proto)
+		write(w)
+		require.NoError(t, w.Flush())
+		return buf.String()
+	}
+
+	assert.Equal(t, "+OK\r\n-ERR bad\r\n:-7\r\n$3\r\na\nb\r\n$0\r\n\r\n", encode(2, func(w *Writer) {
+		w.SimpleString("OK")
+		w.Error("ERR bad")
+		w.Integer(-7)
+		w.Bulk([]byte("a\nb"))
+		w.BulkString("")
+	}))
+
+	t.Run("RESP2", func(t *testing.T) {
+		assert.Equal(t, "$-1\r\n*2\r\n$1\r\nk\r\n:1\r\n", encode(2, func(w *Writer) {
+			w.Null()
+			w.Map(1)
+			w.BulkString("k")
+			w.Integer(1)
+		}))
+	})
+
+	t.Run("RESP3", func(t *testing.T) {
+		assert.Equal(t, "_\r\n%1\r\n$1\r\nk\r\n:1\r\n", encode(3, func(w *Writer) {
+			w.Null()
+			w.Map(1)
+			w.BulkString("k")
+			w.Integer(1)
+		}))
+	})
+}
@@ -0,0 +1,129 @@
+package resp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"distributed-cache/internal/logs"
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/replication"
+	"distributed-cache/internal/store"
+	"distributed-cache/internal/tcpserver"
+)
+
+// ErrServerClosed is returned by Serve after Shutdown.
+var ErrServerClosed = tcpserver.ErrServerClosed
+
+// Server serves the store to Redis clients over RESP2/RESP3.
+//
+// Commands act on the default namespace. Writes go through the same store
+// methods as the HTTP API, so they are recorded in the same metrics, and
+// are replicated to peers the same way, without waiting for acknowledgements.
+type Server struct {
+	nodeID     string
+	store      *store.Store
+	replicator *replication.Replicator
+	metrics    *metrics.Registry
+	logger     *logs.Logger
+	startedAt  time.Time
+
+	cursors *cursorTable
+	tcp     *tcpserver.Server
+}
+
+// NewServer creates a RESP server; call Serve or ListenAndServe to start it.
+func NewServer(
+	nodeID string,
+	cacheStore *store.Store,
+	replicator *replication.Replicator,
+	metricsRegistry *metrics.Registry,
+	logger *logs.Logger,
+) *Server {
+	s := &Server{
+		nodeID:     nodeID,
+		store:      cacheStore,
+		replicator: replicator,
+		metrics:    metricsRegistry,
+		logger:     logger,
+		startedAt:  time.Now(),
+		cursors:    newCursorTable(maxCursors),
+	}
+	s.tcp = tcpserver.New(s.serveConn, metricsRegistry, tcpserver.Metrics{
+		Connections: metrics.RESPConnections,
+	})
+	return s
+}
+
+// ListenAndServe listens on the TCP address addr and serves clients.
+func (s *Server) ListenAndServe(addr string) error {
+	return s.tcp.ListenAndServe(addr)
+}
+
+// Serve accepts clients on l until Shutdown; it always returns an error,
+// ErrServerClosed after Shutdown.
+func (s *Server) Serve(l net.Listener) error {
+	return s.tcp.Serve(l)
+}
+
+// Shutdown stops accepting clients, lets every connection finish the
+// command it is running and closes it. It returns ctx.Err() if ctx ends
+// first, after closing the remaining connections.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.tcp.Shutdown(ctx)
+}
+
+// conn is one client connection. Commands of a connection run one at a time.
+type conn struct {
+	*tcpserver.Conn
+	server *Server
+	reader *Reader
+	writer *Writer
+	name   string
+	quit   bool
+}
+
+func (s *Server) serveConn(tc *tcpserver.Conn) {
+	c := &conn{
+		Conn:   tc,
+		server: s,
+		reader: NewReader(tc),
+		writer: NewWriter(tc),
+	}
+
+	for {
+		args, err := c.reader.ReadCommand()
+		if err != nil {
+			if errors.Is(err, ErrProtocol) {
+				c.writer.Error("ERR " + err.Error())
+				_ = c.writer.Flush()
+			} else if !errors.Is(err, io.EOF) && !c.Closing() {
+				s.logger.Debug("resp connection closed: " + err.Error())
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		if !c.Begin() {
+			_ = c.writer.Flush() // replies to earlier pipelined commands
+			return
+		}
+		quit := s.dispatch(c, args)
+		c.End()
+
+		// Pipelined commands are answered with a single write.
+		done := quit || c.Closing()
+		if done || !c.reader.Buffered() {
+			if err := c.writer.Flush(); err != nil {
+				return
+			}
+		}
+		if done {
+			return
+		}
+	}
+}
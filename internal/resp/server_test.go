@@ -0,0 +1,459 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/peers"
+	"distributed-cache/internal/replication"
+	"distributed-cache/internal/store"
+	"distributed-cache/internal/tcpserver/tcptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testServer struct {
+	*Server
+	addr    string
+	store   *store.Store
+	metrics *metrics.Registry
+	peers   *peers.PeerManager
+	served  <-chan error
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	node := tcptest.NewNode()
+	ts := &testServer{
+		Server:  NewServer(tcptest.NodeID, node.Store, node.Replicator, node.Metrics, node.Logger),
+		store:   node.Store,
+		metrics: node.Metrics,
+		peers:   node.Peers,
+	}
+	ts.addr, ts.served = tcptest.Serve(t, ts.Server)
+	return ts
+}
+
+// respError is an error reply.
+type respError string
+
+// client is a minimal Redis client decoding replies into Go values:
+// strings, respError, int64, nil, []any (arrays and RESP3 maps).
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dial(t *testing.T, ts *testServer) *client {
+	t.Helper()
+
+	conn := tcptest.Dial(t, ts.addr)
+	return &client{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *client) send(args ...string) {
+	c.t.Helper()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	_, err := c.conn.Write([]byte(b.String()))
+	require.NoError(c.t, err)
+}
+
+func (c *client) do(args ...string) any {
+	c.t.Helper()
+
+	c.send(args...)
+	return c.read()
+}
+
+func (c *client) read() any {
+	c.t.Helper()
+
+	line, err := c.r.ReadString('\n')
+	require.NoError(c.t, err)
+	line = strings.TrimSuffix(line, "\r\n")
+
+	switch line[0] {
+	case '+':
+		return line[1:]
+	case '-':
+		return respError(line[1:])
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		require.NoError(c.t, err)
+		return n
+	case '_':
+		return nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		require.NoError(c.t, err)
+		if n < 0 {
+			return nil
+		}
+		buf := make([]byte, n+2)
+		_, err = io.ReadFull(c.r, buf)
+		require.NoError(c.t, err)
+		return string(buf[:n])
+	case '*', '%':
+		n, err := strconv.Atoi(line[1:])
+		require.NoError(c.t, err)
+		if line[0] == '%' {
+			n *= 2
+		}
+		out := make([]any, n)
+		for i := range out {
+			out[i] = c.read()
+		}
+		return out
+	}
+	c.t.Fatalf("unexpected reply %q", line)
+	return nil
+}
+
+func TestServer_GetSet(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	assert.Nil(t, c.do("GET", "k"))
+	assert.Equal(t, "OK", c.do("SET", "k", "hello world"))
+	assert.Equal(t, "hello world", c.do("GET", "k"))
+
+	entry, ok := ts.store.Get("k")
+	require.True(t, ok)
+	assert.Empty(t, entry.ContentType, "stored like JSON writes of the HTTP API")
+	assert.Equal(t, int64(1), ts.metrics.Snapshot()[string(metrics.CacheSetsTotal)])
+}
+
+func TestServer_SetOptions(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	assert.Nil(t, c.do("SET", "k", "v", "XX"), "XX needs the key")
+	assert.Equal(t, "OK", c.do("SET", "k", "v1", "NX"))
+	assert.Nil(t, c.do("SET", "k", "v2", "nx"))
+	assert.Equal(t, "OK", c.do("SET", "k", "v3", "XX", "EX", "100"))
+	assert.Equal(t, "v3", c.do("GET", "k"))
+	assert.Equal(t, int64(100), c.do("TTL", "k"))
+
+	assert.Equal(t, "OK", c.do("SET", "p", "v", "PX", "1"))
+	assert.Eventually(t, func() bool { return c.do("GET", "p") == nil }, time.Second, 5*time.Millisecond)
+
+	tests := []struct {
+		args []string
+		want respError
+	}{
+		{[]string{"SET", "k", "v", "NX", "XX"}, respError(errSyntax)},
+		{[]string{"SET", "k", "v", "EX", "1", "PX", "1"}, respError(errSyntax)},
+		{[]string{"SET", "k", "v", "EX"}, respError(errSyntax)},
+		{[]string{"SET", "k", "v", "KEEPTTL"}, respError(errSyntax)},
+		{[]string{"SET", "k", "v", "EX", "soon"}, respError(errNotInteger)},
+		{[]string{"SET", "k", "v", "EX", "0"}, "ERR invalid expire time in 'set' command"},
+		{[]string{"SET", "k", "v", "PX", "9223372036854775807"}, "ERR invalid expire time in 'set' command"},
+		{[]string{"SET", "k"}, "ERR wrong number of arguments for 'set' command"},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, c.do(tc.args...), tc.args)
+	}
+}
+
+func TestServer_DelExists(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	c.do("MSET", "a", "1", "b", "2")
+	assert.Equal(t, int64(3), c.do("EXISTS", "a", "b", "a", "missing"))
+	assert.Equal(t, int64(2), c.do("DEL", "a", "b", "missing"))
+	assert.Equal(t, int64(0), c.do("EXISTS", "a", "b"))
+	assert.Equal(t, int64(0), c.do("DEL", "a"))
+}
+
+func TestServer_ExpireTTL(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	assert.Equal(t, int64(-2), c.do("TTL", "k"))
+	assert.Equal(t, int64(0), c.do("EXPIRE", "k", "10"))
+
+	c.do("SET", "k", "v")
+	assert.Equal(t, int64(-1), c.do("TTL", "k"))
+	assert.Equal(t, int64(1), c.do("EXPIRE", "k", "10"))
+	assert.Equal(t, int64(10), c.do("TTL", "k"))
+	assert.Equal(t, "v", c.do("GET", "k"))
+
+	assert.Equal(t, respError(errNotInteger), c.do("EXPIRE", "k", "ten"))
+	assert.Equal(t, int64(1), c.do("EXPIRE", "k", "0"), "a non-positive timeout deletes")
+	assert.Nil(t, c.do("GET", "k"))
+}
+
+func TestServer_Incr(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	assert.Equal(t, int64(1), c.do("INCR", "hits"))
+	assert.Equal(t, int64(2), c.do("INCR", "hits"))
+	assert.Equal(t, "2", c.do("GET", "hits"))
+
+	c.do("SET", "name", "v")
+	assert.Equal(t, respError(errNotInteger), c.do("INCR", "name"))
+
+	t.Run("after SET", func(t *testing.T) {
+		assert.Equal(t, "OK", c.do("SET", "window", "0", "EX", "60", "NX"))
+		assert.Equal(t, int64(1), c.do("INCR", "window"))
+		assert.Equal(t, int64(2), c.do("INCR", "window"))
+		assert.Equal(t, "2", c.do("GET", "window"))
+		assert.Equal(t, int64(60), c.do("TTL", "window"), "the expiry is kept")
+
+		c.do("SET", "negative", "-5")
+		assert.Equal(t, int64(-4), c.do("INCR", "negative"))
+
+		c.do("SET", "max", "9223372036854775807")
+		assert.Equal(t, respError("ERR increment or decrement would overflow"), c.do("INCR", "max"))
+		assert.Equal(t, "9223372036854775807", c.do("GET", "max"))
+	})
+}
+
+func TestServer_MGetMSet(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	assert.Equal(t, "OK", c.do("MSET", "a", "1", "b", "2"))
+	assert.Equal(t, []any{"1", nil, "2"}, c.do("MGET", "a", "missing", "b"))
+	assert.Equal(t, respError("ERR wrong number of arguments for 'mset' command"), c.do("MSET", "a", "1", "b"))
+}
+
+func TestServer_Scan(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	for i := range 7 {
+		c.do("SET", fmt.Sprintf("user:%d", i), "v")
+	}
+	c.do("SET", "order:1", "v")
+
+	scanAll := func(args ...string) []string {
+		var keys []string
+		cursor := "0"
+		for range 100 {
+			reply, ok := c.do(append([]string{"SCAN", cursor}, args...)...).([]any)
+			require.True(t, ok)
+			for _, key := range reply[1].([]any) {
+				keys = append(keys, key.(string))
+			}
+			if cursor = reply[0].(string); cursor == "0" {
+				return keys
+			}
+		}
+		t.Fatal("scan did not finish")
+		return nil
+	}
+
+	assert.Len(t, scanAll(), 8)
+	assert.Len(t, scanAll("COUNT", "3"), 8)
+	assert.Equal(t, []string{"user:0", "user:1", "user:2", "user:3", "user:4", "user:5", "user:6"},
+		scanAll("MATCH", "user:*", "COUNT", "2"))
+	assert.Empty(t, scanAll("TYPE", "hash"))
+
+	assert.Equal(t, respError("ERR invalid cursor"), c.do("SCAN", "12345"))
+	assert.Equal(t, respError("ERR invalid cursor"), c.do("SCAN", "x"))
+	assert.Equal(t, respError(errSyntax), c.do("SCAN", "0", "COUNT", "0"))
+	assert.Equal(t, respError(errSyntax), c.do("SCAN", "0", "MATCH"))
+}
+
+func TestCursorTable_ForgetsOldest(t *testing.T) {
+	table := newCursorTable(2)
+
+	first := table.put("a")
+	second := table.put("b")
+	third := table.put("c")
+
+	_, ok := table.get(first)
+	assert.False(t, ok)
+	after, ok := table.get(second)
+	assert.True(t, ok)
+	assert.Equal(t, "b", after)
+	after, _ = table.get(third)
+	assert.Equal(t, "c", after)
+}
+
+func TestServer_ConnectionCommands(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	assert.Equal(t, "PONG", c.do("PING"))
+	assert.Equal(t, "hi", c.do("PING", "hi"))
+	assert.Equal(t, "hi", c.do("ECHO", "hi"))
+	assert.Equal(t, "OK", c.do("SELECT", "0"))
+	assert.Equal(t, respError("ERR DB index is out of range"), c.do("SELECT", "1"))
+
+	assert.Nil(t, c.do("CLIENT", "GETNAME"))
+	assert.Equal(t, "OK", c.do("CLIENT", "SETNAME", "worker"))
+	assert.Equal(t, "worker", c.do("CLIENT", "GETNAME"))
+	assert.Equal(t, "OK", c.do("CLIENT", "SETINFO", "LIB-NAME", "test"))
+	assert.IsType(t, int64(0), c.do("CLIENT", "ID"))
+
+	assert.Equal(t, respError("ERR unknown command 'FLUSHALL'"), c.do("FLUSHALL"))
+	assert.Equal(t, respError("ERR wrong number of arguments for 'get' command"), c.do("GET"))
+	assert.Equal(t, int64(3), ts.metrics.Snapshot()[string(metrics.RESPErrorsTotal)])
+
+	assert.Equal(t, "OK", c.do("QUIT"))
+	_, err := c.r.ReadByte()
+	assert.Error(t, err, "QUIT closes the connection")
+}
+
+func TestServer_Hello(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	reply, ok := c.do("HELLO", "3", "AUTH", "default", "secret", "SETNAME", "app").([]any)
+	require.True(t, ok)
+	hello := make(map[string]any)
+	for i := 0; i < len(reply); i += 2 {
+		hello[reply[i].(string)] = reply[i+1]
+	}
+	assert.Equal(t, "redis", hello["server"])
+	assert.Equal(t, redisVersion, hello["version"])
+	assert.Equal(t, int64(3), hello["proto"])
+	assert.Equal(t, "app", c.do("CLIENT", "GETNAME"))
+
+	// RESP3 nulls
+	c.send("GET", "missing")
+	line, err := c.r.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "_\r\n", line)
+
+	assert.Equal(t, respError("NOPROTO unsupported protocol version"), c.do("HELLO", "4"))
+	c.do("HELLO", "2")
+	c.send("GET", "missing")
+	line, _ = c.r.ReadString('\n')
+	assert.Equal(t, "$-1\r\n", line)
+}
+
+func TestServer_Info(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+	c.do("SET", "k", "v")
+
+	info, ok := c.do("INFO").(string)
+	require.True(t, ok)
+	assert.Contains(t, info, "# Server\r\nredis_version:"+redisVersion)
+	assert.Contains(t, info, "node_id:node-A\r\n")
+	assert.Contains(t, info, "# Clients\r\nconnected_clients:1\r\n")
+	assert.Contains(t, info, "cache_sets_total:1\r\n")
+	assert.Contains(t, info, "# Keyspace\r\ndb0:keys=1\r\n")
+
+	info = c.do("INFO", "keyspace").(string)
+	assert.Equal(t, "# Keyspace\r\ndb0:keys=1\r\n", info)
+	assert.Equal(t, "", c.do("INFO", "nothing"))
+}
+
+func TestServer_Pipelining(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	_, err := c.conn.Write([]byte("*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\nGET k\r\nPING\r\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "OK", c.read())
+	assert.Equal(t, "v", c.read())
+	assert.Equal(t, "PONG", c.read())
+}
+
+func TestServer_ProtocolErrorClosesConnection(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+
+	_, err := c.conn.Write([]byte("*1\r\n+PING\r\n"))
+	require.NoError(t, err)
+
+	reply, ok := c.read().(respError)
+	require.True(t, ok)
+	assert.True(t, strings.HasPrefix(string(reply), "ERR protocol error"))
+
+	_, err = c.r.ReadByte()
+	assert.Error(t, err)
+}
+
+func TestServer_ReplicatesWrites(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string]store.Entry)
+	record := func(key string, entry store.Entry) {
+		mu.Lock()
+		defer mu.Unlock()
+		if entry.Timestamp > received[key].Timestamp {
+			received[key] = entry
+		}
+	}
+
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/internal/replicate":
+			var payload replication.Payload
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			record(payload.Key, payload.Entry)
+		case "/internal/replicate/batch":
+			var payload replication.BatchPayload
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			for _, wr := range payload.Writes {
+				record(wr.Key, wr.Entry)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer peer.Close()
+
+	ts := newTestServer(t)
+	ts.peers.AddPeer(peer.URL)
+	c := dial(t, ts)
+
+	c.do("SET", "a", "1")
+	c.do("MSET", "b", "2", "c", "3")
+	c.do("INCR", "n")
+	c.do("EXPIRE", "a", "60")
+	c.do("DEL", "b", "c")
+	c.do("SET", "a", "2", "NX") // refused, not replicated
+
+	// Writes to a key still queued are coalesced; the peer converges on
+	// the newest write of every key.
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		a, n := received["a"], received["n"]
+		return string(a.Value) == "1" && !a.ExpiresAt.IsZero() &&
+			received["b"].Deleted && received["c"].Deleted && n.Counter != nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	ts := newTestServer(t)
+	c := dial(t, ts)
+	assert.Equal(t, "PONG", c.do("PING"))
+
+	require.NoError(t, ts.Shutdown(context.Background()))
+	assert.ErrorIs(t, <-ts.served, ErrServerClosed)
+
+	_, err := c.r.ReadByte()
+	assert.Error(t, err, "idle connections are closed")
+	assert.Zero(t, ts.metrics.Snapshot()[string(metrics.RESPConnections)])
+
+	_, err = net.DialTimeout("tcp", ts.addr, 100*time.Millisecond)
+	assert.Error(t, err, "the listener is closed")
+}
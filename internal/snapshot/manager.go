@@ -0,0 +1,283 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"distributed-cache/internal/logs"
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/store"
+)
+
+// Store defines the minimal contract required by the snapshot manager.
+type Store interface {
+	Namespaces() []*store.Namespace
+	EnsureNamespace(name string, settings store.NamespaceSettings) (*store.Namespace, error)
+}
+
+// Info describes a snapshot that was saved or restored.
+type Info struct {
+	Path      string    `json:"path"`
+	CreatedAt time.Time `json:"created_at"`
+	Keys      int       `json:"keys"`
+	Expired   int       `json:"expired,omitempty"`
+	Bytes     int64     `json:"bytes,omitempty"`
+}
+
+// Manager saves snapshots of the store into a directory, periodically
+// and on demand, and restores the newest valid one at startup.
+//
+// Files are named after their creation time so that lexical order is
+// chronological; only the newest retain files are kept.
+type Manager struct {
+	dir     string
+	nodeID  string
+	retain  int
+	store   Store
+	logger  *logs.Logger
+	metrics *metrics.Registry
+
+	// saveMu serializes saves so periodic and on-demand runs never interleave.
+	saveMu sync.Mutex
+
+	mu       sync.RWMutex
+	interval time.Duration
+
+	// reconfigured wakes Start so a new interval takes effect immediately.
+	reconfigured chan struct{}
+}
+
+// NewManager creates a snapshot manager.
+//
+// interval: time between periodic snapshots; zero disables them
+// retain: number of snapshot files kept in dir
+func NewManager(
+	dir string,
+	nodeID string,
+	retain int,
+	interval time.Duration,
+	store Store,
+	logger *logs.Logger,
+	metricsRegistry *metrics.Registry,
+) *Manager {
+	return &Manager{
+		dir:          dir,
+		nodeID:       nodeID,
+		retain:       max(retain, 1),
+		interval:     interval,
+		store:        store,
+		logger:       logger,
+		metrics:      metricsRegistry,
+		reconfigured: make(chan struct{}, 1),
+	}
+}
+
+const (
+	filePrefix = "snapshot-"
+	fileSuffix = ".snap"
+)
+
+// Save writes a snapshot of the store and prunes old snapshot files.
+func (m *Manager) Save() (Info, error) {
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+
+	info, err := m.save()
+	if err != nil {
+		m.metrics.Inc(metrics.SnapshotFailuresTotal)
+		m.logger.Error("snapshot failed: " + err.Error())
+		return Info{}, err
+	}
+
+	m.metrics.Inc(metrics.SnapshotSavesTotal)
+	m.logger.Info(fmt.Sprintf("snapshot of %d keys written to %s", info.Keys, info.Path))
+	return info, nil
+}
+
+func (m *Manager) save() (Info, error) {
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return Info{}, err
+	}
+
+	snap := Snapshot{
+		NodeID:    m.nodeID,
+		CreatedAt: time.Now().UTC(),
+	}
+	keys := 0
+	for _, ns := range m.store.Namespaces() {
+		entries := ns.Entries()
+		keys += len(entries)
+
+		if ns.Name() == store.DefaultNamespace {
+			snap.Entries = entries
+			continue
+		}
+		if snap.Namespaces == nil {
+			snap.Namespaces = make(map[string]Namespace)
+		}
+		snap.Namespaces[ns.Name()] = Namespace{Settings: ns.Settings(), Entries: entries}
+	}
+	path := filepath.Join(m.dir, fmt.Sprintf("%s%020d%s", filePrefix, snap.CreatedAt.UnixNano(), fileSuffix))
+
+	n, err := WriteFile(path, snap)
+	if err != nil {
+		return Info{}, err
+	}
+
+	if err := m.prune(); err != nil {
+		m.logger.Warn("snapshot prune failed: " + err.Error())
+	}
+
+	return Info{Path: path, CreatedAt: snap.CreatedAt, Keys: keys, Bytes: n}, nil
+}
+
+// Restore loads the newest valid snapshot into the store.
+//
+// Corrupt or unreadable files are skipped in favor of older ones, and
+// entries that expired while the node was down are not loaded.
+// Restored entries keep their LWW timestamps, so they never override newer data.
+// Missing namespaces are created with their saved settings.
+//
+// A missing or empty directory is a cold start, not an error: Restore
+// returns a zero Info.
+func (m *Manager) Restore() (Info, error) {
+	paths, err := m.files()
+	if err != nil {
+		return Info{}, err
+	}
+
+	for i := len(paths) - 1; i >= 0; i-- {
+		snap, err := ReadFile(paths[i])
+		if err != nil {
+			m.metrics.Inc(metrics.SnapshotCorruptTotal)
+			m.logger.Warn(fmt.Sprintf("skipping snapshot %s: %v", paths[i], err))
+			continue
+		}
+
+		info := m.load(snap)
+		info.Path = paths[i]
+		m.logger.Info(fmt.Sprintf("restored %d keys from %s (%d expired)", info.Keys, info.Path, info.Expired))
+		return info, nil
+	}
+
+	if len(paths) > 0 {
+		return Info{}, errors.New("no valid snapshot in " + m.dir)
+	}
+	return Info{}, nil
+}
+
+func (m *Manager) load(snap Snapshot) Info {
+	info := Info{CreatedAt: snap.CreatedAt}
+
+	m.loadNamespace(store.DefaultNamespace, store.NamespaceSettings{}, snap.Entries, &info)
+	for name, ns := range snap.Namespaces {
+		m.loadNamespace(name, ns.Settings, ns.Entries, &info)
+	}
+
+	m.metrics.Add(metrics.SnapshotRestoredKeys, int64(info.Keys))
+	return info
+}
+
+func (m *Manager) loadNamespace(name string, settings store.NamespaceSettings, entries map[string]store.Entry, info *Info) {
+	ns, err := m.store.EnsureNamespace(name, settings)
+	if err != nil {
+		m.logger.Warn(fmt.Sprintf("skipping snapshot namespace %q: %v", name, err))
+		return
+	}
+
+	now := time.Now()
+	for key, entry := range entries {
+		if entry.IsExpired(now) {
+			info.Expired++
+			continue
+		}
+		if ns.Restore(key, entry) {
+			info.Keys++
+		}
+	}
+}
+
+// files returns the snapshot files in dir, oldest first.
+func (m *Manager) files() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(m.dir, filePrefix+"*"+fileSuffix))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func (m *Manager) prune() error {
+	paths, err := m.files()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for len(paths) > m.retain {
+		errs = append(errs, os.Remove(paths[0]))
+		paths = paths[1:]
+	}
+	return errors.Join(errs...)
+}
+
+// UpdateInterval swaps the periodic snapshot interval; zero disables it.
+// A running loop picks up the new interval without waiting for the old one.
+func (m *Manager) UpdateInterval(interval time.Duration) {
+	m.mu.Lock()
+	m.interval = interval
+	m.mu.Unlock()
+
+	select {
+	case m.reconfigured <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Manager) currentInterval() time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.interval
+}
+
+// Start takes periodic snapshots until the context is cancelled.
+func (m *Manager) Start(ctx context.Context) {
+	var ticker *time.Ticker
+	var tick <-chan time.Time
+
+	reset := func() {
+		if ticker != nil {
+			ticker.Stop()
+			ticker, tick = nil, nil
+		}
+		if interval := m.currentInterval(); interval > 0 {
+			ticker = time.NewTicker(interval)
+			tick = ticker.C
+		}
+	}
+	reset()
+	defer func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-tick:
+			_, _ = m.Save()
+		case <-m.reconfigured:
+			reset()
+		case <-ctx.Done():
+			m.logger.Debug("snapshot worker stopped")
+			return
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package snapshot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"distributed-cache/internal/logs"
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestManager(t *testing.T, dir string, st Store) (*Manager, *metrics.Registry) {
+	t.Helper()
+
+	reg := metrics.NewRegistry()
+	return NewManager(dir, "node-1", 2, 0, st, logs.NewLogger(50, logs.DEBUG), reg), reg
+}
+
+func TestManager_SaveAndRestore(t *testing.T) {
+	dir := t.TempDir()
+
+	source := store.NewStore(metrics.NewRegistry())
+	source.Set("live", store.Entry{Value: []byte("v"), Timestamp: 10})
+	source.Set("ttl", store.Entry{Value: []byte("v"), Timestamp: 11, ExpiresAt: time.Now().Add(50 * time.Millisecond)})
+	source.Set("deleted", store.Tombstone(12))
+
+	m, reg := newTestManager(t, dir, source)
+	info, err := m.Save()
+	require.NoError(t, err)
+	assert.Equal(t, 3, info.Keys)
+	assert.Positive(t, info.Bytes)
+	assert.Equal(t, int64(1), reg.Snapshot()[string(metrics.SnapshotSavesTotal)])
+
+	// "ttl" expires while the node is down.
+	time.Sleep(60 * time.Millisecond)
+
+	target := store.NewStore(metrics.NewRegistry())
+	restorer, reg := newTestManager(t, dir, target)
+	info, err = restorer.Restore()
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, info.Keys)
+	assert.Equal(t, 1, info.Expired)
+	assert.Equal(t, int64(2), reg.Snapshot()[string(metrics.SnapshotRestoredKeys)])
+
+	val, ok := target.Get("live")
+	require.True(t, ok)
+	assert.Equal(t, "v", string(val.Value))
+
+	_, ok = target.Get("ttl")
+	assert.False(t, ok)
+
+	t.Run("restored entries keep their LWW timestamps", func(t *testing.T) {
+		assert.False(t, target.Set("live", store.Entry{Value: []byte("old"), Timestamp: 9}))
+		assert.False(t, target.Set("deleted", store.Entry{Value: []byte("old"), Timestamp: 11}), "tombstone survives restart")
+	})
+}
+
+func TestManager_RestoreSkipsCorruptSnapshots(t *testing.T) {
+	dir := t.TempDir()
+
+	st := store.NewStore(metrics.NewRegistry())
+	st.Set("key", store.Entry{Value: []byte("good"), Timestamp: 1})
+
+	m, _ := newTestManager(t, dir, st)
+	_, err := m.Save()
+	require.NoError(t, err)
+
+	// A newer but damaged snapshot.
+	corrupt := filepath.Join(dir, filePrefix+"99999999999999999999"+fileSuffix)
+	require.NoError(t, os.WriteFile(corrupt, []byte("DCSNAP garbage"), 0o644))
+
+	target := store.NewStore(metrics.NewRegistry())
+	restorer, reg := newTestManager(t, dir, target)
+	info, err := restorer.Restore()
+	require.NoError(t, err)
+
+	assert.NotEqual(t, corrupt, info.Path)
+	assert.Equal(t, int64(1), reg.Snapshot()[string(metrics.SnapshotCorruptTotal)])
+
+	val, ok := target.Get("key")
+	require.True(t, ok)
+	assert.Equal(t, "good", string(val.Value))
+
+	t.Run("only corrupt snapshots", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, filePrefix+"1"+fileSuffix), []byte("junk"), 0o644))
+
+		m, _ := newTestManager(t, dir, store.NewStore(metrics.NewRegistry()))
+		_, err := m.Restore()
+		assert.ErrorContains(t, err, "no valid snapshot")
+	})
+}
+
+func TestManager_RestoreColdStart(t *testing.T) {
+	m, _ := newTestManager(t, filepath.Join(t.TempDir(), "missing"), store.NewStore(metrics.NewRegistry()))
+
+	info, err := m.Restore()
+	assert.NoError(t, err)
+	assert.Equal(t, Info{}, info)
+}
+
+func TestManager_PrunesOldSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	m, _ := newTestManager(t, dir, store.NewStore(metrics.NewRegistry()))
+
+	var last Info
+	for range 4 {
+		info, err := m.Save()
+		require.NoError(t, err)
+		last = info
+	}
+
+	paths, err := m.files()
+	require.NoError(t, err)
+	assert.Len(t, paths, 2, "retain keeps the newest files")
+	assert.Equal(t, last.Path, paths[len(paths)-1])
+}
+
+func TestManager_Start(t *testing.T) {
+	dir := t.TempDir()
+	m, reg := newTestManager(t, dir, store.NewStore(metrics.NewRegistry()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Start(ctx)
+		close(done)
+	}()
+
+	// Periodic snapshots are off until an interval is configured.
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int64(0), reg.Snapshot()[string(metrics.SnapshotSavesTotal)])
+
+	m.UpdateInterval(5 * time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return reg.Snapshot()[string(metrics.SnapshotSavesTotal)] >= 2
+	}, time.Second, 5*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Start did not return after cancel")
+	}
+}
+
+func TestManager_SaveAndRestoreNamespaces(t *testing.T) {
+	dir := t.TempDir()
+
+	settings := store.NamespaceSettings{DefaultTTL: time.Hour, MaxEntries: 100, EvictionPolicy: store.PolicyLFU}
+	source := store.NewStore(metrics.NewRegistry())
+	orders, err := source.CreateNamespace("orders", settings)
+	require.NoError(t, err)
+	source.Set("k", store.Entry{Value: []byte("default"), Timestamp: 1})
+	orders.Set("k", store.Entry{Value: []byte("orders"), Timestamp: 1})
+
+	m, _ := newTestManager(t, dir, source)
+	info, err := m.Save()
+	require.NoError(t, err)
+	assert.Equal(t, 2, info.Keys)
+
+	target := store.NewStore(metrics.NewRegistry())
+	restorer, _ := newTestManager(t, dir, target)
+	info, err = restorer.Restore()
+	require.NoError(t, err)
+	assert.Equal(t, 2, info.Keys)
+
+	val, ok := target.Get("k")
+	require.True(t, ok)
+	assert.Equal(t, "default", string(val.Value))
+
+	ns, ok := target.Namespace("orders")
+	require.True(t, ok)
+	assert.Equal(t, settings, ns.Settings(), "namespaces are recreated with their settings")
+	val, ok = ns.Get("k")
+	require.True(t, ok)
+	assert.Equal(t, "orders", string(val.Value))
+}
@@ -0,0 +1,282 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"distributed-cache/internal/store"
+)
+
+// File format:
+// - magic "DCSNAP" (6 bytes)
+// - format version (uint16, big endian)
+// - payload length (uint64, big endian)
+// - CRC-32C of the payload (uint32, big endian)
+// - payload: JSON document holding the node ID, creation time, entries and namespaces
+//
+// Readers reject unknown versions, truncated files and checksum mismatches,
+// so a half-written or bit-rotted snapshot is never loaded.
+//
+// Versions:
+// - 1: values stored as JSON strings
+// - 2: values stored as base64 bytes with their content type
+// - 3: namespaces other than the default one, with their settings
+const (
+	magic = "DCSNAP"
+
+	// Version is the format version written by Encode.
+	Version uint16 = 3
+
+	// minVersion is the oldest format version Decode still reads.
+	minVersion uint16 = 1
+
+	headerSize = len(magic) + 2 + 8 + 4
+)
+
+// ErrCorrupt is returned when a snapshot fails validation.
+var ErrCorrupt = errors.New("corrupt snapshot")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Snapshot is a point-in-time copy of a node's store.
+type Snapshot struct {
+	NodeID    string
+	CreatedAt time.Time
+
+	// Entries holds the default namespace.
+	Entries map[string]store.Entry
+
+	// Namespaces holds every other namespace by name.
+	Namespaces map[string]Namespace
+}
+
+// Namespace is the copy of one namespace and the settings it was created with.
+type Namespace struct {
+	Settings store.NamespaceSettings
+	Entries  map[string]store.Entry
+}
+
+// payload is the on-disk form of a Snapshot.
+type payload struct {
+	NodeID     string            `json:"node_id"`
+	CreatedAt  time.Time         `json:"created_at"`
+	Entries    []record          `json:"entries"`
+	Namespaces []namespaceRecord `json:"namespaces,omitempty"`
+}
+
+// namespaceRecord is the on-disk form of a Namespace.
+type namespaceRecord struct {
+	Name           string   `json:"name"`
+	DefaultTTLms   int64    `json:"default_ttl_ms,omitempty"`
+	MaxEntries     int      `json:"max_entries,omitempty"`
+	MaxBytes       int64    `json:"max_bytes,omitempty"`
+	EvictionPolicy string   `json:"eviction_policy,omitempty"`
+	Entries        []record `json:"entries"`
+}
+
+// record is the on-disk form of one entry, tombstones included.
+type record struct {
+	Key         string `json:"key"`
+	Data        []byte `json:"data,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Flags       uint32 `json:"flags,omitempty"`
+
+	// LegacyValue holds the value in version 1 files.
+	LegacyValue string `json:"value,omitempty"`
+
+	Timestamp int64          `json:"timestamp"`
+	Version   uint64         `json:"version,omitempty"`
+	Counter   *store.Counter `json:"counter,omitempty"`
+	ExpiresAt time.Time      `json:"expires_at,omitzero"`
+	Deleted   bool           `json:"deleted,omitempty"`
+	DeletedAt time.Time      `json:"deleted_at,omitzero"`
+}
+
+// Encode writes s to w in the current format version.
+func Encode(w io.Writer, s Snapshot) error {
+	p := payload{
+		NodeID:    s.NodeID,
+		CreatedAt: s.CreatedAt,
+		Entries:   encodeEntries(s.Entries),
+	}
+	for name, ns := range s.Namespaces {
+		p.Namespaces = append(p.Namespaces, namespaceRecord{
+			Name:           name,
+			DefaultTTLms:   ns.Settings.DefaultTTL.Milliseconds(),
+			MaxEntries:     ns.Settings.MaxEntries,
+			MaxBytes:       ns.Settings.MaxBytes,
+			EvictionPolicy: ns.Settings.EvictionPolicy,
+			Entries:        encodeEntries(ns.Entries),
+		})
+	}
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, 0, headerSize)
+	header = append(header, magic...)
+	header = binary.BigEndian.AppendUint16(header, Version)
+	header = binary.BigEndian.AppendUint64(header, uint64(len(body)))
+	header = binary.BigEndian.AppendUint32(header, crc32.Checksum(body, crcTable))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+// Decode reads and validates a snapshot written by Encode.
+func Decode(r io.Reader) (Snapshot, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return Snapshot{}, fmt.Errorf("%w: short header", ErrCorrupt)
+	}
+
+	if string(header[:len(magic)]) != magic {
+		return Snapshot{}, fmt.Errorf("%w: bad magic", ErrCorrupt)
+	}
+	header = header[len(magic):]
+
+	if version := binary.BigEndian.Uint16(header); version < minVersion || version > Version {
+		return Snapshot{}, fmt.Errorf("unsupported snapshot version %d", version)
+	}
+	length := binary.BigEndian.Uint64(header[2:])
+	checksum := binary.BigEndian.Uint32(header[10:])
+
+	var body bytes.Buffer
+	if n, err := io.CopyN(&body, r, int64(length)); err != nil || uint64(n) != length {
+		return Snapshot{}, fmt.Errorf("%w: truncated payload", ErrCorrupt)
+	}
+	if crc32.Checksum(body.Bytes(), crcTable) != checksum {
+		return Snapshot{}, fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+	}
+
+	var p payload
+	if err := json.Unmarshal(body.Bytes(), &p); err != nil {
+		return Snapshot{}, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+
+	s := Snapshot{
+		NodeID:    p.NodeID,
+		CreatedAt: p.CreatedAt,
+		Entries:   decodeEntries(p.Entries),
+	}
+	if len(p.Namespaces) > 0 {
+		s.Namespaces = make(map[string]Namespace, len(p.Namespaces))
+	}
+	for _, ns := range p.Namespaces {
+		s.Namespaces[ns.Name] = Namespace{
+			Settings: store.NamespaceSettings{
+				DefaultTTL:     time.Duration(ns.DefaultTTLms) * time.Millisecond,
+				MaxEntries:     ns.MaxEntries,
+				MaxBytes:       ns.MaxBytes,
+				EvictionPolicy: ns.EvictionPolicy,
+			},
+			Entries: decodeEntries(ns.Entries),
+		}
+	}
+	return s, nil
+}
+
+func encodeEntries(entries map[string]store.Entry) []record {
+	records := make([]record, 0, len(entries))
+	for key, e := range entries {
+		records = append(records, record{
+			Key:         key,
+			Data:        e.Value,
+			ContentType: e.ContentType,
+			Flags:       e.Flags,
+			Timestamp:   e.Timestamp,
+			Version:     e.Version,
+			ExpiresAt:   e.ExpiresAt,
+			Deleted:     e.Deleted,
+			DeletedAt:   e.DeletedAt,
+			Counter:     e.Counter,
+		})
+	}
+	return records
+}
+
+func decodeEntries(records []record) map[string]store.Entry {
+	entries := make(map[string]store.Entry, len(records))
+	for _, rec := range records {
+		if rec.Data == nil && rec.LegacyValue != "" {
+			rec.Data = []byte(rec.LegacyValue)
+		}
+		entries[rec.Key] = store.Entry{
+			Value:       rec.Data,
+			ContentType: rec.ContentType,
+			Flags:       rec.Flags,
+			Timestamp:   rec.Timestamp,
+			Version:     rec.Version,
+			ExpiresAt:   rec.ExpiresAt,
+			Deleted:     rec.Deleted,
+			DeletedAt:   rec.DeletedAt,
+			Counter:     rec.Counter,
+		}
+	}
+	return entries
+}
+
+// WriteFile atomically writes a snapshot to path.
+//
+// The data is written to a temporary file in the same directory,
+// synced and renamed over path, so readers never see a partial file.
+// It returns the number of bytes written.
+func WriteFile(path string, s Snapshot) (int64, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	counter := &countingWriter{w: tmp}
+	if err := Encode(counter, s); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+
+	return counter.n, os.Rename(tmp.Name(), path)
+}
+
+// ReadFile reads and validates the snapshot at path.
+func ReadFile(path string) (Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer f.Close()
+
+	return Decode(f)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,160 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"distributed-cache/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSnapshot() Snapshot {
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	deletedAt := time.Now().UTC().Truncate(time.Second)
+
+	return Snapshot{
+		NodeID:    "node-1",
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		Entries: map[string]store.Entry{
+			"k1": {Value: []byte{0xff, 0x00, 'v', '1'}, ContentType: "application/octet-stream", Flags: 42, Timestamp: 1, Version: 7},
+			"k2": {
+				Value:     []byte("5"),
+				Timestamp: 2,
+				ExpiresAt: expires,
+				Counter:   &store.Counter{Epoch: 9, P: map[string]int64{"node-1": 7}, N: map[string]int64{"node-2": 2}},
+			},
+			"gone": {Timestamp: 3, Deleted: true, DeletedAt: deletedAt},
+		},
+	}
+}
+
+func TestEncodeDecode_RoundTrip(t *testing.T) {
+	snap := testSnapshot()
+
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, snap))
+
+	got, err := Decode(&buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, snap.NodeID, got.NodeID)
+	assert.True(t, snap.CreatedAt.Equal(got.CreatedAt))
+	require.Len(t, got.Entries, 3)
+
+	for key, want := range snap.Entries {
+		entry := got.Entries[key]
+		assert.Equal(t, want.Value, entry.Value, key)
+		assert.Equal(t, want.ContentType, entry.ContentType, key)
+		assert.Equal(t, want.Flags, entry.Flags, key)
+		assert.Equal(t, want.Timestamp, entry.Timestamp, key)
+		assert.Equal(t, want.Version, entry.Version, key)
+		assert.Equal(t, want.Counter, entry.Counter, key)
+		assert.True(t, want.ExpiresAt.Equal(entry.ExpiresAt), key)
+		assert.Equal(t, want.Deleted, entry.Deleted, key)
+		assert.True(t, want.DeletedAt.Equal(entry.DeletedAt), key)
+	}
+}
+
+func TestDecode_RejectsInvalidFiles(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, testSnapshot()))
+	valid := buf.Bytes()
+
+	corrupt := func(mutate func(b []byte) []byte) []byte {
+		return mutate(bytes.Clone(valid))
+	}
+
+	cases := map[string][]byte{
+		"empty":     nil,
+		"bad magic": corrupt(func(b []byte) []byte { b[0] = 'X'; return b }),
+		"truncated": corrupt(func(b []byte) []byte { return b[:len(b)-5] }),
+		"bit flip":  corrupt(func(b []byte) []byte { b[len(b)-2] ^= 0x01; return b }),
+	}
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := Decode(bytes.NewReader(data))
+			assert.ErrorIs(t, err, ErrCorrupt)
+		})
+	}
+
+	t.Run("unknown version", func(t *testing.T) {
+		data := corrupt(func(b []byte) []byte { b[len(magic)+1] = 99; return b })
+		_, err := Decode(bytes.NewReader(data))
+		assert.ErrorContains(t, err, "unsupported snapshot version 99")
+	})
+}
+
+func TestDecode_Version1(t *testing.T) {
+	body := []byte(`{"node_id":"node-1","entries":[{"key":"k","value":"legacy","timestamp":1}]}`)
+
+	var buf bytes.Buffer
+	buf.WriteString(magic)
+	buf.Write(binary.BigEndian.AppendUint16(nil, 1))
+	buf.Write(binary.BigEndian.AppendUint64(nil, uint64(len(body))))
+	buf.Write(binary.BigEndian.AppendUint32(nil, crc32.Checksum(body, crcTable)))
+	buf.Write(body)
+
+	got, err := Decode(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("legacy"), got.Entries["k"].Value)
+	assert.Empty(t, got.Entries["k"].ContentType)
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache.snap")
+
+	n, err := WriteFile(path, testSnapshot())
+	require.NoError(t, err)
+
+	stat, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, stat.Size(), n)
+
+	got, err := ReadFile(path)
+	require.NoError(t, err)
+	assert.Len(t, got.Entries, 3)
+
+	// No temporary files are left behind.
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestWriteFile_MissingDirectory(t *testing.T) {
+	_, err := WriteFile(filepath.Join(t.TempDir(), "missing", "cache.snap"), Snapshot{})
+	assert.Error(t, err)
+}
+
+func TestEncodeDecode_Namespaces(t *testing.T) {
+	snap := testSnapshot()
+	settings := store.NamespaceSettings{
+		DefaultTTL:     time.Minute,
+		MaxEntries:     100,
+		MaxBytes:       1 << 20,
+		EvictionPolicy: store.PolicyLFU,
+	}
+	snap.Namespaces = map[string]Namespace{
+		"orders": {Settings: settings, Entries: map[string]store.Entry{"k1": {Value: []byte("o"), Timestamp: 4}}},
+		"empty":  {Entries: map[string]store.Entry{}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, snap))
+
+	got, err := Decode(&buf)
+	require.NoError(t, err)
+
+	assert.Len(t, got.Entries, 3)
+	require.Len(t, got.Namespaces, 2)
+	assert.Equal(t, settings, got.Namespaces["orders"].Settings)
+	assert.Equal(t, []byte("o"), got.Namespaces["orders"].Entries["k1"].Value)
+	assert.Empty(t, got.Namespaces["empty"].Entries)
+}
@@ -0,0 +1,90 @@
+package store
+
+import "time"
+
+// Lookup is the result of reading one key with GetMany.
+type Lookup struct {
+	Entry Entry
+	Found bool
+
+	// Expired reports a key that was stored but had expired; it is removed.
+	Expired bool
+}
+
+// Write is one write of a SetMany batch.
+type Write struct {
+	Key   string
+	Entry Entry
+	Cond  Precondition
+}
+
+// WriteResult is the outcome of one Write: the stored entry, or the error
+// SetIf would have returned.
+type WriteResult struct {
+	Entry Entry
+	Err   error
+}
+
+// GetMany reads many keys, taking each shard's lock once, or twice when
+// a shard read under its read lock holds expired keys to remove.
+// Results are in the order of keys and follow the rules of Get.
+func (n *Namespace) GetMany(keys []string) []Lookup {
+	results := make([]Lookup, len(keys))
+	now := time.Now()
+
+	for sh, idx := range n.groupByShard(len(keys), func(i int) string { return keys[i] }) {
+		if sh.sharedReads {
+			sh.mu.RLock()
+			expired := idx[:0]
+			for _, i := range idx {
+				l, ok := sh.peekLocked(keys[i], now)
+				if !ok {
+					expired = append(expired, i)
+				}
+				results[i] = l
+			}
+			sh.mu.RUnlock()
+
+			if idx = expired; len(idx) == 0 {
+				continue
+			}
+		}
+
+		sh.mu.Lock()
+		for _, i := range idx {
+			results[i] = sh.getLocked(keys[i], now)
+		}
+		sh.mu.Unlock()
+	}
+	return results
+}
+
+// SetMany applies many writes, taking each shard's lock once.
+// Results are in the order of writes and follow the rules of SetIf.
+//
+// Every write succeeds or fails on its own: the batch is not atomic, and
+// writes to the same key are applied in order.
+func (n *Namespace) SetMany(writes []Write) []WriteResult {
+	results := make([]WriteResult, len(writes))
+
+	for sh, idx := range n.groupByShard(len(writes), func(i int) string { return writes[i].Key }) {
+		sh.mu.Lock()
+		for _, i := range idx {
+			w := writes[i]
+			results[i].Entry, results[i].Err = sh.setLocked(w.Key, w.Entry, w.Cond, true)
+		}
+		sh.mu.Unlock()
+	}
+	return results
+}
+
+// groupByShard maps each shard to the indexes of the keys it owns,
+// keeping their order.
+func (n *Namespace) groupByShard(count int, key func(i int) string) map[*shard][]int {
+	groups := make(map[*shard][]int)
+	for i := range count {
+		sh := n.shardFor(key(i))
+		groups[sh] = append(groups[sh], i)
+	}
+	return groups
+}
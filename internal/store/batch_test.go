@@ -0,0 +1,62 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"distributed-cache/internal/metrics"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreGetMany(t *testing.T) {
+	s := NewStore(metrics.NewRegistry())
+	s.Set("a", Entry{Value: []byte("1"), Timestamp: 1})
+	s.Set("b", Entry{Value: []byte("2"), Timestamp: 1})
+	s.Set("gone", Entry{Value: []byte("x"), Timestamp: 1})
+	s.Set("gone", Tombstone(2))
+	s.Set("old", Entry{Value: []byte("x"), Timestamp: 1, ExpiresAt: time.Now().Add(-time.Second)})
+
+	got := s.GetMany([]string{"b", "missing", "a", "gone", "old"})
+	require.Len(t, got, 5)
+
+	assert.True(t, got[0].Found)
+	assert.Equal(t, "2", string(got[0].Entry.Value))
+	assert.Equal(t, Lookup{}, got[1])
+	assert.Equal(t, "1", string(got[2].Entry.Value))
+	assert.Equal(t, Lookup{}, got[3], "tombstones read as missing")
+	assert.Equal(t, Lookup{Expired: true}, got[4])
+
+	assert.NotContains(t, s.Entries(), "old", "expired entries are removed")
+}
+
+func TestStoreSetMany(t *testing.T) {
+	s := NewStore(metrics.NewRegistry())
+	s.Set("locked", Entry{Value: []byte("v"), Timestamp: 1})
+
+	writes := []Write{
+		{Key: "a", Entry: Entry{Value: []byte("1"), Timestamp: 10}},
+		{Key: "locked", Entry: Entry{Value: []byte("w"), Timestamp: 10}, Cond: Precondition{IfAbsent: true}},
+		{Key: "a", Entry: Entry{Value: []byte("2"), Timestamp: 11}},
+		{Key: "a", Entry: Entry{Value: []byte("old"), Timestamp: 5}},
+	}
+	for i := range 100 {
+		writes = append(writes, Write{Key: fmt.Sprintf("k%d", i), Entry: Entry{Value: []byte("v"), Timestamp: 1}})
+	}
+
+	results := s.SetMany(writes)
+	require.Len(t, results, len(writes))
+
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, uint64(1), results[0].Entry.Version)
+	assert.ErrorIs(t, results[1].Err, ErrPreconditionFailed)
+	assert.NoError(t, results[2].Err, "writes to one key apply in order")
+	assert.Equal(t, uint64(2), results[2].Entry.Version)
+	assert.ErrorIs(t, results[3].Err, ErrStale)
+
+	got, _ := s.Get("a")
+	assert.Equal(t, "2", string(got.Value))
+	assert.Equal(t, 102, s.Len())
+}
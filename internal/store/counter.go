@@ -0,0 +1,165 @@
+package store
+
+import (
+	"errors"
+	"maps"
+	"math"
+	"strconv"
+	"time"
+)
+
+// ErrNotCounter is returned when incrementing a key that holds a plain value.
+var ErrNotCounter = errors.New("key holds a non-counter value")
+
+// ErrCounterOverflow is returned when an increment would take the node's
+// slot or the counter total outside the int64 range. A wrapped slot would
+// lose to the old one in every peer's per-node maximum, so replicas would
+// never converge.
+var ErrCounterOverflow = errors.New("increment or decrement would overflow")
+
+// Counter is a PN-counter CRDT.
+//
+// Every node only ever grows its own slots: increments go to P[node] and
+// decrements to N[node]. Replicas merge by taking the per-node maximum, so
+// concurrent updates on different nodes add up instead of overwriting each
+// other, and applying the same state twice is harmless.
+//
+// Epoch identifies the incarnation of the counter: the timestamp of the
+// delete, expired entry or plain value it replaced, or 0 for a new key.
+// Counters created over the same state merge; between epochs the newer one
+// wins, so slots left over from before a delete never come back.
+//
+// Counters are copy-on-write: a Counter held by an Entry is never mutated.
+type Counter struct {
+	Epoch int64
+	P     map[string]int64
+	N     map[string]int64
+}
+
+// Value returns the counter total: sum(P) - sum(N).
+func (c *Counter) Value() int64 {
+	total, _ := c.total()
+	return total
+}
+
+// total returns the counter total and whether it fits in an int64.
+func (c *Counter) total() (int64, bool) {
+	var total int64
+	ok := true
+	for _, n := range c.P {
+		ok = ok && total <= math.MaxInt64-n
+		total += n
+	}
+	for _, n := range c.N {
+		ok = ok && total >= math.MinInt64+n
+		total -= n
+	}
+	return total, ok
+}
+
+// add returns a copy of c with delta applied to nodeID's slot.
+//
+// Returns ErrCounterOverflow if the slot or the total would overflow.
+func (c *Counter) add(nodeID string, delta int64) (*Counter, error) {
+	next := &Counter{P: map[string]int64{}, N: map[string]int64{}}
+	if c != nil {
+		next.Epoch = c.Epoch
+		maps.Copy(next.P, c.P)
+		maps.Copy(next.N, c.N)
+	}
+
+	slots := next.P
+	if delta < 0 {
+		if delta == math.MinInt64 {
+			return nil, ErrCounterOverflow
+		}
+		slots, delta = next.N, -delta
+	}
+	if slots[nodeID] > math.MaxInt64-delta {
+		return nil, ErrCounterOverflow
+	}
+	slots[nodeID] += delta
+
+	if _, ok := next.total(); !ok {
+		return nil, ErrCounterOverflow
+	}
+	return next, nil
+}
+
+// merge returns the per-node maximum of c and other, and whether it differs
+// from c. Both must be of the same epoch.
+func (c *Counter) merge(other *Counter) (*Counter, bool) {
+	next := &Counter{Epoch: c.Epoch, P: maps.Clone(c.P), N: maps.Clone(c.N)}
+	if next.P == nil {
+		next.P = map[string]int64{}
+	}
+	if next.N == nil {
+		next.N = map[string]int64{}
+	}
+
+	changed := false
+	for node, n := range other.P {
+		if n > next.P[node] {
+			next.P[node] = n
+			changed = true
+		}
+	}
+	for node, n := range other.N {
+		if n > next.N[node] {
+			next.N[node] = n
+			changed = true
+		}
+	}
+	return next, changed
+}
+
+// size approximates the memory held by the counter's slots.
+func (c *Counter) size() int {
+	if c == nil {
+		return 0
+	}
+
+	n := 0
+	for node := range c.P {
+		n += len(node) + 8
+	}
+	for node := range c.N {
+		n += len(node) + 8
+	}
+	return n
+}
+
+// AsCounter converts a plain decimal value to a counter holding it in
+// nodeID's slot, keeping its expiry and flags. The counter's epoch is the
+// plain write's timestamp, so nodes converting the same value concurrently
+// produce counters that merge.
+//
+// Store the result with SetIf, IfMatch the plain entry's Version, so that a
+// write racing with the conversion is not lost. Returns false if the value
+// is not a 64-bit decimal integer.
+func AsCounter(entry Entry, nodeID string) (Entry, bool) {
+	n, err := strconv.ParseInt(string(entry.Value), 10, 64)
+	if err != nil {
+		return Entry{}, false
+	}
+	counter, err := (&Counter{Epoch: entry.Timestamp}).add(nodeID, n)
+	if err != nil {
+		return Entry{}, false // MinInt64 has no N slot
+	}
+
+	// Stay ahead of the plain write even if its clock ran fast.
+	timestamp := max(time.Now().UnixNano(), entry.Timestamp+1)
+	converted := counterEntry(counter, timestamp)
+	converted.ExpiresAt = entry.ExpiresAt
+	converted.Flags = entry.Flags
+	return converted, true
+}
+
+// counterEntry renders c as an entry; the value is the decimal total.
+func counterEntry(c *Counter, timestamp int64) Entry {
+	return Entry{
+		Value:     []byte(strconv.FormatInt(c.Value(), 10)),
+		Timestamp: timestamp,
+		Counter:   c,
+	}
+}
@@ -0,0 +1,258 @@
+package store
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"distributed-cache/internal/metrics"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreIncr(t *testing.T) {
+	s := NewStore(metrics.NewRegistry())
+
+	t.Run("creates and updates counters", func(t *testing.T) {
+		entry, err := s.Incr("hits", "node-1", 1, 0)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), entry.Counter.Value())
+
+		entry, err = s.Incr("hits", "node-1", 5, 0)
+		require.NoError(t, err)
+		assert.Equal(t, int64(6), entry.Counter.Value())
+
+		entry, err = s.Incr("hits", "node-1", -2, 0)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), entry.Counter.Value())
+		assert.Equal(t, uint64(3), entry.Version)
+
+		got, ok := s.Get("hits")
+		require.True(t, ok)
+		assert.Equal(t, "4", string(got.Value))
+	})
+
+	t.Run("returned entries are not mutated later", func(t *testing.T) {
+		before, err := s.Incr("snap", "node-1", 1, 0)
+		require.NoError(t, err)
+
+		_, err = s.Incr("snap", "node-1", 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), before.Counter.Value())
+	})
+
+	t.Run("plain values are rejected", func(t *testing.T) {
+		s.Set("plain", Entry{Value: []byte("7"), Timestamp: 1})
+
+		_, err := s.Incr("plain", "node-1", 1, 0)
+		assert.ErrorIs(t, err, ErrNotCounter)
+	})
+
+	t.Run("flags are kept", func(t *testing.T) {
+		s.Set("flagged", Entry{Value: []byte("1"), Counter: &Counter{P: map[string]int64{"node-1": 1}}, Flags: 7, Timestamp: 1})
+
+		entry, err := s.Incr("flagged", "node-1", 1, 0)
+		require.NoError(t, err)
+		assert.Equal(t, uint32(7), entry.Flags)
+	})
+
+	t.Run("deleted counters restart at zero", func(t *testing.T) {
+		_, err := s.Incr("restart", "node-1", 5, 0)
+		require.NoError(t, err)
+		s.Delete("restart")
+
+		entry, err := s.Incr("restart", "node-1", 1, 0)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), entry.Counter.Value())
+	})
+}
+
+func TestAsCounter(t *testing.T) {
+	plain := Entry{Value: []byte("-5"), Timestamp: 10, Flags: 3, ExpiresAt: time.Unix(1_700_000_000, 0), Version: 4}
+
+	converted, ok := AsCounter(plain, "node-1")
+	require.True(t, ok)
+	assert.Equal(t, int64(-5), converted.Counter.Value())
+	assert.Equal(t, "-5", string(converted.Value))
+	assert.Equal(t, int64(10), converted.Counter.Epoch)
+	assert.Greater(t, converted.Timestamp, plain.Timestamp)
+	assert.Equal(t, plain.Flags, converted.Flags)
+	assert.Equal(t, plain.ExpiresAt, converted.ExpiresAt)
+
+	for _, value := range []string{"", "1.5", "ten", "-9223372036854775808"} {
+		_, ok := AsCounter(Entry{Value: []byte(value)}, "node-1")
+		assert.False(t, ok, value)
+	}
+}
+
+func TestStoreIncr_TTL(t *testing.T) {
+	s := NewStore(metrics.NewRegistry())
+
+	first, err := s.Incr("window", "node-1", 1, 50*time.Millisecond)
+	require.NoError(t, err)
+	require.False(t, first.ExpiresAt.IsZero())
+
+	// Without a TTL the current expiry is kept, like a fixed rate-limit window.
+	second, err := s.Incr("window", "node-1", 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, first.ExpiresAt, second.ExpiresAt)
+
+	time.Sleep(80 * time.Millisecond)
+
+	third, err := s.Incr("window", "node-1", 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), third.Counter.Value(), "an expired counter starts over")
+	assert.True(t, third.ExpiresAt.IsZero())
+}
+
+func TestStoreIncr_Concurrent(t *testing.T) {
+	s := NewStore(metrics.NewRegistry())
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				_, _ = s.Incr("hits", "node-1", 1, 0)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, _ := s.Get("hits")
+	assert.Equal(t, int64(1000), got.Counter.Value())
+}
+
+func TestStoreCounters_ConvergeAcrossReplicas(t *testing.T) {
+	a := NewStore(metrics.NewRegistry())
+	b := NewStore(metrics.NewRegistry())
+
+	// Concurrent updates on both nodes before they hear from each other.
+	fromA, err := a.Incr("quota", "node-a", 3, 0)
+	require.NoError(t, err)
+	fromB, err := b.Incr("quota", "node-b", 4, 0)
+	require.NoError(t, err)
+	fromB, err = b.Incr("quota", "node-b", -1, 0)
+	require.NoError(t, err)
+
+	// Exchange state in both directions; LWW would drop one side.
+	assert.True(t, a.Set("quota", fromB))
+	assert.True(t, b.Set("quota", fromA))
+
+	gotA, _ := a.Get("quota")
+	gotB, _ := b.Get("quota")
+	assert.Equal(t, int64(6), gotA.Counter.Value())
+	assert.Equal(t, int64(6), gotB.Counter.Value())
+	assert.Equal(t, "6", string(gotA.Value))
+
+	t.Run("redelivery is a no-op", func(t *testing.T) {
+		assert.False(t, a.Set("quota", fromB))
+
+		got, _ := a.Get("quota")
+		assert.Equal(t, int64(6), got.Counter.Value())
+	})
+
+	t.Run("a newer plain write replaces the counter", func(t *testing.T) {
+		require.True(t, a.Set("quota", Entry{Value: []byte("reset"), Timestamp: time.Now().Add(time.Hour).UnixNano()}))
+
+		got, _ := a.Get("quota")
+		assert.Nil(t, got.Counter)
+		assert.Equal(t, "reset", string(got.Value))
+	})
+}
+
+func TestStoreCounters_DeleteAndRecreateAcrossReplicas(t *testing.T) {
+	// Node A counts to 5, deletes the counter and starts over at 1.
+	run := func(t *testing.T) (a *Store, old, tombstone, recreated Entry) {
+		a = NewStore(metrics.NewRegistry())
+
+		old, err := a.Incr("quota", "node-a", 5, 0)
+		require.NoError(t, err)
+		require.True(t, a.Delete("quota"))
+		tombstone = a.Entries()["quota"]
+		recreated, err = a.Incr("quota", "node-a", 1, 0)
+		require.NoError(t, err)
+		return a, old, tombstone, recreated
+	}
+
+	t.Run("the delete reaches the peer", func(t *testing.T) {
+		a, old, tombstone, recreated := run(t)
+		b := NewStore(metrics.NewRegistry())
+
+		assert.True(t, b.Set("quota", old))
+		assert.True(t, b.Set("quota", tombstone))
+		assert.True(t, b.Set("quota", recreated))
+
+		gotA, _ := a.Get("quota")
+		gotB, _ := b.Get("quota")
+		assert.Equal(t, int64(1), gotA.Counter.Value())
+		assert.Equal(t, int64(1), gotB.Counter.Value())
+	})
+
+	t.Run("the peer only sees the new incarnation", func(t *testing.T) {
+		a, old, _, recreated := run(t)
+		b := NewStore(metrics.NewRegistry())
+
+		assert.True(t, b.Set("quota", old))
+		assert.True(t, b.Set("quota", recreated), "a newer epoch replaces the old counter")
+
+		got, _ := b.Get("quota")
+		assert.Equal(t, int64(1), got.Counter.Value())
+
+		// Late updates of the old incarnation are dropped on both nodes.
+		assert.False(t, a.Set("quota", old))
+		assert.False(t, b.Set("quota", old))
+		got, _ = a.Get("quota")
+		assert.Equal(t, int64(1), got.Counter.Value())
+	})
+
+	t.Run("the peer increments the old incarnation", func(t *testing.T) {
+		a, old, _, recreated := run(t)
+		b := NewStore(metrics.NewRegistry())
+
+		require.True(t, b.Set("quota", old))
+		fromB, err := b.Incr("quota", "node-b", 2, 0)
+		require.NoError(t, err)
+		assert.Equal(t, old.Counter.Epoch, fromB.Counter.Epoch)
+
+		assert.False(t, a.Set("quota", fromB))
+		assert.True(t, b.Set("quota", recreated))
+
+		gotA, _ := a.Get("quota")
+		gotB, _ := b.Get("quota")
+		assert.Equal(t, int64(1), gotA.Counter.Value())
+		assert.Equal(t, int64(1), gotB.Counter.Value())
+	})
+}
+
+func TestStoreCounters_OverflowAcrossReplicas(t *testing.T) {
+	a := NewStore(metrics.NewRegistry())
+	b := NewStore(metrics.NewRegistry())
+
+	fromA, err := a.Incr("big", "node-a", math.MaxInt64-1, 0)
+	require.NoError(t, err)
+	fromA, err = a.Incr("big", "node-a", 1, 0)
+	require.NoError(t, err)
+	require.True(t, b.Set("big", fromA))
+
+	// Neither node's slot nor the total may wrap.
+	_, err = a.Incr("big", "node-a", 1, 0)
+	assert.ErrorIs(t, err, ErrCounterOverflow)
+	_, err = b.Incr("big", "node-b", 1, 0)
+	assert.ErrorIs(t, err, ErrCounterOverflow)
+	_, err = a.Incr("big", "node-a", math.MinInt64, 0)
+	assert.ErrorIs(t, err, ErrCounterOverflow)
+
+	// Decrements still apply, and both replicas agree.
+	fromB, err := b.Incr("big", "node-b", -2, 0)
+	require.NoError(t, err)
+	require.True(t, a.Set("big", fromB))
+
+	gotA, _ := a.Get("big")
+	gotB, _ := b.Get("big")
+	assert.Equal(t, int64(math.MaxInt64-2), gotA.Counter.Value())
+	assert.Equal(t, gotA.Counter.Value(), gotB.Counter.Value())
+}
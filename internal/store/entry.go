@@ -5,13 +5,38 @@ import "time"
 // Entry represents a single value stored in the cache.
 //
 // Design choices:
+// - Value holds arbitrary bytes; ContentType is the MIME type given by the writer, if any.
+// - Flags are opaque client flags stored for memcached clients; other writers leave them zero.
 // - Timestamp is used for Last-Write-Wins (LWW) conflict resolution.
+// - Counter is set for counters written by Store.Incr; Value then holds the decimal total.
+// - Version increases with every accepted write to the key, deletes included; it backs ETags and compare-and-swap.
 // - ExpiresAt enables TTL-based expiration.
 // - Zero value of ExpiresAt means "no expiration".
+// - Deleted marks a tombstone: the key was deleted at Timestamp.
+// - DeletedAt is the wall-clock time of the delete, used to purge tombstones.
+//
+// Tombstones keep taking part in LWW so a delayed older write
+// cannot resurrect a deleted key. They also carry the version forward, so a
+// recreated key does not reuse an old version until the tombstone is purged.
 type Entry struct {
-	Value     string
-	Timestamp int64
-	ExpiresAt time.Time
+	Value       []byte
+	ContentType string
+	Flags       uint32
+	Timestamp   int64
+	Version     uint64
+	Counter     *Counter
+	ExpiresAt   time.Time
+	Deleted     bool
+	DeletedAt   time.Time
+}
+
+// Tombstone returns a deletion marker for the given LWW timestamp.
+func Tombstone(timestamp int64) Entry {
+	return Entry{
+		Timestamp: timestamp,
+		Deleted:   true,
+		DeletedAt: time.Now(),
+	}
 }
 
 // IsExpired checks whether the entry is expired at the given time.
@@ -0,0 +1,88 @@
+package store
+
+import "math/rand/v2"
+
+// maxIndexLevel bounds the skip list height; with p = 1/4 it comfortably
+// covers billions of keys per shard.
+const maxIndexLevel = 16
+
+// keyIndex keeps a shard's records in key order, so Scan can seek to its
+// cursor instead of walking the whole shard.
+//
+// It is a skip list; inserts and removals are O(log n) on average.
+// Caller must hold the shard lock.
+type keyIndex struct {
+	head  indexNode
+	level int
+}
+
+type indexNode struct {
+	rec  *record
+	next []*indexNode
+}
+
+func newKeyIndex() *keyIndex {
+	return &keyIndex{
+		head:  indexNode{next: make([]*indexNode, maxIndexLevel)},
+		level: 1,
+	}
+}
+
+// path returns, for every level, the last node whose key is below key.
+func (ix *keyIndex) path(key string) [maxIndexLevel]*indexNode {
+	var update [maxIndexLevel]*indexNode
+	x := &ix.head
+	for i := ix.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].rec.key < key {
+			x = x.next[i]
+		}
+		update[i] = x
+	}
+	return update
+}
+
+// insert adds rec, whose key must not be indexed yet.
+func (ix *keyIndex) insert(rec *record) {
+	update := ix.path(rec.key)
+
+	level := randomIndexLevel()
+	for i := ix.level; i < level; i++ {
+		update[i] = &ix.head
+	}
+	ix.level = max(ix.level, level)
+
+	node := &indexNode{rec: rec, next: make([]*indexNode, level)}
+	for i := range level {
+		node.next[i] = update[i].next[i]
+		update[i].next[i] = node
+	}
+}
+
+// remove drops key, if indexed.
+func (ix *keyIndex) remove(key string) {
+	update := ix.path(key)
+
+	node := update[0].next[0]
+	if node == nil || node.rec.key != key {
+		return
+	}
+	for i := range node.next {
+		update[i].next[i] = node.next[i]
+	}
+	for ix.level > 1 && ix.head.next[ix.level-1] == nil {
+		ix.level--
+	}
+}
+
+// seek returns the first node whose key is key or above; walk on with next[0].
+func (ix *keyIndex) seek(key string) *indexNode {
+	return ix.path(key)[0].next[0]
+}
+
+func randomIndexLevel() int {
+	level := 1
+	for level < maxIndexLevel && rand.Uint32()&3 == 0 {
+		level++
+	}
+	return level
+}
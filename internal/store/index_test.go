@@ -0,0 +1,47 @@
+package store
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func indexKeys(ix *keyIndex, from string) []string {
+	var keys []string
+	for node := ix.seek(from); node != nil; node = node.next[0] {
+		keys = append(keys, node.rec.key)
+	}
+	return keys
+}
+
+func TestKeyIndex(t *testing.T) {
+	ix := newKeyIndex()
+	want := map[string]bool{}
+
+	for range 5000 {
+		key := fmt.Sprintf("k%04d", rand.IntN(1000))
+		if want[key] {
+			ix.remove(key)
+			delete(want, key)
+		} else {
+			ix.insert(&record{key: key})
+			want[key] = true
+		}
+	}
+	ix.remove("missing")
+
+	sorted := make([]string, 0, len(want))
+	for key := range want {
+		sorted = append(sorted, key)
+	}
+	slices.Sort(sorted)
+
+	assert.Equal(t, sorted, indexKeys(ix, ""))
+
+	from := slices.IndexFunc(sorted, func(key string) bool { return key >= "k0500" })
+	assert.Equal(t, sorted[from:], indexKeys(ix, "k0500"))
+	assert.Empty(t, indexKeys(ix, "l"))
+}
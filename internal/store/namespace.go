@@ -0,0 +1,397 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"time"
+
+	"distributed-cache/internal/metrics"
+)
+
+// DefaultNamespace holds the keys written without a namespace.
+// An empty namespace name always refers to it.
+const DefaultNamespace = "default"
+
+// maxNamespaceLen bounds namespace names, which appear in URLs and metric labels.
+const maxNamespaceLen = 64
+
+var (
+	// ErrNamespaceExists is returned when creating a namespace that already exists.
+	ErrNamespaceExists = errors.New("namespace already exists")
+
+	// ErrInvalidNamespace is returned for names outside [a-z0-9_-]{1,64}.
+	ErrInvalidNamespace = errors.New("namespace name must be 1-64 characters of a-z, 0-9, '_' or '-'")
+)
+
+// NamespaceSettings are the per-namespace rules.
+// Zero limits mean unbounded; an empty EvictionPolicy uses the store's.
+type NamespaceSettings struct {
+	// DefaultTTL applies to writes that carry no expiry; zero means none.
+	DefaultTTL time.Duration
+
+	MaxEntries     int
+	MaxBytes       int64
+	EvictionPolicy string
+}
+
+// Validate reports the first invalid setting.
+func (s NamespaceSettings) Validate() error {
+	switch {
+	case s.DefaultTTL < 0:
+		return errors.New("default TTL must be >= 0")
+	case s.MaxEntries < 0:
+		return errors.New("max entries must be >= 0")
+	case s.MaxBytes < 0:
+		return errors.New("max bytes must be >= 0")
+	}
+	if s.EvictionPolicy != "" {
+		if _, err := LookupPolicy(s.EvictionPolicy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidNamespace reports whether name is a valid namespace name.
+func ValidNamespace(name string) bool {
+	if name == "" || len(name) > maxNamespaceLen {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// Namespace is an isolated keyspace of a Store with its own limits,
+// eviction policy, default TTL and metrics.
+//
+// The same key in two namespaces names two unrelated entries. Metrics are
+// recorded both store-wide and labeled with the namespace, e.g.
+// cache_sets_total{namespace="orders"}.
+type Namespace struct {
+	name     string
+	settings NamespaceSettings
+	shards   []*shard
+	seed     maphash.Seed
+	metrics  namespaceMetrics
+}
+
+// newNamespace builds a namespace of up to shardCount shards.
+// Settings must be valid; newPolicy is used when they name no policy.
+func newNamespace(
+	name string,
+	settings NamespaceSettings,
+	shardCount int,
+	newPolicy func() EvictionPolicy,
+	registry *metrics.Registry,
+	events *watchHub,
+	onWrite func(namespace, key string, entry Entry),
+) *Namespace {
+	if settings.EvictionPolicy != "" {
+		newPolicy, _ = LookupPolicy(settings.EvictionPolicy)
+	}
+
+	// Small limits use fewer shards so that each shard still holds a
+	// useful number of entries and eviction stays close to namespace-wide.
+	if settings.MaxEntries > 0 {
+		shardCount = max(1, min(shardCount, settings.MaxEntries/minShardEntries))
+	}
+	if settings.MaxBytes > 0 {
+		shardCount = max(1, min(shardCount, int(settings.MaxBytes/minShardBytes)))
+	}
+
+	var hook func(key string, entry Entry)
+	if onWrite != nil {
+		hook = func(key string, entry Entry) { onWrite(name, key, entry) }
+	}
+
+	n := &Namespace{
+		name:     name,
+		settings: settings,
+		shards:   make([]*shard, shardCount),
+		seed:     maphash.MakeSeed(),
+		metrics:  newNamespaceMetrics(registry, name),
+	}
+	for i := range n.shards {
+		policy := newPolicy()
+		n.shards[i] = &shard{
+			namespace:  name,
+			data:       make(map[string]*record),
+			index:      newKeyIndex(),
+			metrics:    n.metrics,
+			events:     events,
+			onWrite:    hook,
+			policy:     policy,
+			defaultTTL: settings.DefaultTTL,
+			maxEntries: int(splitLimit(int64(settings.MaxEntries), shardCount, i)),
+			maxBytes:   splitLimit(settings.MaxBytes, shardCount, i),
+
+			sharedReads: (settings.MaxEntries == 0 && settings.MaxBytes == 0) || ignoresAccess(policy),
+		}
+	}
+	return n
+}
+
+// Name returns the namespace name.
+func (n *Namespace) Name() string {
+	return n.name
+}
+
+// Settings returns the rules the namespace was created with.
+func (n *Namespace) Settings() NamespaceSettings {
+	return n.settings
+}
+
+// shardFor returns the shard owning key.
+func (n *Namespace) shardFor(key string) *shard {
+	if len(n.shards) == 1 {
+		return n.shards[0]
+	}
+	return n.shards[maphash.String(n.seed, key)%uint64(len(n.shards))]
+}
+
+// Set inserts or updates a key using Last-Write-Wins semantics.
+//
+// Rules:
+// - If the key does not exist, insert it.
+// - If the key exists, overwrite only if the incoming timestamp is newer.
+// - Tombstones (entry.Deleted) follow the same rules.
+// - A counter merges into a live stored counter instead (see Incr).
+// - A live entry without an expiry gets the namespace's default TTL, if any.
+//
+// An older write therefore never revives a deleted key.
+//
+// Every accepted write bumps the key's Version past the stored one;
+// an entry that already carries a higher Version (from replication) keeps it.
+//
+// Accepted writes are reported to the eviction policy and may evict
+// other entries of the same shard to stay within the configured limits.
+// A rejected (stale) write is not reported.
+//
+// Returns false when the write was rejected as stale.
+func (n *Namespace) Set(key string, entry Entry) bool {
+	_, err := n.shardFor(key).set(key, entry, Precondition{}, true)
+	return err == nil
+}
+
+// SetIf is Set guarded by a precondition, checked atomically with the write.
+//
+// Returns the stored entry with its assigned Version, ErrPreconditionFailed
+// when cond does not hold, or ErrStale when the write is rejected by LWW.
+func (n *Namespace) SetIf(key string, entry Entry, cond Precondition) (Entry, error) {
+	return n.shardFor(key).set(key, entry, cond, true)
+}
+
+// Incr atomically adds delta (negative to decrement) to the counter at key
+// and returns the stored entry.
+//
+// Behavior:
+// - A missing, expired or deleted key starts a new counter at zero
+// - nodeID names the writer's slot in the PN-counter (see Counter)
+// - ttl > 0 resets the expiry; otherwise the counter keeps its current one
+// - Returns ErrNotCounter if the key holds a plain value
+// - Returns ErrCounterOverflow if this node's slot or the total would leave the int64 range
+//
+// Replicated counter entries passed to Set are merged rather than
+// overwritten, so replicas converge on the same total.
+func (n *Namespace) Incr(key, nodeID string, delta int64, ttl time.Duration) (Entry, error) {
+	return n.shardFor(key).incr(key, nodeID, delta, ttl)
+}
+
+// Expire sets the expiry of a live key, keeping its value, and returns
+// the stored entry. It is a write like Set: it bumps the Version and
+// Timestamp, so the returned entry can be replicated as is.
+//
+// A zero expiresAt removes the expiry; the namespace's default TTL, if
+// any, then applies again. Returns false when the key is not present.
+func (n *Namespace) Expire(key string, expiresAt time.Time) (Entry, bool) {
+	return n.shardFor(key).expire(key, expiresAt)
+}
+
+// Restore applies an entry recovered from persistence.
+// It follows the same LWW rules as Set but is not reported to the write hook,
+// so replaying a log or snapshot does not write it again.
+func (n *Namespace) Restore(key string, entry Entry) bool {
+	_, err := n.shardFor(key).set(key, entry, Precondition{}, false)
+	return err == nil
+}
+
+// Get retrieves an entry from the namespace.
+//
+// Behavior:
+// - Returns (entry, true) if key exists and is not expired
+// - If the key is expired, it is deleted and treated as missing
+// - A hit is reported to the eviction policy
+//
+// Reads of a bounded namespace whose policy tracks accesses (LRU, LFU,
+// W-TinyLFU, TTL) update the policy, so they run one at a time per shard.
+// Unbounded namespaces and the random policy serve reads under a shared lock.
+func (n *Namespace) Get(key string) (Entry, bool) {
+	return n.shardFor(key).get(key)
+}
+
+// Delete removes a key by writing a tombstone stamped with the current time.
+//
+// Callers that need to replicate the delete should build the tombstone
+// with Tombstone and pass it to Set, so every node records the same timestamp.
+func (n *Namespace) Delete(key string) bool {
+	return n.Set(key, Tombstone(time.Now().UnixNano()))
+}
+
+// Flush deletes every live key and returns the tombstones it wrote,
+// so the caller can replicate them.
+//
+// Keys written while Flush runs may survive it.
+func (n *Namespace) Flush() []Write {
+	now := time.Now().UnixNano()
+
+	var writes []Write
+	for key := range n.List() {
+		writes = append(writes, Write{Key: key, Entry: Tombstone(now)})
+	}
+
+	flushed := writes[:0]
+	for i, res := range n.SetMany(writes) {
+		if res.Err == nil {
+			flushed = append(flushed, Write{Key: writes[i].Key, Entry: res.Entry})
+		}
+	}
+	return flushed
+}
+
+// List returns a snapshot of all non-expired, non-deleted entries.
+//
+// Shards are visited one at a time, so writes racing with List may or
+// may not be included.
+func (n *Namespace) List() map[string]Entry {
+	now := time.Now()
+	result := make(map[string]Entry)
+
+	for _, sh := range n.shards {
+		sh.list(now, result)
+	}
+	return result
+}
+
+// Entries returns a snapshot of all non-expired entries, tombstones included.
+// Used for persistence, where tombstones must survive a restart.
+func (n *Namespace) Entries() map[string]Entry {
+	now := time.Now()
+	result := make(map[string]Entry)
+
+	for _, sh := range n.shards {
+		sh.entries(now, result)
+	}
+	return result
+}
+
+// Len returns the number of live (non-expired, non-deleted) keys.
+func (n *Namespace) Len() int {
+	now := time.Now()
+	count := 0
+
+	for _, sh := range n.shards {
+		count += sh.len(now)
+	}
+	return count
+}
+
+// Bytes returns the approximate memory used by stored entries.
+func (n *Namespace) Bytes() int64 {
+	var total int64
+	for _, sh := range n.shards {
+		total += sh.size()
+	}
+	return total
+}
+
+// RemoveExpired removes all expired keys from the namespace.
+func (n *Namespace) RemoveExpired() int {
+	now := time.Now()
+	removed := 0
+
+	for _, sh := range n.shards {
+		removed += sh.removeExpired(now)
+	}
+
+	if removed > 0 {
+		n.metrics.Add(metrics.CacheExpiredTotal, int64(removed))
+	}
+
+	return removed
+}
+
+// PurgeTombstones removes tombstones recorded more than grace ago.
+func (n *Namespace) PurgeTombstones(grace time.Duration) int {
+	cutoff := time.Now().Add(-grace)
+	purged := 0
+
+	for _, sh := range n.shards {
+		purged += sh.purgeTombstones(cutoff)
+	}
+
+	return purged
+}
+
+// Metrics returns the namespace's labeled metrics, keyed without the label.
+func (n *Namespace) Metrics() map[string]int64 {
+	snap := n.metrics.registry.Snapshot()
+
+	out := make(map[string]int64, len(n.metrics.labeled))
+	for key, labeled := range n.metrics.labeled {
+		if v, ok := snap[string(labeled)]; ok {
+			out[string(key)] = v
+		}
+	}
+	return out
+}
+
+/* ---------------- Metrics ---------------- */
+
+// namespaceMetricKeys are the metrics a namespace records per label.
+var namespaceMetricKeys = []metrics.MetricKey{
+	metrics.CacheKeysTotal,
+	metrics.CacheSetsTotal,
+	metrics.CacheDeletesTotal,
+	metrics.CacheGetsTotal,
+	metrics.CacheMissesTotal,
+	metrics.CacheExpiredTotal,
+	metrics.CachePreconditionFailedTotal,
+	metrics.CacheEvictionsTotal,
+	metrics.CacheBytes,
+}
+
+// namespaceMetrics records every metric both store-wide and labeled
+// with the namespace. Labeled keys are built once, off the hot path.
+type namespaceMetrics struct {
+	registry *metrics.Registry
+	labeled  map[metrics.MetricKey]metrics.MetricKey
+}
+
+func newNamespaceMetrics(registry *metrics.Registry, name string) namespaceMetrics {
+	labeled := make(map[metrics.MetricKey]metrics.MetricKey, len(namespaceMetricKeys))
+	for _, key := range namespaceMetricKeys {
+		labeled[key] = metrics.Labeled(key, "namespace", name)
+	}
+	return namespaceMetrics{registry: registry, labeled: labeled}
+}
+
+func (m namespaceMetrics) Inc(key metrics.MetricKey) {
+	m.Add(key, 1)
+}
+
+func (m namespaceMetrics) Add(key metrics.MetricKey, delta int64) {
+	m.registry.Add(key, delta)
+	if labeled, ok := m.labeled[key]; ok {
+		m.registry.Add(labeled, delta)
+	}
+}
+
+// namespaceError wraps err with the namespace it concerns.
+func namespaceError(name string, err error) error {
+	return fmt.Errorf("namespace %q: %w", name, err)
+}
@@ -0,0 +1,199 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"distributed-cache/internal/metrics"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespaces_IsolateKeys(t *testing.T) {
+	s := NewStore(metrics.NewRegistry())
+
+	orders, err := s.CreateNamespace("orders", NamespaceSettings{})
+	require.NoError(t, err)
+
+	s.Set("k", Entry{Value: []byte("default"), Timestamp: 1})
+	orders.Set("k", Entry{Value: []byte("orders"), Timestamp: 1})
+
+	got, ok := s.Get("k")
+	require.True(t, ok)
+	assert.Equal(t, "default", string(got.Value))
+
+	got, ok = orders.Get("k")
+	require.True(t, ok)
+	assert.Equal(t, "orders", string(got.Value))
+
+	orders.Delete("k")
+	_, ok = s.Get("k")
+	assert.True(t, ok, "deleting in one namespace leaves the other alone")
+	assert.Equal(t, 1, s.Len(), "Len counts every namespace")
+}
+
+func TestCreateNamespace_Errors(t *testing.T) {
+	s := NewStore(metrics.NewRegistry())
+
+	_, err := s.CreateNamespace("orders", NamespaceSettings{})
+	require.NoError(t, err)
+
+	_, err = s.CreateNamespace("orders", NamespaceSettings{})
+	assert.ErrorIs(t, err, ErrNamespaceExists)
+
+	_, err = s.CreateNamespace(DefaultNamespace, NamespaceSettings{})
+	assert.ErrorIs(t, err, ErrNamespaceExists)
+
+	for _, name := range []string{"", "Orders", "a/b", "a b", string(make([]byte, maxNamespaceLen+1))} {
+		_, err = s.CreateNamespace(name, NamespaceSettings{})
+		assert.ErrorIs(t, err, ErrInvalidNamespace, "name %q", name)
+	}
+
+	_, err = s.CreateNamespace("bad", NamespaceSettings{EvictionPolicy: "fifo"})
+	assert.Error(t, err)
+	_, err = s.CreateNamespace("bad", NamespaceSettings{MaxEntries: -1})
+	assert.Error(t, err)
+	_, ok := s.Namespace("bad")
+	assert.False(t, ok, "invalid settings create nothing")
+}
+
+func TestNamespace_Lookup(t *testing.T) {
+	s := NewStore(metrics.NewRegistry())
+
+	def, ok := s.Namespace("")
+	require.True(t, ok)
+	assert.Same(t, s.Default(), def)
+	assert.Equal(t, DefaultNamespace, def.Name())
+
+	_, ok = s.Namespace("missing")
+	assert.False(t, ok)
+
+	created, err := s.EnsureNamespace("sessions", NamespaceSettings{MaxEntries: 10})
+	require.NoError(t, err)
+	again, err := s.EnsureNamespace("sessions", NamespaceSettings{})
+	require.NoError(t, err)
+	assert.Same(t, created, again)
+	assert.Equal(t, 10, again.Settings().MaxEntries, "existing settings are kept")
+
+	_, err = s.CreateNamespace("archive", NamespaceSettings{})
+	require.NoError(t, err)
+
+	var names []string
+	for _, n := range s.Namespaces() {
+		names = append(names, n.Name())
+	}
+	assert.Equal(t, []string{"archive", DefaultNamespace, "sessions"}, names)
+}
+
+func TestNamespace_DefaultTTL(t *testing.T) {
+	s := NewStore(metrics.NewRegistry())
+	ns, err := s.CreateNamespace("sessions", NamespaceSettings{DefaultTTL: 50 * time.Millisecond})
+	require.NoError(t, err)
+
+	explicit := time.Now().Add(time.Hour)
+	ns.Set("short", Entry{Value: []byte("v"), Timestamp: 1})
+	ns.Set("long", Entry{Value: []byte("v"), Timestamp: 1, ExpiresAt: explicit})
+	_, err = ns.Incr("hits", "node-1", 1, 0)
+	require.NoError(t, err)
+
+	got, _ := ns.Get("long")
+	assert.True(t, got.ExpiresAt.Equal(explicit), "an explicit expiry wins")
+
+	time.Sleep(80 * time.Millisecond)
+
+	_, ok := ns.Get("short")
+	assert.False(t, ok)
+	_, ok = ns.Get("hits")
+	assert.False(t, ok, "counters get the default TTL too")
+	_, ok = ns.Get("long")
+	assert.True(t, ok)
+}
+
+func TestNamespace_OwnLimitsAndPolicy(t *testing.T) {
+	s := NewStore(metrics.NewRegistry(), WithMaxEntries(1000))
+	ns, err := s.CreateNamespace("small", NamespaceSettings{MaxEntries: 2, EvictionPolicy: PolicyLFU})
+	require.NoError(t, err)
+
+	ns.Set("hot", Entry{Value: []byte("v"), Timestamp: 1})
+	for range 3 {
+		ns.Get("hot")
+	}
+	ns.Set("a", Entry{Value: []byte("v"), Timestamp: 1})
+	ns.Set("b", Entry{Value: []byte("v"), Timestamp: 1})
+
+	assert.Equal(t, 2, ns.Len())
+	_, ok := ns.Get("hot")
+	assert.True(t, ok, "LFU keeps the frequently read key")
+
+	for i := range 10 {
+		s.Set(fmt.Sprintf("k%d", i), Entry{Value: []byte("v"), Timestamp: 1})
+	}
+	assert.Equal(t, 10, s.Default().Len(), "the default namespace keeps its own limit")
+}
+
+func TestNamespace_LabeledMetrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+	s := NewStore(reg)
+	ns, err := s.CreateNamespace("orders", NamespaceSettings{})
+	require.NoError(t, err)
+
+	ns.Set("a", Entry{Value: []byte("v"), Timestamp: 1})
+	ns.Set("b", Entry{Value: []byte("v"), Timestamp: 1})
+	s.Set("c", Entry{Value: []byte("v"), Timestamp: 1})
+	ns.Get("missing")
+
+	snap := reg.Snapshot()
+	assert.Equal(t, int64(3), snap[string(metrics.CacheSetsTotal)])
+	assert.Equal(t, int64(2), snap[`cache_sets_total{namespace="orders"}`])
+	assert.Equal(t, int64(1), snap[`cache_sets_total{namespace="default"}`])
+
+	own := ns.Metrics()
+	assert.Equal(t, int64(2), own[string(metrics.CacheSetsTotal)])
+	assert.Equal(t, int64(2), own[string(metrics.CacheKeysTotal)])
+	assert.Equal(t, int64(1), own[string(metrics.CacheMissesTotal)])
+	assert.Equal(t, ns.Bytes(), own[string(metrics.CacheBytes)])
+}
+
+func TestNamespace_Flush(t *testing.T) {
+	s := NewStore(metrics.NewRegistry())
+	ns, err := s.CreateNamespace("orders", NamespaceSettings{})
+	require.NoError(t, err)
+
+	ns.Set("a", Entry{Value: []byte("v"), Timestamp: 1})
+	ns.Set("b", Entry{Value: []byte("v"), Timestamp: 1})
+	ns.Delete("gone")
+	s.Set("a", Entry{Value: []byte("v"), Timestamp: 1})
+
+	flushed := ns.Flush()
+	require.Len(t, flushed, 2, "only live keys are flushed")
+	for _, w := range flushed {
+		assert.True(t, w.Entry.Deleted)
+		assert.Equal(t, uint64(2), w.Entry.Version)
+	}
+
+	assert.Equal(t, 0, ns.Len())
+	assert.Len(t, ns.Entries(), 3, "tombstones are kept for replication")
+	_, ok := s.Get("a")
+	assert.True(t, ok, "other namespaces are untouched")
+
+	assert.Empty(t, ns.Flush())
+}
+
+func TestStoreWriteHook_ReportsNamespace(t *testing.T) {
+	type write struct{ namespace, key string }
+	var seen []write
+
+	s := NewStore(metrics.NewRegistry(), WithWriteHook(func(namespace, key string, _ Entry) {
+		seen = append(seen, write{namespace, key})
+	}))
+	ns, err := s.CreateNamespace("orders", NamespaceSettings{})
+	require.NoError(t, err)
+
+	s.Set("a", Entry{Value: []byte("v"), Timestamp: 1})
+	ns.Set("b", Entry{Value: []byte("v"), Timestamp: 1})
+	ns.Restore("c", Entry{Value: []byte("v"), Timestamp: 1})
+
+	assert.Equal(t, []write{{DefaultNamespace, "a"}, {"orders", "b"}}, seen)
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EvictionPolicy decides which key a bounded Store evicts next.
+//
+// The Store reports every change to its key set and every read hit,
+// then asks for victims while it is over its limits.
+//
+// Design principles:
+// - Policies only track keys; the Store owns entries and accounting
+// - Policies are not safe for concurrent use; the Store calls them under its lock
+// - Victim must return a key that was inserted and not yet removed
+type EvictionPolicy interface {
+	// Insert records a key that was just added to the store.
+	Insert(key string, entry Entry)
+
+	// Update records an accepted overwrite of an existing key.
+	Update(key string, entry Entry)
+
+	// Access records a read hit.
+	Access(key string)
+
+	// Remove forgets a key that left the store for any reason.
+	Remove(key string)
+
+	// Victim returns the key to evict next, or false if no key is tracked.
+	// The Store removes the victim and reports it through Remove.
+	Victim() (string, bool)
+}
+
+// accessIgnorer is implemented by policies whose Access does nothing.
+// Their reads leave the shard unchanged and share its lock.
+type accessIgnorer interface {
+	ignoresAccess()
+}
+
+func ignoresAccess(p EvictionPolicy) bool {
+	_, ok := p.(accessIgnorer)
+	return ok
+}
+
+// Eviction policy names accepted by LookupPolicy and NewPolicy.
+const (
+	PolicyLRU     = "lru"
+	PolicyLFU     = "lfu"
+	PolicyTinyLFU = "tinylfu"
+	PolicyRandom  = "random"
+	PolicyTTL     = "ttl"
+)
+
+var policies = map[string]func() EvictionPolicy{
+	PolicyLRU:     NewLRUPolicy,
+	PolicyLFU:     NewLFUPolicy,
+	PolicyTinyLFU: NewTinyLFUPolicy,
+	PolicyRandom:  NewRandomPolicy,
+	PolicyTTL:     NewTTLPolicy,
+}
+
+// LookupPolicy returns the constructor of an eviction policy by name
+// (case-insensitive), suitable for WithEvictionPolicy.
+func LookupPolicy(name string) (func() EvictionPolicy, error) {
+	newPolicy, ok := policies[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown eviction policy %q (want one of %s)",
+			name, strings.Join(PolicyNames(), ", "))
+	}
+	return newPolicy, nil
+}
+
+// NewPolicy returns a fresh eviction policy by name (case-insensitive).
+func NewPolicy(name string) (EvictionPolicy, error) {
+	newPolicy, err := LookupPolicy(name)
+	if err != nil {
+		return nil, err
+	}
+	return newPolicy(), nil
+}
+
+// PolicyNames lists the accepted eviction policy names.
+func PolicyNames() []string {
+	names := make([]string, 0, len(policies))
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
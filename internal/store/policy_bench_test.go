@@ -0,0 +1,152 @@
+package store
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"distributed-cache/internal/metrics"
+)
+
+// Hit-rate benchmarks replay access traces against a bounded Store:
+// every miss is followed by a Set, as a read-through cache would do.
+//
+// Run them with:
+//
+//	go test ./internal/store -run '^$' -bench HitRate
+//
+// The hit% metric is what matters; ns/op is the cost of one full replay.
+// Trace keys carry no TTL, so ttl-first behaves like LRU here.
+//
+// A recorded trace (one key per line, extra fields ignored) can be added
+// with CACHE_TRACE_FILE=path; CACHE_TRACE_CAPACITY sets its cache size.
+
+type trace struct {
+	name     string
+	capacity int
+	keys     []string
+}
+
+const (
+	traceLength   = 200_000
+	traceKeySpace = 20_000
+	traceCapacity = 1_000
+)
+
+// zipfTrace is a skewed workload with a stable set of popular keys.
+func zipfTrace(seed uint64) []string {
+	r := rand.New(rand.NewPCG(seed, seed))
+	zipf := rand.NewZipf(r, 1.1, 1, traceKeySpace-1)
+
+	keys := make([]string, traceLength)
+	for i := range keys {
+		keys[i] = "k" + strconv.FormatUint(zipf.Uint64(), 10)
+	}
+	return keys
+}
+
+// scanTrace interleaves a skewed workload with one-off sequential scans,
+// like a batch job reading through the key space.
+func scanTrace(seed uint64) []string {
+	keys := zipfTrace(seed)
+	scanned := 0
+	for i := 0; i+2*traceCapacity < len(keys); i += 10 * traceCapacity {
+		for j := range 2 * traceCapacity {
+			keys[i+j] = "scan" + strconv.Itoa(scanned)
+			scanned++
+		}
+	}
+	return keys
+}
+
+// loopTrace cycles over slightly more keys than fit, the worst case for LRU.
+func loopTrace() []string {
+	keys := make([]string, traceLength)
+	for i := range keys {
+		keys[i] = "k" + strconv.Itoa(i%(traceCapacity+traceCapacity/4))
+	}
+	return keys
+}
+
+func loadTraces(tb testing.TB) []trace {
+	traces := []trace{
+		{"zipf", traceCapacity, zipfTrace(1)},
+		{"zipf+scan", traceCapacity, scanTrace(2)},
+		{"loop", traceCapacity, loopTrace()},
+	}
+
+	path := os.Getenv("CACHE_TRACE_FILE")
+	if path == "" {
+		return traces
+	}
+
+	capacity := traceCapacity
+	if v := os.Getenv("CACHE_TRACE_CAPACITY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			tb.Fatalf("invalid CACHE_TRACE_CAPACITY %q", v)
+		}
+		capacity = n
+	}
+
+	keys, err := readTrace(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return append(traces, trace{"recorded", capacity, keys})
+}
+
+func readTrace(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var keys []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+			keys = append(keys, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read trace %s: %w", path, err)
+	}
+	return keys, nil
+}
+
+// replay returns the hit rate of a policy on a trace.
+func replay(policy string, tr trace) float64 {
+	newPolicy, _ := LookupPolicy(policy)
+	s := NewStore(metrics.NewRegistry(),
+		WithShards(1), WithMaxEntries(tr.capacity), WithEvictionPolicy(newPolicy))
+
+	hits := 0
+	for _, key := range tr.keys {
+		if _, ok := s.Get(key); ok {
+			hits++
+			continue
+		}
+		s.Set(key, Entry{Value: []byte("v"), Timestamp: 1})
+	}
+	return float64(hits) / float64(len(tr.keys))
+}
+
+func BenchmarkEvictionHitRate(b *testing.B) {
+	for _, tr := range loadTraces(b) {
+		for _, policy := range PolicyNames() {
+			b.Run(tr.name+"/"+policy, func(b *testing.B) {
+				var rate float64
+				for b.Loop() {
+					rate = replay(policy, tr)
+				}
+				b.ReportMetric(100*rate, "hit%")
+			})
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package store
+
+import "container/heap"
+
+// lfuPolicy evicts the least frequently used key,
+// breaking ties by least recent use.
+type lfuPolicy struct {
+	items map[string]*lfuItem
+	heap  lfuHeap
+	tick  uint64
+}
+
+type lfuItem struct {
+	key   string
+	freq  uint64
+	tick  uint64
+	index int
+}
+
+// NewLFUPolicy returns a least-frequently-used eviction policy.
+// Frequencies are never aged, so it suits stable popularity distributions.
+func NewLFUPolicy() EvictionPolicy {
+	return &lfuPolicy{items: make(map[string]*lfuItem)}
+}
+
+func (p *lfuPolicy) Insert(key string, _ Entry) {
+	p.tick++
+	item := &lfuItem{key: key, freq: 1, tick: p.tick}
+	p.items[key] = item
+	heap.Push(&p.heap, item)
+}
+
+func (p *lfuPolicy) Update(key string, _ Entry) {
+	p.Access(key)
+}
+
+func (p *lfuPolicy) Access(key string) {
+	item, ok := p.items[key]
+	if !ok {
+		return
+	}
+	p.tick++
+	item.freq++
+	item.tick = p.tick
+	heap.Fix(&p.heap, item.index)
+}
+
+func (p *lfuPolicy) Remove(key string) {
+	if item, ok := p.items[key]; ok {
+		heap.Remove(&p.heap, item.index)
+		delete(p.items, key)
+	}
+}
+
+func (p *lfuPolicy) Victim() (string, bool) {
+	if len(p.heap) == 0 {
+		return "", false
+	}
+	return p.heap[0].key, true
+}
+
+// lfuHeap is a min-heap ordered by (freq, tick).
+type lfuHeap []*lfuItem
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].tick < h[j].tick
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x any) {
+	item := x.(*lfuItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *lfuHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,52 @@
+package store
+
+import "container/list"
+
+// lruPolicy evicts the least recently used key.
+type lruPolicy struct {
+	// order runs from most (front) to least (back) recently used.
+	order *list.List
+	elems map[string]*list.Element
+}
+
+// NewLRUPolicy returns a least-recently-used eviction policy.
+// Inserts, accepted overwrites and read hits all count as a use.
+func NewLRUPolicy() EvictionPolicy {
+	return newLRUPolicy()
+}
+
+func newLRUPolicy() *lruPolicy {
+	return &lruPolicy{
+		order: list.New(),
+		elems: make(map[string]*list.Element),
+	}
+}
+
+func (p *lruPolicy) Insert(key string, _ Entry) {
+	p.elems[key] = p.order.PushFront(key)
+}
+
+func (p *lruPolicy) Update(key string, _ Entry) {
+	p.Access(key)
+}
+
+func (p *lruPolicy) Access(key string) {
+	if elem, ok := p.elems[key]; ok {
+		p.order.MoveToFront(elem)
+	}
+}
+
+func (p *lruPolicy) Remove(key string) {
+	if elem, ok := p.elems[key]; ok {
+		p.order.Remove(elem)
+		delete(p.elems, key)
+	}
+}
+
+func (p *lruPolicy) Victim() (string, bool) {
+	back := p.order.Back()
+	if back == nil {
+		return "", false
+	}
+	return back.Value.(string), true
+}
@@ -0,0 +1,46 @@
+package store
+
+import "math/rand/v2"
+
+// randomPolicy evicts a uniformly random key.
+type randomPolicy struct {
+	keys  []string
+	index map[string]int
+}
+
+// NewRandomPolicy returns a random eviction policy.
+// It keeps no usage history, so reads cost nothing.
+func NewRandomPolicy() EvictionPolicy {
+	return &randomPolicy{index: make(map[string]int)}
+}
+
+func (p *randomPolicy) Insert(key string, _ Entry) {
+	p.index[key] = len(p.keys)
+	p.keys = append(p.keys, key)
+}
+
+func (p *randomPolicy) Update(string, Entry) {}
+
+func (p *randomPolicy) Access(string) {}
+
+func (p *randomPolicy) ignoresAccess() {}
+
+func (p *randomPolicy) Remove(key string) {
+	i, ok := p.index[key]
+	if !ok {
+		return
+	}
+
+	last := len(p.keys) - 1
+	p.keys[i] = p.keys[last]
+	p.index[p.keys[i]] = i
+	p.keys = p.keys[:last]
+	delete(p.index, key)
+}
+
+func (p *randomPolicy) Victim() (string, bool) {
+	if len(p.keys) == 0 {
+		return "", false
+	}
+	return p.keys[rand.IntN(len(p.keys))], true
+}
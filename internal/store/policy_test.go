@@ -0,0 +1,189 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"distributed-cache/internal/metrics"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPolicy(t *testing.T) {
+	assert.Equal(t, []string{"lfu", "lru", "random", "tinylfu", "ttl"}, PolicyNames())
+
+	p, err := NewPolicy("TinyLFU")
+	require.NoError(t, err)
+	assert.IsType(t, &tinyLFUPolicy{}, p)
+
+	_, err = NewPolicy("fifo")
+	assert.ErrorContains(t, err, `unknown eviction policy "fifo"`)
+}
+
+func TestPolicies_TrackInsertedKeys(t *testing.T) {
+	for _, name := range PolicyNames() {
+		t.Run(name, func(t *testing.T) {
+			p, err := NewPolicy(name)
+			require.NoError(t, err)
+
+			_, ok := p.Victim()
+			assert.False(t, ok, "empty policy has no victim")
+
+			keys := map[string]bool{"a": true, "b": true, "c": true}
+			for key := range keys {
+				p.Insert(key, Entry{Timestamp: 1})
+			}
+			p.Access("a")
+			p.Update("b", Entry{Timestamp: 2})
+			p.Remove("missing")
+
+			for range len(keys) {
+				victim, ok := p.Victim()
+				require.True(t, ok)
+				require.True(t, keys[victim], "victim %q is tracked", victim)
+
+				delete(keys, victim)
+				p.Remove(victim)
+			}
+
+			_, ok = p.Victim()
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestLRUPolicy(t *testing.T) {
+	p := NewLRUPolicy()
+	p.Insert("a", Entry{})
+	p.Insert("b", Entry{})
+	p.Insert("c", Entry{})
+	p.Access("a")
+	p.Update("b", Entry{})
+
+	assert.Equal(t, []string{"c", "a", "b"}, drain(p))
+}
+
+func TestLFUPolicy(t *testing.T) {
+	p := NewLFUPolicy()
+	p.Insert("a", Entry{})
+	p.Insert("b", Entry{})
+	p.Insert("c", Entry{})
+	p.Access("a")
+	p.Access("a")
+	p.Access("c")
+
+	// b was used once; c and a more often. Ties go to the least recent.
+	assert.Equal(t, []string{"b", "c", "a"}, drain(p))
+}
+
+func TestTTLPolicy(t *testing.T) {
+	now := time.Now()
+	p := NewTTLPolicy()
+
+	p.Insert("forever-1", Entry{})
+	p.Insert("late", Entry{ExpiresAt: now.Add(time.Hour)})
+	p.Insert("forever-2", Entry{})
+	p.Insert("soon", Entry{ExpiresAt: now.Add(time.Minute)})
+	p.Insert("extended", Entry{ExpiresAt: now.Add(time.Second)})
+	p.Access("forever-1")
+
+	// An overwrite may extend or drop the TTL.
+	p.Update("extended", Entry{ExpiresAt: now.Add(2 * time.Hour)})
+
+	assert.Equal(t, []string{"soon", "late", "extended", "forever-2", "forever-1"}, drain(p))
+}
+
+func TestTinyLFUPolicy_RejectsOneHitWonders(t *testing.T) {
+	store := NewStore(metrics.NewRegistry(), WithMaxEntries(100), WithEvictionPolicy(NewTinyLFUPolicy))
+
+	for i := range 100 {
+		store.Set(fmt.Sprintf("hot-%d", i), Entry{Value: []byte("v"), Timestamp: 1})
+	}
+	for range 3 {
+		for i := range 100 {
+			store.Get(fmt.Sprintf("hot-%d", i))
+		}
+	}
+
+	// A scan of keys that are never read again.
+	for i := range 1000 {
+		store.Set(fmt.Sprintf("scan-%d", i), Entry{Value: []byte("v"), Timestamp: 1})
+	}
+
+	hot := 0
+	for i := range 100 {
+		if _, ok := store.Get(fmt.Sprintf("hot-%d", i)); ok {
+			hot++
+		}
+	}
+	assert.GreaterOrEqual(t, hot, 95, "popular keys survive the scan")
+	assert.Equal(t, 100, store.Len())
+}
+
+func TestFrequencySketch(t *testing.T) {
+	s := newFrequencySketch(16)
+
+	assert.Equal(t, uint8(0), s.Estimate("k"))
+	for range 3 {
+		s.Increment("k")
+	}
+	assert.Equal(t, uint8(3), s.Estimate("k"))
+
+	for range 100 {
+		s.Increment("hot")
+	}
+	assert.LessOrEqual(t, s.Estimate("hot"), uint8(sketchMaxCount), "counters saturate")
+
+	t.Run("aging halves counters", func(t *testing.T) {
+		s := newFrequencySketch(16)
+		for range 12 {
+			s.Increment("k")
+		}
+		before := s.Estimate("k")
+
+		s.age()
+		assert.Equal(t, before/2, s.Estimate("k"))
+	})
+
+	t.Run("grows and resets", func(t *testing.T) {
+		s := newFrequencySketch(16)
+		s.Increment("k")
+		s.EnsureCapacity(100)
+
+		assert.Equal(t, 128, len(s.counters[0]))
+		assert.Equal(t, uint8(0), s.Estimate("k"))
+	})
+}
+
+func TestStore_WithEvictionPolicy(t *testing.T) {
+	reg := metrics.NewRegistry()
+	store := NewStore(reg, WithMaxEntries(2), WithEvictionPolicy(NewLFUPolicy))
+
+	store.Set("popular", Entry{Value: []byte("v"), Timestamp: 1})
+	store.Set("rare", Entry{Value: []byte("v"), Timestamp: 1})
+	store.Get("popular")
+	store.Get("popular")
+
+	// "rare" is the least recently used key, yet LFU keeps "popular".
+	store.Get("rare")
+	store.Set("new", Entry{Value: []byte("v"), Timestamp: 1})
+
+	_, ok := store.Get("popular")
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), reg.Snapshot()[string(metrics.CacheEvictionsTotal)])
+}
+
+// drain returns the policy's victims in eviction order.
+func drain(p EvictionPolicy) []string {
+	var order []string
+	for {
+		key, ok := p.Victim()
+		if !ok {
+			return order
+		}
+		order = append(order, key)
+		p.Remove(key)
+	}
+}
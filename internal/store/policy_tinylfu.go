@@ -0,0 +1,231 @@
+package store
+
+import (
+	"container/list"
+	"hash/maphash"
+)
+
+// tinyLFUPolicy is a W-TinyLFU eviction policy.
+//
+// Layout:
+// - A small LRU window (~1% of keys) absorbs new keys and short bursts
+// - Keys leaving the window enter the main area on probation
+// - A second hit in probation promotes a key to the protected segment (~80% of main)
+// - A count-min sketch estimates how often every key was seen, resident or not
+//
+// When the store must evict, the newest probation key (the candidate) competes
+// with the probation LRU key (the victim): the one the sketch has seen less
+// often is evicted. One-hit wonders therefore cannot flush popular keys.
+type tinyLFUPolicy struct {
+	sketch *frequencySketch
+	items  map[string]*tinyLFUItem
+
+	window    *list.List
+	probation *list.List
+	protected *list.List
+
+	// candidate is the last key moved from the window into probation
+	// that has not competed for admission yet.
+	candidate string
+}
+
+type tinyLFUItem struct {
+	segment *list.List
+	elem    *list.Element
+}
+
+const (
+	tinyLFUWindowPercent    = 1
+	tinyLFUProtectedPercent = 80
+)
+
+// NewTinyLFUPolicy returns a W-TinyLFU eviction policy.
+// It keeps near-LFU hit rates on skewed workloads while adapting to change.
+func NewTinyLFUPolicy() EvictionPolicy {
+	return &tinyLFUPolicy{
+		sketch:    newFrequencySketch(sketchMinWidth),
+		items:     make(map[string]*tinyLFUItem),
+		window:    list.New(),
+		probation: list.New(),
+		protected: list.New(),
+	}
+}
+
+func (p *tinyLFUPolicy) Insert(key string, _ Entry) {
+	p.sketch.Increment(key)
+	p.sketch.EnsureCapacity(len(p.items) + 1)
+
+	p.items[key] = &tinyLFUItem{segment: p.window, elem: p.window.PushFront(key)}
+
+	// Window overflow moves to probation; admission is decided on eviction.
+	if p.window.Len() > max(1, len(p.items)*tinyLFUWindowPercent/100) {
+		moved := p.window.Back().Value.(string)
+		p.move(moved, p.probation)
+		p.candidate = moved
+	}
+}
+
+func (p *tinyLFUPolicy) Update(key string, _ Entry) {
+	p.Access(key)
+}
+
+func (p *tinyLFUPolicy) Access(key string) {
+	p.sketch.Increment(key)
+
+	item, ok := p.items[key]
+	if !ok {
+		return
+	}
+
+	switch item.segment {
+	case p.window, p.protected:
+		item.segment.MoveToFront(item.elem)
+	case p.probation:
+		if key == p.candidate {
+			p.candidate = ""
+		}
+		p.move(key, p.protected)
+
+		mainSize := p.probation.Len() + p.protected.Len()
+		if p.protected.Len() > max(1, mainSize*tinyLFUProtectedPercent/100) {
+			p.move(p.protected.Back().Value.(string), p.probation)
+		}
+	}
+}
+
+func (p *tinyLFUPolicy) Remove(key string) {
+	item, ok := p.items[key]
+	if !ok {
+		return
+	}
+
+	item.segment.Remove(item.elem)
+	delete(p.items, key)
+	if key == p.candidate {
+		p.candidate = ""
+	}
+}
+
+func (p *tinyLFUPolicy) Victim() (string, bool) {
+	victim := back(p.probation)
+	if victim == "" {
+		victim = back(p.protected)
+	}
+	if victim == "" {
+		victim = back(p.window)
+	}
+	if victim == "" {
+		return "", false
+	}
+
+	candidate := p.candidate
+	p.candidate = ""
+	if candidate == "" || candidate == victim {
+		return victim, true
+	}
+
+	// Admission: the candidate must have been seen more often than the victim.
+	if p.sketch.Estimate(candidate) > p.sketch.Estimate(victim) {
+		return victim, true
+	}
+	return candidate, true
+}
+
+// move relinks a key at the front of another segment.
+func (p *tinyLFUPolicy) move(key string, to *list.List) {
+	item := p.items[key]
+	item.segment.Remove(item.elem)
+	item.segment = to
+	item.elem = to.PushFront(key)
+}
+
+func back(l *list.List) string {
+	if e := l.Back(); e != nil {
+		return e.Value.(string)
+	}
+	return ""
+}
+
+// frequencySketch is a count-min sketch with 4-bit saturating counters.
+//
+// Counters are halved once the number of increments reaches ten times the
+// width, so old popularity fades and the sketch follows workload changes.
+type frequencySketch struct {
+	seed      maphash.Seed
+	counters  [sketchDepth][]uint8
+	mask      uint64
+	additions int
+}
+
+const (
+	sketchDepth      = 4
+	sketchMaxCount   = 15
+	sketchSampleRate = 10
+	sketchMinWidth   = 1024
+)
+
+func newFrequencySketch(width int) *frequencySketch {
+	s := &frequencySketch{seed: maphash.MakeSeed()}
+	s.resize(width)
+	return s
+}
+
+// EnsureCapacity grows the sketch to at least n counters per row.
+// Growing discards the collected frequencies.
+func (s *frequencySketch) EnsureCapacity(n int) {
+	if uint64(n) > s.mask+1 {
+		s.resize(n)
+	}
+}
+
+func (s *frequencySketch) resize(width int) {
+	size := 1
+	for size < width {
+		size <<= 1
+	}
+
+	for i := range s.counters {
+		s.counters[i] = make([]uint8, size)
+	}
+	s.mask = uint64(size - 1)
+	s.additions = 0
+}
+
+// Increment records one occurrence of key.
+func (s *frequencySketch) Increment(key string) {
+	h := maphash.String(s.seed, key)
+	for i := range s.counters {
+		if c := &s.counters[i][s.index(h, i)]; *c < sketchMaxCount {
+			*c++
+		}
+	}
+
+	s.additions++
+	if s.additions >= sketchSampleRate*len(s.counters[0]) {
+		s.age()
+	}
+}
+
+// Estimate returns the approximate number of recent occurrences of key.
+func (s *frequencySketch) Estimate(key string) uint8 {
+	h := maphash.String(s.seed, key)
+	estimate := uint8(sketchMaxCount)
+	for i := range s.counters {
+		estimate = min(estimate, s.counters[i][s.index(h, i)])
+	}
+	return estimate
+}
+
+func (s *frequencySketch) age() {
+	for i := range s.counters {
+		for j := range s.counters[i] {
+			s.counters[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+// index derives the row's counter from one hash via double hashing.
+func (s *frequencySketch) index(h uint64, row int) uint64 {
+	return (h + uint64(row)*((h>>32)|1)) & s.mask
+}
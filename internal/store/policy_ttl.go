@@ -0,0 +1,95 @@
+package store
+
+import (
+	"container/heap"
+	"time"
+)
+
+// ttlPolicy evicts the key that expires soonest.
+// Keys without a TTL are evicted after all expiring keys, in LRU order.
+type ttlPolicy struct {
+	items     map[string]*ttlItem
+	expiring  ttlHeap
+	permanent *lruPolicy
+}
+
+type ttlItem struct {
+	key       string
+	expiresAt time.Time
+	index     int
+}
+
+// NewTTLPolicy returns a soonest-to-expire eviction policy.
+// It gives up entries that would disappear shortly anyway.
+func NewTTLPolicy() EvictionPolicy {
+	return &ttlPolicy{
+		items:     make(map[string]*ttlItem),
+		permanent: newLRUPolicy(),
+	}
+}
+
+func (p *ttlPolicy) Insert(key string, entry Entry) {
+	if entry.ExpiresAt.IsZero() {
+		p.permanent.Insert(key, entry)
+		return
+	}
+
+	item := &ttlItem{key: key, expiresAt: entry.ExpiresAt}
+	p.items[key] = item
+	heap.Push(&p.expiring, item)
+}
+
+// Update re-files the key, since an overwrite may change or drop its TTL.
+func (p *ttlPolicy) Update(key string, entry Entry) {
+	p.Remove(key)
+	p.Insert(key, entry)
+}
+
+func (p *ttlPolicy) Access(key string) {
+	p.permanent.Access(key)
+}
+
+func (p *ttlPolicy) Remove(key string) {
+	if item, ok := p.items[key]; ok {
+		heap.Remove(&p.expiring, item.index)
+		delete(p.items, key)
+		return
+	}
+	p.permanent.Remove(key)
+}
+
+func (p *ttlPolicy) Victim() (string, bool) {
+	if len(p.expiring) > 0 {
+		return p.expiring[0].key, true
+	}
+	return p.permanent.Victim()
+}
+
+// ttlHeap is a min-heap ordered by expiry time.
+type ttlHeap []*ttlItem
+
+func (h ttlHeap) Len() int { return len(h) }
+
+func (h ttlHeap) Less(i, j int) bool {
+	return h[i].expiresAt.Before(h[j].expiresAt)
+}
+
+func (h ttlHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *ttlHeap) Push(x any) {
+	item := x.(*ttlItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *ttlHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,40 @@
+package store
+
+import "errors"
+
+var (
+	// ErrPreconditionFailed is returned when a conditional write does not
+	// match the key's current state.
+	ErrPreconditionFailed = errors.New("precondition failed")
+
+	// ErrStale is returned when LWW rejects a write as older than the stored one.
+	ErrStale = errors.New("a newer write already exists")
+)
+
+// Precondition guards a conditional write (compare-and-swap).
+// The zero value always holds.
+//
+// A key is present when it is stored, not expired and not deleted.
+type Precondition struct {
+	// IfMatch requires the key to be present at exactly this version; 0 disables the check.
+	IfMatch uint64
+
+	// IfPresent requires the key to be present at any version.
+	IfPresent bool
+
+	// IfAbsent requires the key to be missing, expired or deleted.
+	IfAbsent bool
+}
+
+// holds reports whether the precondition is met by the current entry.
+func (p Precondition) holds(current Entry, present bool) bool {
+	switch {
+	case p.IfAbsent && present:
+		return false
+	case (p.IfPresent || p.IfMatch != 0) && !present:
+		return false
+	case p.IfMatch != 0 && current.Version != p.IfMatch:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"distributed-cache/internal/metrics"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreVersions(t *testing.T) {
+	s := NewStore(metrics.NewRegistry())
+
+	first, err := s.SetIf("k", Entry{Value: []byte("a"), Timestamp: 1}, Precondition{})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), first.Version)
+
+	second, err := s.SetIf("k", Entry{Value: []byte("b"), Timestamp: 2}, Precondition{})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), second.Version)
+
+	t.Run("stale writes keep the version", func(t *testing.T) {
+		_, err := s.SetIf("k", Entry{Value: []byte("old"), Timestamp: 1}, Precondition{})
+		assert.ErrorIs(t, err, ErrStale)
+
+		got, _ := s.Get("k")
+		assert.Equal(t, uint64(2), got.Version)
+	})
+
+	t.Run("deletes carry the version forward", func(t *testing.T) {
+		tomb, err := s.SetIf("k", Tombstone(3), Precondition{})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(3), tomb.Version)
+
+		again, err := s.SetIf("k", Entry{Value: []byte("c"), Timestamp: 4}, Precondition{})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(4), again.Version)
+	})
+
+	t.Run("replicated versions are kept when newer", func(t *testing.T) {
+		got, err := s.SetIf("k", Entry{Value: []byte("d"), Timestamp: 5, Version: 10}, Precondition{})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(10), got.Version)
+
+		got, err = s.SetIf("k", Entry{Value: []byte("e"), Timestamp: 6, Version: 7}, Precondition{})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(11), got.Version, "versions never go backwards")
+	})
+}
+
+func TestStoreSetIf_Preconditions(t *testing.T) {
+	cases := []struct {
+		name string
+		key  string
+		cond Precondition
+		ok   bool
+	}{
+		{"match", "live", Precondition{IfMatch: 1}, true},
+		{"version mismatch", "live", Precondition{IfMatch: 2}, false},
+		{"match on missing key", "missing", Precondition{IfMatch: 1}, false},
+		{"present", "live", Precondition{IfPresent: true}, true},
+		{"present on deleted key", "deleted", Precondition{IfPresent: true}, false},
+		{"present on expired key", "expired", Precondition{IfPresent: true}, false},
+		{"absent", "missing", Precondition{IfAbsent: true}, true},
+		{"absent on deleted key", "deleted", Precondition{IfAbsent: true}, true},
+		{"absent on live key", "live", Precondition{IfAbsent: true}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := metrics.NewRegistry()
+			s := NewStore(reg)
+			s.Set("live", Entry{Value: []byte("v"), Timestamp: 1})
+			s.Set("deleted", Entry{Value: []byte("v"), Timestamp: 1})
+			s.Set("deleted", Tombstone(2))
+			s.Set("expired", Entry{Value: []byte("v"), Timestamp: 1, ExpiresAt: time.Now().Add(-time.Second)})
+
+			_, err := s.SetIf(tc.key, Entry{Value: []byte("new"), Timestamp: 100}, tc.cond)
+			if tc.ok {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrPreconditionFailed)
+			assert.Equal(t, int64(1), reg.Snapshot()[string(metrics.CachePreconditionFailedTotal)])
+		})
+	}
+}
+
+func TestStoreSetIf_CompareAndSwapIsAtomic(t *testing.T) {
+	s := NewStore(metrics.NewRegistry())
+	s.Set("counter", Entry{Value: []byte("0"), Timestamp: 1})
+
+	const writers = 50
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	won := 0
+
+	start, _ := s.Get("counter")
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			entry := Entry{Value: []byte("x"), Timestamp: int64(2 + i)}
+			if _, err := s.SetIf("counter", entry, Precondition{IfMatch: start.Version}); err == nil {
+				mu.Lock()
+				won++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, won, "exactly one writer wins the swap")
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"distributed-cache/internal/util"
+)
+
+// ScanOptions selects a page of keys for Scan.
+type ScanOptions struct {
+	// After resumes the scan after this key (the previous page's Next).
+	After string
+
+	// Prefix keeps only keys starting with it.
+	Prefix string
+
+	// Pattern keeps only keys matching a Redis-style glob (see util.Match),
+	// e.g. "user:*:session". As in Redis, '*' also matches '/'.
+	Pattern string
+
+	// Limit is the maximum page size; values below 1 mean 100.
+	Limit int
+}
+
+// ScanItem is one key returned by Scan.
+type ScanItem struct {
+	Key   string
+	Entry Entry
+
+	// Size is the approximate memory used by the entry.
+	Size int64
+}
+
+// ScanPage is one page of a Scan.
+type ScanPage struct {
+	Items []ScanItem
+
+	// Next is the cursor for the following page; empty on the last page.
+	Next string
+}
+
+// DefaultScanLimit is the page size used when ScanOptions.Limit is unset.
+const DefaultScanLimit = 100
+
+// scanBudgetFactor bounds the keys each shard examines per Scan call to
+// this many times the page size, so a filter matching few keys cannot
+// walk the whole key space in one call.
+const scanBudgetFactor = 10
+
+// Scan returns live keys in lexicographic order, one page at a time.
+//
+// Behavior:
+// - Pages are resumed with a key cursor, so no snapshot is kept between calls
+// - Each shard seeks to the cursor in its key index and stops once it has a page's worth
+// - Each shard examines at most Limit*10 keys, counting skipped ones; a page may then be short, even empty, with Next set
+// - Each shard's read lock is held only while that shard is scanned
+// - A key present for the whole scan is returned exactly once; keys written meanwhile may or may not be
+func (n *Namespace) Scan(opts ScanOptions) ScanPage {
+	if opts.Limit < 1 {
+		opts.Limit = DefaultScanLimit
+	}
+
+	// Every shard contributes its Limit+1 smallest matching keys; the
+	// extra one tells whether another page follows. A shard that runs out
+	// of budget first has not looked past its stop key, so the page ends
+	// at the smallest such key.
+	var (
+		items   []ScanItem
+		bound   string
+		bounded bool
+	)
+	now := time.Now()
+	for _, sh := range n.shards {
+		var stop string
+		var stopped bool
+		items, stop, stopped = sh.scan(now, opts, opts.Limit*scanBudgetFactor, items)
+		if stopped && (!bounded || stop < bound) {
+			bound, bounded = stop, true
+		}
+	}
+	if bounded {
+		items = slices.DeleteFunc(items, func(it ScanItem) bool { return it.Key > bound })
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+
+	page := ScanPage{Items: items}
+	if len(items) > opts.Limit {
+		page.Items = items[:opts.Limit]
+		page.Next = page.Items[opts.Limit-1].Key
+	} else if bounded {
+		page.Next = bound
+	}
+	return page
+}
+
+// scan appends up to opts.Limit+1 matching keys of the shard, in order,
+// examining at most budget keys. When the budget runs out first, it also
+// returns the last key examined and true.
+func (sh *shard) scan(now time.Time, opts ScanOptions, budget int, into []ScanItem) ([]ScanItem, string, bool) {
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	// Start at the first key after the cursor and within the prefix;
+	// After+"\x00" is the smallest key greater than After.
+	start := opts.Prefix
+	if opts.After != "" && opts.After >= start {
+		start = opts.After + "\x00"
+	}
+
+	found, examined := 0, 0
+	var last string
+	for node := sh.index.seek(start); node != nil && found <= opts.Limit; node = node.next[0] {
+		rec := node.rec
+		if !strings.HasPrefix(rec.key, opts.Prefix) {
+			break // past the prefix range
+		}
+		if examined == budget {
+			return into, last, true
+		}
+		examined++
+		last = rec.key
+
+		if rec.entry.Deleted || rec.entry.IsExpired(now) {
+			continue
+		}
+		if opts.Pattern != "" && !util.Match(opts.Pattern, rec.key) {
+			continue
+		}
+
+		into = append(into, ScanItem{Key: rec.key, Entry: rec.entry, Size: rec.size})
+		found++
+	}
+	return into, "", false
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"distributed-cache/internal/metrics"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scanKeys(page ScanPage) []string {
+	keys := make([]string, len(page.Items))
+	for i, it := range page.Items {
+		keys[i] = it.Key
+	}
+	return keys
+}
+
+func TestStoreScan_Pages(t *testing.T) {
+	s := NewStore(metrics.NewRegistry())
+	for i := range 250 {
+		s.Set(fmt.Sprintf("k%03d", i), Entry{Value: []byte("v"), Timestamp: 1})
+	}
+	s.Set("k999", Entry{Value: []byte("v"), Timestamp: 1})
+	s.Set("k999", Tombstone(2))
+	s.Set("k998", Entry{Value: []byte("v"), Timestamp: 1, ExpiresAt: time.Now().Add(-time.Second)})
+
+	var all []string
+	opts := ScanOptions{Limit: 100}
+	pages := 0
+	for {
+		page := s.Scan(opts)
+		pages++
+
+		all = append(all, scanKeys(page)...)
+		if page.Next == "" {
+			break
+		}
+		opts.After = page.Next
+	}
+
+	assert.Equal(t, 3, pages)
+	require.Len(t, all, 250, "tombstones and expired keys are skipped")
+	for i, key := range all {
+		assert.Equal(t, fmt.Sprintf("k%03d", i), key)
+	}
+}
+
+func TestStoreScan_ExactPageHasNoNext(t *testing.T) {
+	s := NewStore(metrics.NewRegistry())
+	s.Set("a", Entry{Value: []byte("1"), Timestamp: 1})
+	s.Set("b", Entry{Value: []byte("22"), Timestamp: 1})
+
+	page := s.Scan(ScanOptions{Limit: 2})
+	assert.Equal(t, []string{"a", "b"}, scanKeys(page))
+	assert.Empty(t, page.Next)
+	assert.Equal(t, entrySize("b", Entry{Value: []byte("22")}), page.Items[1].Size)
+}
+
+func TestStoreScan_Filters(t *testing.T) {
+	s := NewStore(metrics.NewRegistry())
+	for _, key := range []string{"user:1:session", "user:1:profile", "user:2:session", "order:1", "user/3/session"} {
+		s.Set(key, Entry{Value: []byte("v"), Timestamp: 1})
+	}
+
+	page := s.Scan(ScanOptions{Prefix: "user:"})
+	assert.Equal(t, []string{"user:1:profile", "user:1:session", "user:2:session"}, scanKeys(page))
+
+	page = s.Scan(ScanOptions{Pattern: "user:*:session"})
+	assert.Equal(t, []string{"user:1:session", "user:2:session"}, scanKeys(page))
+
+	page = s.Scan(ScanOptions{Pattern: "user*session"})
+	assert.Equal(t, []string{"user/3/session", "user:1:session", "user:2:session"}, scanKeys(page), "'*' matches across '/'")
+
+	page = s.Scan(ScanOptions{Prefix: "user:2", Pattern: "*:profile"})
+	assert.Empty(t, page.Items)
+
+	page = s.Scan(ScanOptions{Pattern: "user:["})
+	assert.Empty(t, page.Items, "an unclosed class is matched as in Redis, not rejected")
+}
+
+func TestStoreScan_ExamineBudget(t *testing.T) {
+	s := NewStore(metrics.NewRegistry(), WithShards(1))
+	for i := range 1000 {
+		s.Set(fmt.Sprintf("k%04d", i), Entry{Value: []byte("v"), Timestamp: 1})
+	}
+
+	page := s.Scan(ScanOptions{Pattern: "none*", Limit: 10})
+	assert.Empty(t, page.Items)
+	assert.Equal(t, "k0099", page.Next, "the scan stops after Limit*10 keys")
+
+	pages := 0
+	opts := ScanOptions{Pattern: "k*7", Limit: 10}
+	var all []string
+	for {
+		page := s.Scan(opts)
+		pages++
+		all = append(all, scanKeys(page)...)
+		if page.Next == "" {
+			break
+		}
+		opts.After = page.Next
+	}
+	assert.Equal(t, 10, pages, "short pages still advance the cursor")
+	require.Len(t, all, 100)
+	for i, key := range all {
+		assert.Equal(t, fmt.Sprintf("k%03d7", i), key)
+	}
+}
+
+func TestStoreScan_ExamineBudgetAcrossShards(t *testing.T) {
+	s := NewStore(metrics.NewRegistry(), WithShards(8))
+	var want []string
+	for i := range 2000 {
+		key := fmt.Sprintf("k%04d", i)
+		s.Set(key, Entry{Value: []byte("v"), Timestamp: 1})
+		if i%50 == 0 {
+			want = append(want, key)
+		}
+	}
+
+	var all []string
+	opts := ScanOptions{Pattern: "k*[05]0", Limit: 5}
+	for {
+		page := s.Scan(opts)
+		assert.LessOrEqual(t, len(page.Items), 5)
+		all = append(all, scanKeys(page)...)
+		if page.Next == "" {
+			break
+		}
+		opts.After = page.Next
+	}
+	assert.Equal(t, want, all, "every match is returned once, in order")
+}
+
+func TestStoreScan_AfterEviction(t *testing.T) {
+	s := NewStore(metrics.NewRegistry(), WithShards(1), WithMaxEntries(10))
+	for i := range 30 {
+		s.Set(fmt.Sprintf("k%02d", i), Entry{Value: []byte("v"), Timestamp: 1})
+	}
+
+	page := s.Scan(ScanOptions{Limit: 100})
+	assert.Len(t, page.Items, len(s.List()), "evicted keys leave the index")
+}
@@ -0,0 +1,431 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"distributed-cache/internal/metrics"
+)
+
+// shard is one hash partition of a Namespace.
+// It owns its keys, eviction policy, byte accounting and limits.
+type shard struct {
+	namespace string
+	mu        sync.RWMutex
+	data      map[string]*record
+	index     *keyIndex
+	metrics   namespaceMetrics
+	events    *watchHub
+	onWrite   func(key string, entry Entry)
+
+	policy EvictionPolicy
+	bytes  int64
+
+	// Tombstones are never evicted, so they do not count toward the
+	// limits; the TTL cleaner purges them after the grace period.
+	tombstones     int
+	tombstoneBytes int64
+
+	// sharedReads lets reads take the read lock: the shard is unbounded
+	// or its policy ignores accesses, so a hit changes nothing.
+	sharedReads bool
+
+	// defaultTTL applies to live writes without an expiry; zero means none.
+	defaultTTL time.Duration
+
+	// Limits; zero means unbounded.
+	maxEntries int
+	maxBytes   int64
+}
+
+// record is the bookkeeping kept for every stored key.
+type record struct {
+	key   string
+	entry Entry
+	size  int64
+}
+
+// set applies an LWW write guarded by cond; notify reports accepted writes to onWrite.
+// It returns the stored entry, carrying its assigned version.
+func (sh *shard) set(key string, entry Entry, cond Precondition, notify bool) (Entry, error) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	return sh.setLocked(key, entry, cond, notify)
+}
+
+// incr adds delta to nodeID's slot of the counter at key and stores the result.
+func (sh *shard) incr(key, nodeID string, delta int64, ttl time.Duration) (Entry, error) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	now := time.Now()
+	timestamp := now.UnixNano()
+
+	var counter *Counter
+	var epoch int64
+	var expiresAt time.Time
+	var flags uint32
+	if rec, exists := sh.data[key]; exists {
+		current := rec.entry
+		// Stay ahead of the stored write even if its clock ran fast.
+		timestamp = max(timestamp, current.Timestamp+1)
+		epoch = current.Timestamp
+
+		if !current.Deleted && !current.IsExpired(now) {
+			if current.Counter == nil {
+				return Entry{}, ErrNotCounter
+			}
+			counter = current.Counter
+			expiresAt = current.ExpiresAt
+			flags = current.Flags
+		}
+	}
+
+	if ttl > 0 {
+		expiresAt = now.Add(ttl)
+	}
+	if counter == nil {
+		counter = &Counter{Epoch: epoch}
+	}
+
+	next, err := counter.add(nodeID, delta)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	entry := counterEntry(next, timestamp)
+	entry.ExpiresAt = expiresAt
+	entry.Flags = flags
+	return sh.setLocked(key, entry, Precondition{}, true)
+}
+
+// expire rewrites the expiry of a live entry as a new write.
+func (sh *shard) expire(key string, expiresAt time.Time) (Entry, bool) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	now := time.Now()
+	rec, exists := sh.data[key]
+	if !exists || rec.entry.Deleted || rec.entry.IsExpired(now) {
+		return Entry{}, false
+	}
+
+	entry := rec.entry
+	// Stay ahead of the stored write even if its clock ran fast.
+	entry.Timestamp = max(now.UnixNano(), entry.Timestamp+1)
+	entry.ExpiresAt = expiresAt
+
+	stored, err := sh.setLocked(key, entry, Precondition{}, true)
+	return stored, err == nil
+}
+
+func (sh *shard) setLocked(key string, entry Entry, cond Precondition, notify bool) (Entry, error) {
+	if entry.Deleted {
+		sh.metrics.Inc(metrics.CacheDeletesTotal)
+	} else {
+		sh.metrics.Inc(metrics.CacheSetsTotal)
+	}
+
+	rec, exists := sh.data[key]
+
+	var current Entry
+	if exists {
+		current = rec.entry
+	}
+	present := exists && !current.Deleted && !current.IsExpired(time.Now())
+	if !cond.holds(current, present) {
+		sh.metrics.Inc(metrics.CachePreconditionFailedTotal)
+		return Entry{}, ErrPreconditionFailed
+	}
+
+	if entry.Counter != nil && present && current.Counter != nil {
+		switch {
+		case entry.Counter.Epoch < current.Counter.Epoch:
+			// An update to an earlier incarnation, deleted since.
+			return Entry{}, ErrStale
+		case entry.Counter.Epoch == current.Counter.Epoch:
+			// Counters merge instead of overwriting, so concurrent increments
+			// on different nodes all count. The newer write decides the expiry
+			// and flags.
+			merged, changed := current.Counter.merge(entry.Counter)
+			if !changed && entry.Timestamp <= current.Timestamp {
+				return Entry{}, ErrStale
+			}
+
+			next := counterEntry(merged, max(entry.Timestamp, current.Timestamp))
+			next.Version = entry.Version
+			next.ExpiresAt, next.Flags = current.ExpiresAt, current.Flags
+			if entry.Timestamp > current.Timestamp {
+				next.ExpiresAt, next.Flags = entry.ExpiresAt, entry.Flags
+			}
+			entry = next
+		}
+		// A newer incarnation replaces the current counter outright.
+	} else if exists && entry.Timestamp <= current.Timestamp {
+		return Entry{}, ErrStale
+	}
+
+	if sh.defaultTTL > 0 && !entry.Deleted && entry.ExpiresAt.IsZero() {
+		entry.ExpiresAt = time.Now().Add(sh.defaultTTL)
+	}
+
+	// Versions only move forward. A replicated or restored entry keeps its
+	// version when that is newer, so replicas converge on the writer's version.
+	if entry.Version <= current.Version {
+		entry.Version = current.Version + 1
+	}
+
+	// Only live (non-tombstone) entries count as keys.
+	wasLive := exists && !current.Deleted
+	switch {
+	case !wasLive && !entry.Deleted:
+		sh.metrics.Inc(metrics.CacheKeysTotal)
+	case wasLive && entry.Deleted:
+		sh.metrics.Add(metrics.CacheKeysTotal, -1)
+	}
+
+	// The policy tracks live keys only: tombstones are never evicted.
+	size := entrySize(key, entry)
+	if exists {
+		sh.trackTombstone(rec, -1)
+		sh.addBytes(size - rec.size)
+		rec.entry = entry
+		rec.size = size
+
+		switch {
+		case entry.Deleted && !current.Deleted:
+			sh.policy.Remove(key)
+		case !entry.Deleted && current.Deleted:
+			sh.policy.Insert(key, entry)
+		case !entry.Deleted:
+			sh.policy.Update(key, entry)
+		}
+	} else {
+		rec = &record{key: key, entry: entry, size: size}
+		sh.data[key] = rec
+		sh.index.insert(rec)
+		sh.addBytes(size)
+		if !entry.Deleted {
+			sh.policy.Insert(key, entry)
+		}
+	}
+	sh.trackTombstone(rec, 1)
+
+	if notify {
+		if sh.onWrite != nil {
+			sh.onWrite(key, entry)
+		}
+		typ := EventSet
+		if entry.Deleted {
+			typ = EventDelete
+		}
+		sh.events.publish(typ, sh.namespace, key, entry)
+	}
+
+	sh.evictLocked()
+	return entry, nil
+}
+
+// get serves a read under the read lock when the shard allows shared
+// reads. Otherwise it takes the write lock, since a hit updates the
+// eviction policy; an expired entry also needs it to be removed on the spot.
+func (sh *shard) get(key string) (Entry, bool) {
+	now := time.Now()
+	if sh.sharedReads {
+		sh.mu.RLock()
+		l, ok := sh.peekLocked(key, now)
+		sh.mu.RUnlock()
+		if ok {
+			return l.Entry, l.Found
+		}
+	}
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	l := sh.getLocked(key, now)
+	return l.Entry, l.Found
+}
+
+// getLocked reads a key, reporting a hit to the eviction policy and
+// removing an expired entry.
+// Caller must hold sh.mu for writing.
+func (sh *shard) getLocked(key string, now time.Time) Lookup {
+	if l, ok := sh.peekLocked(key, now); ok {
+		if l.Found {
+			sh.policy.Access(key)
+		}
+		return l
+	}
+
+	sh.metrics.Inc(metrics.CacheGetsTotal)
+	sh.expireLocked(sh.data[key])
+	sh.metrics.Inc(metrics.CacheExpiredTotal)
+	return Lookup{Expired: true}
+}
+
+// peekLocked reads a key without changing the shard. It returns false,
+// counting nothing, for an expired entry, which only getLocked removes.
+// Caller must hold sh.mu for reading.
+func (sh *shard) peekLocked(key string, now time.Time) (Lookup, bool) {
+	rec, exists := sh.data[key]
+	if exists && !rec.entry.Deleted && rec.entry.IsExpired(now) {
+		return Lookup{}, false
+	}
+
+	sh.metrics.Inc(metrics.CacheGetsTotal)
+	if !exists || rec.entry.Deleted {
+		sh.metrics.Inc(metrics.CacheMissesTotal)
+		return Lookup{}, true
+	}
+	return Lookup{Entry: rec.entry, Found: true}, true
+}
+
+func (sh *shard) list(now time.Time, into map[string]Entry) {
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	for k, rec := range sh.data {
+		if !rec.entry.Deleted && !rec.entry.IsExpired(now) {
+			into[k] = rec.entry
+		}
+	}
+}
+
+func (sh *shard) entries(now time.Time, into map[string]Entry) {
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	for k, rec := range sh.data {
+		if !rec.entry.IsExpired(now) {
+			into[k] = rec.entry
+		}
+	}
+}
+
+func (sh *shard) len(now time.Time) int {
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	count := 0
+	for _, rec := range sh.data {
+		if !rec.entry.Deleted && !rec.entry.IsExpired(now) {
+			count++
+		}
+	}
+	return count
+}
+
+func (sh *shard) size() int64 {
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	return sh.bytes
+}
+
+func (sh *shard) removeExpired(now time.Time) int {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	removed := 0
+	for _, rec := range sh.data {
+		if rec.entry.IsExpired(now) {
+			sh.expireLocked(rec)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (sh *shard) purgeTombstones(cutoff time.Time) int {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	purged := 0
+	for _, rec := range sh.data {
+		if rec.entry.Deleted && !rec.entry.DeletedAt.After(cutoff) {
+			sh.removeLocked(rec)
+			purged++
+		}
+	}
+	return purged
+}
+
+// evictLocked removes the policy's victims until the shard is within
+// its limits. Expired victims are accounted as expirations, not evictions.
+//
+// Evicting a key forgets its LWW timestamp, exactly as if the key had
+// never been written: a later replicated write for it is a fresh insert.
+// Tombstones are never victims, so an older write cannot bring a deleted
+// key back; they leave once PurgeTombstones drops them.
+//
+// Caller must hold sh.mu.
+func (sh *shard) evictLocked() {
+	now := time.Now()
+
+	for sh.overLimitLocked() {
+		key, ok := sh.policy.Victim()
+		if !ok {
+			return
+		}
+
+		rec := sh.data[key]
+		if rec.entry.IsExpired(now) {
+			sh.expireLocked(rec)
+			sh.metrics.Inc(metrics.CacheExpiredTotal)
+		} else {
+			sh.removeLocked(rec)
+			sh.metrics.Inc(metrics.CacheEvictionsTotal)
+		}
+	}
+}
+
+// overLimitLocked reports whether the live entries exceed the limits.
+func (sh *shard) overLimitLocked() bool {
+	live := len(sh.data) - sh.tombstones
+	if live == 0 {
+		return false
+	}
+	return (sh.maxEntries > 0 && live > sh.maxEntries) ||
+		(sh.maxBytes > 0 && sh.bytes-sh.tombstoneBytes > sh.maxBytes)
+}
+
+// removeLocked drops a record and its accounting.
+// Caller must hold sh.mu.
+func (sh *shard) removeLocked(rec *record) {
+	delete(sh.data, rec.key)
+	sh.index.remove(rec.key)
+	sh.addBytes(-rec.size)
+	sh.trackTombstone(rec, -1)
+
+	if !rec.entry.Deleted {
+		sh.policy.Remove(rec.key)
+		sh.metrics.Add(metrics.CacheKeysTotal, -1)
+	}
+}
+
+// trackTombstone adds a tombstone record to the tombstone accounting, or
+// takes it out with sign -1; live records are ignored.
+// Caller must hold sh.mu.
+func (sh *shard) trackTombstone(rec *record, sign int) {
+	if rec.entry.Deleted {
+		sh.tombstones += sign
+		sh.tombstoneBytes += int64(sign) * rec.size
+	}
+}
+
+// expireLocked removes an expired record and tells watchers.
+// Expired tombstones are dropped silently: the key was already deleted.
+// Caller must hold sh.mu.
+func (sh *shard) expireLocked(rec *record) {
+	sh.removeLocked(rec)
+	if !rec.entry.Deleted {
+		sh.events.publish(EventExpire, sh.namespace, rec.key, rec.entry)
+	}
+}
+
+func (sh *shard) addBytes(delta int64) {
+	sh.bytes += delta
+	sh.metrics.Add(metrics.CacheBytes, delta)
+}
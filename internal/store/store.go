@@ -1,6 +1,9 @@
 package store
 
 import (
+	"errors"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,130 +13,329 @@ import (
 // Store is a concurrency-safe in-memory key–value store.
 //
 // Design principles:
-// - Safe for concurrent access using RWMutex
+// - Keys live in namespaces, isolated keyspaces with their own rules (see Namespace)
+// - Keys are hash-partitioned into shards, each guarded by its own lock
 // - Uses Last-Write-Wins (LWW) via logical timestamps
 // - TTL expiration handled using wall-clock time (time.Now)
+// - Deletes are recorded as tombstones so LWW also orders deletes
+// - Tombstones are invisible to readers and purged after a grace period
+// - Optionally bounded by entry count and bytes, evicting via an EvictionPolicy (LRU by default)
+// - Sets, deletes and expirations are published to watchers (see Watch)
+//
+// Limits are split evenly across shards and every shard evicts on its own,
+// so eviction order is only exact within a shard.
+//
+// The key methods of Store (Set, Get, Scan, ...) act on the default
+// namespace, which the limit and policy options configure.
 //
 // Note:
 // TTL testing uses short sleeps instead of injecting a clock,
 // keeping the store free of test-only concerns.
 type Store struct {
-	mu      sync.RWMutex
-	data    map[string]Entry
 	metrics *metrics.Registry
+	events  *watchHub
+
+	mu         sync.RWMutex
+	namespaces map[string]*Namespace
+	def        *Namespace
+
+	// Options, applied when namespaces are built.
+	shardCount   int
+	maxEntries   int
+	maxBytes     int64
+	newPolicy    func() EvictionPolicy
+	onWrite      func(namespace, key string, entry Entry)
+	watchHistory int
 }
 
-// NewStore initializes and returns a new Store.
-func NewStore(metricsRegistry *metrics.Registry) *Store {
-	return &Store{
-		data:    make(map[string]Entry),
-		metrics: metricsRegistry,
+// DefaultShards is the shard count used unless WithShards overrides it.
+const DefaultShards = 32
+
+// Minimum per-shard share of the configured limits.
+const (
+	minShardEntries = 64
+	minShardBytes   = 64 << 10
+)
+
+// Option configures optional Store behavior.
+type Option func(*Store)
+
+// WithShards sets the number of shards; values below 1 are ignored.
+// A single shard gives exact, store-wide eviction order.
+func WithShards(n int) Option {
+	return func(s *Store) {
+		if n >= 1 {
+			s.shardCount = n
+		}
 	}
 }
 
-// Set inserts or updates a key using Last-Write-Wins semantics.
-//
-// Rules:
-// - If the key does not exist, insert it.
-// - If the key exists, overwrite only if the incoming timestamp is newer.
-func (s *Store) Set(key string, entry Entry) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// WithMaxEntries bounds the number of live entries. Tombstones are not
+// counted and never evicted; they are purged after the grace period.
+func WithMaxEntries(n int) Option {
+	return func(s *Store) {
+		s.maxEntries = n
+	}
+}
+
+// WithMaxBytes bounds the approximate memory used by live entries.
+// Tombstones are left out, as for WithMaxEntries.
+func WithMaxBytes(n int64) Option {
+	return func(s *Store) {
+		s.maxBytes = n
+	}
+}
 
-	s.metrics.Inc(metrics.CacheSetsTotal)
+// WithEvictionPolicy replaces the default LRU policy.
+// newPolicy is called once per shard, e.g. WithEvictionPolicy(NewLFUPolicy).
+func WithEvictionPolicy(newPolicy func() EvictionPolicy) Option {
+	return func(s *Store) {
+		s.newPolicy = newPolicy
+	}
+}
+
+// WithWriteHook registers fn to observe every accepted Set and Delete in
+// any namespace, e.g. to append it to a write-ahead log.
+//
+// fn runs under the key's shard lock, so writes to one key are observed in
+// the order they were applied. It must not call back into the Store.
+func WithWriteHook(fn func(namespace, key string, entry Entry)) Option {
+	return func(s *Store) {
+		s.onWrite = fn
+	}
+}
 
-	existing, exists := s.data[key]
-	if exists && entry.Timestamp <= existing.Timestamp {
-		return
+// NewStore initializes and returns a new Store holding the default namespace.
+func NewStore(metricsRegistry *metrics.Registry, opts ...Option) *Store {
+	s := &Store{
+		metrics:      metricsRegistry,
+		namespaces:   make(map[string]*Namespace),
+		shardCount:   DefaultShards,
+		newPolicy:    NewLRUPolicy,
+		watchHistory: DefaultWatchHistory,
 	}
 
-	if !exists {
-		s.metrics.Inc(metrics.CacheKeysTotal)
+	for _, opt := range opts {
+		opt(s)
 	}
+	s.events = newWatchHub(metricsRegistry, s.watchHistory)
 
-	s.data[key] = entry
+	s.def = s.newNamespace(DefaultNamespace, NamespaceSettings{
+		MaxEntries: s.maxEntries,
+		MaxBytes:   s.maxBytes,
+	})
+	s.namespaces[DefaultNamespace] = s.def
+	return s
 }
 
-// Get retrieves a value from the store.
-//
-// Behavior:
-// - Returns (value, true) if key exists and is not expired
-// - If the key is expired, it is deleted and treated as missing
-func (s *Store) Get(key string) (string, bool) {
-	s.metrics.Inc(metrics.CacheGetsTotal)
+func (s *Store) newNamespace(name string, settings NamespaceSettings) *Namespace {
+	return newNamespace(name, settings, s.shardCount, s.newPolicy, s.metrics, s.events, s.onWrite)
+}
 
-	s.mu.RLock()
-	entry, exists := s.data[key]
-	s.mu.RUnlock()
+// splitLimit returns shard i's share of a store-wide limit.
+// The shares add up to the limit; zero stays unbounded.
+func splitLimit(limit int64, shards, i int) int64 {
+	if limit <= 0 {
+		return 0
+	}
 
-	if !exists {
-		s.metrics.Inc(metrics.CacheMissesTotal)
-		return "", false
+	share := limit / int64(shards)
+	if int64(i) < limit%int64(shards) {
+		share++
 	}
+	return max(share, 1)
+}
+
+// entryOverhead approximates the per-entry cost of the map slot,
+// record, policy bookkeeping and entry metadata.
+const entryOverhead = 128
 
-	if entry.IsExpired(time.Now()) {
-		s.mu.Lock()
-		delete(s.data, key)
-		s.mu.Unlock()
+// entrySize returns the approximate memory footprint of an entry.
+func entrySize(key string, entry Entry) int64 {
+	return int64(len(key)+len(entry.Value)+len(entry.ContentType)+entry.Counter.size()) + entryOverhead
+}
+
+/* ---------------- Namespaces ---------------- */
 
-		s.metrics.Inc(metrics.CacheExpiredTotal)
-		s.metrics.Add(metrics.CacheKeysTotal, -1)
+// Default returns the default namespace.
+func (s *Store) Default() *Namespace {
+	return s.def
+}
 
-		return "", false
+// Namespace returns the named namespace; "" names the default one.
+func (s *Store) Namespace(name string) (*Namespace, bool) {
+	if name == "" {
+		return s.def, true
 	}
 
-	return entry.Value, true
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n, ok := s.namespaces[name]
+	return n, ok
 }
 
-// Delete removes a key from the store.
-func (s *Store) Delete(key string) {
+// CreateNamespace adds a namespace with its own settings.
+//
+// Returns ErrInvalidNamespace for a bad name, ErrNamespaceExists if it
+// already exists, or the first invalid setting.
+func (s *Store) CreateNamespace(name string, settings NamespaceSettings) (*Namespace, error) {
+	if !ValidNamespace(name) {
+		return nil, ErrInvalidNamespace
+	}
+	if err := settings.Validate(); err != nil {
+		return nil, namespaceError(name, err)
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, ok := s.data[key]; ok {
-		delete(s.data, key)
-		s.metrics.Add(metrics.CacheKeysTotal, -1)
+	if _, exists := s.namespaces[name]; exists {
+		return nil, namespaceError(name, ErrNamespaceExists)
 	}
+	n := s.newNamespace(name, settings)
+	s.namespaces[name] = n
+	return n, nil
 }
 
-// List returns a snapshot of all non-expired entries.
+// EnsureNamespace returns the named namespace, creating it with settings
+// if it does not exist yet; "" names the default one.
+//
+// Used where writes arrive for a namespace this node may not know yet,
+// e.g. recovery from a snapshot or the write-ahead log.
+func (s *Store) EnsureNamespace(name string, settings NamespaceSettings) (*Namespace, error) {
+	if n, ok := s.Namespace(name); ok {
+		return n, nil
+	}
+
+	n, err := s.CreateNamespace(name, settings)
+	if errors.Is(err, ErrNamespaceExists) {
+		n, _ = s.Namespace(name)
+		return n, nil
+	}
+	return n, err
+}
+
+// Namespaces returns every namespace, the default one included, sorted by name.
+func (s *Store) Namespaces() []*Namespace {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]*Namespace, 0, len(s.namespaces))
+	for _, n := range s.namespaces {
+		out = append(out, n)
+	}
+	slices.SortFunc(out, func(a, b *Namespace) int { return strings.Compare(a.name, b.name) })
+	return out
+}
+
+/* ---------------- Default namespace ---------------- */
+
+// Set writes key in the default namespace; see Namespace.Set.
+func (s *Store) Set(key string, entry Entry) bool {
+	return s.def.Set(key, entry)
+}
+
+// SetIf writes key in the default namespace; see Namespace.SetIf.
+func (s *Store) SetIf(key string, entry Entry, cond Precondition) (Entry, error) {
+	return s.def.SetIf(key, entry, cond)
+}
+
+// Incr updates a counter in the default namespace; see Namespace.Incr.
+func (s *Store) Incr(key, nodeID string, delta int64, ttl time.Duration) (Entry, error) {
+	return s.def.Incr(key, nodeID, delta, ttl)
+}
+
+// Expire sets the expiry of a key of the default namespace; see Namespace.Expire.
+func (s *Store) Expire(key string, expiresAt time.Time) (Entry, bool) {
+	return s.def.Expire(key, expiresAt)
+}
+
+// Restore applies a recovered entry to the default namespace; see Namespace.Restore.
+func (s *Store) Restore(key string, entry Entry) bool {
+	return s.def.Restore(key, entry)
+}
+
+// Get reads key from the default namespace; see Namespace.Get.
+func (s *Store) Get(key string) (Entry, bool) {
+	return s.def.Get(key)
+}
+
+// Delete removes key from the default namespace; see Namespace.Delete.
+func (s *Store) Delete(key string) bool {
+	return s.def.Delete(key)
+}
+
+// List returns the live entries of the default namespace.
 // Used by admin APIs and UI.
 func (s *Store) List() map[string]Entry {
-	now := time.Now()
-	result := make(map[string]Entry)
+	return s.def.List()
+}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// Entries returns the entries of the default namespace, tombstones included.
+func (s *Store) Entries() map[string]Entry {
+	return s.def.Entries()
+}
 
-	for k, v := range s.data {
-		if !v.IsExpired(now) {
-			result[k] = v
-		}
+// GetMany reads many keys from the default namespace; see Namespace.GetMany.
+func (s *Store) GetMany(keys []string) []Lookup {
+	return s.def.GetMany(keys)
+}
+
+// SetMany applies many writes to the default namespace; see Namespace.SetMany.
+func (s *Store) SetMany(writes []Write) []WriteResult {
+	return s.def.SetMany(writes)
+}
+
+// Scan pages through the default namespace; see Namespace.Scan.
+func (s *Store) Scan(opts ScanOptions) ScanPage {
+	return s.def.Scan(opts)
+}
+
+/* ---------------- Store-wide ---------------- */
+
+// Len returns the number of live (non-expired, non-deleted) keys
+// across all namespaces.
+func (s *Store) Len() int {
+	count := 0
+	for _, n := range s.Namespaces() {
+		count += n.Len()
 	}
-	return result
+	return count
 }
 
-// RemoveExpired removes all expired keys from the store.
+// Bytes returns the approximate memory used by stored entries
+// across all namespaces.
+func (s *Store) Bytes() int64 {
+	var total int64
+	for _, n := range s.Namespaces() {
+		total += n.Bytes()
+	}
+	return total
+}
+
+// RemoveExpired removes all expired keys from every namespace.
 //
 // This will be used by the background TTL cleaner.
 func (s *Store) RemoveExpired() int {
-	now := time.Now()
 	removed := 0
-
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	for k, v := range s.data {
-		if v.IsExpired(now) {
-			delete(s.data, k)
-			removed++
-		}
+	for _, n := range s.Namespaces() {
+		removed += n.RemoveExpired()
 	}
+	return removed
+}
 
-	if removed > 0 {
-		s.metrics.Add(metrics.CacheExpiredTotal, int64(removed))
-		s.metrics.Add(metrics.CacheKeysTotal, -int64(removed))
+// PurgeTombstones removes tombstones recorded more than grace ago
+// from every namespace.
+//
+// The grace period must outlast replication retries and peer outages:
+// once a tombstone is purged, a delayed older write can revive the key.
+//
+// This will be used by the background TTL cleaner.
+func (s *Store) PurgeTombstones(grace time.Duration) int {
+	purged := 0
+	for _, n := range s.Namespaces() {
+		purged += n.PurgeTombstones(grace)
 	}
-
-	return removed
+	return purged
 }
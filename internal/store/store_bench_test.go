@@ -0,0 +1,102 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"distributed-cache/internal/metrics"
+)
+
+// Parallel benchmarks compare a single shard (one global lock) with the
+// default shard count. Run them across core counts to see the scaling:
+//
+//	go test ./internal/store -run '^$' -bench Parallel -cpu 1,2,4,8
+
+const benchKeys = 1 << 14
+
+func benchStores() []struct {
+	name   string
+	shards int
+} {
+	return []struct {
+		name   string
+		shards int
+	}{
+		{"shards=1", 1},
+		{fmt.Sprintf("shards=%d", DefaultShards), DefaultShards},
+	}
+}
+
+func newBenchStore(shards int) (*Store, []string) {
+	s := NewStore(metrics.NewRegistry(), WithShards(shards))
+
+	keys := make([]string, benchKeys)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+		s.Set(keys[i], Entry{Value: []byte("value"), Timestamp: 1})
+	}
+	return s, keys
+}
+
+func BenchmarkStoreGetParallel(b *testing.B) {
+	for _, bs := range benchStores() {
+		b.Run(bs.name, func(b *testing.B) {
+			s, keys := newBenchStore(bs.shards)
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					s.Get(keys[i%benchKeys])
+					i++
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkStoreSetParallel(b *testing.B) {
+	for _, bs := range benchStores() {
+		b.Run(bs.name, func(b *testing.B) {
+			s, keys := newBenchStore(bs.shards)
+			var ts atomic.Int64
+			ts.Store(1)
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					s.Set(keys[i%benchKeys], Entry{Value: []byte("value"), Timestamp: ts.Add(1)})
+					i++
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkStoreMixedParallel runs 90% reads and 10% writes.
+func BenchmarkStoreMixedParallel(b *testing.B) {
+	for _, bs := range benchStores() {
+		b.Run(bs.name, func(b *testing.B) {
+			s, keys := newBenchStore(bs.shards)
+			var ts atomic.Int64
+			ts.Store(1)
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					key := keys[i%benchKeys]
+					if i%10 == 0 {
+						s.Set(key, Entry{Value: []byte("value"), Timestamp: ts.Add(1)})
+					} else {
+						s.Get(key)
+					}
+					i++
+				}
+			})
+		})
+	}
+}
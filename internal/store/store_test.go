@@ -1,6 +1,7 @@
 package store
 
 import (
+	"fmt"
 	"sync"
 	"testing"
 	"time"
@@ -16,13 +17,13 @@ func TestStoreGet_Set(t *testing.T) {
 
 	t.Run("set and get existing key", func(t *testing.T) {
 		store.Set("key1", Entry{
-			Value:     "hello",
+			Value:     []byte("hello"),
 			Timestamp: 1,
 		})
 
 		val, ok := store.Get("key1")
 		require.True(t, ok)
-		assert.Equal(t, "hello", val)
+		assert.Equal(t, "hello", string(val.Value))
 	})
 
 	t.Run("get non-existing key", func(t *testing.T) {
@@ -35,7 +36,7 @@ func TestStoreDelete(t *testing.T) {
 	store := NewStore(metrics.NewRegistry())
 
 	store.Set("key1", Entry{
-		Value:     "1",
+		Value:     []byte("1"),
 		Timestamp: 1,
 	})
 
@@ -49,21 +50,32 @@ func TestStoreLastWriteWins(t *testing.T) {
 	store := NewStore(metrics.NewRegistry())
 
 	store.Set("key1", Entry{
-		Value:     "old",
+		Value:     []byte("old"),
 		Timestamp: 1,
 	})
 
 	val, ok := store.Get("key1")
 	require.True(t, ok)
-	assert.Equal(t, "old", val)
+	assert.Equal(t, "old", string(val.Value))
 
 	store.Set("key1", Entry{
-		Value:     "new",
+		Value:     []byte("new"),
 		Timestamp: 2,
 	})
 
 	val, _ = store.Get("key1")
-	assert.Equal(t, "new", val)
+	assert.Equal(t, "new", string(val.Value))
+}
+
+func TestStoreSet_ReportsStaleWrites(t *testing.T) {
+	store := NewStore(metrics.NewRegistry())
+
+	assert.True(t, store.Set("key1", Entry{Value: []byte("new"), Timestamp: 2}))
+	assert.False(t, store.Set("key1", Entry{Value: []byte("old"), Timestamp: 1}))
+	assert.False(t, store.Set("key1", Entry{Value: []byte("same"), Timestamp: 2}))
+
+	val, _ := store.Get("key1")
+	assert.Equal(t, "new", string(val.Value))
 }
 
 func TestStoreConcurrentWrites(t *testing.T) {
@@ -76,7 +88,7 @@ func TestStoreConcurrentWrites(t *testing.T) {
 		go func(ts int64) {
 			defer wg.Done()
 			store.Set("key", Entry{
-				Value:     "value",
+				Value:     []byte("value"),
 				Timestamp: ts,
 			})
 		}(int64(i))
@@ -88,17 +100,66 @@ func TestStoreConcurrentWrites(t *testing.T) {
 	assert.True(t, ok)
 }
 
+func TestStoreExpire(t *testing.T) {
+	store := NewStore(metrics.NewRegistry())
+	future := time.Now().Add(time.Hour)
+
+	t.Run("sets the expiry as a new write", func(t *testing.T) {
+		store.Set("k", Entry{Value: []byte("v"), Timestamp: 1})
+
+		stored, ok := store.Expire("k", future)
+		require.True(t, ok)
+		assert.Equal(t, "v", string(stored.Value))
+		assert.Equal(t, uint64(2), stored.Version)
+		assert.Greater(t, stored.Timestamp, int64(1))
+		assert.True(t, stored.ExpiresAt.Equal(future))
+
+		stored, ok = store.Expire("k", time.Time{})
+		require.True(t, ok)
+		assert.True(t, stored.ExpiresAt.IsZero(), "a zero expiry persists the key")
+	})
+
+	t.Run("keeps counters", func(t *testing.T) {
+		_, err := store.Incr("hits", "node-A", 5, 0)
+		require.NoError(t, err)
+
+		stored, ok := store.Expire("hits", future)
+		require.True(t, ok)
+		assert.Equal(t, int64(5), stored.Counter.Value())
+		assert.True(t, stored.ExpiresAt.Equal(future))
+	})
+
+	t.Run("missing, deleted or expired keys", func(t *testing.T) {
+		store.Set("deleted", Tombstone(1))
+		store.Set("expired", Entry{Value: []byte("v"), Timestamp: 1, ExpiresAt: time.Now().Add(-time.Second)})
+
+		for _, key := range []string{"missing", "deleted", "expired"} {
+			_, ok := store.Expire(key, future)
+			assert.False(t, ok, key)
+		}
+	})
+
+	t.Run("expired by the new expiry", func(t *testing.T) {
+		store.Set("soon", Entry{Value: []byte("v"), Timestamp: 1})
+
+		_, ok := store.Expire("soon", time.Now().Add(-time.Millisecond))
+		require.True(t, ok)
+		_, ok = store.Get("soon")
+		assert.False(t, ok)
+	})
+}
+
 func TestStoreRemoveExpired(t *testing.T) {
 	store := NewStore(metrics.NewRegistry())
 
 	store.Set("k1", Entry{
-		Value:     "v1",
+		Value:     []byte("v1"),
 		Timestamp: 1,
 		ExpiresAt: time.Now().Add(-time.Second),
 	})
 
 	store.Set("k2", Entry{
-		Value:     "v2",
+		Value:     []byte("v2"),
 		Timestamp: 2,
 	})
 
@@ -116,13 +177,13 @@ func TestStoreList_FiltersExpiredKeys(t *testing.T) {
 	store := NewStore(metrics.NewRegistry())
 
 	store.Set("alive", Entry{
-		Value:     "ok",
+		Value:     []byte("ok"),
 		Timestamp: 1,
 		ExpiresAt: time.Now().Add(time.Second),
 	})
 
 	store.Set("expired", Entry{
-		Value:     "gone",
+		Value:     []byte("gone"),
 		Timestamp: 2,
 		ExpiresAt: time.Now().Add(-time.Second),
 	})
@@ -141,7 +202,7 @@ func TestStoreGet_ExpiredKeyIsDeleted(t *testing.T) {
 	store := NewStore(reg)
 
 	store.Set("temp", Entry{
-		Value:     "value",
+		Value:     []byte("value"),
 		Timestamp: 1,
 		ExpiresAt: time.Now().Add(-time.Millisecond),
 	})
@@ -150,7 +211,7 @@ func TestStoreGet_ExpiredKeyIsDeleted(t *testing.T) {
 	val, ok := store.Get("temp")
 
 	assert.False(t, ok)
-	assert.Equal(t, "", val)
+	assert.Equal(t, "", string(val.Value))
 
 	// Ensure key was deleted
 	_, ok = store.Get("temp")
@@ -161,3 +222,332 @@ func TestStoreGet_ExpiredKeyIsDeleted(t *testing.T) {
 	assert.Equal(t, int64(1), snap[string(metrics.CacheExpiredTotal)])
 	assert.Equal(t, int64(0), snap[string(metrics.CacheKeysTotal)])
 }
+
+func TestStoreTombstones(t *testing.T) {
+	reg := metrics.NewRegistry()
+	store := NewStore(reg)
+
+	store.Set("key", Entry{Value: []byte("v1"), Timestamp: 1})
+	require.True(t, store.Set("key", Tombstone(5)))
+
+	t.Run("tombstone hides key", func(t *testing.T) {
+		_, ok := store.Get("key")
+		assert.False(t, ok)
+
+		_, listed := store.List()["key"]
+		assert.False(t, listed)
+	})
+
+	t.Run("older write does not resurrect", func(t *testing.T) {
+		assert.False(t, store.Set("key", Entry{Value: []byte("late"), Timestamp: 3}))
+
+		_, ok := store.Get("key")
+		assert.False(t, ok)
+	})
+
+	t.Run("newer write revives", func(t *testing.T) {
+		assert.True(t, store.Set("key", Entry{Value: []byte("v2"), Timestamp: 6}))
+
+		val, ok := store.Get("key")
+		require.True(t, ok)
+		assert.Equal(t, "v2", string(val.Value))
+	})
+
+	t.Run("older tombstone is rejected", func(t *testing.T) {
+		assert.False(t, store.Set("key", Tombstone(4)))
+
+		_, ok := store.Get("key")
+		assert.True(t, ok)
+	})
+
+	snap := reg.Snapshot()
+	assert.Equal(t, int64(1), snap[string(metrics.CacheKeysTotal)])
+	assert.Equal(t, int64(2), snap[string(metrics.CacheDeletesTotal)])
+}
+
+func TestStorePurgeTombstones(t *testing.T) {
+	store := NewStore(metrics.NewRegistry())
+
+	old := Tombstone(1)
+	old.DeletedAt = time.Now().Add(-time.Hour)
+	store.Set("old", old)
+	store.Set("recent", Tombstone(1))
+	store.Set("live", Entry{Value: []byte("v"), Timestamp: 1})
+
+	assert.Equal(t, 1, store.PurgeTombstones(time.Minute))
+
+	// The purged key no longer blocks older writes.
+	assert.True(t, store.Set("old", Entry{Value: []byte("v"), Timestamp: 1}))
+
+	// The recent tombstone is still protecting its key.
+	assert.False(t, store.Set("recent", Entry{Value: []byte("v"), Timestamp: 1}))
+
+	_, ok := store.Get("live")
+	assert.True(t, ok)
+}
+
+func TestStoreLen_CountsLiveKeysOnly(t *testing.T) {
+	store := NewStore(metrics.NewRegistry())
+
+	store.Set("live", Entry{Value: []byte("v"), Timestamp: 1})
+	store.Set("expired", Entry{Value: []byte("v"), Timestamp: 1, ExpiresAt: time.Now().Add(-time.Second)})
+	store.Set("deleted", Tombstone(1))
+
+	assert.Equal(t, 1, store.Len())
+}
+
+func TestStoreMaxEntries_EvictsLeastRecentlyUsed(t *testing.T) {
+	reg := metrics.NewRegistry()
+	store := NewStore(reg, WithMaxEntries(2))
+
+	store.Set("a", Entry{Value: []byte("1"), Timestamp: 1})
+	store.Set("b", Entry{Value: []byte("2"), Timestamp: 1})
+
+	// Reading "a" makes "b" the least recently used key.
+	_, ok := store.Get("a")
+	require.True(t, ok)
+
+	store.Set("c", Entry{Value: []byte("3"), Timestamp: 1})
+
+	_, ok = store.Get("b")
+	assert.False(t, ok, "least recently used key is evicted")
+	_, ok = store.Get("a")
+	assert.True(t, ok)
+	_, ok = store.Get("c")
+	assert.True(t, ok)
+
+	snap := reg.Snapshot()
+	assert.Equal(t, int64(1), snap[string(metrics.CacheEvictionsTotal)])
+	assert.Equal(t, int64(2), snap[string(metrics.CacheKeysTotal)])
+}
+
+func TestStoreMaxBytes(t *testing.T) {
+	reg := metrics.NewRegistry()
+	limit := 2 * entrySize("k1", Entry{Value: []byte("0123456789")})
+	store := NewStore(reg, WithMaxBytes(limit))
+
+	store.Set("k1", Entry{Value: []byte("0123456789"), Timestamp: 1})
+	store.Set("k2", Entry{Value: []byte("0123456789"), Timestamp: 1})
+	assert.Equal(t, limit, store.Bytes())
+
+	store.Set("k3", Entry{Value: []byte("0123456789"), Timestamp: 1})
+	assert.Equal(t, limit, store.Bytes())
+
+	_, ok := store.Get("k1")
+	assert.False(t, ok)
+
+	snap := reg.Snapshot()
+	assert.Equal(t, int64(1), snap[string(metrics.CacheEvictionsTotal)])
+	assert.Equal(t, limit, snap[string(metrics.CacheBytes)])
+
+	t.Run("oversized entry is not retained", func(t *testing.T) {
+		big := make([]byte, limit)
+		assert.True(t, store.Set("big", Entry{Value: big, Timestamp: 1}))
+
+		_, ok := store.Get("big")
+		assert.False(t, ok)
+		assert.LessOrEqual(t, store.Bytes(), limit)
+	})
+}
+
+func TestStoreEviction_StaleWriteDoesNotRefreshRecency(t *testing.T) {
+	store := NewStore(metrics.NewRegistry(), WithMaxEntries(2))
+
+	store.Set("a", Entry{Value: []byte("1"), Timestamp: 5})
+	store.Set("b", Entry{Value: []byte("2"), Timestamp: 5})
+
+	// Rejected by LWW, so "a" stays the least recently used key.
+	assert.False(t, store.Set("a", Entry{Value: []byte("old"), Timestamp: 1}))
+
+	store.Set("c", Entry{Value: []byte("3"), Timestamp: 5})
+
+	_, ok := store.Get("a")
+	assert.False(t, ok)
+	_, ok = store.Get("b")
+	assert.True(t, ok)
+}
+
+func TestStoreEviction_ExpiredVictimCountsAsExpired(t *testing.T) {
+	reg := metrics.NewRegistry()
+	store := NewStore(reg, WithMaxEntries(1))
+
+	store.Set("old", Entry{Value: []byte("v"), Timestamp: 1, ExpiresAt: time.Now().Add(-time.Second)})
+	store.Set("new", Entry{Value: []byte("v"), Timestamp: 1})
+
+	snap := reg.Snapshot()
+	assert.Equal(t, int64(0), snap[string(metrics.CacheEvictionsTotal)])
+	assert.Equal(t, int64(1), snap[string(metrics.CacheExpiredTotal)])
+}
+
+func TestStoreEviction_KeepsTombstones(t *testing.T) {
+	reg := metrics.NewRegistry()
+	store := NewStore(reg, WithShards(1), WithMaxEntries(2))
+
+	for i := range 5 {
+		key := fmt.Sprintf("deleted-%d", i)
+		store.Set(key, Entry{Value: []byte("v"), Timestamp: 1})
+		store.Set(key, Tombstone(5))
+	}
+
+	// Tombstones do not count toward the limit ...
+	store.Set("a", Entry{Value: []byte("1"), Timestamp: 1})
+	store.Set("b", Entry{Value: []byte("2"), Timestamp: 1})
+	assert.Equal(t, int64(0), reg.Snapshot()[string(metrics.CacheEvictionsTotal)])
+
+	// ... and are never evicted, so older writes stay rejected.
+	store.Set("c", Entry{Value: []byte("3"), Timestamp: 1})
+	assert.Equal(t, int64(1), reg.Snapshot()[string(metrics.CacheEvictionsTotal)])
+	assert.Len(t, store.Entries(), 7)
+	for i := range 5 {
+		assert.False(t, store.Set(fmt.Sprintf("deleted-%d", i), Entry{Value: []byte("old"), Timestamp: 3}))
+	}
+
+	// A key written again after its delete is tracked for eviction again.
+	assert.True(t, store.Set("deleted-0", Entry{Value: []byte("new"), Timestamp: 6}))
+	assert.Equal(t, 2, store.Len())
+	assert.Equal(t, int64(2), reg.Snapshot()[string(metrics.CacheEvictionsTotal)])
+}
+
+func TestStoreBytes_TracksUpdatesAndRemovals(t *testing.T) {
+	reg := metrics.NewRegistry()
+	store := NewStore(reg)
+
+	store.Set("k", Entry{Value: []byte("short"), Timestamp: 1})
+	store.Set("k", Entry{Value: []byte("a much longer value"), Timestamp: 2})
+	assert.Equal(t, entrySize("k", Entry{Value: []byte("a much longer value")}), store.Bytes())
+
+	store.Set("tmp", Entry{Value: []byte("v"), Timestamp: 1, ExpiresAt: time.Now().Add(-time.Second)})
+	store.RemoveExpired()
+	assert.Equal(t, entrySize("k", Entry{Value: []byte("a much longer value")}), store.Bytes())
+
+	store.Delete("k")
+	assert.Equal(t, entrySize("k", Entry{}), store.Bytes())
+
+	store.PurgeTombstones(0)
+	assert.Equal(t, int64(0), store.Bytes())
+	assert.Equal(t, int64(0), reg.Snapshot()[string(metrics.CacheBytes)])
+}
+
+func TestNewStore_ShardCount(t *testing.T) {
+	reg := metrics.NewRegistry()
+
+	assert.Len(t, NewStore(reg).Default().shards, DefaultShards)
+	assert.Len(t, NewStore(reg, WithShards(4)).Default().shards, 4)
+	assert.Len(t, NewStore(reg, WithShards(0)).Default().shards, DefaultShards, "invalid count is ignored")
+
+	// Small limits shrink the shard count.
+	assert.Len(t, NewStore(reg, WithMaxEntries(10)).Default().shards, 1)
+	assert.Len(t, NewStore(reg, WithMaxEntries(4*minShardEntries)).Default().shards, 4)
+	assert.Len(t, NewStore(reg, WithMaxBytes(2*minShardBytes)).Default().shards, 2)
+}
+
+func TestSplitLimit(t *testing.T) {
+	var total int64
+	for i := range 7 {
+		share := splitLimit(100, 7, i)
+		assert.InDelta(t, 100/7, share, 1)
+		total += share
+	}
+	assert.Equal(t, int64(100), total)
+
+	assert.Equal(t, int64(0), splitLimit(0, 7, 3), "unbounded stays unbounded")
+}
+
+func TestStoreShards_EnforceLimitStoreWide(t *testing.T) {
+	reg := metrics.NewRegistry()
+	store := NewStore(reg, WithShards(8), WithMaxEntries(8*minShardEntries))
+
+	for i := range 5000 {
+		store.Set(fmt.Sprintf("key-%d", i), Entry{Value: []byte("v"), Timestamp: 1})
+	}
+
+	assert.Equal(t, 8*minShardEntries, store.Len())
+
+	snap := reg.Snapshot()
+	assert.Equal(t, int64(store.Len()), snap[string(metrics.CacheKeysTotal)])
+	assert.Equal(t, int64(5000-store.Len()), snap[string(metrics.CacheEvictionsTotal)])
+	assert.Equal(t, store.Bytes(), snap[string(metrics.CacheBytes)])
+}
+
+func TestStoreShards_ConcurrentMixedAccess(t *testing.T) {
+	reg := metrics.NewRegistry()
+	store := NewStore(reg, WithShards(8))
+
+	var wg sync.WaitGroup
+	for w := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 500 {
+				key := fmt.Sprintf("key-%d", i%100)
+				ts := int64(w*1000 + i + 1)
+
+				switch i % 4 {
+				case 0, 1:
+					store.Set(key, Entry{Value: []byte("v"), Timestamp: ts})
+				case 2:
+					store.Get(key)
+				case 3:
+					store.Set(key, Tombstone(ts))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Metrics stay consistent with the shards' contents.
+	snap := reg.Snapshot()
+	assert.Equal(t, int64(store.Len()), snap[string(metrics.CacheKeysTotal)])
+	assert.Equal(t, int64(len(store.List())), snap[string(metrics.CacheKeysTotal)])
+	assert.Equal(t, store.Bytes(), snap[string(metrics.CacheBytes)])
+	assert.Equal(t, int64(16*500/4), snap[string(metrics.CacheGetsTotal)])
+}
+
+func TestStoreShards_SharedReads(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want bool
+	}{
+		{"Unbounded", nil, true},
+		{"BoundedLRU", []Option{WithMaxEntries(1000)}, false},
+		{"BoundedRandom", []Option{WithMaxEntries(1000), WithEvictionPolicy(NewRandomPolicy)}, true},
+		{"BoundedTTL", []Option{WithMaxBytes(1 << 20), WithEvictionPolicy(NewTTLPolicy)}, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := metrics.NewRegistry()
+			store := NewStore(reg, tc.opts...)
+			for _, sh := range store.def.shards {
+				assert.Equal(t, tc.want, sh.sharedReads)
+			}
+
+			// Reads behave the same under either lock.
+			store.Set("live", Entry{Value: []byte("v"), Timestamp: 1})
+			store.Set("old", Entry{Value: []byte("v"), Timestamp: 1, ExpiresAt: time.Now().Add(-time.Second)})
+
+			_, ok := store.Get("live")
+			assert.True(t, ok)
+			_, ok = store.Get("old")
+			assert.False(t, ok)
+			assert.NotContains(t, store.Entries(), "old")
+
+			snap := reg.Snapshot()
+			assert.Equal(t, int64(2), snap[string(metrics.CacheGetsTotal)])
+			assert.Equal(t, int64(1), snap[string(metrics.CacheExpiredTotal)])
+		})
+	}
+}
+
+func TestStoreEntries_IncludesTombstones(t *testing.T) {
+	store := NewStore(metrics.NewRegistry())
+
+	store.Set("live", Entry{Value: []byte("v"), Timestamp: 1})
+	store.Set("deleted", Tombstone(2))
+	store.Set("expired", Entry{Value: []byte("v"), Timestamp: 3, ExpiresAt: time.Now().Add(-time.Second)})
+
+	entries := store.Entries()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "v", string(entries["live"].Value))
+	assert.True(t, entries["deleted"].Deleted)
+}
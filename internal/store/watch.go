@@ -0,0 +1,295 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"distributed-cache/internal/metrics"
+)
+
+// EventType names the kind of change an Event reports.
+type EventType string
+
+const (
+	EventSet    EventType = "set"
+	EventDelete EventType = "delete"
+	EventExpire EventType = "expire"
+)
+
+// Event is one change to a key, as seen by watchers. It carries the
+// written entry's metadata but not its value, so the retained history
+// pins no values outside the store's limits.
+//
+// Seq numbers every event of a Store, across namespaces, in the order the
+// changes were applied; changes to one key are never reordered. Sequence
+// numbers restart from 1 when the node restarts.
+type Event struct {
+	Seq       uint64
+	Type      EventType
+	Namespace string
+	Key       string
+	Version   uint64
+	Timestamp int64 // the entry's LWW timestamp
+	Time      time.Time
+}
+
+// DefaultWatchHistory is the number of recent events kept for resuming
+// watches unless WithWatchHistory overrides it. Resuming is opt-in:
+// retaining events makes every write go through the store-wide watch lock.
+const DefaultWatchHistory = 0
+
+// DefaultWatchBuffer is the per-watcher buffer used when WatchOptions.Buffer is unset.
+const DefaultWatchBuffer = 256
+
+var (
+	// ErrSlowConsumer ends a watch whose buffer filled up.
+	ErrSlowConsumer = errors.New("watcher fell behind and was disconnected")
+
+	// ErrWatchGap is returned when a watch cannot resume without missing events.
+	ErrWatchGap = errors.New("events to resume from are no longer retained")
+
+	// ErrNoWatchHistory is returned when a watch asks to resume from a
+	// Store that keeps no history (see WithWatchHistory).
+	ErrNoWatchHistory = errors.New("watch history is disabled")
+
+	// ErrWatchClosed ends the watches of a Store that stopped them.
+	ErrWatchClosed = errors.New("watches stopped")
+)
+
+// WatchOptions select the events a watch receives.
+type WatchOptions struct {
+	// Namespace to watch; empty means the default namespace.
+	Namespace string
+
+	// Prefix limits the watch to keys starting with it.
+	Prefix string
+
+	// After resumes the watch: retained events with a higher Seq are
+	// delivered first. Zero starts with new events only.
+	After uint64
+
+	// Buffer bounds the events queued for the watcher; a watcher that
+	// falls further behind is disconnected with ErrSlowConsumer.
+	Buffer int
+}
+
+// WithWatchHistory sets how many recent events are kept for resuming
+// watches; zero disables resuming.
+func WithWatchHistory(n int) Option {
+	return func(s *Store) {
+		if n >= 0 {
+			s.watchHistory = n
+		}
+	}
+}
+
+// Watcher receives the events of one watch.
+type Watcher struct {
+	hub    *watchHub
+	opts   WatchOptions
+	events chan Event
+	err    error // guarded by hub.mu; set before events is closed
+}
+
+// Events returns the watch's events. The channel is closed when the
+// watch ends; Err then reports why.
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// Err returns ErrSlowConsumer or ErrWatchClosed once Events is closed,
+// and nil while the watch runs or after Close.
+func (w *Watcher) Err() error {
+	w.hub.mu.Lock()
+	defer w.hub.mu.Unlock()
+
+	return w.err
+}
+
+// Close ends the watch. It is safe to call more than once.
+func (w *Watcher) Close() {
+	w.hub.mu.Lock()
+	defer w.hub.mu.Unlock()
+
+	w.hub.removeLocked(w, nil)
+}
+
+func (w *Watcher) matches(ev Event) bool {
+	return ev.Namespace == w.opts.Namespace && strings.HasPrefix(ev.Key, w.opts.Prefix)
+}
+
+// Watch subscribes to set, delete and expire events of one namespace.
+//
+// Events are published while the change is applied, without blocking
+// writers: a watcher whose buffer is full is disconnected instead.
+//
+// Returns ErrNoWatchHistory when opts.After is set but no history is kept,
+// and ErrWatchGap when opts.After is older than the retained history or
+// newer than the latest event (e.g. from before a restart).
+func (s *Store) Watch(opts WatchOptions) (*Watcher, error) {
+	if opts.Namespace == "" {
+		opts.Namespace = DefaultNamespace
+	}
+	if opts.Buffer < 1 {
+		opts.Buffer = DefaultWatchBuffer
+	}
+	return s.events.subscribe(opts)
+}
+
+// StopWatches ends every watch with ErrWatchClosed, e.g. on shutdown.
+func (s *Store) StopWatches() {
+	s.events.stop()
+}
+
+// watchHub numbers events, keeps the recent ones and fans them out.
+type watchHub struct {
+	metrics     *metrics.Registry
+	historySize int
+
+	// seq and watching are also read without mu by publish's fast path.
+	seq      atomic.Uint64
+	watching atomic.Int64
+
+	mu       sync.Mutex
+	history  []Event // ring buffer of the newest events
+	next     int     // history slot written next
+	watchers map[*Watcher]struct{}
+	stopped  bool
+}
+
+func newWatchHub(registry *metrics.Registry, history int) *watchHub {
+	return &watchHub{
+		metrics:     registry,
+		historySize: history,
+		history:     make([]Event, 0, history),
+		watchers:    make(map[*Watcher]struct{}),
+	}
+}
+
+// publish records an event and delivers it to matching watchers.
+func (h *watchHub) publish(typ EventType, namespace, key string, entry Entry) {
+	// With nothing to record or deliver, only number the event, so that
+	// a later resume still detects the gap.
+	if h.historySize == 0 && h.watching.Load() == 0 {
+		h.seq.Add(1)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ev := Event{
+		Seq:       h.seq.Add(1),
+		Type:      typ,
+		Namespace: namespace,
+		Key:       key,
+		Version:   entry.Version,
+		Timestamp: entry.Timestamp,
+		Time:      time.Now(),
+	}
+
+	if cap(h.history) > 0 {
+		if len(h.history) < cap(h.history) {
+			h.history = append(h.history, ev)
+		} else {
+			h.history[h.next] = ev
+		}
+		h.next = (h.next + 1) % cap(h.history)
+	}
+
+	for w := range h.watchers {
+		if !w.matches(ev) {
+			continue
+		}
+		select {
+		case w.events <- ev:
+		default:
+			h.metrics.Inc(metrics.WatchSlowConsumersTotal)
+			h.removeLocked(w, ErrSlowConsumer)
+		}
+	}
+}
+
+func (h *watchHub) subscribe(opts WatchOptions) (*Watcher, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.stopped {
+		return nil, ErrWatchClosed
+	}
+
+	var replay []Event
+	if opts.After > 0 && h.historySize == 0 {
+		return nil, ErrNoWatchHistory
+	}
+	if opts.After > 0 {
+		seq := h.seq.Load()
+		if opts.After > seq || opts.After+1 < h.oldestLocked() {
+			return nil, fmt.Errorf("%w: resume after %d, retained %d to %d", ErrWatchGap, opts.After, h.oldestLocked(), seq)
+		}
+		replay = h.sinceLocked(opts.After)
+	}
+
+	w := &Watcher{hub: h, opts: opts}
+	var matched []Event
+	for _, ev := range replay {
+		if w.matches(ev) {
+			matched = append(matched, ev)
+		}
+	}
+
+	// Replayed events never count against the buffer.
+	w.events = make(chan Event, opts.Buffer+len(matched))
+	for _, ev := range matched {
+		w.events <- ev
+	}
+
+	h.watchers[w] = struct{}{}
+	h.watching.Add(1)
+	h.metrics.Inc(metrics.WatchSubscribers)
+	return w, nil
+}
+
+// oldestLocked returns the Seq of the oldest retained event, or the next
+// Seq when none is retained.
+func (h *watchHub) oldestLocked() uint64 {
+	return h.seq.Load() + 1 - uint64(len(h.history))
+}
+
+// sinceLocked returns the retained events with a Seq above after, oldest first.
+func (h *watchHub) sinceLocked(after uint64) []Event {
+	n := int(h.seq.Load() - after)
+	out := make([]Event, 0, n)
+
+	start := h.next - n
+	for i := range n {
+		out = append(out, h.history[(start+i+cap(h.history))%cap(h.history)])
+	}
+	return out
+}
+
+// removeLocked ends a watch with err; ending it twice is a no-op.
+func (h *watchHub) removeLocked(w *Watcher, err error) {
+	if _, ok := h.watchers[w]; !ok {
+		return
+	}
+	delete(h.watchers, w)
+	h.watching.Add(-1)
+	w.err = err
+	close(w.events)
+	h.metrics.Add(metrics.WatchSubscribers, -1)
+}
+
+func (h *watchHub) stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.stopped = true
+	for w := range h.watchers {
+		h.removeLocked(w, ErrWatchClosed)
+	}
+}
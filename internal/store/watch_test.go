@@ -0,0 +1,180 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"distributed-cache/internal/metrics"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// queued returns the events already queued for w.
+func queued(w *Watcher) []Event {
+	var events []Event
+	for {
+		select {
+		case ev, ok := <-w.Events():
+			if !ok {
+				return events
+			}
+			events = append(events, ev)
+		default:
+			return events
+		}
+	}
+}
+
+func eventKeys(events []Event) []string {
+	keys := make([]string, len(events))
+	for i, ev := range events {
+		keys[i] = fmt.Sprintf("%s %s", ev.Type, ev.Key)
+	}
+	return keys
+}
+
+func TestWatch_SetDeleteExpire(t *testing.T) {
+	s := NewStore(metrics.NewRegistry())
+	w, err := s.Watch(WatchOptions{})
+	require.NoError(t, err)
+	defer w.Close()
+
+	s.Set("a", Entry{Value: []byte("1"), Timestamp: 1})
+	s.Set("a", Entry{Value: []byte("stale"), Timestamp: 0})
+	s.Delete("a")
+	s.Set("ttl", Entry{Value: []byte("1"), Timestamp: 1, ExpiresAt: time.Now().Add(-time.Second)})
+	s.Get("ttl")
+	s.Set("swept", Entry{Value: []byte("1"), Timestamp: 1, ExpiresAt: time.Now().Add(-time.Second)})
+	s.RemoveExpired()
+	s.Restore("restored", Entry{Value: []byte("1"), Timestamp: 1})
+
+	events := queued(w)
+	assert.Equal(t, []string{"set a", "delete a", "set ttl", "expire ttl", "set swept", "expire swept"}, eventKeys(events))
+	for i, ev := range events {
+		assert.Equal(t, uint64(i+1), ev.Seq)
+		assert.Equal(t, DefaultNamespace, ev.Namespace)
+	}
+	assert.Equal(t, uint64(2), events[1].Version)
+}
+
+func TestWatch_Filters(t *testing.T) {
+	s := NewStore(metrics.NewRegistry())
+	orders, err := s.CreateNamespace("orders", NamespaceSettings{})
+	require.NoError(t, err)
+
+	users, err := s.Watch(WatchOptions{Prefix: "user:"})
+	require.NoError(t, err)
+	inOrders, err := s.Watch(WatchOptions{Namespace: "orders"})
+	require.NoError(t, err)
+
+	s.Set("user:1", Entry{Value: []byte("1"), Timestamp: 1})
+	s.Set("order:1", Entry{Value: []byte("1"), Timestamp: 1})
+	orders.Set("user:2", Entry{Value: []byte("1"), Timestamp: 1})
+
+	assert.Equal(t, []string{"set user:1"}, eventKeys(queued(users)))
+
+	events := queued(inOrders)
+	assert.Equal(t, []string{"set user:2"}, eventKeys(events))
+	assert.Equal(t, "orders", events[0].Namespace)
+	assert.Equal(t, uint64(3), events[0].Seq, "sequence numbers span namespaces")
+}
+
+func TestWatch_Resume(t *testing.T) {
+	s := NewStore(metrics.NewRegistry(), WithWatchHistory(3))
+	for i := range 5 {
+		s.Set(fmt.Sprintf("k%d", i), Entry{Value: []byte("v"), Timestamp: 1})
+	}
+
+	w, err := s.Watch(WatchOptions{After: 2})
+	require.NoError(t, err)
+	s.Set("k5", Entry{Value: []byte("v"), Timestamp: 1})
+	assert.Equal(t, []string{"set k2", "set k3", "set k4", "set k5"}, eventKeys(queued(w)))
+	w.Close()
+
+	w, err = s.Watch(WatchOptions{After: 6})
+	require.NoError(t, err, "resuming at the latest event replays nothing")
+	assert.Empty(t, queued(w))
+	w.Close()
+
+	_, err = s.Watch(WatchOptions{After: 2})
+	assert.ErrorIs(t, err, ErrWatchGap, "event 3 was dropped from the history")
+
+	_, err = s.Watch(WatchOptions{After: 7})
+	assert.ErrorIs(t, err, ErrWatchGap, "a sequence number from the future (e.g. before a restart)")
+}
+
+func TestWatch_ResumeWithoutHistory(t *testing.T) {
+	s := NewStore(metrics.NewRegistry())
+	s.Set("a", Entry{Value: []byte("v"), Timestamp: 1})
+
+	w, err := s.Watch(WatchOptions{After: 0})
+	require.NoError(t, err)
+	w.Close()
+
+	_, err = s.Watch(WatchOptions{After: 1})
+	assert.ErrorIs(t, err, ErrNoWatchHistory, "no history is kept by default")
+}
+
+func TestWatch_NumbersEventsWithoutWatchers(t *testing.T) {
+	s := NewStore(metrics.NewRegistry(), WithWatchHistory(0))
+	s.Set("a", Entry{Value: []byte("v"), Timestamp: 1})
+	s.Set("b", Entry{Value: []byte("v"), Timestamp: 1})
+
+	w, err := s.Watch(WatchOptions{})
+	require.NoError(t, err)
+	defer w.Close()
+
+	s.Set("c", Entry{Value: []byte("v"), Timestamp: 1})
+	assert.Equal(t, uint64(3), (<-w.Events()).Seq)
+}
+
+func TestWatch_SlowConsumerIsDisconnected(t *testing.T) {
+	reg := metrics.NewRegistry()
+	s := NewStore(reg)
+
+	slow, err := s.Watch(WatchOptions{Buffer: 2})
+	require.NoError(t, err)
+	fast, err := s.Watch(WatchOptions{Buffer: 10})
+	require.NoError(t, err)
+	defer fast.Close()
+
+	for i := range 3 {
+		assert.True(t, s.Set(fmt.Sprintf("k%d", i), Entry{Value: []byte("v"), Timestamp: 1}), "writers never block")
+	}
+
+	assert.Len(t, queued(slow), 2, "buffered events are still delivered")
+	_, open := <-slow.Events()
+	assert.False(t, open)
+	assert.ErrorIs(t, slow.Err(), ErrSlowConsumer)
+
+	assert.Len(t, queued(fast), 3)
+	assert.NoError(t, fast.Err())
+
+	snap := reg.Snapshot()
+	assert.Equal(t, int64(1), snap[string(metrics.WatchSlowConsumersTotal)])
+	assert.Equal(t, int64(1), snap[string(metrics.WatchSubscribers)])
+}
+
+func TestWatch_CloseAndStop(t *testing.T) {
+	s := NewStore(metrics.NewRegistry())
+
+	w, err := s.Watch(WatchOptions{})
+	require.NoError(t, err)
+	w.Close()
+	w.Close()
+	_, open := <-w.Events()
+	assert.False(t, open)
+	assert.NoError(t, w.Err())
+
+	w, err = s.Watch(WatchOptions{})
+	require.NoError(t, err)
+	s.StopWatches()
+	_, open = <-w.Events()
+	assert.False(t, open)
+	assert.ErrorIs(t, w.Err(), ErrWatchClosed)
+
+	_, err = s.Watch(WatchOptions{})
+	assert.ErrorIs(t, err, ErrWatchClosed)
+}
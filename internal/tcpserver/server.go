@@ -0,0 +1,251 @@
+// Package tcpserver runs the listeners and client connections of the
+// line-protocol front-ends (RESP, memcached), including graceful shutdown.
+// The front-ends only parse and answer commands.
+package tcpserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"distributed-cache/internal/metrics"
+)
+
+// ErrServerClosed is returned by Serve after Shutdown.
+var ErrServerClosed = errors.New("tcpserver: server closed")
+
+// Handler serves one connection until it returns; the connection is then
+// closed. It should wrap each command in Conn.Begin and Conn.End, and stop
+// once Begin fails or Conn.Closing reports a shutdown.
+type Handler func(c *Conn)
+
+// Metrics names the gauge of open connections and, optionally, the
+// counter of accepted ones.
+type Metrics struct {
+	Connections      metrics.MetricKey
+	ConnectionsTotal metrics.MetricKey // empty for none
+}
+
+// Server accepts connections and hands each one to its Handler on its
+// own goroutine.
+type Server struct {
+	handler Handler
+	metrics *metrics.Registry
+	keys    Metrics
+	nextID  atomic.Int64
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[*Conn]struct{}
+	closed    bool
+	active    sync.WaitGroup
+}
+
+// New creates a server; call Serve or ListenAndServe to start it.
+func New(handler Handler, metricsRegistry *metrics.Registry, keys Metrics) *Server {
+	return &Server{
+		handler:   handler,
+		metrics:   metricsRegistry,
+		keys:      keys,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[*Conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on the TCP address addr and serves clients.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts clients on l until Shutdown; it always returns an error,
+// ErrServerClosed after Shutdown.
+func (s *Server) Serve(l net.Listener) error {
+	if !s.track(l) {
+		l.Close()
+		return ErrServerClosed
+	}
+	defer s.untrack(l)
+
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+
+		c := s.newConn(nc)
+		if c == nil {
+			nc.Close()
+			return ErrServerClosed
+		}
+		go s.serveConn(c)
+	}
+}
+
+// Shutdown stops accepting clients, lets every connection finish the
+// command it is running and closes it. It returns ctx.Err() if ctx ends
+// first, after closing the remaining connections.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for c := range s.conns {
+		c.closeIdle()
+	}
+	s.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		s.active.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for c := range s.conns {
+			c.Close()
+		}
+		s.mu.Unlock()
+		<-finished
+		return ctx.Err()
+	}
+}
+
+// NumConns returns the number of connected clients.
+func (s *Server) NumConns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.conns)
+}
+
+func (s *Server) track(l net.Listener) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	s.listeners[l] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(l net.Listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.listeners, l)
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closed
+}
+
+// newConn registers a client connection, or returns nil after Shutdown.
+func (s *Server) newConn(nc net.Conn) *Conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+
+	c := &Conn{Conn: nc, ID: s.nextID.Add(1), server: s}
+	s.conns[c] = struct{}{}
+	s.active.Add(1)
+	s.metrics.Inc(s.keys.Connections)
+	if s.keys.ConnectionsTotal != "" {
+		s.metrics.Inc(s.keys.ConnectionsTotal)
+	}
+	return c
+}
+
+func (s *Server) removeConn(c *Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.conns, c)
+	s.active.Done()
+	s.metrics.Add(s.keys.Connections, -1)
+}
+
+func (s *Server) serveConn(c *Conn) {
+	defer s.removeConn(c)
+	defer c.Close()
+
+	s.handler(c)
+}
+
+// Conn is one client connection. Commands of a connection run one at a time.
+type Conn struct {
+	net.Conn
+
+	// ID numbers the connections of a Server from 1.
+	ID int64
+
+	server *Server
+
+	// busy is set while a command runs; guarded by server.mu.
+	busy    bool
+	closing bool
+}
+
+// Begin marks the connection busy, unless Shutdown is closing it.
+func (c *Conn) Begin() bool {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+
+	if c.closing {
+		return false
+	}
+	c.busy = true
+	return true
+}
+
+// End marks the command started by Begin as finished.
+func (c *Conn) End() {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+
+	c.busy = false
+}
+
+// Closing reports whether Shutdown is closing the connection.
+func (c *Conn) Closing() bool {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+
+	return c.closing
+}
+
+// closeIdle closes the connection unless a command is running, in which
+// case the connection closes itself after replying. Called with server.mu held.
+func (c *Conn) closeIdle() {
+	c.closing = true
+	if !c.busy {
+		// Unblock the pending read; the handler then returns.
+		_ = c.SetReadDeadline(time.Now())
+	}
+}
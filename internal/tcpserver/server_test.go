@@ -0,0 +1,155 @@
+package tcpserver
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/tcpserver/tcptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testConnections      metrics.MetricKey = "test_connections"
+	testConnectionsTotal metrics.MetricKey = "test_connections_total"
+)
+
+type lineServer struct {
+	*Server
+	metrics *metrics.Registry
+	addr    string
+	served  <-chan error
+	slow    chan struct{} // receives when a slow command starts
+	release chan struct{} // closed to finish slow commands
+}
+
+// newLineServer replies to every line with the connection ID; a "slow"
+// line runs until release is closed.
+func newLineServer(t *testing.T) *lineServer {
+	t.Helper()
+
+	ls := &lineServer{
+		metrics: metrics.NewRegistry(),
+		slow:    make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+	handler := func(c *Conn) {
+		r := bufio.NewReader(c)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil || !c.Begin() {
+				return
+			}
+			if line == "slow\n" {
+				ls.slow <- struct{}{}
+				<-ls.release
+			}
+			_, err = c.Write([]byte(strconv.FormatInt(c.ID, 10) + "\n"))
+			c.End()
+			if err != nil || c.Closing() {
+				return
+			}
+		}
+	}
+	ls.Server = New(handler, ls.metrics, Metrics{Connections: testConnections, ConnectionsTotal: testConnectionsTotal})
+	ls.addr, ls.served = tcptest.Serve(t, ls.Server)
+	return ls
+}
+
+func request(t *testing.T, conn net.Conn, r *bufio.Reader, line string) string {
+	t.Helper()
+
+	_, err := conn.Write([]byte(line + "\n"))
+	require.NoError(t, err)
+	reply, err := r.ReadString('\n')
+	require.NoError(t, err)
+	return reply
+}
+
+func TestServer_Connections(t *testing.T) {
+	s := newLineServer(t)
+
+	a := tcptest.Dial(t, s.addr)
+	assert.Equal(t, "1\n", request(t, a, bufio.NewReader(a), "ping"))
+	b := tcptest.Dial(t, s.addr)
+	assert.Equal(t, "2\n", request(t, b, bufio.NewReader(b), "ping"))
+
+	assert.Equal(t, 2, s.NumConns())
+	assert.Equal(t, int64(2), s.metrics.Snapshot()[string(testConnections)])
+
+	a.Close()
+	assert.Eventually(t, func() bool { return s.NumConns() == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(1), s.metrics.Snapshot()[string(testConnections)])
+	assert.Equal(t, int64(2), s.metrics.Snapshot()[string(testConnectionsTotal)])
+}
+
+func TestServer_ShutdownFinishesRunningCommand(t *testing.T) {
+	s := newLineServer(t)
+
+	idle := tcptest.Dial(t, s.addr)
+	idleReader := bufio.NewReader(idle)
+	request(t, idle, idleReader, "ping")
+
+	busy := tcptest.Dial(t, s.addr)
+	busyReader := bufio.NewReader(busy)
+	_, err := busy.Write([]byte("slow\n"))
+	require.NoError(t, err)
+	<-s.slow
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- s.Shutdown(context.Background()) }()
+	assert.ErrorIs(t, <-s.served, ErrServerClosed)
+
+	_, err = idleReader.ReadByte()
+	assert.Error(t, err, "idle connections are closed")
+
+	close(s.release)
+	reply, err := busyReader.ReadString('\n')
+	require.NoError(t, err, "the running command is answered")
+	assert.Equal(t, "2\n", reply)
+	_, err = busyReader.ReadByte()
+	assert.Error(t, err, "then the connection is closed")
+
+	require.NoError(t, <-shutdown)
+	assert.Zero(t, s.NumConns())
+	assert.Zero(t, s.metrics.Snapshot()[string(testConnections)])
+
+	_, err = net.DialTimeout("tcp", s.addr, 100*time.Millisecond)
+	assert.Error(t, err, "the listener is closed")
+}
+
+func TestServer_ShutdownTimeout(t *testing.T) {
+	s := newLineServer(t)
+
+	conn := tcptest.Dial(t, s.addr)
+	_, err := conn.Write([]byte("slow\n"))
+	require.NoError(t, err)
+	<-s.slow
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		close(s.release) // let the stuck command return
+	}()
+	assert.ErrorIs(t, s.Shutdown(ctx), context.DeadlineExceeded)
+	assert.Zero(t, s.NumConns())
+}
+
+func TestServer_ServeAfterShutdown(t *testing.T) {
+	s := New(func(*Conn) {}, metrics.NewRegistry(), Metrics{Connections: testConnections})
+	require.NoError(t, s.Shutdown(context.Background()))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	assert.ErrorIs(t, s.Serve(l), ErrServerClosed)
+
+	_, err = net.DialTimeout("tcp", l.Addr().String(), 100*time.Millisecond)
+	assert.Error(t, err, "the listener is closed")
+}
@@ -0,0 +1,85 @@
+// Package tcptest provides the fixtures shared by the tests of the TCP
+// front-ends: a single-node environment, a loopback listener and helpers.
+package tcptest
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"distributed-cache/internal/logs"
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/peers"
+	"distributed-cache/internal/replication"
+	"distributed-cache/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+// NodeID is the ID of the node built by NewNode.
+const NodeID = "node-A"
+
+// Node is what a front-end server needs to run on a single node. Its
+// replicator has no peers until the test adds some.
+type Node struct {
+	Store      *store.Store
+	Metrics    *metrics.Registry
+	Peers      *peers.PeerManager
+	Replicator *replication.Replicator
+	Logger     *logs.Logger
+}
+
+// NewNode builds an empty node.
+func NewNode() *Node {
+	cfg := peers.DefaultPeerConfig()
+	reg := metrics.NewRegistry()
+	logger := logs.NewLogger(50, logs.DEBUG)
+	pm := peers.NewPeerManager(cfg, reg)
+
+	return &Node{
+		Store:      store.NewStore(reg),
+		Metrics:    reg,
+		Peers:      pm,
+		Replicator: replication.NewReplicator(NodeID, pm, cfg, logger, reg),
+		Logger:     logger,
+	}
+}
+
+// Server is a front-end server.
+type Server interface {
+	Serve(l net.Listener) error
+	Shutdown(ctx context.Context) error
+}
+
+// Serve runs srv on a loopback port until the test ends. It returns the
+// address and a channel receiving the result of Serve.
+func Serve(t *testing.T, srv Server) (addr string, served <-chan error) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ch := make(chan error, 1)
+	go func() { ch <- srv.Serve(l) }()
+	t.Cleanup(func() { _ = srv.Shutdown(context.Background()) })
+	return l.Addr().String(), ch
+}
+
+// Dial connects to addr; the connection is closed when the test ends.
+func Dial(t *testing.T, addr string) net.Conn {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// Words converts the arguments of a parsed command to strings.
+func Words(args [][]byte) []string {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		out[i] = string(arg)
+	}
+	return out
+}
@@ -2,43 +2,82 @@ package ttl
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"distributed-cache/internal/logs"
 	"distributed-cache/internal/metrics"
 )
 
+// DefaultTombstoneGrace is how long delete tombstones are kept by default.
+// It should outlast replication retries and short peer outages.
+const DefaultTombstoneGrace = 10 * time.Minute
+
 // Store defines the minimal contract required by the TTL cleaner.
 type Store interface {
 	RemoveExpired() int
+	PurgeTombstones(grace time.Duration) int
 }
 
-// Cleaner periodically removes expired keys from the store.
+// Cleaner periodically removes expired keys and old tombstones from the store.
 type Cleaner struct {
-	store    Store
-	interval time.Duration
-	logger   *logs.Logger
-	metrics  *metrics.Registry
+	store   Store
+	logger  *logs.Logger
+	metrics *metrics.Registry
+
+	mu             sync.RWMutex
+	interval       time.Duration
+	tombstoneGrace time.Duration
+
+	// reconfigured wakes Start so a new interval takes effect immediately.
+	reconfigured chan struct{}
 }
 
 // NewCleaner creates a new TTL cleaner.
+//
+// tombstoneGrace: how long delete tombstones are kept before being purged
 func NewCleaner(
 	store Store,
 	interval time.Duration,
+	tombstoneGrace time.Duration,
 	logger *logs.Logger,
 	metricsRegistry *metrics.Registry,
 ) *Cleaner {
 	return &Cleaner{
-		store:    store,
-		interval: interval,
-		logger:   logger,
-		metrics:  metricsRegistry,
+		store:          store,
+		interval:       interval,
+		tombstoneGrace: tombstoneGrace,
+		logger:         logger,
+		metrics:        metricsRegistry,
+		reconfigured:   make(chan struct{}, 1),
+	}
+}
+
+// UpdateSettings swaps the cleanup interval and tombstone grace period.
+// A running loop picks up the new interval without waiting for the old one.
+func (c *Cleaner) UpdateSettings(interval, tombstoneGrace time.Duration) {
+	c.mu.Lock()
+	c.interval = interval
+	c.tombstoneGrace = tombstoneGrace
+	c.mu.Unlock()
+
+	select {
+	case c.reconfigured <- struct{}{}:
+	default:
 	}
 }
 
+func (c *Cleaner) settings() (time.Duration, time.Duration) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.interval, c.tombstoneGrace
+}
+
 // Start runs the cleanup loop until the context is cancelled.
 func (c *Cleaner) Start(ctx context.Context) {
-	ticker := time.NewTicker(c.interval)
+	interval, _ := c.settings()
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
@@ -46,6 +85,9 @@ func (c *Cleaner) Start(ctx context.Context) {
 		case <-ticker.C:
 			c.metrics.Inc(metrics.TTLCleanupRunsTotal)
 			c.runOnce()
+		case <-c.reconfigured:
+			interval, _ := c.settings()
+			ticker.Reset(interval)
 		case <-ctx.Done():
 			c.logger.Debug("ttl cleaner stopped")
 			return
@@ -60,4 +102,11 @@ func (c *Cleaner) runOnce() {
 		c.metrics.Add(metrics.TTLKeysRemovedTotal, int64(removed))
 		c.logger.Info("ttl cleaner removed expired keys")
 	}
+
+	_, grace := c.settings()
+	purged := c.store.PurgeTombstones(grace)
+	if purged > 0 {
+		c.metrics.Add(metrics.TTLTombstonesPurged, int64(purged))
+		c.logger.Debug("ttl cleaner purged tombstones")
+	}
 }
@@ -16,12 +16,19 @@ import (
 
 type mockStore struct {
 	removed int32
+	purged  int
+	grace   time.Duration
 }
 
 func (m *mockStore) RemoveExpired() int {
 	return int(atomic.AddInt32(&m.removed, 1))
 }
 
+func (m *mockStore) PurgeTombstones(grace time.Duration) int {
+	m.grace = grace
+	return m.purged
+}
+
 /* ---------------- Tests ---------------- */
 
 func TestCleaner_RunOnce_RemovesExpiredAndUpdatesMetrics(t *testing.T) {
@@ -29,7 +36,7 @@ func TestCleaner_RunOnce_RemovesExpiredAndUpdatesMetrics(t *testing.T) {
 	reg := metrics.NewRegistry()
 	logger := logs.NewLogger(10, logs.DEBUG)
 
-	cleaner := NewCleaner(store, time.Second, logger, reg)
+	cleaner := NewCleaner(store, time.Second, time.Minute, logger, reg)
 
 	cleaner.runOnce()
 
@@ -39,12 +46,27 @@ func TestCleaner_RunOnce_RemovesExpiredAndUpdatesMetrics(t *testing.T) {
 	assert.Equal(t, int64(1), snap[string(metrics.TTLKeysRemovedTotal)])
 }
 
+func TestCleaner_RunOnce_PurgesTombstonesWithGrace(t *testing.T) {
+	store := &mockStore{purged: 3}
+	reg := metrics.NewRegistry()
+	logger := logs.NewLogger(10, logs.DEBUG)
+
+	cleaner := NewCleaner(store, time.Second, 42*time.Second, logger, reg)
+
+	cleaner.runOnce()
+
+	assert.Equal(t, 42*time.Second, store.grace)
+
+	snap := reg.Snapshot()
+	assert.Equal(t, int64(3), snap[string(metrics.TTLTombstonesPurged)])
+}
+
 func TestCleaner_Start_RunsPeriodicallyAndTracksRuns(t *testing.T) {
 	store := &mockStore{}
 	reg := metrics.NewRegistry()
 	logger := logs.NewLogger(10, logs.DEBUG)
 
-	cleaner := NewCleaner(store, 5*time.Millisecond, logger, reg)
+	cleaner := NewCleaner(store, 5*time.Millisecond, time.Minute, logger, reg)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -62,7 +84,7 @@ func TestCleaner_Start_StopsOnContextCancel(t *testing.T) {
 	reg := metrics.NewRegistry()
 	logger := logs.NewLogger(10, logs.DEBUG)
 
-	cleaner := NewCleaner(store, 5*time.Millisecond, logger, reg)
+	cleaner := NewCleaner(store, 5*time.Millisecond, time.Minute, logger, reg)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	go cleaner.Start(ctx)
@@ -78,3 +100,25 @@ func TestCleaner_Start_StopsOnContextCancel(t *testing.T) {
 	// Allow at most one extra tick due to race with ticker
 	assert.LessOrEqual(t, runsAfter, runsAtCancel+1)
 }
+
+func TestCleaner_UpdateSettings(t *testing.T) {
+	store := &mockStore{}
+	reg := metrics.NewRegistry()
+	logger := logs.NewLogger(10, logs.DEBUG)
+
+	cleaner := NewCleaner(store, time.Hour, time.Minute, logger, reg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go cleaner.Start(ctx)
+
+	cleaner.UpdateSettings(5*time.Millisecond, time.Second)
+
+	assert.Eventually(t, func() bool {
+		return reg.Snapshot()[string(metrics.TTLCleanupRunsTotal)] >= 2
+	}, 100*time.Millisecond, 5*time.Millisecond)
+
+	_, grace := cleaner.settings()
+	assert.Equal(t, time.Second, grace)
+}
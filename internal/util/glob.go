@@ -0,0 +1,91 @@
+package util
+
+// Match reports whether s matches a Redis-style glob pattern.
+//
+// Syntax:
+// - '*' matches any sequence of bytes, including none
+// - '?' matches exactly one byte
+// - '[abc]', '[a-z]' and '[^a]' match one byte in, or not in, a set
+// - '\' matches the next byte literally
+//
+// As in Redis, a class without its closing ']' runs to the end of the pattern.
+func Match(pattern, s string) bool {
+	p, c := 0, 0
+	// Position to resume from when the last '*' must swallow one more byte.
+	starP, starC := -1, 0
+
+	for c < len(s) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				starP, starC = p, c
+				p++
+				continue
+			case '?':
+				p++
+				c++
+				continue
+			case '[':
+				if next, ok := matchClass(pattern, p, s[c]); ok {
+					p, c = next, c+1
+					continue
+				}
+			case '\\':
+				if p+1 < len(pattern) && pattern[p+1] == s[c] {
+					p += 2
+					c++
+					continue
+				}
+			default:
+				if pattern[p] == s[c] {
+					p++
+					c++
+					continue
+				}
+			}
+		}
+
+		if starP < 0 {
+			return false
+		}
+		starC++
+		p, c = starP+1, starC
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchClass matches b against the class opening at pattern[open].
+// It returns the index after the class and whether b is in it.
+func matchClass(pattern string, open int, b byte) (int, bool) {
+	i := open + 1
+	negate := i < len(pattern) && pattern[i] == '^'
+	if negate {
+		i++
+	}
+
+	matched := false
+	for i < len(pattern) {
+		switch {
+		case pattern[i] == ']':
+			return i + 1, matched != negate
+		case pattern[i] == '\\' && i+1 < len(pattern):
+			matched = matched || pattern[i+1] == b
+			i += 2
+		case i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']':
+			lo, hi := pattern[i], pattern[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			matched = matched || lo <= b && b <= hi
+			i += 3
+		default:
+			matched = matched || pattern[i] == b
+			i++
+		}
+	}
+	return i, matched != negate
+}
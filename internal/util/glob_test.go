@@ -0,0 +1,50 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{"news", "news", true},
+		{"news", "newsx", false},
+		{"", "", true},
+		{"", "a", false},
+
+		{"*", "", true},
+		{"*", "anything", true},
+		{"news.*", "news.sport", true},
+		{"news.*", "news.", true},
+		{"news.*", "news", false},
+		{"*.sport", "news.sport", true},
+		{"n*s*t", "news.sport", true},
+		{"n*s*x", "news.sport", false},
+		{"**", "a", true},
+
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"h[c-a]llo", "hbllo", true},
+		{"h[a-c]llo", "hdllo", false},
+		{`h[\]]llo`, "h]llo", true},
+		{"h[ab", "ha", true},
+
+		{`news\*`, "news*", true},
+		{`news\*`, "newsx", false},
+		{`\?`, "?", true},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, Match(tc.pattern, tc.s), "Match(%q, %q)", tc.pattern, tc.s)
+	}
+}
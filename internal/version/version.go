@@ -0,0 +1,8 @@
+package version
+
+// Version is the build version reported by the node.
+//
+// Override at build time with:
+//
+//	go build -ldflags "-X distributed-cache/internal/version.Version=v1.2.3"
+var Version = "dev"
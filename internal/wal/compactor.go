@@ -0,0 +1,106 @@
+package wal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"distributed-cache/internal/logs"
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/snapshot"
+)
+
+// Snapshotter writes a snapshot of the whole store.
+type Snapshotter interface {
+	Save() (snapshot.Info, error)
+}
+
+// Compactor keeps the log short by rewriting it from a snapshot.
+//
+// Compaction order matters:
+// 1. rotate, so new writes go to a fresh segment
+// 2. snapshot the store, which covers every write in the sealed segments
+// 3. delete the sealed segments
+//
+// A crash between steps leaves extra segments behind, never missing writes.
+type Compactor struct {
+	log       *Log
+	snapshots Snapshotter
+	threshold int64
+	logger    *logs.Logger
+	metrics   *metrics.Registry
+
+	// checkInterval is how often the log size is compared to threshold.
+	checkInterval time.Duration
+}
+
+// NewCompactor creates a compactor that runs once the log exceeds
+// threshold bytes.
+func NewCompactor(
+	log *Log,
+	snapshots Snapshotter,
+	threshold int64,
+	logger *logs.Logger,
+	metricsRegistry *metrics.Registry,
+) *Compactor {
+	return &Compactor{
+		log:           log,
+		snapshots:     snapshots,
+		threshold:     threshold,
+		logger:        logger,
+		metrics:       metricsRegistry,
+		checkInterval: 10 * time.Second,
+	}
+}
+
+// Compact rewrites the log from a fresh snapshot.
+func (c *Compactor) Compact() error {
+	seq, err := c.log.Rotate()
+	if err != nil {
+		return fmt.Errorf("wal compaction: rotate: %w", err)
+	}
+
+	if _, err := c.snapshots.Save(); err != nil {
+		return fmt.Errorf("wal compaction: snapshot: %w", err)
+	}
+
+	if err := c.log.RemoveBefore(seq); err != nil {
+		return fmt.Errorf("wal compaction: remove segments: %w", err)
+	}
+
+	c.metrics.Inc(metrics.WALCompactionsTotal)
+	c.logger.Info("wal compacted")
+	return nil
+}
+
+// Start compacts whenever the log outgrows the threshold, until the
+// context is cancelled.
+func (c *Compactor) Start(ctx context.Context) {
+	ticker := time.NewTicker(c.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.runOnce()
+		case <-ctx.Done():
+			c.logger.Debug("wal compactor stopped")
+			return
+		}
+	}
+}
+
+func (c *Compactor) runOnce() {
+	size, err := c.log.Size()
+	if err != nil {
+		c.logger.Error("wal size: " + err.Error())
+		return
+	}
+	if size < c.threshold {
+		return
+	}
+
+	if err := c.Compact(); err != nil {
+		c.logger.Error(err.Error())
+	}
+}
@@ -0,0 +1,80 @@
+package wal
+
+import (
+	"path/filepath"
+	"testing"
+
+	"distributed-cache/internal/logs"
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/snapshot"
+	"distributed-cache/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompactor_RewritesLogFromSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	walDir := filepath.Join(dir, "wal")
+	snapDir := filepath.Join(dir, "snapshots")
+	logger := logs.NewLogger(50, logs.DEBUG)
+
+	l, reg := openTestLog(t, walDir, SyncNever)
+	replayInto(t, l)
+
+	st := store.NewStore(metrics.NewRegistry(), store.WithWriteHook(l.Hook))
+	snaps := snapshot.NewManager(snapDir, "node-1", 2, 0, st, logger, reg)
+	compactor := NewCompactor(l, snaps, 1, logger, reg)
+
+	for i := range 10 {
+		st.Set("counter", store.Entry{Value: []byte{byte('0' + i)}, Timestamp: int64(i + 1)})
+	}
+	st.Set("other", store.Entry{Value: []byte("x"), Timestamp: 1})
+
+	before, err := l.Size()
+	require.NoError(t, err)
+
+	require.NoError(t, compactor.Compact())
+	assert.Equal(t, int64(1), reg.Snapshot()[string(metrics.WALCompactionsTotal)])
+
+	after, err := l.Size()
+	require.NoError(t, err)
+	assert.Less(t, after, before)
+
+	// Writes after compaction land in the new segment.
+	st.Set("late", store.Entry{Value: []byte("y"), Timestamp: 1})
+	require.NoError(t, l.Close())
+
+	// Warm restart: snapshot first, then the remaining log.
+	restored := store.NewStore(metrics.NewRegistry())
+	_, err = snapshot.NewManager(snapDir, "node-1", 2, 0, restored, logger, metrics.NewRegistry()).Restore()
+	require.NoError(t, err)
+
+	reopened, _ := openTestLog(t, walDir, SyncNever)
+	_, err = reopened.Replay(func(_, key string, entry store.Entry) { restored.Restore(key, entry) })
+	require.NoError(t, err)
+	require.NoError(t, reopened.Close())
+
+	assert.Equal(t, st.List(), restored.List())
+}
+
+func TestCompactor_RunsOnlyAboveThreshold(t *testing.T) {
+	dir := t.TempDir()
+	logger := logs.NewLogger(50, logs.DEBUG)
+
+	l, reg := openTestLog(t, filepath.Join(dir, "wal"), SyncNever)
+	replayInto(t, l)
+	defer l.Close()
+
+	st := store.NewStore(metrics.NewRegistry(), store.WithWriteHook(l.Hook))
+	snaps := snapshot.NewManager(filepath.Join(dir, "snapshots"), "node-1", 2, 0, st, logger, reg)
+	compactor := NewCompactor(l, snaps, 1<<20, logger, reg)
+
+	st.Set("a", store.Entry{Value: []byte("1"), Timestamp: 1})
+	compactor.runOnce()
+	assert.Zero(t, reg.Snapshot()[string(metrics.WALCompactionsTotal)])
+
+	compactor.threshold = 1
+	compactor.runOnce()
+	assert.Equal(t, int64(1), reg.Snapshot()[string(metrics.WALCompactionsTotal)])
+}
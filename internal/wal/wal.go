@@ -0,0 +1,570 @@
+package wal
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"distributed-cache/internal/logs"
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/store"
+)
+
+// Log is an append-only write-ahead log of store writes.
+//
+// Design principles:
+// - The log is a sequence of segment files; only the newest one is written
+// - Every record is framed as length + CRC-32C + JSON payload
+// - Replay applies records in order; LWW makes re-applying a write harmless
+// - A torn or corrupt record ends its segment, which is truncated there
+// - A failed append seals its segment, so a torn record never hides later ones
+// - Compaction starts a new segment, snapshots the store and drops older segments
+//
+// Record layout:
+// - payload length (uint32, big endian)
+// - CRC-32C of the payload (uint32, big endian)
+// - payload: JSON-encoded namespace, key and entry
+type Log struct {
+	dir     string
+	policy  SyncPolicy
+	logger  *logs.Logger
+	metrics *metrics.Registry
+
+	// syncMu serializes fsyncs and is taken before mu. Appends only need
+	// mu, so they go on while a flush is running.
+	syncMu sync.Mutex
+
+	mu       sync.Mutex
+	file     segmentFile
+	seq      uint64
+	size     int64  // bytes in the current segment
+	failed   error  // set while a failed append is being sealed
+	appended uint64 // records appended so far
+	synced   uint64 // records known to be on disk
+	replayed bool
+}
+
+// segmentFile is the segment a Log appends to; *os.File in production.
+type segmentFile interface {
+	io.Writer
+	Truncate(size int64) error
+	Sync() error
+	Close() error
+}
+
+// SyncPolicy controls when appended records are fsynced.
+type SyncPolicy string
+
+const (
+	// SyncAlways fsyncs every record before Append returns. The fsync runs
+	// without the log lock, and appends waiting on it share the next one
+	// (group commit), but each write still waits for a disk flush while
+	// its shard lock is held, so write latency is bound to fsync latency.
+	SyncAlways SyncPolicy = "always"
+
+	// SyncEverySecond fsyncs once per second; a crash loses at most ~1s of writes.
+	SyncEverySecond SyncPolicy = "every-second"
+
+	// SyncNever leaves flushing to the operating system.
+	SyncNever SyncPolicy = "never"
+)
+
+// ParseSyncPolicy validates a sync policy name.
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	switch p := SyncPolicy(strings.ToLower(s)); p {
+	case SyncAlways, SyncEverySecond, SyncNever:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown wal sync policy %q (want always, every-second or never)", s)
+}
+
+const (
+	segmentPrefix = "wal-"
+	segmentSuffix = ".log"
+	headerSize    = 8
+
+	// maxRecordSize guards replay against reading a garbage length.
+	maxRecordSize = 64 << 20
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// record is the on-disk form of one write.
+type record struct {
+	// Namespace is empty in records written before namespaces, which
+	// belong to the default namespace.
+	Namespace   string `json:"namespace,omitempty"`
+	Key         string `json:"key"`
+	Data        []byte `json:"data,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Flags       uint32 `json:"flags,omitempty"`
+
+	// LegacyValue holds the value of records written before values were bytes.
+	LegacyValue string `json:"value,omitempty"`
+
+	Timestamp int64          `json:"timestamp"`
+	Version   uint64         `json:"version,omitempty"`
+	Counter   *store.Counter `json:"counter,omitempty"`
+	ExpiresAt time.Time      `json:"expires_at,omitzero"`
+	Deleted   bool           `json:"deleted,omitempty"`
+	DeletedAt time.Time      `json:"deleted_at,omitzero"`
+}
+
+// Open prepares a log in dir, creating the directory if needed.
+// Call Replay before the first Append.
+func Open(dir string, policy SyncPolicy, logger *logs.Logger, metricsRegistry *metrics.Registry) (*Log, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Log{
+		dir:     dir,
+		policy:  policy,
+		logger:  logger,
+		metrics: metricsRegistry,
+	}, nil
+}
+
+// ReplayInfo summarizes a replay.
+type ReplayInfo struct {
+	Segments  int
+	Records   int
+	TornBytes int64
+}
+
+// Replay applies every logged write in order, then opens a fresh segment
+// for appends.
+//
+// A segment ending in a torn or corrupt record (typically a crash during a
+// write) is truncated after its last valid record.
+//
+// An empty namespace passed to apply names the default namespace.
+func (l *Log) Replay(apply func(namespace, key string, entry store.Entry)) (ReplayInfo, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.replayed {
+		return ReplayInfo{}, errors.New("wal: already replayed")
+	}
+
+	segments, err := l.segments()
+	if err != nil {
+		return ReplayInfo{}, err
+	}
+
+	info := ReplayInfo{Segments: len(segments)}
+	var size int64
+	for _, seq := range segments {
+		records, valid, torn, err := l.replaySegment(seq, apply)
+		if err != nil {
+			return info, err
+		}
+		info.Records += records
+		info.TornBytes += torn
+		size += valid
+	}
+
+	l.metrics.Add(metrics.WALReplayedRecords, int64(info.Records))
+	l.metrics.Add(metrics.WALBytes, size)
+
+	next := uint64(1)
+	if len(segments) > 0 {
+		next = segments[len(segments)-1] + 1
+	}
+	if err := l.openSegmentLocked(next); err != nil {
+		return info, err
+	}
+
+	l.replayed = true
+	return info, nil
+}
+
+// replaySegment applies one segment and returns the number of records,
+// the size of the valid prefix and the number of truncated bytes.
+func (l *Log) replaySegment(seq uint64, apply func(string, string, store.Entry)) (int, int64, int64, error) {
+	path := l.segmentPath(seq)
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	r := bufio.NewReader(f)
+	var offset int64
+	records := 0
+
+	for {
+		rec, n, err := readRecord(r)
+		if err == io.EOF {
+			return records, offset, 0, nil
+		}
+		if err != nil {
+			torn := stat.Size() - offset
+			l.metrics.Inc(metrics.WALTornRecordsTotal)
+			l.logger.Warn(fmt.Sprintf("wal: truncating %s at offset %d (%d bytes): %v", path, offset, torn, err))
+
+			if err := f.Truncate(offset); err != nil {
+				return records, 0, 0, err
+			}
+			return records, offset, torn, f.Sync()
+		}
+
+		if rec.Data == nil && rec.LegacyValue != "" {
+			rec.Data = []byte(rec.LegacyValue)
+		}
+		apply(rec.Namespace, rec.Key, store.Entry{
+			Value:       rec.Data,
+			ContentType: rec.ContentType,
+			Flags:       rec.Flags,
+			Timestamp:   rec.Timestamp,
+			Version:     rec.Version,
+			ExpiresAt:   rec.ExpiresAt,
+			Deleted:     rec.Deleted,
+			DeletedAt:   rec.DeletedAt,
+			Counter:     rec.Counter,
+		})
+		offset += n
+		records++
+	}
+}
+
+// readRecord reads one framed record. It returns io.EOF only at a clean
+// record boundary; any partial or invalid record is an error.
+func readRecord(r io.Reader) (record, int64, error) {
+	var header [headerSize]byte
+	n, err := io.ReadFull(r, header[:])
+	if err == io.EOF {
+		return record{}, 0, io.EOF
+	}
+	if err != nil {
+		return record{}, 0, fmt.Errorf("torn header (%d bytes)", n)
+	}
+
+	length := binary.BigEndian.Uint32(header[:4])
+	checksum := binary.BigEndian.Uint32(header[4:])
+	if length == 0 || length > maxRecordSize {
+		return record{}, 0, fmt.Errorf("invalid record length %d", length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return record{}, 0, errors.New("torn payload")
+	}
+	if crc32.Checksum(payload, crcTable) != checksum {
+		return record{}, 0, errors.New("checksum mismatch")
+	}
+
+	var rec record
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return record{}, 0, fmt.Errorf("invalid payload: %w", err)
+	}
+	return rec, int64(headerSize + length), nil
+}
+
+// Append logs one accepted write. Under SyncAlways the record is on disk
+// when Append returns.
+//
+// A failed write may leave part of the record in the segment, and replay
+// stops at a torn record. The segment is therefore truncated back and
+// sealed, and appends continue in a new one; appends fail until then.
+func (l *Log) Append(namespace, key string, entry store.Entry) error {
+	payload, err := json.Marshal(record{
+		Namespace:   namespace,
+		Key:         key,
+		Data:        entry.Value,
+		ContentType: entry.ContentType,
+		Flags:       entry.Flags,
+		Timestamp:   entry.Timestamp,
+		Version:     entry.Version,
+		ExpiresAt:   entry.ExpiresAt,
+		Deleted:     entry.Deleted,
+		DeletedAt:   entry.DeletedAt,
+		Counter:     entry.Counter,
+	})
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, headerSize, headerSize+len(payload))
+	binary.BigEndian.PutUint32(buf[:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:], crc32.Checksum(payload, crcTable))
+	buf = append(buf, payload...)
+
+	l.mu.Lock()
+	if l.failed != nil {
+		err := l.failed
+		l.mu.Unlock()
+		return err
+	}
+	if l.file == nil {
+		l.mu.Unlock()
+		return errors.New("wal: not open for appends")
+	}
+	if _, err := l.file.Write(buf); err != nil {
+		l.failed = fmt.Errorf("wal: segment %d failed: %w", l.seq, err)
+		seq, size := l.seq, l.size
+		l.mu.Unlock()
+		l.metrics.Inc(metrics.WALFailuresTotal)
+		l.seal(seq, size)
+		return err
+	}
+	l.size += int64(len(buf))
+	l.appended++
+	n := l.appended
+	l.mu.Unlock()
+
+	l.metrics.Inc(metrics.WALAppendsTotal)
+	l.metrics.Add(metrics.WALBytes, int64(len(buf)))
+
+	if l.policy == SyncAlways {
+		return l.syncThrough(n)
+	}
+	return nil
+}
+
+// seal truncates segment seq back to size, the end of its last complete
+// record, and continues in a new segment. If a new segment cannot be
+// opened, the log stays failed and every later Append returns the error.
+func (l *Log) seal(seq uint64, size int64) {
+	l.syncMu.Lock()
+	defer l.syncMu.Unlock()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil || l.seq != seq {
+		return // closed or rotated meanwhile
+	}
+
+	// Replay would truncate the torn record too; this only keeps the
+	// sealed segment clean.
+	if err := l.file.Truncate(size); err != nil {
+		l.logger.Warn(fmt.Sprintf("wal: truncating segment %d after a failed append: %v", seq, err))
+	}
+	if err := l.closeLocked(); err != nil {
+		l.logger.Warn(fmt.Sprintf("wal: closing segment %d after a failed append: %v", seq, err))
+	}
+	if err := l.openSegmentLocked(seq + 1); err != nil {
+		l.failed = fmt.Errorf("wal: segment %d failed and no new segment could be opened: %w", seq, err)
+		l.logger.Error(l.failed.Error())
+		return
+	}
+	l.logger.Warn(fmt.Sprintf("wal: sealed segment %d after a failed append", seq))
+}
+
+// Hook adapts Append to store.WithWriteHook.
+// Append failures are logged and counted; the write itself stays applied.
+func (l *Log) Hook(namespace, key string, entry store.Entry) {
+	if err := l.Append(namespace, key, entry); err != nil {
+		l.logger.Error("wal append failed: " + err.Error())
+	}
+}
+
+// Sync flushes appended records to disk.
+func (l *Log) Sync() error {
+	return l.syncThrough(math.MaxUint64)
+}
+
+// syncThrough returns once the first n appended records are on disk.
+// The fsync runs without mu and covers every record appended before it
+// starts, so concurrent appends queued on syncMu share one flush: the
+// writers behind it find their records already synced.
+func (l *Log) syncThrough(n uint64) error {
+	l.syncMu.Lock()
+	defer l.syncMu.Unlock()
+
+	l.mu.Lock()
+	file, target := l.file, l.appended
+	done := l.synced >= min(n, target)
+	l.mu.Unlock()
+
+	if done {
+		return nil
+	}
+	if file == nil {
+		return errors.New("wal: closed before sync")
+	}
+	if err := file.Sync(); err != nil {
+		l.metrics.Inc(metrics.WALFailuresTotal)
+		return err
+	}
+
+	l.mu.Lock()
+	l.synced = max(l.synced, target)
+	l.mu.Unlock()
+	return nil
+}
+
+// syncLocked fsyncs the current segment; the caller holds syncMu and mu.
+func (l *Log) syncLocked() error {
+	if l.file == nil || l.synced == l.appended {
+		return nil
+	}
+	if err := l.file.Sync(); err != nil {
+		l.metrics.Inc(metrics.WALFailuresTotal)
+		return err
+	}
+	l.synced = l.appended
+	return nil
+}
+
+// Rotate seals the current segment and starts a new one.
+// It returns the sequence number of the new segment.
+func (l *Log) Rotate() (uint64, error) {
+	l.syncMu.Lock()
+	defer l.syncMu.Unlock()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return 0, errors.New("wal: not open for appends")
+	}
+	if err := l.closeLocked(); err != nil {
+		return 0, err
+	}
+
+	next := l.seq + 1
+	return next, l.openSegmentLocked(next)
+}
+
+// RemoveBefore deletes the segments older than seq.
+func (l *Log) RemoveBefore(seq uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	segments, err := l.segments()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, s := range segments {
+		if s >= seq {
+			break
+		}
+
+		path := l.segmentPath(s)
+		if stat, err := os.Stat(path); err == nil {
+			l.metrics.Add(metrics.WALBytes, -stat.Size())
+		}
+		errs = append(errs, os.Remove(path))
+	}
+	return errors.Join(errs...)
+}
+
+// Size returns the total size of all segments in bytes.
+func (l *Log) Size() (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	segments, err := l.segments()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, s := range segments {
+		stat, err := os.Stat(l.segmentPath(s))
+		if err != nil {
+			return 0, err
+		}
+		total += stat.Size()
+	}
+	return total, nil
+}
+
+// Close syncs and closes the current segment.
+func (l *Log) Close() error {
+	l.syncMu.Lock()
+	defer l.syncMu.Unlock()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.closeLocked()
+}
+
+func (l *Log) closeLocked() error {
+	if l.file == nil {
+		return nil
+	}
+
+	syncErr := l.syncLocked()
+	closeErr := l.file.Close()
+	l.file = nil
+	return errors.Join(syncErr, closeErr)
+}
+
+// Start fsyncs once per second under SyncEverySecond until the context
+// is cancelled. Other policies need no background work.
+func (l *Log) Start(ctx context.Context) {
+	if l.policy != SyncEverySecond {
+		return
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := l.Sync(); err != nil {
+				l.logger.Error("wal sync failed: " + err.Error())
+			}
+		case <-ctx.Done():
+			l.logger.Debug("wal sync worker stopped")
+			return
+		}
+	}
+}
+
+func (l *Log) openSegmentLocked(seq uint64) error {
+	f, err := os.OpenFile(l.segmentPath(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	l.file = f
+	l.seq = seq
+	l.size = 0
+	l.failed = nil
+	l.synced = l.appended
+	return nil
+}
+
+func (l *Log) segmentPath(seq uint64) string {
+	return filepath.Join(l.dir, fmt.Sprintf("%s%020d%s", segmentPrefix, seq, segmentSuffix))
+}
+
+// segments returns the sequence numbers of all segments, oldest first.
+func (l *Log) segments() ([]uint64, error) {
+	paths, err := filepath.Glob(filepath.Join(l.dir, segmentPrefix+"*"+segmentSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	seqs := make([]uint64, 0, len(paths))
+	for _, path := range paths {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), segmentPrefix), segmentSuffix)
+		if seq, err := strconv.ParseUint(name, 10, 64); err == nil {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
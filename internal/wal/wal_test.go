@@ -0,0 +1,363 @@
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"distributed-cache/internal/logs"
+	"distributed-cache/internal/metrics"
+	"distributed-cache/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestLog(t *testing.T, dir string, policy SyncPolicy) (*Log, *metrics.Registry) {
+	t.Helper()
+
+	reg := metrics.NewRegistry()
+	l, err := Open(dir, policy, logs.NewLogger(50, logs.DEBUG), reg)
+	require.NoError(t, err)
+	return l, reg
+}
+
+// replayInto replays a log into a fresh store.
+func replayInto(t *testing.T, l *Log) (*store.Store, ReplayInfo) {
+	t.Helper()
+
+	st := store.NewStore(metrics.NewRegistry())
+	info, err := l.Replay(func(namespace, key string, entry store.Entry) {
+		ns, err := st.EnsureNamespace(namespace, store.NamespaceSettings{})
+		require.NoError(t, err)
+		ns.Restore(key, entry)
+	})
+	require.NoError(t, err)
+	return st, info
+}
+
+func TestParseSyncPolicy(t *testing.T) {
+	for _, name := range []string{"always", "every-second", "NEVER"} {
+		_, err := ParseSyncPolicy(name)
+		assert.NoError(t, err, name)
+	}
+
+	_, err := ParseSyncPolicy("sometimes")
+	assert.ErrorContains(t, err, `unknown wal sync policy "sometimes"`)
+}
+
+func TestLog_AppendAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	l, reg := openTestLog(t, dir, SyncAlways)
+	_, info := replayInto(t, l)
+	assert.Equal(t, ReplayInfo{}, info, "empty directory")
+
+	require.NoError(t, l.Append("", "a", store.Entry{Value: []byte("1"), Timestamp: 1}))
+	require.NoError(t, l.Append("", "a", store.Entry{Value: []byte("2"), Timestamp: 2}))
+	require.NoError(t, l.Append("", "b", store.Entry{Value: []byte("3"), Timestamp: 3, ExpiresAt: expires}))
+	require.NoError(t, l.Append("", "c", store.Entry{Value: []byte("4"), Timestamp: 4}))
+	require.NoError(t, l.Append("", "c", store.Tombstone(5)))
+	require.NoError(t, l.Close())
+
+	assert.Equal(t, int64(5), reg.Snapshot()[string(metrics.WALAppendsTotal)])
+
+	reopened, _ := openTestLog(t, dir, SyncAlways)
+	st, info := replayInto(t, reopened)
+	defer reopened.Close()
+
+	assert.Equal(t, 5, info.Records)
+	assert.Zero(t, info.TornBytes)
+
+	val, ok := st.Get("a")
+	require.True(t, ok)
+	assert.Equal(t, "2", string(val.Value))
+	assert.True(t, expires.Equal(st.List()["b"].ExpiresAt))
+
+	_, ok = st.Get("c")
+	assert.False(t, ok, "delete is replayed")
+
+	t.Run("appends go to a new segment", func(t *testing.T) {
+		segments, err := reopened.segments()
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{1, 2}, segments)
+	})
+}
+
+func TestLog_ReplayBinaryAndLegacyRecords(t *testing.T) {
+	dir := t.TempDir()
+	binaryValue := []byte{0x89, 'P', 'N', 'G', 0x00, 0xff}
+
+	l, _ := openTestLog(t, dir, SyncAlways)
+	replayInto(t, l)
+	require.NoError(t, l.Append("", "img", store.Entry{Value: binaryValue, ContentType: "image/png", Flags: 42, Timestamp: 1, Version: 3}))
+	require.NoError(t, l.Close())
+
+	// A record written before values were bytes.
+	payload := []byte(`{"key":"old","value":"legacy","timestamp":2}`)
+	buf := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	buf = binary.BigEndian.AppendUint32(buf, crc32.Checksum(payload, crcTable))
+	f, err := os.OpenFile(l.segmentPath(1), os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.Write(append(buf, payload...))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	reopened, _ := openTestLog(t, dir, SyncAlways)
+	st, info := replayInto(t, reopened)
+	require.NoError(t, reopened.Close())
+	assert.Equal(t, 2, info.Records)
+
+	img, ok := st.Get("img")
+	require.True(t, ok)
+	assert.Equal(t, binaryValue, img.Value)
+	assert.Equal(t, "image/png", img.ContentType)
+	assert.Equal(t, uint32(42), img.Flags)
+	assert.Equal(t, uint64(3), img.Version)
+
+	old, ok := st.Get("old")
+	require.True(t, ok)
+	assert.Equal(t, "legacy", string(old.Value))
+}
+
+func TestLog_ReplayTruncatesTornTail(t *testing.T) {
+	cases := map[string]func(t *testing.T, path string){
+		"partial record": func(t *testing.T, path string) {
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+			require.NoError(t, err)
+			_, err = f.Write([]byte{0, 0, 0, 40, 1, 2, 3, 4, '{', '"'})
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+		},
+		"checksum mismatch": func(t *testing.T, path string) {
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			data[len(data)-3] ^= 0xff
+			require.NoError(t, os.WriteFile(path, data, 0o644))
+		},
+	}
+
+	for name, damage := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			l, _ := openTestLog(t, dir, SyncAlways)
+			replayInto(t, l)
+			require.NoError(t, l.Append("", "a", store.Entry{Value: []byte("1"), Timestamp: 1}))
+			require.NoError(t, l.Append("", "b", store.Entry{Value: []byte("2"), Timestamp: 2}))
+			require.NoError(t, l.Close())
+
+			path := l.segmentPath(1)
+			damage(t, path)
+
+			reopened, reg := openTestLog(t, dir, SyncAlways)
+			st, info := replayInto(t, reopened)
+			require.NoError(t, reopened.Close())
+
+			assert.Positive(t, info.TornBytes)
+			assert.Equal(t, int64(1), reg.Snapshot()[string(metrics.WALTornRecordsTotal)])
+
+			_, ok := st.Get("a")
+			assert.True(t, ok, "records before the damage survive")
+
+			// The segment now ends at a clean record boundary.
+			again, _ := openTestLog(t, dir, SyncAlways)
+			_, info = replayInto(t, again)
+			require.NoError(t, again.Close())
+			assert.Zero(t, info.TornBytes)
+		})
+	}
+}
+
+func TestLog_AppendRequiresReplay(t *testing.T) {
+	l, _ := openTestLog(t, t.TempDir(), SyncNever)
+	assert.Error(t, l.Append("", "a", store.Entry{Value: []byte("1"), Timestamp: 1}))
+
+	replayInto(t, l)
+	_, err := l.Replay(func(string, string, store.Entry) {})
+	assert.Error(t, err, "replay runs once")
+	require.NoError(t, l.Close())
+}
+
+// tornFile writes only the first n bytes of the next write, then fails,
+// as a full disk would.
+type tornFile struct {
+	segmentFile
+	n           int
+	truncateErr error
+}
+
+func (f *tornFile) Write(p []byte) (int, error) {
+	n, _ := f.segmentFile.Write(p[:f.n])
+	return n, errors.New("no space left on device")
+}
+
+func (f *tornFile) Truncate(size int64) error {
+	if f.truncateErr != nil {
+		return f.truncateErr
+	}
+	return f.segmentFile.Truncate(size)
+}
+
+func TestLog_FailedAppendSealsSegment(t *testing.T) {
+	cases := map[string]struct {
+		truncateErr error
+		wantTorn    int64
+	}{
+		"truncated":         {wantTorn: 0},
+		"truncation failed": {truncateErr: errors.New("read-only file system"), wantTorn: 5},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			l, reg := openTestLog(t, dir, SyncAlways)
+			replayInto(t, l)
+
+			require.NoError(t, l.Append("", "a", store.Entry{Value: []byte("1"), Timestamp: 1}))
+			l.file = &tornFile{segmentFile: l.file, n: 5, truncateErr: tc.truncateErr}
+			assert.Error(t, l.Append("", "b", store.Entry{Value: []byte("2"), Timestamp: 2}))
+			require.NoError(t, l.Append("", "c", store.Entry{Value: []byte("3"), Timestamp: 3}), "appends go on in a new segment")
+			require.NoError(t, l.Close())
+			assert.Equal(t, int64(1), reg.Snapshot()[string(metrics.WALFailuresTotal)])
+
+			reopened, _ := openTestLog(t, dir, SyncAlways)
+			st, info := replayInto(t, reopened)
+			require.NoError(t, reopened.Close())
+
+			assert.Equal(t, 2, info.Records)
+			assert.Equal(t, tc.wantTorn, info.TornBytes)
+			_, ok := st.Get("b")
+			assert.False(t, ok)
+			_, ok = st.Get("c")
+			assert.True(t, ok, "the record after the torn one is replayed")
+		})
+	}
+}
+
+func TestLog_FailsWhenNoSegmentCanBeOpened(t *testing.T) {
+	dir := t.TempDir()
+	l, _ := openTestLog(t, dir, SyncNever)
+	replayInto(t, l)
+
+	// The next segment's path is taken by a directory.
+	require.NoError(t, os.Mkdir(l.segmentPath(2), 0o755))
+	l.file = &tornFile{segmentFile: l.file, n: 5}
+	assert.Error(t, l.Append("", "a", store.Entry{Value: []byte("1"), Timestamp: 1}))
+
+	err := l.Append("", "b", store.Entry{Value: []byte("2"), Timestamp: 2})
+	assert.ErrorContains(t, err, "no new segment could be opened")
+	require.NoError(t, l.Close())
+}
+
+func TestLog_HookLogsStoreWrites(t *testing.T) {
+	dir := t.TempDir()
+
+	l, _ := openTestLog(t, dir, SyncNever)
+	replayInto(t, l)
+
+	st := store.NewStore(metrics.NewRegistry(), store.WithWriteHook(l.Hook))
+	st.Set("a", store.Entry{Value: []byte("1"), Timestamp: 1})
+	st.Set("a", store.Entry{Value: []byte("stale"), Timestamp: 0})
+	st.Delete("a")
+	st.Restore("b", store.Entry{Value: []byte("from snapshot"), Timestamp: 1})
+	require.NoError(t, l.Close())
+
+	reopened, _ := openTestLog(t, dir, SyncNever)
+	_, info := replayInto(t, reopened)
+	require.NoError(t, reopened.Close())
+
+	assert.Equal(t, 2, info.Records, "only accepted Set and Delete are logged")
+}
+
+func TestLog_ConcurrentSyncedAppends(t *testing.T) {
+	dir := t.TempDir()
+
+	l, reg := openTestLog(t, dir, SyncAlways)
+	replayInto(t, l)
+
+	// Writers share fsyncs, and rotation may close a segment between an
+	// append and its fsync.
+	var wg sync.WaitGroup
+	for w := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 50 {
+				key := fmt.Sprintf("w%d-%d", w, i)
+				assert.NoError(t, l.Append("", key, store.Entry{Value: []byte("v"), Timestamp: 1}))
+			}
+		}()
+	}
+	for range 5 {
+		_, err := l.Rotate()
+		require.NoError(t, err)
+	}
+	wg.Wait()
+	require.NoError(t, l.Close())
+	assert.Equal(t, int64(0), reg.Snapshot()[string(metrics.WALFailuresTotal)])
+
+	reopened, _ := openTestLog(t, dir, SyncAlways)
+	_, info := replayInto(t, reopened)
+	require.NoError(t, reopened.Close())
+	assert.Equal(t, 400, info.Records)
+}
+
+func TestLog_RotateAndRemoveBefore(t *testing.T) {
+	l, reg := openTestLog(t, t.TempDir(), SyncNever)
+	replayInto(t, l)
+	defer l.Close()
+
+	require.NoError(t, l.Append("", "a", store.Entry{Value: []byte("1"), Timestamp: 1}))
+	seq, err := l.Rotate()
+	require.NoError(t, err)
+	require.NoError(t, l.Append("", "b", store.Entry{Value: []byte("2"), Timestamp: 2}))
+
+	before, err := l.Size()
+	require.NoError(t, err)
+	assert.Equal(t, before, reg.Snapshot()[string(metrics.WALBytes)])
+
+	require.NoError(t, l.RemoveBefore(seq))
+
+	segments, err := l.segments()
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{seq}, segments)
+
+	after, err := l.Size()
+	require.NoError(t, err)
+	assert.Less(t, after, before)
+	assert.Equal(t, after, reg.Snapshot()[string(metrics.WALBytes)])
+}
+
+func TestLog_ReplaysNamespaces(t *testing.T) {
+	dir := t.TempDir()
+
+	l, _ := openTestLog(t, dir, SyncNever)
+	replayInto(t, l)
+
+	st := store.NewStore(metrics.NewRegistry(), store.WithWriteHook(l.Hook))
+	orders, err := st.CreateNamespace("orders", store.NamespaceSettings{})
+	require.NoError(t, err)
+	st.Set("a", store.Entry{Value: []byte("default"), Timestamp: 1})
+	orders.Set("a", store.Entry{Value: []byte("orders"), Timestamp: 1})
+	require.NoError(t, l.Close())
+
+	reopened, _ := openTestLog(t, dir, SyncNever)
+	restored, _ := replayInto(t, reopened)
+	require.NoError(t, reopened.Close())
+
+	got, ok := restored.Get("a")
+	require.True(t, ok)
+	assert.Equal(t, "default", string(got.Value))
+
+	ns, ok := restored.Namespace("orders")
+	require.True(t, ok)
+	got, ok = ns.Get("a")
+	require.True(t, ok)
+	assert.Equal(t, "orders", string(got.Value))
+}